@@ -0,0 +1,74 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"testing"
+)
+
+func verify(t *testing.T, files Files, host string) error {
+	t.Helper()
+	pair, err := tls.LoadX509KeyPair(files.Cert, files.Key)
+	if err != nil {
+		t.Fatalf("LoadX509KeyPair: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	caPEM, err := os.ReadFile(files.CACert)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(caPEM)
+	_, err = leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: roots})
+	return err
+}
+
+func TestEnsure_IssuesCertTrustedByCA(t *testing.T) {
+	dir := t.TempDir()
+	files, err := Ensure(dir, []string{"train.lan", "192.168.1.119"})
+	if err != nil {
+		t.Fatalf("Ensure: %v", err)
+	}
+	for _, host := range []string{"train.lan", "192.168.1.119"} {
+		if err := verify(t, files, host); err != nil {
+			t.Errorf("certificate should be valid for %s: %v", host, err)
+		}
+	}
+	if err := verify(t, files, "other.lan"); err == nil {
+		t.Errorf("certificate should not be valid for other.lan")
+	}
+}
+
+func TestEnsure_ReusesCAAndReissuesOnHostChange(t *testing.T) {
+	dir := t.TempDir()
+	first, err := Ensure(dir, []string{"train.lan"})
+	if err != nil {
+		t.Fatalf("Ensure: %v", err)
+	}
+	caBefore, _ := os.ReadFile(first.CACert)
+	certBefore, _ := os.ReadFile(first.Cert)
+
+	// Same hosts: nothing changes
+	if _, err := Ensure(dir, []string{"train.lan"}); err != nil {
+		t.Fatalf("Ensure: %v", err)
+	}
+	if certAgain, _ := os.ReadFile(first.Cert); string(certAgain) != string(certBefore) {
+		t.Errorf("server certificate should be reused when hosts are unchanged")
+	}
+
+	// New host: server cert reissued under the same CA
+	second, err := Ensure(dir, []string{"train.lan", "gym.lan"})
+	if err != nil {
+		t.Fatalf("Ensure: %v", err)
+	}
+	if caAfter, _ := os.ReadFile(second.CACert); string(caAfter) != string(caBefore) {
+		t.Errorf("CA should be reused so phones keep trusting it")
+	}
+	if err := verify(t, second, "gym.lan"); err != nil {
+		t.Errorf("reissued certificate should cover gym.lan: %v", err)
+	}
+}
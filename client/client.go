@@ -0,0 +1,870 @@
+// Package client is a Go client for the Train REST API described by
+// /api/v1/openapi.json.
+//
+//	c := client.New("https://train.lan:3001")
+//	exercises, err := c.ListExercises(ctx, client.ExerciseFilter{Category: "Legs-Push"})
+//
+// API errors are returned as *Error, so callers can branch on the error code:
+//
+//	var apiErr *client.Error
+//	if errors.As(err, &apiErr) && apiErr.Code == client.CodeConflict { ... }
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Error codes returned by the API
+const (
+	CodeInvalidJSON      = "invalid_json"
+	CodeValidation       = "validation_failed"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeForbidden        = "forbidden"
+	CodeInternal         = "internal_error"
+)
+
+// Error is a non-2xx response from the API
+type Error struct {
+	StatusCode int
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	Field      string `json:"field,omitempty"`
+	ID         string `json:"id,omitempty"`
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("train api: %d %s: %s", e.StatusCode, e.Code, e.Message)
+	if e.Field != "" {
+		msg += " (field " + e.Field + ")"
+	}
+	return msg
+}
+
+// Client calls the API of one Train server
+type Client struct {
+	// BaseURL is the server root, e.g. "http://localhost:3001"
+	BaseURL string
+	// HTTPClient sends the requests; http.DefaultClient when nil
+	HTTPClient *http.Client
+}
+
+// New returns a client for the server at baseURL
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// do sends a JSON request and decodes a JSON response into out (if non-nil)
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		apiErr := &Error{StatusCode: resp.StatusCode}
+		var envelope struct {
+			Error *Error `json:"error"`
+		}
+		envelope.Error = apiErr
+		if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil || apiErr.Code == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// message discards the acknowledgement returned by updates and deletes
+type message struct {
+	Message string `json:"message"`
+}
+
+type created struct {
+	ID int64 `json:"id"`
+}
+
+// --- Exercises ---
+
+// values encodes the non-zero options as query parameters
+func (o ListOptions) values() url.Values {
+	query := url.Values{}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Offset > 0 {
+		query.Set("offset", strconv.Itoa(o.Offset))
+	}
+	for key, value := range map[string]string{"cursor": o.Cursor, "sort": o.Sort, "from": o.From, "to": o.To} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	return query
+}
+
+// ListExercises returns a page of exercises, ordered by name by default
+func (c *Client) ListExercises(ctx context.Context, filter ExerciseFilter) (*ExerciseList, error) {
+	query := filter.ListOptions.values()
+	for key, value := range map[string]string{"search": filter.Search, "type": filter.Type, "category": filter.Category} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	var resp ExerciseList
+	if err := c.do(ctx, http.MethodGet, "/api/v1/exercises", query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetExercise returns one exercise
+func (c *Client) GetExercise(ctx context.Context, id int) (*Exercise, error) {
+	var ex Exercise
+	if err := c.do(ctx, http.MethodGet, "/api/v1/exercises/"+strconv.Itoa(id), nil, nil, &ex); err != nil {
+		return nil, err
+	}
+	return &ex, nil
+}
+
+// CreateExercise adds an exercise and returns its ID
+func (c *Client) CreateExercise(ctx context.Context, in ExerciseInput) (int64, error) {
+	var resp created
+	err := c.do(ctx, http.MethodPost, "/api/v1/exercises", nil, in, &resp)
+	return resp.ID, err
+}
+
+// UpdateExercise changes an exercise
+func (c *Client) UpdateExercise(ctx context.Context, id int, in ExerciseUpdate) error {
+	return c.do(ctx, http.MethodPut, "/api/v1/exercises/"+strconv.Itoa(id), nil, in, &message{})
+}
+
+// DeleteExercise removes an exercise with its routines and history
+func (c *Client) DeleteExercise(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/exercises/"+strconv.Itoa(id), nil, nil, &message{})
+}
+
+// --- Routines ---
+
+// GetRoutine returns a day's routine with progression status
+func (c *Client) GetRoutine(ctx context.Context, day string) (*RoutineDay, error) {
+	var resp RoutineDay
+	if err := c.do(ctx, http.MethodGet, "/api/v1/routines/"+url.PathEscape(day), nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CreateRoutine appends an exercise to a day and returns the routine ID
+func (c *Client) CreateRoutine(ctx context.Context, in RoutineInput) (int64, error) {
+	var resp created
+	err := c.do(ctx, http.MethodPost, "/api/v1/routines", nil, in, &resp)
+	return resp.ID, err
+}
+
+// UpdateRoutine changes a routine entry
+func (c *Client) UpdateRoutine(ctx context.Context, id int, in RoutineUpdate) error {
+	return c.do(ctx, http.MethodPut, "/api/v1/routines/"+strconv.Itoa(id), nil, in, &message{})
+}
+
+// DeleteRoutine removes an exercise from a day
+func (c *Client) DeleteRoutine(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/routines/"+strconv.Itoa(id), nil, nil, &message{})
+}
+
+// ReorderRoutines sets the order of a day's routines
+func (c *Client) ReorderRoutines(ctx context.Context, day string, routineIDs []int) error {
+	in := struct {
+		DayOfWeek  string `json:"day_of_week"`
+		RoutineIDs []int  `json:"routine_ids"`
+	}{day, routineIDs}
+	return c.do(ctx, http.MethodPost, "/api/v1/routines/reorder", nil, in, &message{})
+}
+
+// --- History ---
+
+// GetHistory returns a page of an exercise's sessions, newest first by default
+func (c *Client) GetHistory(ctx context.Context, exerciseID int, opts ListOptions) (*HistoryList, error) {
+	var resp HistoryList
+	if err := c.do(ctx, http.MethodGet, "/api/v1/history/"+strconv.Itoa(exerciseID), opts.values(), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// HistoryLog returns sessions across all exercises, grouped by date
+func (c *Client) HistoryLog(ctx context.Context, q HistoryQuery) (*HistoryLog, error) {
+	query := ListOptions{Limit: q.Limit, Cursor: q.Cursor}.values()
+	for key, value := range map[string]string{"from": q.From, "to": q.To, "category": q.Category, "type": q.Type, "day": q.Day} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	var resp HistoryLog
+	if err := c.do(ctx, http.MethodGet, "/api/v1/history", query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetPR returns an exercise's personal record, or nil when it has none
+func (c *Client) GetPR(ctx context.Context, exerciseID int) (*PersonalRecord, error) {
+	var resp struct {
+		PR *PersonalRecord `json:"pr"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/v1/history/"+strconv.Itoa(exerciseID)+"/pr", nil, nil, &resp)
+	return resp.PR, err
+}
+
+// GetCardioRecords returns an exercise's longest-distance and fastest-pace
+// sessions; each is nil when no session logged one
+func (c *Client) GetCardioRecords(ctx context.Context, exerciseID int) (longest, fastest *CardioRecord, err error) {
+	var resp struct {
+		LongestDistance *CardioRecord `json:"longest_distance"`
+		FastestPace     *CardioRecord `json:"fastest_pace"`
+	}
+	err = c.do(ctx, http.MethodGet, "/api/v1/history/"+strconv.Itoa(exerciseID)+"/pr", nil, nil, &resp)
+	return resp.LongestDistance, resp.FastestPace, err
+}
+
+// CreateHistory records a session
+func (c *Client) CreateHistory(ctx context.Context, in HistoryInput) (*HistoryCreated, error) {
+	var resp HistoryCreated
+	if err := c.do(ctx, http.MethodPost, "/api/v1/history", nil, in, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// UpdateHistory changes a session
+func (c *Client) UpdateHistory(ctx context.Context, id int, in HistoryUpdate) error {
+	return c.do(ctx, http.MethodPut, "/api/v1/history/"+strconv.Itoa(id), nil, in, &message{})
+}
+
+// DeleteHistory removes a session
+func (c *Client) DeleteHistory(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/history/"+strconv.Itoa(id), nil, nil, &message{})
+}
+
+// --- Day titles ---
+
+// GetDayTitle returns a day's title, empty when unset
+func (c *Client) GetDayTitle(ctx context.Context, day string) (string, error) {
+	var resp struct {
+		Title string `json:"title"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/v1/days/"+url.PathEscape(day), nil, nil, &resp)
+	return resp.Title, err
+}
+
+// SetDayTitle sets a day's title
+func (c *Client) SetDayTitle(ctx context.Context, day, title string) error {
+	in := struct {
+		Title string `json:"title"`
+	}{title}
+	return c.do(ctx, http.MethodPut, "/api/v1/days/"+url.PathEscape(day), nil, in, &message{})
+}
+
+// --- Metrics ---
+
+// ListMetricTypes returns metric types with their latest entry
+func (c *Client) ListMetricTypes(ctx context.Context) ([]MetricType, error) {
+	var resp struct {
+		MetricTypes []MetricType `json:"metric_types"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/v1/metrics", nil, nil, &resp)
+	return resp.MetricTypes, err
+}
+
+// CreateMetricType adds a metric type and returns its ID
+func (c *Client) CreateMetricType(ctx context.Context, in MetricTypeInput) (int64, error) {
+	var resp created
+	err := c.do(ctx, http.MethodPost, "/api/v1/metrics", nil, in, &resp)
+	return resp.ID, err
+}
+
+// UpdateMetricType changes a metric type
+func (c *Client) UpdateMetricType(ctx context.Context, id int, in MetricTypeUpdate) error {
+	return c.do(ctx, http.MethodPut, "/api/v1/metrics/"+strconv.Itoa(id), nil, in, &message{})
+}
+
+// DeleteMetricType removes a metric type and its entries
+func (c *Client) DeleteMetricType(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/metrics/"+strconv.Itoa(id), nil, nil, &message{})
+}
+
+// ReorderMetricTypes sets the position of each metric type
+func (c *Client) ReorderMetricTypes(ctx context.Context, order []MetricOrder) error {
+	in := struct {
+		MetricTypes []MetricOrder `json:"metric_types"`
+	}{order}
+	return c.do(ctx, http.MethodPost, "/api/v1/metrics/reorder", nil, in, &message{})
+}
+
+// GetMetricEntries returns a page of a metric type's entries, newest first
+// by default
+func (c *Client) GetMetricEntries(ctx context.Context, metricTypeID int, opts ListOptions) (*MetricEntryList, error) {
+	var resp MetricEntryList
+	if err := c.do(ctx, http.MethodGet, "/api/v1/metrics/"+strconv.Itoa(metricTypeID)+"/entries", opts.values(), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetMetricAnomalies returns a metric type's entries outside its limits or
+// far from their neighbours, newest first
+func (c *Client) GetMetricAnomalies(ctx context.Context, metricTypeID int, opts AnomalyOptions) ([]MetricAnomaly, error) {
+	query := url.Values{}
+	if opts.Threshold > 0 {
+		query.Set("threshold", strconv.FormatFloat(opts.Threshold, 'f', -1, 64))
+	}
+	if opts.Window > 0 {
+		query.Set("window", strconv.Itoa(opts.Window))
+	}
+	var resp struct {
+		Anomalies []MetricAnomaly `json:"anomalies"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/v1/metrics/"+strconv.Itoa(metricTypeID)+"/anomalies", query, nil, &resp)
+	return resp.Anomalies, err
+}
+
+// GetDashboard returns every metric's entries over the last days days;
+// days <= 0 uses the server default
+func (c *Client) GetDashboard(ctx context.Context, days int) ([]DashboardMetric, error) {
+	query := url.Values{}
+	if days > 0 {
+		query.Set("days", strconv.Itoa(days))
+	}
+	var resp struct {
+		Metrics []DashboardMetric `json:"metrics"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/v1/metrics/dashboard", query, nil, &resp)
+	return resp.Metrics, err
+}
+
+// GetDashboardTrends returns the dashboard with each metric's trend
+func (c *Client) GetDashboardTrends(ctx context.Context, opts TrendOptions) ([]DashboardMetric, error) {
+	query := url.Values{"trend": {"true"}}
+	if opts.Days > 0 {
+		query.Set("days", strconv.Itoa(opts.Days))
+	}
+	if opts.Alpha > 0 {
+		query.Set("alpha", strconv.FormatFloat(opts.Alpha, 'f', -1, 64))
+	}
+	for id, goal := range opts.Goals {
+		query.Add("goal", strconv.Itoa(id)+":"+strconv.FormatFloat(goal, 'f', -1, 64))
+	}
+	var resp struct {
+		Metrics []DashboardMetric `json:"metrics"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/v1/metrics/dashboard", query, nil, &resp)
+	return resp.Metrics, err
+}
+
+// CreateMetricEntry records a measurement and returns its ID
+func (c *Client) CreateMetricEntry(ctx context.Context, in MetricEntryInput) (int64, error) {
+	resp, err := c.RecordMetricEntry(ctx, in)
+	if err != nil {
+		return 0, err
+	}
+	return resp.ID, nil
+}
+
+// RecordMetricEntry records a measurement and returns its ID with any
+// warnings about how fast it changed
+func (c *Client) RecordMetricEntry(ctx context.Context, in MetricEntryInput) (*MetricEntrySaved, error) {
+	var resp MetricEntrySaved
+	if err := c.do(ctx, http.MethodPost, "/api/v1/metric-entries", nil, in, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// UpdateMetricEntry changes a measurement
+func (c *Client) UpdateMetricEntry(ctx context.Context, id int, in MetricEntryUpdate) error {
+	return c.do(ctx, http.MethodPut, "/api/v1/metric-entries/"+strconv.Itoa(id), nil, in, &message{})
+}
+
+// DeleteMetricEntry removes a measurement
+func (c *Client) DeleteMetricEntry(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/metric-entries/"+strconv.Itoa(id), nil, nil, &message{})
+}
+
+// --- Goals ---
+
+// ListGoals returns goals with their progress, unachieved first
+func (c *Client) ListGoals(ctx context.Context) ([]Goal, error) {
+	var resp struct {
+		Goals []Goal `json:"goals"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/v1/goals", nil, nil, &resp)
+	return resp.Goals, err
+}
+
+// GetGoal returns a goal with its progress
+func (c *Client) GetGoal(ctx context.Context, id int) (*Goal, error) {
+	var resp Goal
+	if err := c.do(ctx, http.MethodGet, "/api/v1/goals/"+strconv.Itoa(id), nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CreateGoal sets a goal and returns its ID
+func (c *Client) CreateGoal(ctx context.Context, in GoalInput) (int64, error) {
+	var resp created
+	err := c.do(ctx, http.MethodPost, "/api/v1/goals", nil, in, &resp)
+	return resp.ID, err
+}
+
+// UpdateGoal changes a goal
+func (c *Client) UpdateGoal(ctx context.Context, id int, in GoalUpdate) error {
+	return c.do(ctx, http.MethodPut, "/api/v1/goals/"+strconv.Itoa(id), nil, in, &message{})
+}
+
+// DeleteGoal removes a goal
+func (c *Client) DeleteGoal(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/goals/"+strconv.Itoa(id), nil, nil, &message{})
+}
+
+// --- Categories and exercise types ---
+
+// ListCategories returns the categories in display order with how many
+// exercises are in each
+func (c *Client) ListCategories(ctx context.Context) ([]Category, error) {
+	var resp struct {
+		Categories []Category `json:"categories"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/v1/categories", nil, nil, &resp)
+	return resp.Categories, err
+}
+
+// CreateCategory adds a category and returns its ID
+func (c *Client) CreateCategory(ctx context.Context, in CategoryInput) (int64, error) {
+	var resp created
+	err := c.do(ctx, http.MethodPost, "/api/v1/categories", nil, in, &resp)
+	return resp.ID, err
+}
+
+// UpdateCategory renames or reorders a category; exercises follow a rename
+func (c *Client) UpdateCategory(ctx context.Context, id int, in CategoryUpdate) error {
+	return c.do(ctx, http.MethodPut, "/api/v1/categories/"+strconv.Itoa(id), nil, in, &message{})
+}
+
+// DeleteCategory removes a category, leaving its exercises uncategorised
+func (c *Client) DeleteCategory(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/categories/"+strconv.Itoa(id), nil, nil, &message{})
+}
+
+// ReorderCategories sets the position of each category
+func (c *Client) ReorderCategories(ctx context.Context, order []CategoryOrder) error {
+	in := struct {
+		Categories []CategoryOrder `json:"categories"`
+	}{order}
+	return c.do(ctx, http.MethodPost, "/api/v1/categories/reorder", nil, in, &message{})
+}
+
+// ListExerciseTypes returns the exercise types in display order with how
+// many exercises use each
+func (c *Client) ListExerciseTypes(ctx context.Context) ([]ExerciseType, error) {
+	var resp struct {
+		ExerciseTypes []ExerciseType `json:"exercise_types"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/v1/exercise-types", nil, nil, &resp)
+	return resp.ExerciseTypes, err
+}
+
+// CreateExerciseType adds an exercise type and returns it with defaults
+// filled in
+func (c *Client) CreateExerciseType(ctx context.Context, in ExerciseTypeInput) (*ExerciseType, error) {
+	var t ExerciseType
+	if err := c.do(ctx, http.MethodPost, "/api/v1/exercise-types", nil, in, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// UpdateExerciseType changes the non-nil fields of an exercise type
+func (c *Client) UpdateExerciseType(ctx context.Context, name string, in ExerciseTypeInput) (*ExerciseType, error) {
+	var t ExerciseType
+	if err := c.do(ctx, http.MethodPut, "/api/v1/exercise-types/"+url.PathEscape(name), nil, in, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// DeleteExerciseType removes an exercise type no exercise uses
+func (c *Client) DeleteExerciseType(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/exercise-types/"+url.PathEscape(name), nil, nil, &message{})
+}
+
+// --- Muscles ---
+
+// ListMuscles returns every muscle with how many exercises work it
+func (c *Client) ListMuscles(ctx context.Context) ([]Muscle, error) {
+	var resp struct {
+		Muscles []Muscle `json:"muscles"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/v1/muscles", nil, nil, &resp)
+	return resp.Muscles, err
+}
+
+// CreateMuscle adds a muscle and returns its ID
+func (c *Client) CreateMuscle(ctx context.Context, in MuscleInput) (int64, error) {
+	var resp created
+	err := c.do(ctx, http.MethodPost, "/api/v1/muscles", nil, in, &resp)
+	return resp.ID, err
+}
+
+// UpdateMuscle renames or reorders a muscle
+func (c *Client) UpdateMuscle(ctx context.Context, id int, in MuscleUpdate) error {
+	return c.do(ctx, http.MethodPut, "/api/v1/muscles/"+strconv.Itoa(id), nil, in, &message{})
+}
+
+// DeleteMuscle removes a muscle and its exercise mappings
+func (c *Client) DeleteMuscle(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/muscles/"+strconv.Itoa(id), nil, nil, &message{})
+}
+
+// GetExerciseMuscles returns the muscles an exercise works, primary first
+func (c *Client) GetExerciseMuscles(ctx context.Context, exerciseID int) ([]ExerciseMuscle, error) {
+	var resp struct {
+		Muscles []ExerciseMuscle `json:"muscles"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/v1/exercises/"+strconv.Itoa(exerciseID)+"/muscles", nil, nil, &resp)
+	return resp.Muscles, err
+}
+
+// SetExerciseMuscles replaces the muscles an exercise works and returns the
+// stored mapping
+func (c *Client) SetExerciseMuscles(ctx context.Context, exerciseID int, muscles []ExerciseMuscleInput) ([]ExerciseMuscle, error) {
+	in := struct {
+		Muscles []ExerciseMuscleInput `json:"muscles"`
+	}{muscles}
+	var resp struct {
+		Muscles []ExerciseMuscle `json:"muscles"`
+	}
+	err := c.do(ctx, http.MethodPut, "/api/v1/exercises/"+strconv.Itoa(exerciseID)+"/muscles", nil, in, &resp)
+	return resp.Muscles, err
+}
+
+// --- Plan ---
+
+// ExportPlan returns the weekly plan in the text format ImportPlan accepts
+func (c *Client) ExportPlan(ctx context.Context) (string, error) {
+	var resp struct {
+		Plan string `json:"plan"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/v1/plan", nil, nil, &resp)
+	return resp.Plan, err
+}
+
+// ImportPlan replaces the routines of every day in plan and returns how many
+// days were updated
+func (c *Client) ImportPlan(ctx context.Context, plan string) (int, error) {
+	in := struct {
+		Plan string `json:"plan"`
+	}{plan}
+	var resp struct {
+		DaysUpdated int `json:"days_updated"`
+	}
+	err := c.do(ctx, http.MethodPost, "/api/v1/plan", nil, in, &resp)
+	return resp.DaysUpdated, err
+}
+
+// --- Stats ---
+
+// Calendar returns each day of year with its training status, weekly
+// adherence and streaks; year 0 means the server's current year
+func (c *Client) Calendar(ctx context.Context, year int) (*Calendar, error) {
+	query := url.Values{}
+	if year != 0 {
+		query.Set("year", strconv.Itoa(year))
+	}
+	var resp Calendar
+	if err := c.do(ctx, http.MethodGet, "/api/v1/stats/calendar", query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Volume returns hard sets, reps and tonnage per week, category and exercise
+// between from and to (inclusive YYYY-MM-DD; empty uses the server default of
+// the twelve weeks ending today)
+func (c *Client) Volume(ctx context.Context, from, to string) (*Volume, error) {
+	query := url.Values{}
+	if from != "" {
+		query.Set("from", from)
+	}
+	if to != "" {
+		query.Set("to", to)
+	}
+	var resp Volume
+	if err := c.do(ctx, http.MethodGet, "/api/v1/stats/volume", query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// MuscleVolume returns the sets credited to each muscle per week between
+// from and to (empty uses the server default of the twelve weeks ending today)
+func (c *Client) MuscleVolume(ctx context.Context, from, to string) (*MuscleVolume, error) {
+	query := url.Values{}
+	if from != "" {
+		query.Set("from", from)
+	}
+	if to != "" {
+		query.Set("to", to)
+	}
+	var resp MuscleVolume
+	if err := c.do(ctx, http.MethodGet, "/api/v1/stats/muscles", query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CardioStats returns cardio duration and distance per week between from and
+// to (empty uses the server default of the twelve weeks ending today)
+func (c *Client) CardioStats(ctx context.Context, from, to string) (*CardioStats, error) {
+	query := url.Values{}
+	if from != "" {
+		query.Set("from", from)
+	}
+	if to != "" {
+		query.Set("to", to)
+	}
+	var resp CardioStats
+	if err := c.do(ctx, http.MethodGet, "/api/v1/stats/cardio", query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Strength returns the best squat, bench and deadlift and their Wilks, DOTS
+// and IPF GL scores over time between from and to (empty uses the server
+// default of the year ending today)
+func (c *Client) Strength(ctx context.Context, from, to string) (*Strength, error) {
+	query := url.Values{}
+	if from != "" {
+		query.Set("from", from)
+	}
+	if to != "" {
+		query.Set("to", to)
+	}
+	var resp Strength
+	if err := c.do(ctx, http.MethodGet, "/api/v1/stats/strength", query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// --- Settings ---
+
+// GetSettings returns the profile settings
+func (c *Client) GetSettings(ctx context.Context) (*Settings, error) {
+	var resp Settings
+	if err := c.do(ctx, http.MethodGet, "/api/v1/settings", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// UpdateSettings changes the non-nil settings and returns them all
+func (c *Client) UpdateSettings(ctx context.Context, in SettingsUpdate) (*Settings, error) {
+	var resp Settings
+	if err := c.do(ctx, http.MethodPut, "/api/v1/settings", nil, in, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// --- Sync ---
+
+// Sync pushes a device's change log and returns the changes made on the
+// server since the cursor of the previous sync ("" fetches everything)
+func (c *Client) Sync(ctx context.Context, since string, changes []SyncChange) (*SyncResponse, error) {
+	body := struct {
+		Since   string       `json:"since,omitempty"`
+		Changes []SyncChange `json:"changes"`
+	}{since, changes}
+	var resp SyncResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/sync", nil, body, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// --- Shared sessions ---
+
+// StartSharedSession starts a shared workout with the caller as host
+func (c *Client) StartSharedSession(ctx context.Context, in SharedSessionInput) (*SharedSessionJoined, error) {
+	var resp SharedSessionJoined
+	if err := c.do(ctx, http.MethodPost, "/api/v1/shared-sessions", nil, in, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// JoinSharedSession joins the running session with code under name
+func (c *Client) JoinSharedSession(ctx context.Context, code, name string) (*SharedSessionJoined, error) {
+	var resp SharedSessionJoined
+	body := struct {
+		Name string `json:"name"`
+	}{name}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/shared-sessions/"+url.PathEscape(code)+"/join", nil, body, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetSharedSession returns a session with everyone's sets
+func (c *Client) GetSharedSession(ctx context.Context, code string) (*SharedSession, error) {
+	var resp SharedSession
+	if err := c.do(ctx, http.MethodGet, "/api/v1/shared-sessions/"+url.PathEscape(code), nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// RecordSharedSet records a set for the participant holding token
+func (c *Client) RecordSharedSet(ctx context.Context, code, token string, in SharedSetInput) (*SharedSession, error) {
+	body := struct {
+		Token string `json:"token"`
+		SharedSetInput
+	}{token, in}
+	var resp SharedSession
+	if err := c.do(ctx, http.MethodPut, "/api/v1/shared-sessions/"+url.PathEscape(code)+"/sets", nil, body, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// FinishSharedSession ends a session with the host's token, logging the
+// host's results, and returns everyone's entries
+func (c *Client) FinishSharedSession(ctx context.Context, code, token string) (*SharedSession, error) {
+	body := struct {
+		Token string `json:"token"`
+	}{token}
+	var resp SharedSession
+	if err := c.do(ctx, http.MethodPost, "/api/v1/shared-sessions/"+url.PathEscape(code)+"/finish", nil, body, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ImportSharedEntries logs a partner's entries from a finished session on
+// this server, matching exercises by name. Each entry keeps its client_id,
+// so importing the same entries again changes nothing.
+func (c *Client) ImportSharedEntries(ctx context.Context, entries []SharedEntry) ([]int64, error) {
+	ids := []int64{}
+	for _, e := range entries {
+		list, err := c.ListExercises(ctx, ExerciseFilter{Search: e.ExerciseName})
+		if err != nil {
+			return ids, err
+		}
+		exerciseID := 0
+		for _, ex := range list.Exercises {
+			if strings.EqualFold(ex.Name, e.ExerciseName) {
+				exerciseID = ex.ID
+			}
+		}
+		if exerciseID == 0 {
+			return ids, fmt.Errorf("train api: no exercise named %q", e.ExerciseName)
+		}
+		created, err := c.CreateHistory(ctx, HistoryInput{
+			ExerciseID:    exerciseID,
+			SessionDate:   e.SessionDate,
+			Weight:        e.Weight,
+			SetsCompleted: e.SetsCompleted,
+			Completed:     e.Completed,
+			Volume:        e.Volume,
+			ClientID:      e.ClientID,
+		})
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, created.ID)
+	}
+	return ids, nil
+}
+
+// --- Events ---
+
+// Events streams change notifications to fn until ctx is cancelled or the
+// server ends the stream, e.g. on shutdown (nil; reconnect to keep listening)
+func (c *Client) Events(ctx context.Context, fn func(Event)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"/api/v1/events", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &Error{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	}
+
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() {
+		data, ok := strings.CutPrefix(lines.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			return fmt.Errorf("train api: bad event %q: %w", data, err)
+		}
+		fn(e)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return lines.Err()
+}
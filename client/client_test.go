@@ -0,0 +1,485 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"train/db"
+	"train/handlers"
+)
+
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+	database, err := db.OpenForTesting()
+	if err != nil {
+		t.Fatalf("OpenForTesting: %v", err)
+	}
+	mux := http.NewServeMux()
+	handlers.Register(mux, database, handlers.Options{PlanImport: true})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(func() {
+		srv.Close()
+		database.Close()
+	})
+	return New(srv.URL + "/")
+}
+
+func TestClient_ExerciseAndHistoryRoundTrip(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	category := "Legs-Push"
+	id, err := c.CreateExercise(ctx, ExerciseInput{Name: "Squat", Type: "weight", Category: &category})
+	if err != nil {
+		t.Fatalf("CreateExercise: %v", err)
+	}
+	if _, err := c.CreateRoutine(ctx, RoutineInput{ExerciseID: int(id), DayOfWeek: "Monday"}); err != nil {
+		t.Fatalf("CreateRoutine: %v", err)
+	}
+
+	weight := 100.0
+	created, err := c.CreateHistory(ctx, HistoryInput{ExerciseID: int(id), SessionDate: "2026-01-12", Weight: &weight, SetsCompleted: []int{5, 5, 5}, Completed: true})
+	if err != nil {
+		t.Fatalf("CreateHistory: %v", err)
+	}
+	if !created.IsPR {
+		t.Error("first weighted session should be a PR")
+	}
+
+	history, err := c.GetHistory(ctx, int(id), ListOptions{})
+	if err != nil {
+		t.Fatalf("GetHistory: %v", err)
+	}
+	if history.ExerciseName != "Squat" || len(history.History) != 1 {
+		t.Errorf("unexpected history: %+v", history)
+	}
+
+	log, err := c.HistoryLog(ctx, HistoryQuery{From: "2026-01-01", To: "2026-01-31", Day: "Monday"})
+	if err != nil || log.TotalSessions != 1 || log.Days[0].Sessions[0].ExerciseName != "Squat" {
+		t.Errorf("HistoryLog = %+v, %v", log, err)
+	}
+
+	pr, err := c.GetPR(ctx, int(id))
+	if err != nil || pr == nil || pr.Weight != 100 {
+		t.Errorf("GetPR = %+v, %v", pr, err)
+	}
+
+	day, err := c.GetRoutine(ctx, "Monday")
+	if err != nil {
+		t.Fatalf("GetRoutine: %v", err)
+	}
+	if len(day.Exercises) != 1 || day.Exercises[0].LastDone == nil {
+		t.Errorf("unexpected routine: %+v", day)
+	}
+
+	cal, err := c.Calendar(ctx, 2026)
+	if err != nil || cal.TrainedDays != 1 || len(cal.PlannedWeekdays) != 1 {
+		t.Errorf("Calendar = %+v, %v", cal, err)
+	}
+
+	vol, err := c.Volume(ctx, "2026-01-12", "2026-01-18")
+	if err != nil || vol.Totals.Tonnage != 1500 || vol.Balance[0].Region != "Legs" {
+		t.Errorf("Volume = %+v, %v", vol, err)
+	}
+
+	muscles, err := c.ListMuscles(ctx)
+	if err != nil || len(muscles) == 0 {
+		t.Fatalf("ListMuscles = %+v, %v", muscles, err)
+	}
+	mapped, err := c.SetExerciseMuscles(ctx, int(id), []ExerciseMuscleInput{{MuscleID: muscles[0].ID, Role: "secondary"}})
+	if err != nil || len(mapped) != 1 || mapped[0].Weight != 0.5 {
+		t.Errorf("SetExerciseMuscles = %+v, %v", mapped, err)
+	}
+	byMuscle, err := c.MuscleVolume(ctx, "2026-01-12", "2026-01-18")
+	if err != nil || byMuscle.Muscles[0].Sets != 1.5 {
+		t.Errorf("MuscleVolume = %+v, %v", byMuscle, err)
+	}
+
+	exercises, err := c.ListExercises(ctx, ExerciseFilter{Category: "Legs-Push"})
+	if err != nil || len(exercises.Exercises) != 1 || exercises.Total != 1 {
+		t.Errorf("ListExercises = %+v, %v", exercises, err)
+	}
+}
+
+func TestClient_Metrics(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	types, err := c.ListMetricTypes(ctx)
+	if err != nil || len(types) == 0 {
+		t.Fatalf("ListMetricTypes = %+v, %v", types, err)
+	}
+	if _, err := c.CreateMetricEntry(ctx, MetricEntryInput{MetricTypeID: types[0].ID, EntryDate: "2026-01-12", Value: 80.5}); err != nil {
+		t.Fatalf("CreateMetricEntry: %v", err)
+	}
+	if _, err := c.CreateMetricEntry(ctx, MetricEntryInput{MetricTypeID: types[0].ID, EntryDate: "2026-01-13", Value: 80.1}); err != nil {
+		t.Fatalf("CreateMetricEntry: %v", err)
+	}
+
+	first, err := c.GetMetricEntries(ctx, types[0].ID, ListOptions{Limit: 1})
+	if err != nil || len(first.Entries) != 1 || first.Entries[0].Value != 80.1 || first.NextCursor == "" {
+		t.Fatalf("GetMetricEntries = %+v, %v", first, err)
+	}
+	next, err := c.GetMetricEntries(ctx, types[0].ID, ListOptions{Limit: 1, Cursor: first.NextCursor})
+	if err != nil || len(next.Entries) != 1 || next.Entries[0].Value != 80.5 || next.NextCursor != "" {
+		t.Errorf("second page = %+v, %v", next, err)
+	}
+
+	// Retrying with the same client_id doesn't add another entry
+	retry := MetricEntryInput{MetricTypeID: types[0].ID, EntryDate: "2026-01-14", Value: 79.9, ClientID: "retry-1"}
+	for range 2 {
+		if _, err := c.CreateMetricEntry(ctx, retry); err != nil {
+			t.Fatalf("CreateMetricEntry retry: %v", err)
+		}
+	}
+	all, err := c.GetMetricEntries(ctx, types[0].ID, ListOptions{})
+	if err != nil || all.Total != 3 {
+		t.Errorf("expected 3 entries after a retry, got %+v, %v", all, err)
+	}
+}
+
+func TestClient_CategoriesAndTypes(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	id, err := c.CreateCategory(ctx, CategoryInput{Name: "Full-Body", OrderIndex: 6})
+	if err != nil {
+		t.Fatalf("CreateCategory: %v", err)
+	}
+	name := "Whole-Body"
+	if err := c.UpdateCategory(ctx, int(id), CategoryUpdate{Name: &name}); err != nil {
+		t.Fatalf("UpdateCategory: %v", err)
+	}
+	if err := c.ReorderCategories(ctx, []CategoryOrder{{ID: int(id), OrderIndex: -1}}); err != nil {
+		t.Fatalf("ReorderCategories: %v", err)
+	}
+	categories, err := c.ListCategories(ctx)
+	if err != nil || categories[0].Name != "Whole-Body" {
+		t.Errorf("ListCategories = %+v, %v", categories, err)
+	}
+
+	label := "Resistance band"
+	created, err := c.CreateExerciseType(ctx, ExerciseTypeInput{Name: "band", Label: &label})
+	if err != nil || created.SetUnit != "reps" || !created.UsesWeight {
+		t.Fatalf("CreateExerciseType = %+v, %v", created, err)
+	}
+	lower := "lower"
+	updated, err := c.UpdateExerciseType(ctx, "band", ExerciseTypeInput{PRDirection: &lower})
+	if err != nil || updated.PRDirection != "lower" || updated.Label != label {
+		t.Errorf("UpdateExerciseType = %+v, %v", updated, err)
+	}
+	if _, err := c.CreateExercise(ctx, ExerciseInput{Name: "Band Pull-Apart", Type: "band", Category: &name}); err != nil {
+		t.Fatalf("CreateExercise with custom type: %v", err)
+	}
+	types, err := c.ListExerciseTypes(ctx)
+	if err != nil || len(types) != 7 {
+		t.Errorf("ListExerciseTypes = %+v, %v", types, err)
+	}
+
+	var apiErr *Error
+	if err := c.DeleteExerciseType(ctx, "band"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict {
+		t.Errorf("DeleteExerciseType in use = %v, want 409", err)
+	}
+	if err := c.DeleteCategory(ctx, int(id)); err != nil {
+		t.Errorf("DeleteCategory: %v", err)
+	}
+}
+
+func TestClient_CardioSessions(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	id, err := c.CreateExercise(ctx, ExerciseInput{Name: "Run", Type: "cardio"})
+	if err != nil {
+		t.Fatalf("CreateExercise: %v", err)
+	}
+	duration, distance := 1500, 5.0
+	created, err := c.CreateHistory(ctx, HistoryInput{ExerciseID: int(id), SessionDate: "2026-01-12", Completed: true,
+		Cardio: Cardio{DurationSeconds: &duration, DistanceKm: &distance}})
+	if err != nil || !created.DistancePR || !created.PacePR {
+		t.Fatalf("CreateHistory = %+v, %v", created, err)
+	}
+
+	longest, fastest, err := c.GetCardioRecords(ctx, int(id))
+	if err != nil || longest == nil || *longest.DistanceKm != 5 || fastest == nil || *fastest.PaceSecondsPerKm != 300 {
+		t.Errorf("GetCardioRecords = %+v, %+v, %v", longest, fastest, err)
+	}
+	stats, err := c.CardioStats(ctx, "2026-01-12", "2026-01-18")
+	if err != nil || stats.Totals.DistanceKm != 5 || stats.Totals.DurationSeconds != 1500 || stats.Totals.DistancePRs != 1 {
+		t.Errorf("CardioStats = %+v, %v", stats, err)
+	}
+}
+
+func TestClient_SettingsAndStrength(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	id, err := c.CreateExercise(ctx, ExerciseInput{Name: "Low Bar Squat", Type: "weight"})
+	if err != nil {
+		t.Fatalf("CreateExercise: %v", err)
+	}
+	sex, squat := "female", int(id)
+	settings, err := c.UpdateSettings(ctx, SettingsUpdate{Sex: &sex, Lifts: &LiftSettings{Squat: &squat}})
+	if err != nil || settings.Sex == nil || *settings.Sex != "female" || settings.Lifts.Squat == nil || *settings.Lifts.Squat != squat {
+		t.Fatalf("UpdateSettings = %+v, %v", settings, err)
+	}
+	if _, err := c.CreateMetricEntry(ctx, MetricEntryInput{MetricTypeID: 1, EntryDate: "2026-01-12", Value: 60}); err != nil {
+		t.Fatalf("CreateMetricEntry: %v", err)
+	}
+	weight := 90.0
+	if _, err := c.CreateHistory(ctx, HistoryInput{ExerciseID: squat, SessionDate: "2026-01-12", Weight: &weight, SetsCompleted: []int{1}, Completed: true}); err != nil {
+		t.Fatalf("CreateHistory: %v", err)
+	}
+
+	strength, err := c.Strength(ctx, "2026-01-01", "2026-01-31")
+	if err != nil || *strength.Lifts[0].E1RM != 90 || *strength.Lifts[0].BodyweightRatio != 1.5 || strength.Lifts[0].Standard != "advanced" || strength.Total != nil {
+		t.Errorf("Strength = %+v, %v", strength, err)
+	}
+}
+
+func TestClient_ComputedMetric(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	height := 200.0
+	if _, err := c.UpdateSettings(ctx, SettingsUpdate{HeightCm: &height}); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	id, err := c.CreateMetricType(ctx, MetricTypeInput{Name: "Weight per m", Unit: "kg/m", Color: "#FFFFFF", Formula: "{Weight} / (height / 100)"})
+	if err != nil {
+		t.Fatalf("CreateMetricType: %v", err)
+	}
+	if _, err := c.CreateMetricEntry(ctx, MetricEntryInput{MetricTypeID: 1, EntryDate: "2026-01-12", Value: 80}); err != nil {
+		t.Fatalf("CreateMetricEntry: %v", err)
+	}
+	if _, err := c.CreateMetricEntry(ctx, MetricEntryInput{MetricTypeID: int(id), EntryDate: "2026-01-12", Value: 40}); err == nil {
+		t.Error("expected an error adding an entry to a computed metric")
+	}
+
+	types, err := c.ListMetricTypes(ctx)
+	if err != nil {
+		t.Fatalf("ListMetricTypes: %v", err)
+	}
+	for _, mt := range types {
+		if mt.ID == int(id) && (mt.Formula == nil || mt.LatestEntry == nil || mt.LatestEntry.Value != 40) {
+			t.Errorf("unexpected computed metric: %+v", mt)
+		}
+	}
+}
+
+func TestClient_DashboardTrends(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	for i, v := range []float64{82, 81} {
+		date := time.Now().AddDate(0, 0, -7*(1-i)).Format("2006-01-02")
+		if _, err := c.CreateMetricEntry(ctx, MetricEntryInput{MetricTypeID: 1, EntryDate: date, Value: v}); err != nil {
+			t.Fatalf("CreateMetricEntry: %v", err)
+		}
+	}
+	metrics, err := c.GetDashboardTrends(ctx, TrendOptions{Days: 30, Goals: map[int]float64{1: 79}})
+	if err != nil {
+		t.Fatalf("GetDashboardTrends: %v", err)
+	}
+	trend := metrics[0].Trend
+	want := time.Now().AddDate(0, 0, 14).Format("2006-01-02")
+	if trend == nil || trend.SlopePerWeek == nil || *trend.SlopePerWeek != -1 || trend.ProjectedDate == nil || *trend.ProjectedDate != want {
+		t.Errorf("unexpected trend: %+v", trend)
+	}
+}
+
+func TestClient_MetricLimitsAndAnomalies(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	low, high, daily := 30.0, 250.0, 1.0
+	if err := c.UpdateMetricType(ctx, 1, MetricTypeUpdate{Limits: &MetricLimits{Min: &low, Max: &high, MaxDailyChange: &daily}}); err != nil {
+		t.Fatalf("UpdateMetricType: %v", err)
+	}
+	_, err := c.CreateMetricEntry(ctx, MetricEntryInput{MetricTypeID: 1, EntryDate: "2026-01-12", Value: 780})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Code != CodeValidation || apiErr.Field != "value" {
+		t.Errorf("expected validation error on value, got %v", err)
+	}
+	if saved, err := c.RecordMetricEntry(ctx, MetricEntryInput{MetricTypeID: 1, EntryDate: "2026-01-12", Value: 80}); err != nil || len(saved.Warnings) != 0 {
+		t.Fatalf("RecordMetricEntry = %+v, %v", saved, err)
+	}
+	if saved, err := c.RecordMetricEntry(ctx, MetricEntryInput{MetricTypeID: 1, EntryDate: "2026-01-13", Value: 83}); err != nil || len(saved.Warnings) != 1 {
+		t.Fatalf("expected a warning for 3 kg in a day, got %+v, %v", saved, err)
+	}
+
+	if err := c.UpdateMetricType(ctx, 1, MetricTypeUpdate{Limits: &MetricLimits{Max: &low}}); err != nil {
+		t.Fatalf("UpdateMetricType: %v", err)
+	}
+	anomalies, err := c.GetMetricAnomalies(ctx, 1, AnomalyOptions{Window: 3})
+	if err != nil || len(anomalies) != 2 || anomalies[0].Value != 83 || anomalies[0].Reason != "above_max" {
+		t.Errorf("GetMetricAnomalies = %+v, %v", anomalies, err)
+	}
+}
+
+func TestClient_Goals(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	weight := 1
+	if _, err := c.CreateMetricEntry(ctx, MetricEntryInput{MetricTypeID: weight, EntryDate: "2026-01-12", Value: 80}); err != nil {
+		t.Fatalf("CreateMetricEntry: %v", err)
+	}
+	id, err := c.CreateGoal(ctx, GoalInput{MetricTypeID: &weight, Direction: "at_most", Target: 75})
+	if err != nil {
+		t.Fatalf("CreateGoal: %v", err)
+	}
+	if _, err := c.CreateMetricEntry(ctx, MetricEntryInput{MetricTypeID: weight, EntryDate: "2026-01-19", Value: 74.8}); err != nil {
+		t.Fatalf("CreateMetricEntry: %v", err)
+	}
+	goal, err := c.GetGoal(ctx, int(id))
+	if err != nil {
+		t.Fatalf("GetGoal: %v", err)
+	}
+	if goal.AchievedAt == nil || goal.StartValue == nil || *goal.StartValue != 80 || goal.Progress.Percent == nil || *goal.Progress.Percent != 100 {
+		t.Errorf("expected an achieved goal from 80, got %+v", goal)
+	}
+
+	target := 70.0
+	if err := c.UpdateGoal(ctx, int(id), GoalUpdate{Target: &target}); err != nil {
+		t.Fatalf("UpdateGoal: %v", err)
+	}
+	goals, err := c.ListGoals(ctx)
+	if err != nil || len(goals) != 1 || goals[0].Target != 70 || goals[0].AchievedAt != nil {
+		t.Errorf("ListGoals = %+v, %v", goals, err)
+	}
+	if err := c.DeleteGoal(ctx, int(id)); err != nil {
+		t.Fatalf("DeleteGoal: %v", err)
+	}
+	if _, err := c.GetGoal(ctx, int(id)); err == nil {
+		t.Error("expected an error getting a deleted goal")
+	}
+}
+
+func TestClient_SyncRoundTrip(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	first, err := c.Sync(ctx, "", []SyncChange{{Entity: "metric_entries", ClientID: "weigh-in-1", Op: "upsert",
+		Data: MetricEntryInput{MetricTypeID: 1, EntryDate: "2026-01-12", Value: 80.5}}})
+	if err != nil || first.Results[0].Status != "applied" || len(first.MetricEntries) != 1 {
+		t.Fatalf("Sync = %+v, %v", first, err)
+	}
+	second, err := c.Sync(ctx, first.Cursor, []SyncChange{{Entity: "metric_entries", ClientID: "weigh-in-1", Op: "delete"}})
+	if err != nil || len(second.Deleted) != 1 || second.Deleted[0].ClientID != "weigh-in-1" {
+		t.Errorf("Sync delete = %+v, %v", second, err)
+	}
+}
+
+func TestClient_SharedSessionAcrossServers(t *testing.T) {
+	host, partner := newTestClient(t), newTestClient(t)
+	ctx := context.Background()
+
+	squat, err := host.CreateExercise(ctx, ExerciseInput{Name: "Squat", Type: "weight"})
+	if err != nil {
+		t.Fatalf("CreateExercise: %v", err)
+	}
+	if _, err := host.CreateRoutine(ctx, RoutineInput{ExerciseID: int(squat), DayOfWeek: "Monday"}); err != nil {
+		t.Fatalf("CreateRoutine: %v", err)
+	}
+	if _, err := partner.CreateExercise(ctx, ExerciseInput{Name: "squat", Type: "weight"}); err != nil {
+		t.Fatalf("CreateExercise: %v", err)
+	}
+
+	started, err := host.StartSharedSession(ctx, SharedSessionInput{DayOfWeek: "Monday", SessionDate: "2026-01-12", Name: "Sam"})
+	if err != nil {
+		t.Fatalf("StartSharedSession: %v", err)
+	}
+	// The partner's device talks to the host's server during the workout
+	joined, err := host.JoinSharedSession(ctx, started.Session.Code, "Alex")
+	if err != nil {
+		t.Fatalf("JoinSharedSession: %v", err)
+	}
+	weight := 60.0
+	if _, err := host.RecordSharedSet(ctx, started.Session.Code, joined.Token,
+		SharedSetInput{ExerciseID: int(squat), SetNumber: 1, Reps: 5, Weight: &weight}); err != nil {
+		t.Fatalf("RecordSharedSet: %v", err)
+	}
+	finished, err := host.FinishSharedSession(ctx, started.Session.Code, started.Token)
+	if err != nil || len(finished.Participants[1].Entries) != 1 {
+		t.Fatalf("FinishSharedSession = %+v, %v", finished, err)
+	}
+
+	// ...and imports its results into its own history, once
+	entries := finished.Participants[1].Entries
+	for range 2 {
+		if ids, err := partner.ImportSharedEntries(ctx, entries); err != nil || len(ids) != 1 {
+			t.Fatalf("ImportSharedEntries = %v, %v", ids, err)
+		}
+	}
+	log, err := partner.HistoryLog(ctx, HistoryQuery{From: "2026-01-01", To: "2026-01-31"})
+	if err != nil || log.TotalSessions != 1 || *log.Days[0].Sessions[0].Weight != 60 {
+		t.Errorf("partner history = %+v, %v", log, err)
+	}
+}
+
+func TestClient_EventsReportChanges(t *testing.T) {
+	c := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	got := make(chan Event, 64)
+	go c.Events(ctx, func(e Event) { got <- e })
+
+	// Keep changing data until the stream is connected and reports it
+	for i := 0; ; i++ {
+		if _, err := c.CreateExercise(ctx, ExerciseInput{Name: "Squat " + strconv.Itoa(i), Type: "weight"}); err != nil {
+			t.Fatalf("CreateExercise: %v", err)
+		}
+		select {
+		case e := <-got:
+			if e.Type != "exercise.created" || e.ID == 0 {
+				t.Errorf("unexpected event: %+v", e)
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("no event received")
+		}
+	}
+}
+
+func TestClient_PlanExportIsJSON(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	if _, err := c.ImportPlan(ctx, "# Monday: Legs\n1. Squat | weight | Legs-Push | 3x5 | 100kg"); err != nil {
+		t.Fatalf("ImportPlan: %v", err)
+	}
+	plan, err := c.ExportPlan(ctx)
+	if err != nil {
+		t.Fatalf("ExportPlan: %v", err)
+	}
+	if plan == "" {
+		t.Error("expected exported plan text")
+	}
+}
+
+func TestClient_ErrorsAreTyped(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	_, err := c.GetExercise(ctx, 999)
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *Error, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound || apiErr.Code != CodeNotFound {
+		t.Errorf("unexpected error: %+v", apiErr)
+	}
+
+	_, err = c.CreateExercise(ctx, ExerciseInput{Name: "Bad", Type: "machine"})
+	if !errors.As(err, &apiErr) || apiErr.Code != CodeValidation || apiErr.Field != "type" {
+		t.Errorf("expected validation error on type, got %v", err)
+	}
+}
@@ -0,0 +1,902 @@
+package client
+
+// Request and response bodies of the Train API. They mirror the schemas in
+// handlers/openapi.json; optional request fields are pointers so that zero
+// values can still be sent.
+
+// Exercise is a movement in the library
+type Exercise struct {
+	ID           int      `json:"id"`
+	Name         string   `json:"name"`
+	Type         string   `json:"type"`
+	Category     string   `json:"category,omitempty"`
+	TargetSets   *int     `json:"target_sets,omitempty"`
+	TargetReps   *int     `json:"target_reps,omitempty"`
+	TargetWeight *float64 `json:"target_weight,omitempty"`
+	CreatedAt    string   `json:"created_at"`
+}
+
+// ListOptions pages, sorts and date-filters a listing; zero fields use the
+// server defaults
+type ListOptions struct {
+	Limit  int
+	Offset int
+	// Cursor is Page.NextCursor from the previous call
+	Cursor string
+	// Sort is a field name, prefixed with "-" for descending
+	Sort string
+	// From and To are inclusive YYYY-MM-DD bounds
+	From, To string
+}
+
+// Page describes where a listing's rows sit in the full result
+type Page struct {
+	Total      int    `json:"total"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ExerciseFilter narrows ListExercises; empty fields are ignored
+type ExerciseFilter struct {
+	Search   string
+	Type     string
+	Category string
+	ListOptions
+}
+
+// ExerciseList is one page of exercises
+type ExerciseList struct {
+	Exercises []Exercise `json:"exercises"`
+	Page
+}
+
+// ExerciseInput creates an exercise
+type ExerciseInput struct {
+	Name         string   `json:"name"`
+	Type         string   `json:"type"`
+	Category     *string  `json:"category,omitempty"`
+	TargetSets   *int     `json:"target_sets,omitempty"`
+	TargetReps   *int     `json:"target_reps,omitempty"`
+	TargetWeight *float64 `json:"target_weight,omitempty"`
+}
+
+// ExerciseUpdate changes the non-nil fields of an exercise
+type ExerciseUpdate struct {
+	Name         *string  `json:"name,omitempty"`
+	Type         *string  `json:"type,omitempty"`
+	Category     *string  `json:"category,omitempty"`
+	TargetSets   *int     `json:"target_sets,omitempty"`
+	TargetReps   *int     `json:"target_reps,omitempty"`
+	TargetWeight *float64 `json:"target_weight,omitempty"`
+}
+
+// RoutineExercise is one exercise on a day's routine
+type RoutineExercise struct {
+	RoutineID            int      `json:"routine_id"`
+	ExerciseID           int      `json:"exercise_id"`
+	OrderIndex           int      `json:"order_index"`
+	Name                 string   `json:"name"`
+	Type                 string   `json:"type"`
+	Category             *string  `json:"category,omitempty"`
+	TargetSets           *int     `json:"target_sets,omitempty"`
+	TargetReps           *int     `json:"target_reps,omitempty"`
+	TargetWeight         *float64 `json:"target_weight,omitempty"`
+	Notes                *string  `json:"notes,omitempty"`
+	LastDone             *string  `json:"last_done,omitempty"`
+	ConsecutiveSuccesses int      `json:"consecutive_successes"`
+	ReadyToProgress      bool     `json:"ready_to_progress"`
+}
+
+// RoutineDay is a day's title and ordered exercises
+type RoutineDay struct {
+	Day       string            `json:"day"`
+	Title     string            `json:"title"`
+	Exercises []RoutineExercise `json:"exercises"`
+}
+
+// RoutineInput appends an exercise to a day
+type RoutineInput struct {
+	ExerciseID int     `json:"exercise_id"`
+	DayOfWeek  string  `json:"day_of_week"`
+	Notes      *string `json:"notes,omitempty"`
+}
+
+// RoutineUpdate changes the non-nil fields of a routine entry
+type RoutineUpdate struct {
+	OrderIndex *int    `json:"order_index,omitempty"`
+	Notes      *string `json:"notes,omitempty"`
+}
+
+// History is one recorded session of an exercise
+type History struct {
+	ID            int      `json:"id"`
+	ExerciseID    int      `json:"exercise_id"`
+	SessionDate   string   `json:"session_date"`
+	Weight        *float64 `json:"weight,omitempty"`
+	SetsCompleted []int    `json:"sets_completed"`
+	Completed     bool     `json:"completed"`
+	Volume        *float64 `json:"volume,omitempty"`
+	IsPR          bool     `json:"is_pr"`
+	Notes         *string  `json:"notes,omitempty"`
+	Cardio
+	// DistancePR and PacePR flag a session that went further, or faster,
+	// than every earlier one of its exercise
+	DistancePR bool `json:"distance_pr"`
+	PacePR     bool `json:"pace_pr"`
+	Load
+}
+
+// Load is a session's bodyweight-aware load, worked out by the server:
+// bodyweight plus or minus the logged weight for bodyweight and assisted
+// types, else the weight
+type Load struct {
+	Bodyweight      *float64 `json:"bodyweight,omitempty"`
+	EffectiveLoad   *float64 `json:"effective_load,omitempty"`
+	EffectiveVolume *float64 `json:"effective_volume,omitempty"`
+	E1RM            *float64 `json:"e1rm,omitempty"`
+}
+
+// Cardio is the structured cardio data of a session; pace is seconds per km
+type Cardio struct {
+	DurationSeconds  *int     `json:"duration_seconds,omitempty"`
+	DistanceKm       *float64 `json:"distance_km,omitempty"`
+	PaceSecondsPerKm *float64 `json:"pace_seconds_per_km,omitempty"`
+	AvgHeartRate     *int     `json:"avg_heart_rate,omitempty"`
+	ElevationGainM   *float64 `json:"elevation_gain_m,omitempty"`
+	Calories         *int     `json:"calories,omitempty"`
+}
+
+// HistoryList is one page of an exercise's sessions
+type HistoryList struct {
+	ExerciseID   int       `json:"exercise_id"`
+	ExerciseName string    `json:"exercise_name"`
+	History      []History `json:"history"`
+	Page
+}
+
+// HistoryQuery filters HistoryLog; empty fields are ignored
+type HistoryQuery struct {
+	// From and To are inclusive YYYY-MM-DD bounds; the server defaults to
+	// the 30 days ending today
+	From, To string
+	Category string
+	Type     string
+	// Day limits the log to one weekday, e.g. "Tuesday"
+	Day string
+	// Limit and Cursor page the log as for ListOptions
+	Limit  int
+	Cursor string
+}
+
+// LoggedSession is a session with the exercise it belongs to
+type LoggedSession struct {
+	History
+	ExerciseName string  `json:"exercise_name"`
+	ExerciseType string  `json:"exercise_type"`
+	Category     *string `json:"category,omitempty"`
+}
+
+// HistoryLogDay groups the sessions recorded on one date
+type HistoryLogDay struct {
+	Date      string          `json:"date"`
+	DayOfWeek string          `json:"day_of_week"`
+	Sessions  []LoggedSession `json:"sessions"`
+}
+
+// HistoryLog is every session in a date range, grouped by date, newest first
+type HistoryLog struct {
+	From          string          `json:"from"`
+	To            string          `json:"to"`
+	Days          []HistoryLogDay `json:"days"`
+	TotalSessions int             `json:"total_sessions"`
+	Page
+}
+
+// PersonalRecord is the session flagged as an exercise's PR
+type PersonalRecord struct {
+	Weight float64 `json:"weight"`
+	Date   string  `json:"date"`
+	Volume float64 `json:"volume"`
+	Load
+}
+
+// CardioRecord is the session holding a cardio record
+type CardioRecord struct {
+	Date string `json:"date"`
+	Cardio
+}
+
+// HistoryInput records a session. Cardio sessions may leave SetsCompleted
+// empty; the pace is derived from duration and distance when omitted.
+type HistoryInput struct {
+	ExerciseID    int      `json:"exercise_id"`
+	SessionDate   string   `json:"session_date"`
+	Weight        *float64 `json:"weight,omitempty"`
+	SetsCompleted []int    `json:"sets_completed"`
+	Completed     bool     `json:"completed"`
+	Volume        *float64 `json:"volume,omitempty"`
+	Notes         *string  `json:"notes,omitempty"`
+	// ClientID makes the create idempotent: sending it again updates the
+	// same session instead of logging another
+	ClientID string `json:"client_id,omitempty"`
+	Cardio
+}
+
+// HistoryCreated is returned by CreateHistory
+type HistoryCreated struct {
+	ID         int64  `json:"id"`
+	IsPR       bool   `json:"is_pr"`
+	DistancePR bool   `json:"distance_pr"`
+	PacePR     bool   `json:"pace_pr"`
+	Message    string `json:"message"`
+}
+
+// HistoryUpdate changes the non-nil fields of a session
+type HistoryUpdate struct {
+	Weight        *float64 `json:"weight,omitempty"`
+	SetsCompleted *[]int   `json:"sets_completed,omitempty"`
+	Completed     *bool    `json:"completed,omitempty"`
+	Volume        *float64 `json:"volume,omitempty"`
+	Notes         *string  `json:"notes,omitempty"`
+	Cardio
+}
+
+// MetricPoint is a single dated value
+type MetricPoint struct {
+	Date  string  `json:"date"`
+	Value float64 `json:"value"`
+}
+
+// MetricType is a tracked body metric with its most recent entry
+type MetricType struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Unit       string `json:"unit"`
+	Color      string `json:"color"`
+	OrderIndex int    `json:"order_index"`
+	IsDefault  bool   `json:"is_default"`
+	// Formula is set on computed metrics, which can't have entries added
+	Formula     *string      `json:"formula,omitempty"`
+	Limits      MetricLimits `json:"limits"`
+	LatestEntry *MetricPoint `json:"latest_entry,omitempty"`
+}
+
+// MetricLimits are a measured metric's plausible values; nil fields are
+// unchecked. Entries outside Min and Max are refused, and entries changing
+// faster than MaxDailyChange a day are saved with warnings.
+type MetricLimits struct {
+	Min            *float64 `json:"min,omitempty"`
+	Max            *float64 `json:"max,omitempty"`
+	MaxDailyChange *float64 `json:"max_daily_change,omitempty"`
+}
+
+// MetricTypeInput creates a metric type
+type MetricTypeInput struct {
+	Name       string `json:"name"`
+	Unit       string `json:"unit"`
+	Color      string `json:"color"`
+	OrderIndex int    `json:"order_index"`
+	// Formula makes the metric computed from others, e.g.
+	// "{Weight} / (height / 100) ^ 2"
+	Formula string        `json:"formula,omitempty"`
+	Limits  *MetricLimits `json:"limits,omitempty"`
+}
+
+// MetricTypeUpdate changes the non-nil fields of a metric type
+type MetricTypeUpdate struct {
+	Name    *string `json:"name,omitempty"`
+	Unit    *string `json:"unit,omitempty"`
+	Color   *string `json:"color,omitempty"`
+	Formula *string `json:"formula,omitempty"`
+	// Limits replaces all three limits; an empty MetricLimits clears them
+	Limits     *MetricLimits `json:"limits,omitempty"`
+	OrderIndex *int          `json:"order_index,omitempty"`
+}
+
+// MetricOrder places a metric type at a position
+type MetricOrder struct {
+	ID         int `json:"id"`
+	OrderIndex int `json:"order_index"`
+}
+
+// MetricEntry is one measurement
+type MetricEntry struct {
+	ID           int     `json:"id"`
+	MetricTypeID int     `json:"metric_type_id"`
+	EntryDate    string  `json:"entry_date"`
+	Value        float64 `json:"value"`
+	Notes        *string `json:"notes,omitempty"`
+	CreatedAt    string  `json:"created_at"`
+}
+
+// MetricEntrySaved is returned by RecordMetricEntry. Warnings describe
+// changes from the neighbouring entries faster than the metric's
+// MaxDailyChange.
+type MetricEntrySaved struct {
+	ID       int64    `json:"id"`
+	Message  string   `json:"message"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// MetricAnomaly is an entry worth reviewing: Reason is "below_min",
+// "above_max", "jump" or "outlier". Expected is the median of its neighbours and
+// Score its modified z-score; both are nil with fewer than three entries.
+type MetricAnomaly struct {
+	MetricEntry
+	Reason   string   `json:"reason"`
+	Expected *float64 `json:"expected"`
+	Score    *float64 `json:"score"`
+}
+
+// AnomalyOptions tunes the outlier test; zero values use the server
+// defaults (a score of 3.5, 7 entries either side)
+type AnomalyOptions struct {
+	Threshold float64
+	Window    int
+}
+
+// MetricEntryList is one page of a metric type's entries
+type MetricEntryList struct {
+	Entries []MetricEntry `json:"entries"`
+	Page
+}
+
+// MetricEntryInput records a measurement
+type MetricEntryInput struct {
+	MetricTypeID int     `json:"metric_type_id"`
+	EntryDate    string  `json:"entry_date"`
+	Value        float64 `json:"value"`
+	Notes        *string `json:"notes,omitempty"`
+	// ClientID makes the create idempotent, as for HistoryInput
+	ClientID string `json:"client_id,omitempty"`
+}
+
+// MetricEntryUpdate changes the non-nil fields of a measurement
+type MetricEntryUpdate struct {
+	Value     *float64 `json:"value,omitempty"`
+	EntryDate *string  `json:"entry_date,omitempty"`
+	Notes     *string  `json:"notes,omitempty"`
+}
+
+// Category groups exercises, e.g. "Legs-Push"
+type Category struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	OrderIndex    int    `json:"order_index"`
+	IsDefault     bool   `json:"is_default"`
+	ExerciseCount int    `json:"exercise_count"`
+}
+
+// CategoryInput creates a category
+type CategoryInput struct {
+	Name       string `json:"name"`
+	OrderIndex int    `json:"order_index"`
+}
+
+// CategoryUpdate changes the non-nil fields of a category
+type CategoryUpdate struct {
+	Name       *string `json:"name,omitempty"`
+	OrderIndex *int    `json:"order_index,omitempty"`
+}
+
+// CategoryOrder places a category at a position
+type CategoryOrder struct {
+	ID         int `json:"id"`
+	OrderIndex int `json:"order_index"`
+}
+
+// ExerciseType describes how sessions of a type are recorded and scored
+type ExerciseType struct {
+	Name           string `json:"name"`
+	Label          string `json:"label"`
+	UsesWeight     bool   `json:"uses_weight"`
+	SetUnit        string `json:"set_unit"`
+	PRMetric       string `json:"pr_metric"`
+	PRDirection    string `json:"pr_direction"`
+	Progression    string `json:"progression"`
+	CountsVolume   bool   `json:"counts_volume"`
+	CountsTonnage  bool   `json:"counts_tonnage"`
+	BodyweightLoad string `json:"bodyweight_load"`
+	OrderIndex     int    `json:"order_index"`
+	IsDefault      bool   `json:"is_default"`
+	ExerciseCount  int    `json:"exercise_count"`
+}
+
+// ExerciseTypeInput creates an exercise type (Name and Label required) or
+// changes the non-nil fields of one
+type ExerciseTypeInput struct {
+	Name           string  `json:"name,omitempty"`
+	Label          *string `json:"label,omitempty"`
+	UsesWeight     *bool   `json:"uses_weight,omitempty"`
+	SetUnit        *string `json:"set_unit,omitempty"`
+	PRMetric       *string `json:"pr_metric,omitempty"`
+	PRDirection    *string `json:"pr_direction,omitempty"`
+	Progression    *string `json:"progression,omitempty"`
+	CountsVolume   *bool   `json:"counts_volume,omitempty"`
+	CountsTonnage  *bool   `json:"counts_tonnage,omitempty"`
+	BodyweightLoad *string `json:"bodyweight_load,omitempty"`
+	OrderIndex     *int    `json:"order_index,omitempty"`
+}
+
+// Muscle is a muscle exercises can be mapped to
+type Muscle struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	OrderIndex    int    `json:"order_index"`
+	IsDefault     bool   `json:"is_default"`
+	ExerciseCount int    `json:"exercise_count"`
+}
+
+// MuscleInput creates a muscle
+type MuscleInput struct {
+	Name       string `json:"name"`
+	OrderIndex int    `json:"order_index"`
+}
+
+// MuscleUpdate changes the non-nil fields of a muscle
+type MuscleUpdate struct {
+	Name       *string `json:"name,omitempty"`
+	OrderIndex *int    `json:"order_index,omitempty"`
+}
+
+// ExerciseMuscle is a muscle an exercise works; Weight is the share of each
+// set credited to it
+type ExerciseMuscle struct {
+	MuscleID int     `json:"muscle_id"`
+	Name     string  `json:"name"`
+	Role     string  `json:"role"`
+	Weight   float64 `json:"weight"`
+}
+
+// ExerciseMuscleInput maps a muscle to an exercise. Role is "primary" or
+// "secondary"; a nil Weight uses 1 or 0.5 respectively.
+type ExerciseMuscleInput struct {
+	MuscleID int      `json:"muscle_id"`
+	Role     string   `json:"role"`
+	Weight   *float64 `json:"weight,omitempty"`
+}
+
+// DashboardMetric is one metric type's series on the dashboard
+type DashboardMetric struct {
+	ID      int           `json:"id"`
+	Name    string        `json:"name"`
+	Unit    string        `json:"unit"`
+	Color   string        `json:"color"`
+	Formula *string       `json:"formula,omitempty"`
+	Entries []MetricPoint `json:"entries"`
+	// Trend is set by GetDashboardTrends
+	Trend *MetricTrend `json:"trend,omitempty"`
+}
+
+// MetricTrend is a metric's smoothed series (newest first), its slope in
+// units per week and when it is projected to reach the requested goal
+type MetricTrend struct {
+	EWMA          []MetricPoint `json:"ewma"`
+	RollingMean   []MetricPoint `json:"rolling_mean"`
+	SlopePerWeek  *float64      `json:"slope_per_week"`
+	Goal          *float64      `json:"goal,omitempty"`
+	ProjectedDate *string       `json:"projected_date"`
+}
+
+// TrendOptions selects the dashboard range, the EWMA smoothing (0 for the
+// server default) and goals by metric type ID to project dates for
+type TrendOptions struct {
+	Days  int
+	Alpha float64
+	Goals map[int]float64
+}
+
+// Goal is a target for a metric (MetricTypeID set) or a lift (ExerciseID
+// set), with where it stands now
+type Goal struct {
+	ID           int          `json:"id"`
+	MetricTypeID *int         `json:"metric_type_id,omitempty"`
+	ExerciseID   *int         `json:"exercise_id,omitempty"`
+	Direction    string       `json:"direction"`
+	Target       float64      `json:"target"`
+	Reps         *int         `json:"reps,omitempty"`
+	TargetDate   *string      `json:"target_date,omitempty"`
+	StartValue   *float64     `json:"start_value,omitempty"`
+	Notes        *string      `json:"notes,omitempty"`
+	AchievedAt   *string      `json:"achieved_at,omitempty"`
+	CreatedAt    string       `json:"created_at"`
+	Kind         string       `json:"kind"`
+	Name         string       `json:"name"`
+	Unit         string       `json:"unit"`
+	Progress     GoalProgress `json:"progress"`
+}
+
+// GoalProgress is a goal's latest value, the percent of the way there
+// from its start value and when the trend is projected to reach it
+type GoalProgress struct {
+	Current     *float64 `json:"current"`
+	CurrentDate *string  `json:"current_date"`
+	Percent     *float64 `json:"percent"`
+	ETA         *string  `json:"eta"`
+	OnTrack     *bool    `json:"on_track,omitempty"`
+}
+
+// GoalInput sets a goal. Set exactly one of MetricTypeID and ExerciseID;
+// Direction is "at_most" or "at_least" and Reps defaults to 1 for lifts.
+type GoalInput struct {
+	MetricTypeID *int    `json:"metric_type_id,omitempty"`
+	ExerciseID   *int    `json:"exercise_id,omitempty"`
+	Direction    string  `json:"direction,omitempty"`
+	Target       float64 `json:"target"`
+	Reps         *int    `json:"reps,omitempty"`
+	TargetDate   *string `json:"target_date,omitempty"`
+	Notes        *string `json:"notes,omitempty"`
+}
+
+// GoalUpdate changes the non-nil fields of a goal; an empty TargetDate or
+// Notes clears it
+type GoalUpdate struct {
+	Direction  *string  `json:"direction,omitempty"`
+	Target     *float64 `json:"target,omitempty"`
+	Reps       *int     `json:"reps,omitempty"`
+	TargetDate *string  `json:"target_date,omitempty"`
+	Notes      *string  `json:"notes,omitempty"`
+}
+
+// CalendarDay is one day of the training calendar. Status is "trained",
+// "missed", "rest", "planned" or "untracked".
+type CalendarDay struct {
+	Date     string `json:"date"`
+	Status   string `json:"status"`
+	Sessions int    `json:"sessions"`
+}
+
+// CalendarWeek summarises a Monday-to-Sunday week; Adherence is nil when
+// nothing was planned yet
+type CalendarWeek struct {
+	WeekStart string   `json:"week_start"`
+	Planned   int      `json:"planned"`
+	Trained   int      `json:"trained"`
+	Missed    int      `json:"missed"`
+	Adherence *float64 `json:"adherence"`
+}
+
+// Calendar is a year of training days with streaks and adherence
+type Calendar struct {
+	Year            int            `json:"year"`
+	PlannedWeekdays []string       `json:"planned_weekdays"`
+	Days            []CalendarDay  `json:"days"`
+	Weeks           []CalendarWeek `json:"weeks"`
+	TrainedDays     int            `json:"trained_days"`
+	MissedDays      int            `json:"missed_days"`
+	Adherence       *float64       `json:"adherence"`
+	CurrentStreak   int            `json:"current_streak"`
+	LongestStreak   int            `json:"longest_streak"`
+}
+
+// VolumeTotals are hard sets, reps and tonnage (kg)
+type VolumeTotals struct {
+	Sets    int     `json:"sets"`
+	Reps    int     `json:"reps"`
+	Tonnage float64 `json:"tonnage"`
+}
+
+// CategoryVolume is the volume of one category; Category is empty for
+// uncategorised exercises
+type CategoryVolume struct {
+	Category string `json:"category"`
+	VolumeTotals
+	// WeeklySets is set on the range totals only
+	WeeklySets *float64 `json:"weekly_sets,omitempty"`
+}
+
+// ExerciseVolume is the volume of one exercise
+type ExerciseVolume struct {
+	ExerciseID int    `json:"exercise_id"`
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Category   string `json:"category"`
+	VolumeTotals
+}
+
+// VolumeWeek is the volume of a Monday-to-Sunday week
+type VolumeWeek struct {
+	WeekStart string `json:"week_start"`
+	VolumeTotals
+	Categories []CategoryVolume `json:"categories"`
+	Exercises  []ExerciseVolume `json:"exercises"`
+}
+
+// PushPullBalance compares push and pull sets for a body region; Ratio is
+// nil without pull sets
+type PushPullBalance struct {
+	Region   string   `json:"region"`
+	PushSets int      `json:"push_sets"`
+	PullSets int      `json:"pull_sets"`
+	Ratio    *float64 `json:"ratio"`
+}
+
+// Volume is training volume over a date range
+type Volume struct {
+	From       string            `json:"from"`
+	To         string            `json:"to"`
+	Weeks      []VolumeWeek      `json:"weeks"`
+	Totals     VolumeTotals      `json:"totals"`
+	Categories []CategoryVolume  `json:"categories"`
+	Exercises  []ExerciseVolume  `json:"exercises"`
+	Balance    []PushPullBalance `json:"balance"`
+}
+
+// MuscleSets is the sets credited to a muscle
+type MuscleSets struct {
+	MuscleID      int     `json:"muscle_id"`
+	Name          string  `json:"name"`
+	Sets          float64 `json:"sets"`
+	PrimarySets   float64 `json:"primary_sets"`
+	SecondarySets float64 `json:"secondary_sets"`
+	// WeeklySets is set on the range totals only
+	WeeklySets *float64 `json:"weekly_sets,omitempty"`
+}
+
+// MuscleWeek is the sets credited to each muscle worked in a week
+type MuscleWeek struct {
+	WeekStart string       `json:"week_start"`
+	Muscles   []MuscleSets `json:"muscles"`
+}
+
+// MuscleVolume is per-muscle volume over a date range
+type MuscleVolume struct {
+	From         string       `json:"from"`
+	To           string       `json:"to"`
+	Weeks        []MuscleWeek `json:"weeks"`
+	Muscles      []MuscleSets `json:"muscles"`
+	UnmappedSets int          `json:"unmapped_sets"`
+}
+
+// CardioTotals sum the cardio fields of some sessions
+type CardioTotals struct {
+	Sessions         int      `json:"sessions"`
+	DurationSeconds  int      `json:"duration_seconds"`
+	DistanceKm       float64  `json:"distance_km"`
+	ElevationGainM   float64  `json:"elevation_gain_m"`
+	Calories         int      `json:"calories"`
+	PaceSecondsPerKm *float64 `json:"pace_seconds_per_km"`
+	DistancePRs      int      `json:"distance_prs"`
+	PacePRs          int      `json:"pace_prs"`
+}
+
+// CardioWeek is the cardio totals of a Monday-to-Sunday week
+type CardioWeek struct {
+	WeekStart string `json:"week_start"`
+	CardioTotals
+}
+
+// CardioExercise is the cardio totals of one exercise
+type CardioExercise struct {
+	ExerciseID int    `json:"exercise_id"`
+	Name       string `json:"name"`
+	CardioTotals
+}
+
+// CardioStats is cardio distance and time over a date range
+type CardioStats struct {
+	From      string           `json:"from"`
+	To        string           `json:"to"`
+	Weeks     []CardioWeek     `json:"weeks"`
+	Totals    CardioTotals     `json:"totals"`
+	Exercises []CardioExercise `json:"exercises"`
+}
+
+// StrengthScores are a total of best e1RMs and its bodyweight-normalised
+// scores; nil until all three lifts, a bodyweight and the sex are known
+type StrengthScores struct {
+	Total *float64 `json:"total,omitempty"`
+	Wilks *float64 `json:"wilks,omitempty"`
+	DOTS  *float64 `json:"dots,omitempty"`
+	IPFGL *float64 `json:"ipf_gl,omitempty"`
+}
+
+// LiftStrength is a lift's best e1RM and strength standard
+type LiftStrength struct {
+	Lift            string   `json:"lift"`
+	ExerciseID      *int     `json:"exercise_id"`
+	ExerciseName    *string  `json:"exercise_name,omitempty"`
+	E1RM            *float64 `json:"e1rm,omitempty"`
+	Date            *string  `json:"date,omitempty"`
+	BodyweightRatio *float64 `json:"bodyweight_ratio,omitempty"`
+	Standard        string   `json:"standard,omitempty"`
+}
+
+// StrengthPoint is the best of each lift so far on a date one was trained
+type StrengthPoint struct {
+	Date       string   `json:"date"`
+	Bodyweight *float64 `json:"bodyweight,omitempty"`
+	Squat      *float64 `json:"squat,omitempty"`
+	Bench      *float64 `json:"bench,omitempty"`
+	Deadlift   *float64 `json:"deadlift,omitempty"`
+	StrengthScores
+}
+
+// Strength is relative strength over a date range, scored as of To
+type Strength struct {
+	From       string         `json:"from"`
+	To         string         `json:"to"`
+	Sex        *string        `json:"sex"`
+	Bodyweight *float64       `json:"bodyweight,omitempty"`
+	Lifts      []LiftStrength `json:"lifts"`
+	StrengthScores
+	Series []StrengthPoint `json:"series"`
+}
+
+// Settings are the profile settings the stats use; unset values are nil
+type Settings struct {
+	Sex      *string      `json:"sex"`
+	HeightCm *float64     `json:"height_cm"`
+	Lifts    LiftSettings `json:"lifts"`
+}
+
+// LiftSettings maps the powerlifting lifts to exercise IDs
+type LiftSettings struct {
+	Squat    *int `json:"squat,omitempty"`
+	Bench    *int `json:"bench,omitempty"`
+	Deadlift *int `json:"deadlift,omitempty"`
+}
+
+// SettingsUpdate changes the non-nil settings. An empty Sex, a HeightCm of 0
+// or a lift ID of 0 unsets it.
+type SettingsUpdate struct {
+	Sex      *string       `json:"sex,omitempty"`
+	HeightCm *float64      `json:"height_cm,omitempty"`
+	Lifts    *LiftSettings `json:"lifts,omitempty"`
+}
+
+// SyncChange is one entry of a device's change log. Data is the whole
+// record (a HistoryInput, RoutineInput or MetricEntryInput) for an upsert.
+type SyncChange struct {
+	Entity      string      `json:"entity"`
+	ClientID    string      `json:"client_id"`
+	Op          string      `json:"op"`
+	BaseVersion int         `json:"base_version"`
+	Data        interface{} `json:"data,omitempty"`
+}
+
+// SyncResult is the outcome of one change: applied, conflict or rejected
+type SyncResult struct {
+	Entity   string `json:"entity"`
+	ClientID string `json:"client_id"`
+	Status   string `json:"status"`
+	Version  int    `json:"version,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// SyncMeta is the sync metadata of a record
+type SyncMeta struct {
+	ClientID  string `json:"client_id"`
+	Version   int    `json:"version"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// SyncHistory is a synced history entry
+type SyncHistory struct {
+	SyncMeta
+	History
+}
+
+// SyncRoutine is a synced routine entry
+type SyncRoutine struct {
+	SyncMeta
+	ID         int     `json:"id"`
+	ExerciseID int     `json:"exercise_id"`
+	DayOfWeek  string  `json:"day_of_week"`
+	OrderIndex int     `json:"order_index"`
+	Notes      *string `json:"notes,omitempty"`
+}
+
+// SyncMetricEntry is a synced metric entry
+type SyncMetricEntry struct {
+	SyncMeta
+	MetricEntry
+}
+
+// SyncTombstone records a deleted record
+type SyncTombstone struct {
+	Entity    string `json:"entity"`
+	ClientID  string `json:"client_id"`
+	Version   int    `json:"version"`
+	DeletedAt string `json:"deleted_at"`
+}
+
+// SyncResponse holds the results of a sync and the server's changes since
+// the cursor; Cursor goes into the next call
+type SyncResponse struct {
+	Cursor        string            `json:"cursor"`
+	Results       []SyncResult      `json:"results"`
+	History       []SyncHistory     `json:"history"`
+	Routines      []SyncRoutine     `json:"routines"`
+	MetricEntries []SyncMetricEntry `json:"metric_entries"`
+	Deleted       []SyncTombstone   `json:"deleted"`
+}
+
+// Event is a change notification from the event stream, e.g. Type
+// "history.created" with the new session's ID
+type Event struct {
+	Type       string `json:"type"`
+	ID         int64  `json:"id,omitempty"`
+	ExerciseID int    `json:"exercise_id,omitempty"`
+	Day        string `json:"day,omitempty"`
+}
+
+// SharedSessionInput starts a shared workout; SessionDate defaults to today
+type SharedSessionInput struct {
+	DayOfWeek   string `json:"day_of_week"`
+	SessionDate string `json:"session_date,omitempty"`
+	Name        string `json:"name"`
+}
+
+// SharedSetInput records one of a participant's sets. Sending the same
+// SetNumber again corrects it.
+type SharedSetInput struct {
+	ExerciseID int      `json:"exercise_id"`
+	SetNumber  int      `json:"set_number"`
+	Reps       int      `json:"reps"`
+	Weight     *float64 `json:"weight,omitempty"`
+}
+
+// SharedExercise is an exercise on a shared session's routine
+type SharedExercise struct {
+	ExerciseID   int      `json:"exercise_id"`
+	Name         string   `json:"name"`
+	Type         string   `json:"type"`
+	TargetSets   *int     `json:"target_sets,omitempty"`
+	TargetReps   *int     `json:"target_reps,omitempty"`
+	TargetWeight *float64 `json:"target_weight,omitempty"`
+}
+
+// SharedSet is a set a participant completed
+type SharedSet struct {
+	ExerciseID  int      `json:"exercise_id"`
+	SetNumber   int      `json:"set_number"`
+	Reps        int      `json:"reps"`
+	Weight      *float64 `json:"weight,omitempty"`
+	CompletedAt string   `json:"completed_at"`
+}
+
+// SharedEntry is a participant's result for one exercise. ExerciseID is
+// the host server's; ImportSharedEntries matches ExerciseName instead.
+type SharedEntry struct {
+	ClientID      string   `json:"client_id"`
+	ExerciseID    int      `json:"exercise_id"`
+	ExerciseName  string   `json:"exercise_name"`
+	SessionDate   string   `json:"session_date"`
+	Weight        *float64 `json:"weight,omitempty"`
+	SetsCompleted []int    `json:"sets_completed"`
+	Completed     bool     `json:"completed"`
+	Volume        *float64 `json:"volume,omitempty"`
+	HistoryID     *int64   `json:"history_id,omitempty"`
+	IsPR          bool     `json:"is_pr,omitempty"`
+}
+
+// SharedParticipant is someone training in a shared session; Entries are
+// set once the session is finished
+type SharedParticipant struct {
+	ID      int           `json:"id"`
+	Name    string        `json:"name"`
+	IsHost  bool          `json:"is_host"`
+	Sets    []SharedSet   `json:"sets"`
+	Entries []SharedEntry `json:"entries,omitempty"`
+}
+
+// SharedSession is a shared workout and everyone's progress in it
+type SharedSession struct {
+	ID           int                 `json:"id"`
+	Code         string              `json:"code"`
+	DayOfWeek    string              `json:"day_of_week"`
+	SessionDate  string              `json:"session_date"`
+	FinishedAt   *string             `json:"finished_at,omitempty"`
+	Exercises    []SharedExercise    `json:"exercises"`
+	Participants []SharedParticipant `json:"participants"`
+}
+
+// SharedSessionJoined is returned when starting or joining a session. Token
+// is needed to record sets.
+type SharedSessionJoined struct {
+	ParticipantID int64         `json:"participant_id"`
+	Token         string        `json:"token"`
+	Session       SharedSession `json:"session"`
+}
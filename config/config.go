@@ -0,0 +1,397 @@
+// Package config loads server settings from built-in defaults, an optional
+// config file, TRAIN_* environment variables and command-line flags, in that
+// order of increasing precedence.
+package config
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds everything main needs to wire up the server
+type Config struct {
+	Addr           string
+	DBPath         string
+	LegacyJSONPath string
+	BackupDir      string
+	LogLevel       string
+	LogFormat      string
+	PublicDir      string
+	TLSCert        string
+	TLSKey         string
+	// TLSHostnames, when set without TLSCert/TLSKey, makes the server issue
+	// its own certificate for these names from a local CA kept in TLSDir
+	// (by default a tls directory next to the database, see CertDir)
+	TLSHostnames []string
+	TLSDir       string
+	// HTTPRedirectAddr, when set alongside TLS, serves plain HTTP there that
+	// redirects every request to HTTPS
+	HTTPRedirectAddr string
+	Timeouts         Timeouts
+	Features         Features
+}
+
+// Timeouts bound how long the HTTP server waits on clients
+type Timeouts struct {
+	ReadHeader time.Duration
+	Read       time.Duration
+	Write      time.Duration
+	Idle       time.Duration
+	// Drain is how long /readyz reports 503 on SIGTERM before the listeners
+	// close, so load balancers polling it stop sending new requests
+	Drain time.Duration
+	// Shutdown is how long in-flight requests get to finish on SIGTERM
+	Shutdown time.Duration
+}
+
+// Features are optional behaviours that can be switched off per deployment
+type Features struct {
+	// PlanImport allows POST /api/plan to replace routines from pasted text
+	PlanImport bool
+	// LegacyMigration imports train.json on first start when no database exists
+	LegacyMigration bool
+	// Metrics exposes Prometheus-format request and database stats at /metrics
+	Metrics bool
+}
+
+// Default returns the settings used when nothing else is configured
+func Default() Config {
+	return Config{
+		Addr:           ":3001", // Keeping same port as old server for ease
+		DBPath:         "train.db",
+		LegacyJSONPath: "train.json",
+		BackupDir:      ".",
+		LogLevel:       "info",
+		LogFormat:      "text",
+		Timeouts: Timeouts{
+			ReadHeader: 5 * time.Second,
+			Read:       15 * time.Second,
+			Write:      30 * time.Second,
+			Idle:       2 * time.Minute,
+			Shutdown:   15 * time.Second,
+		},
+		Features: Features{
+			PlanImport:      true,
+			LegacyMigration: true,
+			Metrics:         true,
+		},
+	}
+}
+
+// setting describes one configurable key. The same key is used in config
+// files (dotted for sections), upper-cased with a TRAIN_ prefix for the
+// environment, and dashed for flags.
+type setting struct {
+	key   string
+	usage string
+	get   func(*Config) string
+	set   func(*Config, string) error
+}
+
+func stringSetting(key, usage string, field func(*Config) *string) setting {
+	return setting{
+		key:   key,
+		usage: usage,
+		get:   func(c *Config) string { return *field(c) },
+		set:   func(c *Config, v string) error { *field(c) = v; return nil },
+	}
+}
+
+func boolSetting(key, usage string, field func(*Config) *bool) setting {
+	return setting{
+		key:   key,
+		usage: usage,
+		get:   func(c *Config) string { return strconv.FormatBool(*field(c)) },
+		set: func(c *Config, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("%s: expected true or false, got %q", key, v)
+			}
+			*field(c) = b
+			return nil
+		},
+	}
+}
+
+func listSetting(key, usage string, field func(*Config) *[]string) setting {
+	return setting{
+		key:   key,
+		usage: usage + " (comma-separated)",
+		get:   func(c *Config) string { return strings.Join(*field(c), ",") },
+		set: func(c *Config, v string) error {
+			var items []string
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(unquote(strings.TrimSpace(item))); item != "" {
+					items = append(items, item)
+				}
+			}
+			*field(c) = items
+			return nil
+		},
+	}
+}
+
+func durationSetting(key, usage string, field func(*Config) *time.Duration) setting {
+	return setting{
+		key:   key,
+		usage: usage,
+		get:   func(c *Config) string { return field(c).String() },
+		set: func(c *Config, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("%s: expected a duration like 30s, got %q", key, v)
+			}
+			*field(c) = d
+			return nil
+		},
+	}
+}
+
+var settings = []setting{
+	stringSetting("addr", "listen address", func(c *Config) *string { return &c.Addr }),
+	stringSetting("db_path", "SQLite database file", func(c *Config) *string { return &c.DBPath }),
+	stringSetting("legacy_json_path", "legacy train.json to import on first start", func(c *Config) *string { return &c.LegacyJSONPath }),
+	stringSetting("backup_dir", "directory for backups", func(c *Config) *string { return &c.BackupDir }),
+	stringSetting("log_level", "log level: debug, info, warn or error", func(c *Config) *string { return &c.LogLevel }),
+	stringSetting("log_format", "log output format: text or json", func(c *Config) *string { return &c.LogFormat }),
+	stringSetting("public_dir", "serve frontend files from this directory instead of the embedded copy", func(c *Config) *string { return &c.PublicDir }),
+	stringSetting("tls_cert", "TLS certificate file (PEM)", func(c *Config) *string { return &c.TLSCert }),
+	stringSetting("tls_key", "TLS private key file (PEM)", func(c *Config) *string { return &c.TLSKey }),
+	listSetting("tls_hostnames", "hostnames/IPs to issue a certificate for from the local CA", func(c *Config) *[]string { return &c.TLSHostnames }),
+	stringSetting("tls_dir", "directory for the local CA and issued certificates (default tls next to db_path)", func(c *Config) *string { return &c.TLSDir }),
+	stringSetting("http_redirect_addr", "plain HTTP listen address that redirects to HTTPS", func(c *Config) *string { return &c.HTTPRedirectAddr }),
+	durationSetting("timeouts.read_header", "time allowed to read request headers", func(c *Config) *time.Duration { return &c.Timeouts.ReadHeader }),
+	durationSetting("timeouts.read", "time allowed to read a whole request", func(c *Config) *time.Duration { return &c.Timeouts.Read }),
+	durationSetting("timeouts.write", "time allowed to write a response", func(c *Config) *time.Duration { return &c.Timeouts.Write }),
+	durationSetting("timeouts.idle", "keep-alive idle timeout", func(c *Config) *time.Duration { return &c.Timeouts.Idle }),
+	durationSetting("timeouts.drain", "time /readyz fails on shutdown before connections stop being accepted", func(c *Config) *time.Duration { return &c.Timeouts.Drain }),
+	durationSetting("timeouts.shutdown", "time in-flight requests get to finish on shutdown", func(c *Config) *time.Duration { return &c.Timeouts.Shutdown }),
+	boolSetting("features.plan_import", "allow replacing routines via POST /api/plan", func(c *Config) *bool { return &c.Features.PlanImport }),
+	boolSetting("features.legacy_migration", "import train.json when no database exists", func(c *Config) *bool { return &c.Features.LegacyMigration }),
+	boolSetting("features.metrics", "serve Prometheus metrics at /metrics", func(c *Config) *bool { return &c.Features.Metrics }),
+}
+
+func lookup(key string) (setting, bool) {
+	for _, s := range settings {
+		if s.key == key {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+func envName(key string) string {
+	return "TRAIN_" + strings.ToUpper(strings.NewReplacer(".", "_").Replace(key))
+}
+
+func flagName(key string) string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(key)
+}
+
+// Load builds the configuration for a process started with the given
+// arguments (excluding the program name). The config file is taken from
+// -config or TRAIN_CONFIG.
+func Load(args []string) (Config, error) {
+	return load(args, os.LookupEnv)
+}
+
+func load(args []string, getenv func(string) (string, bool)) (Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("train", flag.ContinueOnError)
+	configPath := fs.String("config", "", "config file (.toml, .yaml or .yml)")
+	flagValues := map[string]*string{}
+	for _, s := range settings {
+		flagValues[s.key] = fs.String(flagName(s.key), s.get(&cfg), s.usage+" (env "+envName(s.key)+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	path := *configPath
+	if path == "" {
+		path, _ = getenv("TRAIN_CONFIG")
+	}
+	if path != "" {
+		if err := applyFile(&cfg, path); err != nil {
+			return cfg, err
+		}
+	}
+
+	for _, s := range settings {
+		if v, ok := getenv(envName(s.key)); ok {
+			if err := s.set(&cfg, v); err != nil {
+				return cfg, fmt.Errorf("%s: %w", envName(s.key), err)
+			}
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if flagName(s.key) == f.Name && flagErr == nil {
+				flagErr = s.set(&cfg, *flagValues[s.key])
+			}
+		}
+	})
+	if flagErr != nil {
+		return cfg, flagErr
+	}
+
+	if _, err := cfg.SlogLevel(); err != nil {
+		return cfg, err
+	}
+	if cfg.LogFormat != "text" && cfg.LogFormat != "json" {
+		return cfg, fmt.Errorf("log_format: expected text or json, got %q", cfg.LogFormat)
+	}
+	return cfg, nil
+}
+
+// Logger builds the process-wide logger from LogLevel and LogFormat
+func (c Config) Logger(w io.Writer) *slog.Logger {
+	level, _ := c.SlogLevel()
+	opts := &slog.HandlerOptions{Level: level}
+	if c.LogFormat == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// SlogLevel converts LogLevel for use with log/slog
+func (c Config) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return slog.LevelInfo, fmt.Errorf("log_level: %w", err)
+	}
+	return level, nil
+}
+
+// TLSEnabled reports whether the server should listen with HTTPS
+func (c Config) TLSEnabled() bool {
+	return (c.TLSCert != "" && c.TLSKey != "") || len(c.TLSHostnames) > 0
+}
+
+// CertDir returns the directory for the local CA and issued certificates:
+// TLSDir, or a tls directory beside the database so the CA key is kept with
+// the data rather than the source
+func (c Config) CertDir() string {
+	if c.TLSDir != "" {
+		return c.TLSDir
+	}
+	return filepath.Join(filepath.Dir(c.DBPath), "tls")
+}
+
+// BackupPath returns the location for a backup file with the given name
+func (c Config) BackupPath(name string) string {
+	return filepath.Join(c.BackupDir, name)
+}
+
+func applyFile(cfg *Config, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer f.Close()
+
+	values, err := parseFile(f, strings.ToLower(filepath.Ext(path)))
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for key, v := range values {
+		s, ok := lookup(key)
+		if !ok {
+			return fmt.Errorf("%s: unknown setting %q", path, key)
+		}
+		if err := s.set(cfg, v); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// parseFile reads the flat subset of TOML and YAML the config needs: scalar
+// keys, optionally grouped under one level of [section] (TOML) or an
+// indented "section:" block (YAML). Keys are returned dotted, e.g.
+// "features.plan_import".
+func parseFile(r io.Reader, ext string) (map[string]string, error) {
+	sep := "="
+	switch ext {
+	case ".toml":
+	case ".yaml", ".yml":
+		sep = ":"
+	default:
+		return nil, fmt.Errorf("unsupported config format %q (use .toml, .yaml or .yml)", ext)
+	}
+
+	values := map[string]string{}
+	section := ""
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		raw := scanner.Text()
+		line := strings.TrimSpace(stripComment(raw))
+		if line == "" || line == "---" {
+			continue
+		}
+
+		if sep == "=" && strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, sep)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key %s value", lineNo, sep)
+		}
+		key = strings.TrimSpace(key)
+		value = unquote(strings.TrimSpace(value))
+
+		if sep == ":" {
+			indented := raw != strings.TrimLeft(raw, " \t")
+			if !indented {
+				section = ""
+				if value == "" {
+					section = key // start of a nested block
+					continue
+				}
+			}
+		}
+
+		if section != "" {
+			key = section + "." + key
+		}
+		values[key] = value
+	}
+	return values, scanner.Err()
+}
+
+func stripComment(line string) string {
+	inQuote := byte(0)
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case inQuote != 0:
+			if c == inQuote {
+				inQuote = 0
+			}
+		case c == '"' || c == '\'':
+			inQuote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func unquote(v string) string {
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
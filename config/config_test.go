@@ -0,0 +1,121 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(k string) (string, bool) {
+		v, ok := vars[k]
+		return v, ok
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := load(nil, env(nil))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !reflect.DeepEqual(cfg, Default()) {
+		t.Errorf("expected defaults, got %+v", cfg)
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeFile(t, "train.toml", `
+addr = ":4000"
+db_path = "/var/lib/train/file.db"
+log_level = "debug" # comment
+
+[features]
+plan_import = false
+`)
+	cfg, err := load(
+		[]string{"-config", path, "-db-path", "/flag.db"},
+		env(map[string]string{"TRAIN_ADDR": ":5000", "TRAIN_DB_PATH": "/env.db"}),
+	)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Addr != ":5000" {
+		t.Errorf("env should override file, got addr %q", cfg.Addr)
+	}
+	if cfg.DBPath != "/flag.db" {
+		t.Errorf("flag should override env, got db_path %q", cfg.DBPath)
+	}
+	if cfg.LogLevel != "debug" {
+		t.Errorf("file value should apply, got log_level %q", cfg.LogLevel)
+	}
+	if cfg.Features.PlanImport {
+		t.Errorf("features.plan_import should be disabled by file")
+	}
+}
+
+func TestConfig_CertDirDefaultsBesideDatabase(t *testing.T) {
+	cfg := Default()
+	cfg.DBPath = "/var/lib/train/train.db"
+	if got := cfg.CertDir(); got != filepath.Join("/var/lib/train", "tls") {
+		t.Errorf("expected tls beside the database, got %q", got)
+	}
+	cfg.TLSDir = "/etc/train/tls"
+	if got := cfg.CertDir(); got != "/etc/train/tls" {
+		t.Errorf("expected tls_dir to win, got %q", got)
+	}
+}
+
+func TestLoad_YAMLFile(t *testing.T) {
+	path := writeFile(t, "train.yaml", `
+addr: ":4000"
+features:
+  legacy_migration: false
+tls_cert: /etc/train/cert.pem
+tls_hostnames: "train.lan, 192.168.1.119"
+`)
+	cfg, err := load([]string{"-config", path}, env(nil))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Addr != ":4000" || cfg.TLSCert != "/etc/train/cert.pem" {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if cfg.Features.LegacyMigration {
+		t.Errorf("features.legacy_migration should be disabled by file")
+	}
+	if want := []string{"train.lan", "192.168.1.119"}; !reflect.DeepEqual(cfg.TLSHostnames, want) {
+		t.Errorf("tls_hostnames = %q, want %q", cfg.TLSHostnames, want)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	cases := map[string]func() error{
+		"unknown file key": func() error {
+			_, err := load([]string{"-config", writeFile(t, "c.toml", "port = 1")}, env(nil))
+			return err
+		},
+		"bad bool env": func() error {
+			_, err := load(nil, env(map[string]string{"TRAIN_FEATURES_PLAN_IMPORT": "maybe"}))
+			return err
+		},
+		"bad log level": func() error {
+			_, err := load([]string{"-log-level", "loud"}, env(nil))
+			return err
+		},
+	}
+	for name, fn := range cases {
+		if fn() == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
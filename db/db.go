@@ -1,35 +1,43 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	_ "embed"
 	"encoding/json"
 	"fmt"
-	"os"
+	"math"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
 
-const dbPath = "train.db"
+// schemaSQL is the canonical schema, compiled into the binary so the server
+// does not depend on db/schema.sql being present in the working directory.
+//
+//go:embed schema.sql
+var schemaSQL string
 
 // DB wraps the sql.DB connection
 type DB struct {
 	*sql.DB
 }
 
-// Open opens the database connection and initializes the schema
-func Open() (*DB, error) {
-	db, err := sql.Open("sqlite", dbPath)
+// Open opens the database file at path and initializes the schema
+func Open(path string) (*DB, error) {
+	// Pragmas go in the DSN so every pooled connection gets them, not just
+	// the first. WAL lets health checks and reads proceed while a write is in
+	// progress; the busy timeout makes concurrent writers wait instead of
+	// failing.
+	dsn := path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	// Enable foreign keys
-	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
-	}
-
 	// Initialize schema
 	if err := initSchema(db); err != nil {
 		db.Close()
@@ -39,14 +47,31 @@ func Open() (*DB, error) {
 	return &DB{db}, nil
 }
 
+// Checkpoint folds the write-ahead log back into the main database file so
+// the .db file is complete on its own after shutdown
+func (db *DB) Checkpoint() error {
+	if _, err := db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return fmt.Errorf("failed to checkpoint database: %w", err)
+	}
+	return nil
+}
+
 // initSchema creates all tables and indexes
 func initSchema(db *sql.DB) error {
-	schema, err := os.ReadFile("db/schema.sql")
-	if err != nil {
-		return fmt.Errorf("failed to read schema file: %w", err)
+	// The exercise type and metric type seeds in schema.sql name
+	// bodyweight_load, formula and is_bodyweight, so existing tables need
+	// the columns before the schema runs
+	if err := migrateBodyweightLoad(db); err != nil {
+		return fmt.Errorf("failed to migrate bodyweight load: %w", err)
+	}
+	if err := migrateMetricFormulas(db); err != nil {
+		return fmt.Errorf("failed to migrate metric formulas: %w", err)
+	}
+	if err := migrateBodyweightMetric(db); err != nil {
+		return fmt.Errorf("failed to migrate bodyweight metric: %w", err)
 	}
 
-	_, err = db.Exec(string(schema))
+	_, err := db.Exec(schemaSQL)
 	if err != nil {
 		return fmt.Errorf("failed to execute schema: %w", err)
 	}
@@ -56,46 +81,74 @@ func initSchema(db *sql.DB) error {
 		return fmt.Errorf("failed to migrate targets: %w", err)
 	}
 
-	// Run migration to add 'assisted' to exercise type constraint
-	if err := migrateExerciseTypeConstraint(db); err != nil {
-		return fmt.Errorf("failed to migrate exercise type constraint: %w", err)
+	// Run migration to replace the type/category CHECK constraints with
+	// references to the exercise_types and categories tables
+	if err := migrateExerciseTaxonomy(db); err != nil {
+		return fmt.Errorf("failed to migrate exercise taxonomy: %w", err)
+	}
+
+	// Run migration to add the structured cardio columns to history
+	if err := migrateHistoryCardio(db); err != nil {
+		return fmt.Errorf("failed to migrate history cardio columns: %w", err)
+	}
+
+	// Run migration to add the sync metadata columns and the triggers that
+	// maintain them
+	if err := migrateSyncColumns(db); err != nil {
+		return fmt.Errorf("failed to migrate sync columns: %w", err)
+	}
+
+	// Run migration to add the metric type limit columns
+	if err := migrateMetricLimits(db); err != nil {
+		return fmt.Errorf("failed to migrate metric limits: %w", err)
 	}
 
 	return nil
 }
 
-// migrateExerciseTypeConstraint recreates the exercises table if the CHECK
-// constraint does not yet include the 'assisted' type.
-func migrateExerciseTypeConstraint(db *sql.DB) error {
+// migrateExerciseTaxonomy rebuilds an exercises table whose type and
+// category are still fixed by CHECK constraints, so that they reference the
+// exercise_types and categories tables instead. schema.sql has already
+// created and seeded those tables with the values the constraints allowed.
+func migrateExerciseTaxonomy(db *sql.DB) error {
 	var createSQL string
 	err := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type='table' AND name='exercises'`).Scan(&createSQL)
 	if err != nil {
-		return nil // Fresh DB; schema.sql already has the correct constraint.
+		return nil // Fresh DB; schema.sql already has the current definition.
 	}
-	if strings.Contains(createSQL, "timed_hold") {
+	if !strings.Contains(createSQL, "CHECK(type") {
 		return nil // Already up to date.
 	}
 
-	// Disable FK checks for the duration of the table swap.
-	if _, err := db.Exec(`PRAGMA foreign_keys = OFF`); err != nil {
+	// PRAGMA foreign_keys applies per connection, so the whole swap runs on
+	// one connection; with foreign keys on, dropping exercises would cascade
+	// to routines and history.
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to reserve connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF`); err != nil {
 		return fmt.Errorf("failed to disable foreign keys: %w", err)
 	}
-	defer db.Exec(`PRAGMA foreign_keys = ON`)
+	defer conn.ExecContext(ctx, `PRAGMA foreign_keys = ON`)
 
 	stmts := []string{
 		`DROP TABLE IF EXISTS exercises_new`,
 		`CREATE TABLE exercises_new (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			name TEXT NOT NULL UNIQUE,
-			type TEXT NOT NULL CHECK(type IN ('cardio', 'weight', 'bodyweight', 'assisted', 'carry', 'timed_hold')),
-			category TEXT CHECK(category IN ('Legs-Push', 'Legs-Pull', 'Arms-Push', 'Arms-Pull', 'Core-Push', 'Core-Pull')),
+			type TEXT NOT NULL REFERENCES exercise_types(name),
+			category TEXT REFERENCES categories(name) ON UPDATE CASCADE ON DELETE SET NULL,
 			target_sets INTEGER,
 			target_reps INTEGER,
 			target_weight REAL,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)`,
-		`INSERT INTO exercises_new SELECT id, name, type, category, target_sets, target_reps, target_weight, created_at, updated_at FROM exercises`,
+		`INSERT INTO exercises_new SELECT id, name, type, NULLIF(category, ''), target_sets, target_reps, target_weight, created_at, updated_at FROM exercises`,
 		`DROP TABLE exercises`,
 		`ALTER TABLE exercises_new RENAME TO exercises`,
 		`CREATE INDEX IF NOT EXISTS idx_exercises_name ON exercises(name)`,
@@ -103,70 +156,210 @@ func migrateExerciseTypeConstraint(db *sql.DB) error {
 		`CREATE INDEX IF NOT EXISTS idx_exercises_category ON exercises(category)`,
 	}
 	for _, stmt := range stmts {
-		if _, err := db.Exec(stmt); err != nil {
-			return fmt.Errorf("failed to migrate exercise type constraint: %w", err)
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to migrate exercise taxonomy: %w", err)
 		}
 	}
 	return nil
 }
 
 // OpenForTesting opens an in-memory SQLite database suitable for unit tests.
+// The embedded schema is applied, so tests see the same tables as production.
 func OpenForTesting() (*DB, error) {
 	db, err := sql.Open("sqlite", ":memory:")
 	if err != nil {
 		return nil, fmt.Errorf("failed to open test database: %w", err)
 	}
+	// Every connection to ":memory:" gets its own empty database, so pin the
+	// pool to a single connection.
+	db.SetMaxOpenConns(1)
 	if _, err := db.Exec(`PRAGMA foreign_keys = ON`); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
 	}
-	// Inline the schema for tests (no file I/O dependency).
-	schemaStmts := []string{
-		`CREATE TABLE IF NOT EXISTS exercises (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL UNIQUE,
-			type TEXT NOT NULL CHECK(type IN ('cardio', 'weight', 'bodyweight', 'assisted', 'carry', 'timed_hold')),
-			category TEXT,
-			target_sets INTEGER,
-			target_reps INTEGER,
-			target_weight REAL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS history (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			exercise_id INTEGER NOT NULL,
-			session_date DATE NOT NULL,
-			weight REAL,
-			sets_completed TEXT NOT NULL,
-			completed BOOLEAN NOT NULL DEFAULT 0,
-			volume REAL,
-			is_pr BOOLEAN DEFAULT 0,
-			notes TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (exercise_id) REFERENCES exercises(id) ON DELETE CASCADE
-		)`,
-		`CREATE TABLE IF NOT EXISTS routines (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			exercise_id INTEGER NOT NULL,
-			day_of_week TEXT NOT NULL,
-			order_index INTEGER NOT NULL,
-			notes TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (exercise_id) REFERENCES exercises(id) ON DELETE CASCADE
-		)`,
-		`CREATE TABLE IF NOT EXISTS day_titles (
-			day_of_week TEXT PRIMARY KEY,
-			title TEXT NOT NULL DEFAULT ''
-		)`,
+	if err := initSchema(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to init test schema: %w", err)
+	}
+	return &DB{db}, nil
+}
+
+// migrateHistoryCardio adds the structured cardio columns to a history
+// table created before they existed
+func migrateHistoryCardio(db *sql.DB) error {
+	for _, col := range []struct{ name, def string }{
+		{"duration_seconds", "INTEGER"},
+		{"distance_km", "REAL"},
+		{"pace_seconds_per_km", "REAL"},
+		{"avg_heart_rate", "INTEGER"},
+		{"elevation_gain_m", "REAL"},
+		{"calories", "INTEGER"},
+	} {
+		var exists int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('history') WHERE name = ?`, col.name).Scan(&exists); err != nil {
+			return err
+		}
+		if exists > 0 {
+			continue
+		}
+		if _, err := db.Exec("ALTER TABLE history ADD COLUMN " + col.name + " " + col.def); err != nil {
+			return fmt.Errorf("failed to add column: %w", err)
+		}
+	}
+	return nil
+}
+
+// SyncTables lists the tables the sync API exchanges. Each doubles as the
+// entity name in sync requests and tombstones.
+var SyncTables = []string{"history", "routines", "metric_entries"}
+
+// SyncNow is the SQL expression for sync timestamps. Millisecond precision
+// keeps them ordered as strings.
+const SyncNow = `strftime('%Y-%m-%dT%H:%M:%fZ', 'now')`
+
+// migrateSyncColumns adds client_id, version and updated_at to the synced
+// tables, backfills them and installs the triggers that keep them current:
+// every insert gets a client_id, every update that doesn't set the version
+// itself bumps it, and every delete leaves a tombstone. Going through
+// triggers keeps the REST endpoints, cascades and plan import sync-aware
+// without each of them having to remember to.
+func migrateSyncColumns(db *sql.DB) error {
+	for _, table := range SyncTables {
+		for _, col := range []struct{ name, def string }{
+			{"client_id", "TEXT"},
+			{"version", "INTEGER NOT NULL DEFAULT 1"},
+			{"updated_at", "TEXT"},
+		} {
+			var exists int
+			if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, col.name).Scan(&exists); err != nil {
+				return err
+			}
+			if exists > 0 {
+				continue
+			}
+			if _, err := db.Exec("ALTER TABLE " + table + " ADD COLUMN " + col.name + " " + col.def); err != nil {
+				return fmt.Errorf("failed to add column: %w", err)
+			}
+		}
+
+		stmts := []string{
+			`UPDATE ` + table + ` SET client_id = lower(hex(randomblob(16))) WHERE client_id IS NULL`,
+			`UPDATE ` + table + ` SET updated_at = ` + SyncNow + ` WHERE updated_at IS NULL`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS idx_` + table + `_client_id ON ` + table + `(client_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_` + table + `_updated_at ON ` + table + `(updated_at)`,
+			// Rows inserted without sync metadata get it here. The nested
+			// update leaves version alone, and the update trigger skips rows
+			// that had no updated_at yet, so a new row starts at version 1.
+			`CREATE TRIGGER IF NOT EXISTS ` + table + `_sync_insert AFTER INSERT ON ` + table + `
+			WHEN NEW.client_id IS NULL OR NEW.updated_at IS NULL
+			BEGIN
+				UPDATE ` + table + ` SET client_id = COALESCE(NEW.client_id, lower(hex(randomblob(16)))),
+					updated_at = COALESCE(NEW.updated_at, ` + SyncNow + `)
+				WHERE id = NEW.id;
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS ` + table + `_sync_update AFTER UPDATE ON ` + table + `
+			WHEN OLD.updated_at IS NOT NULL AND NEW.version = OLD.version
+			BEGIN
+				UPDATE ` + table + ` SET version = OLD.version + 1, updated_at = ` + SyncNow + ` WHERE id = NEW.id;
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS ` + table + `_sync_delete AFTER DELETE ON ` + table + `
+			WHEN OLD.client_id IS NOT NULL
+			BEGIN
+				INSERT OR REPLACE INTO sync_tombstones (entity, client_id, version, deleted_at)
+				VALUES ('` + table + `', OLD.client_id, OLD.version + 1, ` + SyncNow + `);
+			END`,
+		}
+		for _, stmt := range stmts {
+			if _, err := db.Exec(stmt); err != nil {
+				return fmt.Errorf("failed to migrate %s sync metadata: %w", table, err)
+			}
+		}
+	}
+	return nil
+}
+
+// migrateBodyweightLoad adds bodyweight_load to an exercise_types table
+// created before it existed and sets it on the built-in bodyweight and
+// assisted types. It only runs when the column is added, so a user's later
+// choice for those types is kept. A new database gets the column and the
+// seeded values from schema.sql.
+func migrateBodyweightLoad(db *sql.DB) error {
+	var columns, exists int
+	if err := db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(name = 'bodyweight_load'), 0) FROM pragma_table_info('exercise_types')`).Scan(&columns, &exists); err != nil {
+		return err
 	}
-	for _, stmt := range schemaStmts {
+	if columns == 0 || exists > 0 {
+		return nil
+	}
+	stmts := []string{
+		`ALTER TABLE exercise_types ADD COLUMN bodyweight_load TEXT NOT NULL DEFAULT 'none'
+			CHECK(bodyweight_load IN ('none', 'add', 'subtract'))`,
+		`UPDATE exercise_types SET bodyweight_load = 'add', uses_weight = 1 WHERE name = 'bodyweight' AND is_default = 1`,
+		`UPDATE exercise_types SET bodyweight_load = 'subtract' WHERE name = 'assisted' AND is_default = 1`,
+	}
+	for _, stmt := range stmts {
 		if _, err := db.Exec(stmt); err != nil {
-			db.Close()
-			return nil, fmt.Errorf("failed to init test schema: %w", err)
+			return fmt.Errorf("failed to add bodyweight_load: %w", err)
 		}
 	}
-	return &DB{db}, nil
+	return nil
+}
+
+// migrateMetricFormulas adds formula to a metric_types table created before
+// computed metrics existed. schema.sql then seeds the computed defaults.
+func migrateMetricFormulas(db *sql.DB) error {
+	var columns, exists int
+	if err := db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(name = 'formula'), 0) FROM pragma_table_info('metric_types')`).Scan(&columns, &exists); err != nil {
+		return err
+	}
+	if columns == 0 || exists > 0 {
+		return nil
+	}
+	if _, err := db.Exec(`ALTER TABLE metric_types ADD COLUMN formula TEXT`); err != nil {
+		return fmt.Errorf("failed to add formula: %w", err)
+	}
+	return nil
+}
+
+// migrateBodyweightMetric adds is_bodyweight to a metric_types table created
+// before it existed and sets it on the default Weight metric, which
+// bodyweight was looked up by until then
+func migrateBodyweightMetric(db *sql.DB) error {
+	var columns, exists int
+	if err := db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(name = 'is_bodyweight'), 0) FROM pragma_table_info('metric_types')`).Scan(&columns, &exists); err != nil {
+		return err
+	}
+	if columns == 0 || exists > 0 {
+		return nil
+	}
+	stmts := []string{
+		`ALTER TABLE metric_types ADD COLUMN is_bodyweight BOOLEAN NOT NULL DEFAULT 0`,
+		`UPDATE metric_types SET is_bodyweight = 1 WHERE name = 'Weight'`,
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to add is_bodyweight: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateMetricLimits adds the limit columns to a metric_types table
+// created before they existed
+func migrateMetricLimits(db *sql.DB) error {
+	for _, col := range []string{"min_value", "max_value", "max_daily_change"} {
+		var exists int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('metric_types') WHERE name = ?`, col).Scan(&exists); err != nil {
+			return err
+		}
+		if exists > 0 {
+			continue
+		}
+		if _, err := db.Exec("ALTER TABLE metric_types ADD COLUMN " + col + " REAL"); err != nil {
+			return fmt.Errorf("failed to add column: %w", err)
+		}
+	}
+	return nil
 }
 
 // migrateTargetsToExercises moves target_sets/reps/weight from routines to exercises (one-time)
@@ -309,6 +502,74 @@ type History struct {
 	Volume        *float64 `json:"volume,omitempty"`
 	IsPR          bool     `json:"is_pr"`
 	Notes         *string  `json:"notes,omitempty"`
+	Cardio
+	CardioPRs
+	Load
+}
+
+// CardioPRs flags a session that went further, or faster, than every
+// earlier session of its exercise. Like Load they are worked out when
+// history is read (see CardioPRSQL), so edits and deletions re-rank the
+// sessions after them.
+type CardioPRs struct {
+	DistancePR bool `json:"distance_pr,omitempty"`
+	PacePR     bool `json:"pace_pr,omitempty"`
+}
+
+// ScanFields returns pointers to p's fields in CardioPRSQL order
+func (p *CardioPRs) ScanFields() []interface{} {
+	return []interface{}{&p.DistancePR, &p.PacePR}
+}
+
+// CardioPRSQL returns the SQL expressions for the CardioPRs of the history
+// row named table, comma-separated. Earlier sessions are those on an
+// earlier date, or logged before it on the same date.
+func CardioPRSQL(table string) string {
+	earlier := `SELECT 1 FROM history earlier WHERE earlier.exercise_id = ` + table + `.exercise_id
+		AND (earlier.session_date < ` + table + `.session_date OR (earlier.session_date = ` + table + `.session_date AND earlier.id < ` + table + `.id))`
+	return `(COALESCE(` + table + `.distance_km, 0) > 0 AND NOT EXISTS (` + earlier + `
+			AND earlier.distance_km >= ` + table + `.distance_km)),
+		(COALESCE(` + table + `.pace_seconds_per_km, 0) > 0 AND NOT EXISTS (` + earlier + `
+			AND earlier.pace_seconds_per_km > 0 AND earlier.pace_seconds_per_km <= ` + table + `.pace_seconds_per_km))`
+}
+
+// GetCardioPRs returns the CardioPRs of a history entry
+func (db *DB) GetCardioPRs(id int64) (CardioPRs, error) {
+	var prs CardioPRs
+	err := db.QueryRow(`SELECT `+CardioPRSQL("history")+` FROM history WHERE id = ?`, id).Scan(prs.ScanFields()...)
+	if err != nil {
+		return CardioPRs{}, fmt.Errorf("failed to get cardio records: %w", err)
+	}
+	return prs, nil
+}
+
+// Load holds a session's bodyweight-aware figures. They are worked out when
+// history is read, from the exercise type and the bodyweight entries, and
+// are not stored.
+type Load struct {
+	// Bodyweight is set for types whose load includes it
+	Bodyweight      *float64 `json:"bodyweight,omitempty"`
+	EffectiveLoad   *float64 `json:"effective_load,omitempty"`
+	EffectiveVolume *float64 `json:"effective_volume,omitempty"`
+	E1RM            *float64 `json:"e1rm,omitempty"`
+}
+
+// Cardio holds the structured fields of a distance- or time-based session
+type Cardio struct {
+	DurationSeconds  *int     `json:"duration_seconds,omitempty"`
+	DistanceKm       *float64 `json:"distance_km,omitempty"`
+	PaceSecondsPerKm *float64 `json:"pace_seconds_per_km,omitempty"`
+	AvgHeartRate     *int     `json:"avg_heart_rate,omitempty"`
+	ElevationGainM   *float64 `json:"elevation_gain_m,omitempty"`
+	Calories         *int     `json:"calories,omitempty"`
+}
+
+// CardioColumns lists the history cardio columns in Cardio order
+const CardioColumns = "duration_seconds, distance_km, pace_seconds_per_km, avg_heart_rate, elevation_gain_m, calories"
+
+// ScanFields returns pointers to c's fields in CardioColumns order
+func (c *Cardio) ScanFields() []interface{} {
+	return []interface{}{&c.DurationSeconds, &c.DistanceKm, &c.PaceSecondsPerKm, &c.AvgHeartRate, &c.ElevationGainM, &c.Calories}
 }
 
 // DayTitle represents a day's title
@@ -325,7 +586,30 @@ type MetricType struct {
 	Color      string `json:"color"`
 	OrderIndex int    `json:"order_index"`
 	IsDefault  bool   `json:"is_default"`
-	CreatedAt  string `json:"created_at"`
+	// Formula makes the metric computed from others (see Formula); nil for
+	// measured metrics
+	Formula   *string      `json:"formula,omitempty"`
+	Limits    MetricLimits `json:"limits"`
+	CreatedAt string       `json:"created_at"`
+}
+
+// MetricLimits are the plausible values of a measured metric; nil fields
+// are unchecked. Entries outside Min and Max are refused, and a change of
+// more than MaxDailyChange per day from the neighbouring entries is flagged.
+type MetricLimits struct {
+	Min            *float64 `json:"min,omitempty"`
+	Max            *float64 `json:"max,omitempty"`
+	MaxDailyChange *float64 `json:"max_daily_change,omitempty"`
+}
+
+// metricTypeColumns lists the metric_types columns in scanFields order
+const metricTypeColumns = `id, name, unit, color, order_index, is_default, formula,
+	min_value, max_value, max_daily_change, created_at`
+
+// scanFields returns pointers to m's fields in metricTypeColumns order
+func (m *MetricType) scanFields() []interface{} {
+	return []interface{}{&m.ID, &m.Name, &m.Unit, &m.Color, &m.OrderIndex, &m.IsDefault, &m.Formula,
+		&m.Limits.Min, &m.Limits.Max, &m.Limits.MaxDailyChange, &m.CreatedAt}
 }
 
 // MetricEntry represents a single measurement of a metric
@@ -409,20 +693,69 @@ func (db *DB) CreateRoutine(exerciseID int, dayOfWeek string, orderIndex int, no
 }
 
 // CreateHistory inserts a new history entry
-func (db *DB) CreateHistory(exerciseID int, sessionDate string, weight *float64, setsCompleted []int, completed bool, volume *float64, isPR bool, notes *string) (int64, error) {
+func (db *DB) CreateHistory(exerciseID int, sessionDate string, weight *float64, setsCompleted []int, completed bool, volume *float64, isPR bool, notes *string, cardio Cardio) (int64, error) {
+	return db.CreateHistoryWithClientID(nil, exerciseID, sessionDate, weight, setsCompleted, completed, volume, isPR, notes, cardio)
+}
+
+// CreateHistoryWithClientID inserts a new history entry under a
+// client-supplied id; a nil clientID gets a generated one. A PR takes the
+// flag from the exercise's other sessions in the same transaction.
+func (db *DB) CreateHistoryWithClientID(clientID *string, exerciseID int, sessionDate string, weight *float64, setsCompleted []int, completed bool, volume *float64, isPR bool, notes *string, cardio Cardio) (int64, error) {
+	if setsCompleted == nil {
+		setsCompleted = []int{}
+	}
 	setsJSON, err := json.Marshal(setsCompleted)
 	if err != nil {
 		return 0, fmt.Errorf("failed to marshal sets: %w", err)
 	}
 
-	result, err := db.Exec(
-		"INSERT INTO history (exercise_id, session_date, weight, sets_completed, completed, volume, is_pr, notes) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
-		exerciseID, sessionDate, weight, string(setsJSON), completed, volume, isPR, notes,
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"INSERT INTO history (client_id, exercise_id, session_date, weight, sets_completed, completed, volume, is_pr, notes, "+CardioColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		clientID, exerciseID, sessionDate, weight, string(setsJSON), completed, volume, isPR, notes,
+		cardio.DurationSeconds, cardio.DistanceKm, cardio.PaceSecondsPerKm, cardio.AvgHeartRate, cardio.ElevationGainM, cardio.Calories,
 	)
 	if err != nil {
 		return 0, fmt.Errorf("failed to create history: %w", err)
 	}
-	return result.LastInsertId()
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if isPR {
+		if err := ClearOtherPRs(tx, exerciseID, id); err != nil {
+			return 0, err
+		}
+	}
+	return id, tx.Commit()
+}
+
+// SetPR makes id the exercise's only PR session, or clears the flag from
+// all of them when id is 0. Rows that already hold the right flag are left
+// alone, so their sync versions stay put.
+func (db *DB) SetPR(exerciseID int, id int64) error {
+	_, err := db.Exec("UPDATE history SET is_pr = (id = ?) WHERE exercise_id = ? AND is_pr != (id = ?)", id, exerciseID, id)
+	if err != nil {
+		return fmt.Errorf("failed to set PR flag: %w", err)
+	}
+	return nil
+}
+
+// ClearOtherPRs clears the PR flag on an exercise's sessions other than
+// keepID. It runs in the transaction that writes the new PR, so a write that
+// fails leaves the old flag in place. Only rows that carry the flag are
+// touched, so their sync versions stay put.
+func ClearOtherPRs(tx *sql.Tx, exerciseID int, keepID int64) error {
+	_, err := tx.Exec("UPDATE history SET is_pr = 0 WHERE exercise_id = ? AND is_pr = 1 AND id != ?", exerciseID, keepID)
+	if err != nil {
+		return fmt.Errorf("failed to clear PR flags: %w", err)
+	}
+	return nil
 }
 
 // CreateDayTitle inserts or updates a day title
@@ -462,11 +795,13 @@ func nullFloat(f float64) *float64 {
 
 // Metric Type CRUD
 
-// CreateMetricType inserts a new metric type
-func (db *DB) CreateMetricType(name, unit, color string, orderIndex int, isDefault bool) (int64, error) {
+// CreateMetricType inserts a new metric type; a non-nil formula makes it
+// computed
+func (db *DB) CreateMetricType(name, unit, color string, formula *string, limits MetricLimits, orderIndex int, isDefault bool) (int64, error) {
 	result, err := db.Exec(
-		"INSERT INTO metric_types (name, unit, color, order_index, is_default) VALUES (?, ?, ?, ?, ?)",
-		name, unit, color, orderIndex, isDefault,
+		`INSERT INTO metric_types (name, unit, color, formula, min_value, max_value, max_daily_change, order_index, is_default)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		name, unit, color, formula, limits.Min, limits.Max, limits.MaxDailyChange, orderIndex, isDefault,
 	)
 	if err != nil {
 		return 0, fmt.Errorf("failed to create metric type: %w", err)
@@ -476,9 +811,7 @@ func (db *DB) CreateMetricType(name, unit, color string, orderIndex int, isDefau
 
 // GetMetricTypes retrieves all metric types ordered by order_index
 func (db *DB) GetMetricTypes() ([]MetricType, error) {
-	rows, err := db.Query(
-		"SELECT id, name, unit, color, order_index, is_default, created_at FROM metric_types ORDER BY order_index",
-	)
+	rows, err := db.Query("SELECT " + metricTypeColumns + " FROM metric_types ORDER BY order_index")
 	if err != nil {
 		return nil, fmt.Errorf("failed to query metric types: %w", err)
 	}
@@ -487,7 +820,7 @@ func (db *DB) GetMetricTypes() ([]MetricType, error) {
 	var metrics []MetricType
 	for rows.Next() {
 		var m MetricType
-		if err := rows.Scan(&m.ID, &m.Name, &m.Unit, &m.Color, &m.OrderIndex, &m.IsDefault, &m.CreatedAt); err != nil {
+		if err := rows.Scan(m.scanFields()...); err != nil {
 			return nil, fmt.Errorf("failed to scan metric type: %w", err)
 		}
 		metrics = append(metrics, m)
@@ -496,12 +829,41 @@ func (db *DB) GetMetricTypes() ([]MetricType, error) {
 	return metrics, nil
 }
 
-// UpdateMetricType updates a metric type's fields
-func (db *DB) UpdateMetricType(id int, name, unit, color *string, orderIndex *int) error {
+// GetMetricType retrieves a metric type by ID, or nil if there is none
+func (db *DB) GetMetricType(id int) (*MetricType, error) {
+	var m MetricType
+	err := db.QueryRow("SELECT "+metricTypeColumns+" FROM metric_types WHERE id = ?", id).Scan(m.scanFields()...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metric type: %w", err)
+	}
+	return &m, nil
+}
+
+// UpdateMetricType updates a metric type's fields; non-nil limits replace
+// all three limits. Renaming it also renames its references in other
+// metrics' formulas.
+func (db *DB) UpdateMetricType(id int, name, unit, color, formula *string, limits *MetricLimits, orderIndex *int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	query := "UPDATE metric_types SET updated_at = CURRENT_TIMESTAMP"
 	args := []interface{}{}
 
+	// The formula rewrite and the rename commit together, so a rename that
+	// fails (e.g. a duplicate name) leaves the formulas untouched
 	if name != nil {
+		_, err := tx.Exec(`UPDATE metric_types SET formula = REPLACE(formula, '{' || (SELECT name FROM metric_types WHERE id = ?) || '}', ?)
+			WHERE formula IS NOT NULL`, id, "{"+*name+"}")
+		if err != nil {
+			return fmt.Errorf("failed to update formulas: %w", err)
+		}
+
 		query += ", name = ?"
 		args = append(args, *name)
 	}
@@ -513,6 +875,14 @@ func (db *DB) UpdateMetricType(id int, name, unit, color *string, orderIndex *in
 		query += ", color = ?"
 		args = append(args, *color)
 	}
+	if formula != nil {
+		query += ", formula = ?"
+		args = append(args, *formula)
+	}
+	if limits != nil {
+		query += ", min_value = ?, max_value = ?, max_daily_change = ?"
+		args = append(args, limits.Min, limits.Max, limits.MaxDailyChange)
+	}
 	if orderIndex != nil {
 		query += ", order_index = ?"
 		args = append(args, *orderIndex)
@@ -521,11 +891,10 @@ func (db *DB) UpdateMetricType(id int, name, unit, color *string, orderIndex *in
 	query += " WHERE id = ?"
 	args = append(args, id)
 
-	_, err := db.Exec(query, args...)
-	if err != nil {
+	if _, err := tx.Exec(query, args...); err != nil {
 		return fmt.Errorf("failed to update metric type: %w", err)
 	}
-	return nil
+	return tx.Commit()
 }
 
 // DeleteMetricType deletes a metric type (cascades to entries)
@@ -541,9 +910,15 @@ func (db *DB) DeleteMetricType(id int) error {
 
 // CreateMetricEntry inserts a new metric entry
 func (db *DB) CreateMetricEntry(metricTypeID int, entryDate string, value float64, notes *string) (int64, error) {
+	return db.CreateMetricEntryWithClientID(nil, metricTypeID, entryDate, value, notes)
+}
+
+// CreateMetricEntryWithClientID inserts a new metric entry under a
+// client-supplied id; a nil clientID gets a generated one
+func (db *DB) CreateMetricEntryWithClientID(clientID *string, metricTypeID int, entryDate string, value float64, notes *string) (int64, error) {
 	result, err := db.Exec(
-		"INSERT INTO metric_entries (metric_type_id, entry_date, value, notes) VALUES (?, ?, ?, ?)",
-		metricTypeID, entryDate, value, notes,
+		"INSERT INTO metric_entries (client_id, metric_type_id, entry_date, value, notes) VALUES (?, ?, ?, ?, ?)",
+		clientID, metricTypeID, entryDate, value, notes,
 	)
 	if err != nil {
 		return 0, fmt.Errorf("failed to create metric entry: %w", err)
@@ -551,20 +926,22 @@ func (db *DB) CreateMetricEntry(metricTypeID int, entryDate string, value float6
 	return result.LastInsertId()
 }
 
-// GetEntriesByType retrieves entries for a specific metric type, limited by count
-func (db *DB) GetEntriesByType(metricTypeID int, limit int) ([]MetricEntry, error) {
-	query := "SELECT id, metric_type_id, entry_date, value, notes, created_at FROM metric_entries WHERE metric_type_id = ? ORDER BY entry_date DESC"
-	if limit > 0 {
-		query += fmt.Sprintf(" LIMIT %d", limit)
-	}
+// GetDashboardData retrieves entries for all metrics within the last N days
+func (db *DB) GetDashboardData(days int) (map[int][]MetricEntry, error) {
+	query := `
+		SELECT id, metric_type_id, CAST(entry_date AS TEXT), value, notes, created_at
+		FROM metric_entries
+		WHERE entry_date >= date('now', '-' || ? || ' days')
+		ORDER BY entry_date DESC
+	`
 
-	rows, err := db.Query(query, metricTypeID)
+	rows, err := db.Query(query, days)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query metric entries: %w", err)
+		return nil, fmt.Errorf("failed to query dashboard data: %w", err)
 	}
 	defer rows.Close()
 
-	var entries []MetricEntry
+	data := make(map[int][]MetricEntry)
 	for rows.Next() {
 		var e MetricEntry
 		var notes sql.NullString
@@ -574,41 +951,163 @@ func (db *DB) GetEntriesByType(metricTypeID int, limit int) ([]MetricEntry, erro
 		if notes.Valid {
 			e.Notes = &notes.String
 		}
-		entries = append(entries, e)
+		data[e.MetricTypeID] = append(data[e.MetricTypeID], e)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query dashboard data: %w", err)
+	}
+	rows.Close()
+
+	types, err := db.GetMetricTypes()
+	if err != nil {
+		return nil, err
+	}
+	since := time.Now().AddDate(0, 0, -days).Format("2006-01-02")
+	for _, mt := range types {
+		if mt.Formula == nil {
+			continue
+		}
+		entries, err := db.ComputeMetricEntries(mt, since)
+		if err != nil {
+			return nil, err
+		}
+		data[mt.ID] = entries
+	}
+	return data, nil
+}
+
+// ComputeMetricEntries evaluates a computed metric on every date from since
+// ("" for all) on which one of its inputs was measured, newest first. Each
+// input is its latest value on or before the date; dates before every
+// input has a value are skipped. Values are rounded to two decimals and
+// have ID 0.
+func (db *DB) ComputeMetricEntries(mt MetricType, since string) ([]MetricEntry, error) {
+	entries := []MetricEntry{}
+	if mt.Formula == nil {
+		return entries, nil
+	}
+	f, err := ParseFormula(*mt.Formula)
+	if err != nil || len(f.Metrics) == 0 {
+		// Formulas are checked when saved; one a rename broke has no values
+		return entries, nil
+	}
+	var height *float64
+	if f.UsesHeight {
+		settings, err := db.GetSettings()
+		if err != nil {
+			return nil, err
+		}
+		v, err := strconv.ParseFloat(settings[SettingHeight], 64)
+		if err != nil {
+			return entries, nil
+		}
+		height = &v
+	}
+
+	names, _ := json.Marshal(f.Metrics)
+	rows, err := db.Query(`
+		SELECT mt.name, CAST(me.entry_date AS TEXT), me.value
+		FROM metric_entries me JOIN metric_types mt ON mt.id = me.metric_type_id
+		WHERE mt.name IN (SELECT value FROM json_each(?)) AND mt.formula IS NULL
+		ORDER BY me.entry_date, me.id
+	`, string(names))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metric inputs: %w", err)
+	}
+	defer rows.Close()
+
+	values := map[string]float64{}
+	for rows.Next() {
+		var name, date string
+		var value float64
+		if err := rows.Scan(&name, &date, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan metric input: %w", err)
+		}
+		values[name] = value
+		v, ok := f.Eval(values, height)
+		if !ok || date < since {
+			continue
+		}
+		e := MetricEntry{MetricTypeID: mt.ID, EntryDate: date, Value: math.Round(v*100) / 100}
+		// Inputs measured on the same day make one entry
+		if n := len(entries); n > 0 && entries[n-1].EntryDate == date {
+			entries[n-1] = e
+		} else {
+			entries = append(entries, e)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query metric inputs: %w", err)
+	}
+	slices.Reverse(entries)
 	return entries, nil
 }
 
-// GetDashboardData retrieves entries for all metrics within the last N days
-func (db *DB) GetDashboardData(days int) (map[int][]MetricEntry, error) {
-	query := `
-		SELECT id, metric_type_id, entry_date, value, notes, created_at
-		FROM metric_entries
-		WHERE entry_date >= date('now', '-' || ? || ' days')
-		ORDER BY entry_date DESC
-	`
+// GetMetricEntry retrieves a metric entry by ID, or nil if there is none
+func (db *DB) GetMetricEntry(id int) (*MetricEntry, error) {
+	var e MetricEntry
+	err := db.QueryRow(
+		"SELECT id, metric_type_id, CAST(entry_date AS TEXT), value, notes, created_at FROM metric_entries WHERE id = ?", id,
+	).Scan(&e.ID, &e.MetricTypeID, &e.EntryDate, &e.Value, &e.Notes, &e.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metric entry: %w", err)
+	}
+	return &e, nil
+}
 
-	rows, err := db.Query(query, days)
+// GetMetricEntries retrieves every entry of a metric type, oldest first
+func (db *DB) GetMetricEntries(metricTypeID int) ([]MetricEntry, error) {
+	rows, err := db.Query(`SELECT id, metric_type_id, CAST(entry_date AS TEXT), value, notes, created_at
+		FROM metric_entries WHERE metric_type_id = ? ORDER BY entry_date, id`, metricTypeID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query dashboard data: %w", err)
+		return nil, fmt.Errorf("failed to query metric entries: %w", err)
 	}
 	defer rows.Close()
 
-	data := make(map[int][]MetricEntry)
+	entries := []MetricEntry{}
 	for rows.Next() {
 		var e MetricEntry
-		var notes sql.NullString
-		if err := rows.Scan(&e.ID, &e.MetricTypeID, &e.EntryDate, &e.Value, &notes, &e.CreatedAt); err != nil {
+		if err := rows.Scan(&e.ID, &e.MetricTypeID, &e.EntryDate, &e.Value, &e.Notes, &e.CreatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan metric entry: %w", err)
 		}
-		if notes.Valid {
-			e.Notes = &notes.String
-		}
-		data[e.MetricTypeID] = append(data[e.MetricTypeID], e)
+		entries = append(entries, e)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query metric entries: %w", err)
+	}
+	return entries, nil
+}
 
-	return data, nil
+// AdjacentMetricEntries retrieves a metric type's latest entry before date
+// and its earliest entry after it, either nil if there is none. The entry
+// excludeID (0 for none) is skipped, so an edited entry isn't its own
+// neighbour.
+func (db *DB) AdjacentMetricEntries(metricTypeID int, date string, excludeID int) (before, after *MetricEntry, err error) {
+	get := func(cmp, order string) (*MetricEntry, error) {
+		var e MetricEntry
+		err := db.QueryRow(`SELECT id, metric_type_id, CAST(entry_date AS TEXT), value, notes, created_at
+			FROM metric_entries WHERE metric_type_id = ? AND id != ? AND entry_date `+cmp+` ?
+			ORDER BY entry_date `+order+`, id `+order+` LIMIT 1`, metricTypeID, excludeID, date,
+		).Scan(&e.ID, &e.MetricTypeID, &e.EntryDate, &e.Value, &e.Notes, &e.CreatedAt)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get adjacent metric entry: %w", err)
+		}
+		return &e, nil
+	}
+	if before, err = get("<", "DESC"); err != nil {
+		return nil, nil, err
+	}
+	if after, err = get(">", "ASC"); err != nil {
+		return nil, nil, err
+	}
+	return before, after, nil
 }
 
 // GetLatestEntry retrieves the most recent entry for a metric type
@@ -634,6 +1133,43 @@ func (db *DB) GetLatestEntry(metricTypeID int) (*MetricEntry, error) {
 	return &e, nil
 }
 
+// BodyweightMetricID returns the ID of the metric marked is_bodyweight, or
+// 0 if there is none
+func (db *DB) BodyweightMetricID() (int, error) {
+	var id int
+	err := db.QueryRow("SELECT id FROM metric_types WHERE is_bodyweight = 1").Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to find bodyweight metric: %w", err)
+	}
+	return id, nil
+}
+
+// BodyweightSQL returns an SQL expression for the bodyweight on the date
+// dateExpr evaluates to, from the metric marked is_bodyweight (the default
+// Weight, whatever it is renamed to): the latest entry on or before it, or
+// else the first one after it, so sessions logged before the first weigh-in
+// still get a bodyweight. It is NULL when no bodyweight was ever logged.
+func BodyweightSQL(dateExpr string) string {
+	entries := `SELECT me.value FROM metric_entries me JOIN metric_types mt ON mt.id = me.metric_type_id
+		WHERE mt.is_bodyweight = 1 AND me.entry_date `
+	return `COALESCE(
+		(` + entries + `<= ` + dateExpr + ` ORDER BY me.entry_date DESC LIMIT 1),
+		(` + entries + `> ` + dateExpr + ` ORDER BY me.entry_date LIMIT 1))`
+}
+
+// BodyweightOn returns the bodyweight on date (see BodyweightSQL), or nil
+func (db *DB) BodyweightOn(date string) (*float64, error) {
+	var bw *float64
+	err := db.QueryRow(`SELECT `+BodyweightSQL("d.date")+` FROM (SELECT ? AS date) d`, date).Scan(&bw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bodyweight: %w", err)
+	}
+	return bw, nil
+}
+
 // UpdateMetricEntry updates a metric entry's fields
 func (db *DB) UpdateMetricEntry(id int, value *float64, entryDate, notes *string) error {
 	query := "UPDATE metric_entries SET "
@@ -679,3 +1215,360 @@ func (db *DB) DeleteMetricEntry(id int) error {
 	}
 	return nil
 }
+
+// Muscle CRUD
+
+// Muscle is a muscle that exercises can be mapped to
+type Muscle struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	OrderIndex int    `json:"order_index"`
+	IsDefault  bool   `json:"is_default"`
+}
+
+// ExerciseMuscle maps an exercise to a muscle it works. Weight is the
+// fraction of each set credited to the muscle.
+type ExerciseMuscle struct {
+	MuscleID int     `json:"muscle_id"`
+	Name     string  `json:"name"`
+	Role     string  `json:"role"`
+	Weight   float64 `json:"weight"`
+}
+
+// CreateMuscle inserts a new muscle
+func (db *DB) CreateMuscle(name string, orderIndex int) (int64, error) {
+	result, err := db.Exec("INSERT INTO muscles (name, order_index) VALUES (?, ?)", name, orderIndex)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create muscle: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// GetMuscles retrieves all muscles ordered by order_index
+func (db *DB) GetMuscles() ([]Muscle, error) {
+	rows, err := db.Query("SELECT id, name, order_index, is_default FROM muscles ORDER BY order_index, name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query muscles: %w", err)
+	}
+	defer rows.Close()
+
+	var muscles []Muscle
+	for rows.Next() {
+		var m Muscle
+		if err := rows.Scan(&m.ID, &m.Name, &m.OrderIndex, &m.IsDefault); err != nil {
+			return nil, fmt.Errorf("failed to scan muscle: %w", err)
+		}
+		muscles = append(muscles, m)
+	}
+	return muscles, rows.Err()
+}
+
+// GetExerciseMuscles retrieves the muscles an exercise works, primary first
+func (db *DB) GetExerciseMuscles(exerciseID int) ([]ExerciseMuscle, error) {
+	rows, err := db.Query(`
+		SELECT em.muscle_id, m.name, em.role, em.weight
+		FROM exercise_muscles em
+		JOIN muscles m ON m.id = em.muscle_id
+		WHERE em.exercise_id = ?
+		ORDER BY em.role, em.weight DESC, m.order_index
+	`, exerciseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exercise muscles: %w", err)
+	}
+	defer rows.Close()
+
+	muscles := []ExerciseMuscle{}
+	for rows.Next() {
+		var m ExerciseMuscle
+		if err := rows.Scan(&m.MuscleID, &m.Name, &m.Role, &m.Weight); err != nil {
+			return nil, fmt.Errorf("failed to scan exercise muscle: %w", err)
+		}
+		muscles = append(muscles, m)
+	}
+	return muscles, rows.Err()
+}
+
+// SetExerciseMuscles replaces an exercise's muscle mapping
+func (db *DB) SetExerciseMuscles(exerciseID int, muscles []ExerciseMuscle) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM exercise_muscles WHERE exercise_id = ?", exerciseID); err != nil {
+		return fmt.Errorf("failed to clear exercise muscles: %w", err)
+	}
+	for _, m := range muscles {
+		if _, err := tx.Exec(
+			"INSERT INTO exercise_muscles (exercise_id, muscle_id, role, weight) VALUES (?, ?, ?, ?)",
+			exerciseID, m.MuscleID, m.Role, m.Weight,
+		); err != nil {
+			return fmt.Errorf("failed to set exercise muscle: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Category and exercise type CRUD
+
+// Category groups exercises, e.g. "Legs-Push"
+type Category struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	OrderIndex int    `json:"order_index"`
+	IsDefault  bool   `json:"is_default"`
+}
+
+// ExerciseType describes how sessions of a type are recorded and scored.
+// See the exercise_types table in schema.sql for what each field means.
+type ExerciseType struct {
+	Name           string `json:"name"`
+	Label          string `json:"label"`
+	UsesWeight     bool   `json:"uses_weight"`
+	SetUnit        string `json:"set_unit"`
+	PRMetric       string `json:"pr_metric"`
+	PRDirection    string `json:"pr_direction"`
+	Progression    string `json:"progression"`
+	CountsVolume   bool   `json:"counts_volume"`
+	CountsTonnage  bool   `json:"counts_tonnage"`
+	BodyweightLoad string `json:"bodyweight_load"`
+	OrderIndex     int    `json:"order_index"`
+	IsDefault      bool   `json:"is_default"`
+}
+
+// exerciseTypeColumns lists the exercise_types columns in ExerciseType order
+const exerciseTypeColumns = `name, label, uses_weight, set_unit, pr_metric, pr_direction, progression,
+	counts_volume, counts_tonnage, bodyweight_load, order_index, is_default`
+
+func (t *ExerciseType) scanFields() []interface{} {
+	return []interface{}{&t.Name, &t.Label, &t.UsesWeight, &t.SetUnit, &t.PRMetric, &t.PRDirection, &t.Progression,
+		&t.CountsVolume, &t.CountsTonnage, &t.BodyweightLoad, &t.OrderIndex, &t.IsDefault}
+}
+
+// GetCategories retrieves all categories ordered by order_index
+func (db *DB) GetCategories() ([]Category, error) {
+	rows, err := db.Query("SELECT id, name, order_index, is_default FROM categories ORDER BY order_index, name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query categories: %w", err)
+	}
+	defer rows.Close()
+
+	categories := []Category{}
+	for rows.Next() {
+		var c Category
+		if err := rows.Scan(&c.ID, &c.Name, &c.OrderIndex, &c.IsDefault); err != nil {
+			return nil, fmt.Errorf("failed to scan category: %w", err)
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+// CreateCategory inserts a new category
+func (db *DB) CreateCategory(name string, orderIndex int) (int64, error) {
+	result, err := db.Exec("INSERT INTO categories (name, order_index) VALUES (?, ?)", name, orderIndex)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create category: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// GetExerciseTypes retrieves all exercise types ordered by order_index
+func (db *DB) GetExerciseTypes() ([]ExerciseType, error) {
+	rows, err := db.Query("SELECT " + exerciseTypeColumns + " FROM exercise_types ORDER BY order_index, name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exercise types: %w", err)
+	}
+	defer rows.Close()
+
+	types := []ExerciseType{}
+	for rows.Next() {
+		var t ExerciseType
+		if err := rows.Scan(t.scanFields()...); err != nil {
+			return nil, fmt.Errorf("failed to scan exercise type: %w", err)
+		}
+		types = append(types, t)
+	}
+	return types, rows.Err()
+}
+
+// GetExerciseType retrieves an exercise type by name, or nil if there is none
+func (db *DB) GetExerciseType(name string) (*ExerciseType, error) {
+	var t ExerciseType
+	err := db.QueryRow("SELECT "+exerciseTypeColumns+" FROM exercise_types WHERE name = ?", name).Scan(t.scanFields()...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get exercise type: %w", err)
+	}
+	return &t, nil
+}
+
+// CreateExerciseType inserts a new exercise type
+func (db *DB) CreateExerciseType(t ExerciseType) error {
+	_, err := db.Exec(
+		"INSERT INTO exercise_types ("+exerciseTypeColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 0)",
+		t.Name, t.Label, t.UsesWeight, t.SetUnit, t.PRMetric, t.PRDirection, t.Progression,
+		t.CountsVolume, t.CountsTonnage, t.BodyweightLoad, t.OrderIndex,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create exercise type: %w", err)
+	}
+	return nil
+}
+
+// Settings
+
+// Setting keys
+const (
+	// SettingSex is "male" or "female", for sex-specific strength formulas
+	SettingSex = "sex"
+	// SettingSquatExercise, SettingBenchExercise and SettingDeadliftExercise
+	// hold the IDs of the exercises scored as the powerlifting lifts
+	SettingSquatExercise    = "squat_exercise_id"
+	SettingBenchExercise    = "bench_exercise_id"
+	SettingDeadliftExercise = "deadlift_exercise_id"
+	// SettingHeight is the height in cm that computed metrics use
+	SettingHeight = "height_cm"
+)
+
+// GetSettings retrieves every setting that is set, by key
+func (db *DB) GetSettings() (map[string]string, error) {
+	rows, err := db.Query("SELECT key, value FROM settings")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get settings: %w", err)
+	}
+	defer rows.Close()
+
+	settings := map[string]string{}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan setting: %w", err)
+		}
+		settings[key] = value
+	}
+	return settings, rows.Err()
+}
+
+// SetSetting stores a setting; an empty value unsets it
+func (db *DB) SetSetting(key, value string) error {
+	var err error
+	if value == "" {
+		_, err = db.Exec("DELETE FROM settings WHERE key = ?", key)
+	} else {
+		_, err = db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", key, value)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set %s: %w", key, err)
+	}
+	return nil
+}
+
+// Goal CRUD
+
+// Goal is a target for a body metric or a lift. Metric goals have a
+// MetricTypeID and either direction; lift goals have an ExerciseID, Reps
+// and direction at_least, and are met by lifting Target kg for Reps reps.
+type Goal struct {
+	ID           int      `json:"id"`
+	MetricTypeID *int     `json:"metric_type_id,omitempty"`
+	ExerciseID   *int     `json:"exercise_id,omitempty"`
+	Direction    string   `json:"direction"`
+	Target       float64  `json:"target"`
+	Reps         *int     `json:"reps,omitempty"`
+	TargetDate   *string  `json:"target_date,omitempty"`
+	StartValue   *float64 `json:"start_value,omitempty"`
+	Notes        *string  `json:"notes,omitempty"`
+	AchievedAt   *string  `json:"achieved_at,omitempty"`
+	CreatedAt    string   `json:"created_at"`
+}
+
+// goalColumns lists the goals columns in Goal order
+const goalColumns = `id, metric_type_id, exercise_id, direction, target, reps, CAST(target_date AS TEXT),
+	start_value, notes, achieved_at, created_at`
+
+// scanFields returns pointers to g's fields in goalColumns order
+func (g *Goal) scanFields() []interface{} {
+	return []interface{}{&g.ID, &g.MetricTypeID, &g.ExerciseID, &g.Direction, &g.Target, &g.Reps, &g.TargetDate,
+		&g.StartValue, &g.Notes, &g.AchievedAt, &g.CreatedAt}
+}
+
+// CreateGoal inserts a goal; its ID, achieved_at and created_at are ignored
+func (db *DB) CreateGoal(g Goal) (int64, error) {
+	result, err := db.Exec(
+		`INSERT INTO goals (metric_type_id, exercise_id, direction, target, reps, target_date, start_value, notes)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		g.MetricTypeID, g.ExerciseID, g.Direction, g.Target, g.Reps, g.TargetDate, g.StartValue, g.Notes,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create goal: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// GetGoals retrieves all goals, unachieved first, then by target date
+func (db *DB) GetGoals() ([]Goal, error) {
+	rows, err := db.Query(`SELECT ` + goalColumns + ` FROM goals
+		ORDER BY achieved_at IS NOT NULL, target_date IS NULL, target_date, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query goals: %w", err)
+	}
+	defer rows.Close()
+
+	goals := []Goal{}
+	for rows.Next() {
+		var g Goal
+		if err := rows.Scan(g.scanFields()...); err != nil {
+			return nil, fmt.Errorf("failed to scan goal: %w", err)
+		}
+		goals = append(goals, g)
+	}
+	return goals, rows.Err()
+}
+
+// GetGoal retrieves a goal by ID, or nil if there is none
+func (db *DB) GetGoal(id int) (*Goal, error) {
+	var g Goal
+	err := db.QueryRow(`SELECT `+goalColumns+` FROM goals WHERE id = ?`, id).Scan(g.scanFields()...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get goal: %w", err)
+	}
+	return &g, nil
+}
+
+// UpdateGoal saves a goal's editable fields and achieved_at
+func (db *DB) UpdateGoal(g Goal) error {
+	_, err := db.Exec(
+		`UPDATE goals SET direction = ?, target = ?, reps = ?, target_date = ?, notes = ?, achieved_at = ? WHERE id = ?`,
+		g.Direction, g.Target, g.Reps, g.TargetDate, g.Notes, g.AchievedAt, g.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update goal: %w", err)
+	}
+	return nil
+}
+
+// SetGoalAchieved stamps a goal as achieved now, unless it already is
+func (db *DB) SetGoalAchieved(id int) error {
+	_, err := db.Exec(`UPDATE goals SET achieved_at = `+SyncNow+` WHERE id = ? AND achieved_at IS NULL`, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark goal achieved: %w", err)
+	}
+	return nil
+}
+
+// DeleteGoal deletes a goal, reporting whether it existed
+func (db *DB) DeleteGoal(id int) (bool, error) {
+	result, err := db.Exec("DELETE FROM goals WHERE id = ?", id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete goal: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
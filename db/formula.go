@@ -0,0 +1,232 @@
+package db
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Formula is a computed metric's expression over other metrics, e.g.
+// "{Weight} / (height / 100) ^ 2". {Name} is the value of the metric type
+// with that name and height the height_cm setting. It supports numbers,
+// + - * / ^ (right-associative), unary minus and parentheses. As usual ^
+// binds tighter than unary minus, so -x^2 is -(x^2); whitespace of any
+// kind separates tokens.
+type Formula struct {
+	root formulaNode
+	// Metrics are the metric type names the formula reads, in order of
+	// first use
+	Metrics []string
+	// UsesHeight is set when the formula reads the height setting
+	UsesHeight bool
+}
+
+// formulaNode evaluates part of a formula; ok is false when a value is
+// missing or the result isn't a finite number
+type formulaNode func(metrics map[string]float64, height *float64) (v float64, ok bool)
+
+// ParseFormula parses a formula, reporting the first syntax error
+func ParseFormula(s string) (*Formula, error) {
+	p := &formulaParser{src: s}
+	p.next()
+	root, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok != "" {
+		return nil, fmt.Errorf("unexpected %q", p.tok)
+	}
+	return &Formula{root: root, Metrics: p.metrics, UsesHeight: p.usesHeight}, nil
+}
+
+// Eval computes the formula from the metric values by name and the height
+// in cm. ok is false when an input is missing or the result isn't finite.
+func (f *Formula) Eval(metrics map[string]float64, height *float64) (float64, bool) {
+	v, ok := f.root(metrics, height)
+	if !ok || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, false
+	}
+	return v, true
+}
+
+type formulaParser struct {
+	src        string
+	pos        int
+	tok        string
+	metrics    []string
+	usesHeight bool
+}
+
+// next reads the next token into p.tok; "" is the end of the input
+func (p *formulaParser) next() {
+	for p.pos < len(p.src) {
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		p.pos += size
+	}
+	start := p.pos
+	switch {
+	case p.pos == len(p.src):
+	case p.src[p.pos] == '{':
+		if end := strings.IndexByte(p.src[p.pos:], '}'); end > 0 {
+			p.pos += end + 1
+		} else {
+			p.pos = len(p.src)
+		}
+	case isFormulaDigit(p.src[p.pos]):
+		for p.pos < len(p.src) && isFormulaDigit(p.src[p.pos]) {
+			p.pos++
+		}
+	case isFormulaLetter(p.src[p.pos]):
+		for p.pos < len(p.src) && (isFormulaLetter(p.src[p.pos]) || isFormulaDigit(p.src[p.pos])) {
+			p.pos++
+		}
+	default:
+		p.pos++
+	}
+	p.tok = p.src[start:p.pos]
+}
+
+func isFormulaDigit(c byte) bool  { return c >= '0' && c <= '9' || c == '.' }
+func isFormulaLetter(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' }
+
+// expr parses terms joined by + and -
+func (p *formulaParser) expr() (formulaNode, error) {
+	left, err := p.term()
+	for err == nil && (p.tok == "+" || p.tok == "-") {
+		op := p.tok
+		p.next()
+		var right formulaNode
+		if right, err = p.term(); err == nil {
+			left = binary(left, right, op)
+		}
+	}
+	return left, err
+}
+
+// term parses factors joined by * and /
+func (p *formulaParser) term() (formulaNode, error) {
+	left, err := p.unary()
+	for err == nil && (p.tok == "*" || p.tok == "/") {
+		op := p.tok
+		p.next()
+		var right formulaNode
+		if right, err = p.unary(); err == nil {
+			left = binary(left, right, op)
+		}
+	}
+	return left, err
+}
+
+// power parses an operand, raised to a power if ^ follows. The exponent
+// may be negated, as in 2^-1.
+func (p *formulaParser) power() (formulaNode, error) {
+	base, err := p.operand()
+	if err != nil || p.tok != "^" {
+		return base, err
+	}
+	p.next()
+	exponent, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return binary(base, exponent, "^"), nil
+}
+
+// unary parses a power, negated by any leading minus signs
+func (p *formulaParser) unary() (formulaNode, error) {
+	if p.tok != "-" {
+		return p.power()
+	}
+	p.next()
+	operand, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return func(m map[string]float64, h *float64) (float64, bool) {
+		v, ok := operand(m, h)
+		return -v, ok
+	}, nil
+}
+
+// operand parses a number, metric reference, height or parenthesised
+// expression
+func (p *formulaParser) operand() (formulaNode, error) {
+	tok := p.tok
+	switch {
+	case tok == "":
+		return nil, fmt.Errorf("unexpected end of formula")
+	case tok == "(":
+		p.next()
+		inner, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.next()
+		return inner, nil
+	case tok[0] == '{':
+		name := strings.TrimSpace(strings.TrimSuffix(tok[1:], "}"))
+		if !strings.HasSuffix(tok, "}") || name == "" {
+			return nil, fmt.Errorf("unterminated metric reference %q", tok)
+		}
+		p.next()
+		if !slices.Contains(p.metrics, name) {
+			p.metrics = append(p.metrics, name)
+		}
+		return func(m map[string]float64, _ *float64) (float64, bool) {
+			v, ok := m[name]
+			return v, ok
+		}, nil
+	case tok == "height":
+		p.next()
+		p.usesHeight = true
+		return func(_ map[string]float64, h *float64) (float64, bool) {
+			if h == nil {
+				return 0, false
+			}
+			return *h, true
+		}, nil
+	case isFormulaDigit(tok[0]):
+		v, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", tok)
+		}
+		p.next()
+		return func(map[string]float64, *float64) (float64, bool) { return v, true }, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", tok)
+}
+
+// binary applies op to the values of left and right
+func binary(left, right formulaNode, op string) formulaNode {
+	return func(m map[string]float64, h *float64) (float64, bool) {
+		a, ok := left(m, h)
+		if !ok {
+			return 0, false
+		}
+		b, ok := right(m, h)
+		if !ok {
+			return 0, false
+		}
+		switch op {
+		case "+":
+			return a + b, true
+		case "-":
+			return a - b, true
+		case "*":
+			return a * b, true
+		case "/":
+			return a / b, b != 0
+		}
+		return math.Pow(a, b), true
+	}
+}
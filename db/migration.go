@@ -9,11 +9,6 @@ import (
 	"sort"
 )
 
-const (
-	trainJSONPath   = "train.json"
-	trainBackupPath = "train.json.backup"
-)
-
 // TrainJSON represents the structure of train.json
 type TrainJSON map[string]DayData
 
@@ -47,27 +42,29 @@ type HistoryData struct {
 	Volume    float64 `json:"volume"`
 }
 
-// ShouldMigrate checks if migration is needed
-func ShouldMigrate() bool {
+// ShouldMigrate checks if migration is needed: the database at dbPath does
+// not exist yet but a legacy train.json does at jsonPath
+func ShouldMigrate(dbPath, jsonPath string) bool {
 	// Check if database exists
 	if _, err := os.Stat(dbPath); err == nil {
 		return false // Database exists, no migration needed
 	}
 
 	// Check if train.json exists
-	if _, err := os.Stat(trainJSONPath); os.IsNotExist(err) {
+	if _, err := os.Stat(jsonPath); os.IsNotExist(err) {
 		return false // No source file to migrate from
 	}
 
 	return true
 }
 
-// Migrate performs the migration from train.json to SQLite
-func Migrate() error {
+// Migrate performs the migration from the train.json at jsonPath to a new
+// SQLite database at dbPath, then moves the JSON file to backupPath
+func Migrate(dbPath, jsonPath, backupPath string) error {
 	log.Println("Starting migration from train.json to SQLite...")
 
 	// Read train.json
-	data, err := os.ReadFile(trainJSONPath)
+	data, err := os.ReadFile(jsonPath)
 	if err != nil {
 		return fmt.Errorf("failed to read train.json: %w", err)
 	}
@@ -78,7 +75,7 @@ func Migrate() error {
 	}
 
 	// Open database
-	database, err := Open()
+	database, err := Open(dbPath)
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
@@ -94,10 +91,10 @@ func Migrate() error {
 	log.Println("Data migration completed, backing up original file...")
 
 	// Backup original file
-	if err := os.Rename(trainJSONPath, trainBackupPath); err != nil {
+	if err := os.Rename(jsonPath, backupPath); err != nil {
 		log.Printf("Warning: failed to backup train.json: %v", err)
 	} else {
-		log.Printf("Backed up train.json to %s", trainBackupPath)
+		log.Printf("Backed up train.json to %s", backupPath)
 	}
 
 	log.Println("Migration completed successfully!")
@@ -214,6 +211,7 @@ func migrateData(db *DB, trainData TrainJSON) error {
 				volume,
 				isPR,
 				nil,
+				Cardio{},
 			)
 			if err != nil {
 				return fmt.Errorf("failed to create history: %w", err)
@@ -1,11 +1,50 @@
 -- Database schema for workout tracker
 
+-- Exercise categories (user-managed; exercises reference them by name)
+CREATE TABLE IF NOT EXISTS categories (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    order_index INTEGER NOT NULL DEFAULT 0,
+    is_default BOOLEAN DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_categories_order ON categories(order_index);
+
+-- Exercise types. Each row describes how sessions of that type are recorded
+-- and scored, so new types need no schema or code changes:
+--   uses_weight     the weight field applies
+--   set_unit        what each sets_completed entry counts: reps, laps, seconds,
+--                   or none (a completion marker)
+--   pr_metric       compared to find PRs: weight, volume, or none
+--   pr_direction    higher or lower values are better
+--   progression     what counts as a success towards ready_to_progress:
+--                   weight (completed at target weight), completion, or none
+--   counts_volume   included in volume analytics
+--   counts_tonnage  weight × reps is load lifted
+CREATE TABLE IF NOT EXISTS exercise_types (
+    name TEXT PRIMARY KEY,
+    label TEXT NOT NULL,
+    uses_weight BOOLEAN NOT NULL DEFAULT 0,
+    set_unit TEXT NOT NULL DEFAULT 'reps' CHECK(set_unit IN ('reps', 'laps', 'seconds', 'none')),
+    pr_metric TEXT NOT NULL DEFAULT 'weight' CHECK(pr_metric IN ('weight', 'volume', 'none')),
+    pr_direction TEXT NOT NULL DEFAULT 'higher' CHECK(pr_direction IN ('higher', 'lower')),
+    progression TEXT NOT NULL DEFAULT 'weight' CHECK(progression IN ('weight', 'completion', 'none')),
+    counts_volume BOOLEAN NOT NULL DEFAULT 1,
+    counts_tonnage BOOLEAN NOT NULL DEFAULT 0,
+    -- How the lifter's bodyweight enters the load: 'add' for bodyweight moves
+    -- (plus any added weight), 'subtract' when the weight is assistance
+    bodyweight_load TEXT NOT NULL DEFAULT 'none' CHECK(bodyweight_load IN ('none', 'add', 'subtract')),
+    order_index INTEGER NOT NULL DEFAULT 0,
+    is_default BOOLEAN DEFAULT 0
+);
+
 -- Exercise definitions (master library)
 CREATE TABLE IF NOT EXISTS exercises (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     name TEXT NOT NULL UNIQUE,
-    type TEXT NOT NULL CHECK(type IN ('cardio', 'weight', 'bodyweight', 'assisted', 'carry', 'timed_hold')),
-    category TEXT CHECK(category IN ('Legs-Push', 'Legs-Pull', 'Arms-Push', 'Arms-Pull', 'Core-Push', 'Core-Pull')),
+    type TEXT NOT NULL REFERENCES exercise_types(name),
+    category TEXT REFERENCES categories(name) ON UPDATE CASCADE ON DELETE SET NULL,
     target_sets INTEGER,
     target_reps INTEGER,
     target_weight REAL,
@@ -24,6 +63,11 @@ CREATE TABLE IF NOT EXISTS routines (
     day_of_week TEXT NOT NULL CHECK(day_of_week IN ('Monday', 'Tuesday', 'Wednesday', 'Thursday', 'Friday', 'Saturday', 'Sunday')),
     order_index INTEGER NOT NULL,
     notes TEXT,
+    -- Sync metadata: a stable id shared across devices, a version bumped on
+    -- every change and the server time of the last change
+    client_id TEXT,
+    version INTEGER NOT NULL DEFAULT 1,
+    updated_at TEXT,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (exercise_id) REFERENCES exercises(id) ON DELETE CASCADE
 );
@@ -43,6 +87,18 @@ CREATE TABLE IF NOT EXISTS history (
     volume REAL,
     is_pr BOOLEAN DEFAULT 0,
     notes TEXT,
+    -- Structured cardio, all optional; pace is seconds per km
+    duration_seconds INTEGER,
+    distance_km REAL,
+    pace_seconds_per_km REAL,
+    avg_heart_rate INTEGER,
+    elevation_gain_m REAL,
+    calories INTEGER,
+    -- Sync metadata: a stable id shared across devices, a version bumped on
+    -- every change and the server time of the last change
+    client_id TEXT,
+    version INTEGER NOT NULL DEFAULT 1,
+    updated_at TEXT,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (exercise_id) REFERENCES exercises(id) ON DELETE CASCADE
 );
@@ -65,6 +121,17 @@ CREATE TABLE IF NOT EXISTS metric_types (
     color TEXT NOT NULL,
     order_index INTEGER NOT NULL DEFAULT 0,
     is_default BOOLEAN DEFAULT 0,
+    -- Computed metrics have a formula over other metrics (see db.Formula)
+    -- and no stored entries; NULL for measured metrics
+    formula TEXT,
+    -- Optional plausible range and largest expected change per day of a
+    -- measured metric's entries (see db.MetricLimits)
+    min_value REAL,
+    max_value REAL,
+    max_daily_change REAL,
+    -- Marks the metric whose entries are the lifter's bodyweight (see
+    -- BodyweightSQL), so renaming it keeps bodyweight-based loads working
+    is_bodyweight BOOLEAN NOT NULL DEFAULT 0,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
@@ -79,6 +146,11 @@ CREATE TABLE IF NOT EXISTS metric_entries (
     entry_date DATE NOT NULL,
     value REAL NOT NULL,
     notes TEXT,
+    -- Sync metadata: a stable id shared across devices, a version bumped on
+    -- every change and the server time of the last change
+    client_id TEXT,
+    version INTEGER NOT NULL DEFAULT 1,
+    updated_at TEXT,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (metric_type_id) REFERENCES metric_types(id) ON DELETE CASCADE
 );
@@ -86,8 +158,167 @@ CREATE TABLE IF NOT EXISTS metric_entries (
 CREATE INDEX IF NOT EXISTS idx_metric_entries_type_date ON metric_entries(metric_type_id, entry_date DESC);
 CREATE INDEX IF NOT EXISTS idx_metric_entries_date ON metric_entries(entry_date DESC);
 
--- Default metric seeds
-INSERT OR IGNORE INTO metric_types (name, unit, color, order_index, is_default) VALUES
-    ('Weight', 'kg', '#00E5FF', 0, 1),
-    ('Body Fat %', '%', '#FF9800', 1, 1),
-    ('Waist', 'cm', '#00C853', 2, 1);
+-- Deleted history, routine and metric entry rows, kept so devices that sync
+-- later learn about the delete
+CREATE TABLE IF NOT EXISTS sync_tombstones (
+    entity TEXT NOT NULL,
+    client_id TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    deleted_at TEXT NOT NULL,
+    PRIMARY KEY (entity, client_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_tombstones_deleted ON sync_tombstones(deleted_at);
+
+-- Shared workouts: a host trains a day's routine with partners who join by
+-- code. Sets are recorded per participant; token identifies a participant's
+-- device when it records sets.
+CREATE TABLE IF NOT EXISTS shared_sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    code TEXT NOT NULL UNIQUE,
+    day_of_week TEXT NOT NULL,
+    session_date DATE NOT NULL,
+    finished_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS shared_session_participants (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    token TEXT NOT NULL UNIQUE,
+    is_host BOOLEAN NOT NULL DEFAULT 0,
+    joined_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (session_id, name),
+    FOREIGN KEY (session_id) REFERENCES shared_sessions(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS shared_session_sets (
+    participant_id INTEGER NOT NULL,
+    exercise_id INTEGER NOT NULL,
+    set_number INTEGER NOT NULL CHECK(set_number >= 1),
+    reps INTEGER NOT NULL CHECK(reps >= 0),
+    weight REAL CHECK(weight >= 0),
+    completed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (participant_id, exercise_id, set_number),
+    FOREIGN KEY (participant_id) REFERENCES shared_session_participants(id) ON DELETE CASCADE,
+    FOREIGN KEY (exercise_id) REFERENCES exercises(id) ON DELETE CASCADE
+);
+
+-- Profile settings the stats need, one row per key (see the Setting*
+-- constants in db.go). Unset keys have no row.
+CREATE TABLE IF NOT EXISTS settings (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
+
+-- Goals: a body metric reaching target (at_most or at_least), or lifting
+-- target kg for reps reps (always at_least). Exactly one of metric_type_id
+-- and exercise_id is set. start_value is where the metric or lift stood
+-- when the goal was set; achieved_at is stamped when a saved entry or
+-- session first meets it.
+CREATE TABLE IF NOT EXISTS goals (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    metric_type_id INTEGER,
+    exercise_id INTEGER,
+    direction TEXT NOT NULL CHECK(direction IN ('at_most', 'at_least')),
+    target REAL NOT NULL,
+    reps INTEGER CHECK(reps > 0),
+    target_date DATE,
+    start_value REAL,
+    notes TEXT,
+    achieved_at TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    CHECK((metric_type_id IS NULL) != (exercise_id IS NULL)),
+    FOREIGN KEY (metric_type_id) REFERENCES metric_types(id) ON DELETE CASCADE,
+    FOREIGN KEY (exercise_id) REFERENCES exercises(id) ON DELETE CASCADE
+);
+
+-- Default category and exercise type seeds. Only an empty table is seeded,
+-- so defaults the user deletes stay deleted.
+INSERT INTO categories (name, order_index, is_default)
+    SELECT * FROM (VALUES
+        ('Legs-Push', 0, 1),
+        ('Legs-Pull', 1, 1),
+        ('Arms-Push', 2, 1),
+        ('Arms-Pull', 3, 1),
+        ('Core-Push', 4, 1),
+        ('Core-Pull', 5, 1))
+    WHERE NOT EXISTS (SELECT 1 FROM categories);
+
+INSERT INTO exercise_types
+    (name, label, uses_weight, set_unit, pr_metric, pr_direction, progression, counts_volume, counts_tonnage, bodyweight_load, order_index, is_default)
+    SELECT * FROM (VALUES
+        ('weight', 'Weight', 1, 'reps', 'weight', 'higher', 'weight', 1, 1, 'none', 0, 1),
+        ('bodyweight', 'Bodyweight', 1, 'reps', 'weight', 'higher', 'completion', 1, 0, 'add', 1, 1),
+        ('assisted', 'Assisted (reverse progression)', 1, 'reps', 'weight', 'lower', 'weight', 1, 0, 'subtract', 2, 1),
+        ('cardio', 'Cardio', 0, 'none', 'none', 'higher', 'none', 0, 0, 'none', 3, 1),
+        ('carry', 'Carry (distance + weight)', 1, 'laps', 'weight', 'higher', 'weight', 1, 1, 'none', 4, 1),
+        ('timed_hold', 'Timed Hold (seconds)', 1, 'seconds', 'volume', 'higher', 'completion', 1, 0, 'none', 5, 1))
+    WHERE NOT EXISTS (SELECT 1 FROM exercise_types);
+
+-- Default metric seeds, guarded like categories so renamed and deleted
+-- defaults aren't seeded again. The computed ones are seeded once into
+-- tables from before computed metrics.
+INSERT INTO metric_types (name, unit, color, order_index, is_default, is_bodyweight)
+    SELECT * FROM (VALUES
+        ('Weight', 'kg', '#00E5FF', 0, 1, 1),
+        ('Body Fat %', '%', '#FF9800', 1, 1, 0),
+        ('Waist', 'cm', '#00C853', 2, 1, 0))
+    WHERE NOT EXISTS (SELECT 1 FROM metric_types);
+
+INSERT OR IGNORE INTO metric_types (name, unit, color, order_index, is_default, formula)
+    SELECT * FROM (VALUES
+        ('BMI', 'kg/m²', '#E040FB', 3, 1, '{Weight} / (height / 100) ^ 2'),
+        ('Lean Mass', 'kg', '#2979FF', 4, 1, '{Weight} * (1 - {Body Fat %} / 100)'),
+        ('Waist-to-Height', 'ratio', '#FFD600', 5, 1, '{Waist} / height'))
+    WHERE NOT EXISTS (SELECT 1 FROM metric_types WHERE formula IS NOT NULL);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_metric_types_bodyweight ON metric_types(is_bodyweight) WHERE is_bodyweight = 1;
+
+-- Muscles (targets for per-muscle volume accounting)
+CREATE TABLE IF NOT EXISTS muscles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    order_index INTEGER NOT NULL DEFAULT 0,
+    is_default BOOLEAN DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_muscles_order ON muscles(order_index);
+
+-- Muscles worked by each exercise. weight is the fraction of a set credited
+-- to the muscle (1 for primary movers, 0.5 for secondary by default).
+CREATE TABLE IF NOT EXISTS exercise_muscles (
+    exercise_id INTEGER NOT NULL,
+    muscle_id INTEGER NOT NULL,
+    role TEXT NOT NULL CHECK(role IN ('primary', 'secondary')),
+    weight REAL NOT NULL CHECK(weight > 0 AND weight <= 1),
+    PRIMARY KEY (exercise_id, muscle_id),
+    FOREIGN KEY (exercise_id) REFERENCES exercises(id) ON DELETE CASCADE,
+    FOREIGN KEY (muscle_id) REFERENCES muscles(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_exercise_muscles_muscle ON exercise_muscles(muscle_id);
+
+-- Default muscle seeds, guarded like categories so deleted defaults stay deleted
+INSERT INTO muscles (name, order_index, is_default)
+    SELECT * FROM (VALUES
+        ('Chest', 0, 1),
+        ('Front Delts', 1, 1),
+        ('Side Delts', 2, 1),
+        ('Rear Delts', 3, 1),
+        ('Lats', 4, 1),
+        ('Upper Back', 5, 1),
+        ('Biceps', 6, 1),
+        ('Triceps', 7, 1),
+        ('Forearms', 8, 1),
+        ('Abs', 9, 1),
+        ('Obliques', 10, 1),
+        ('Lower Back', 11, 1),
+        ('Glutes', 12, 1),
+        ('Quads', 13, 1),
+        ('Hamstrings', 14, 1),
+        ('Adductors', 15, 1),
+        ('Calves', 16, 1))
+    WHERE NOT EXISTS (SELECT 1 FROM muscles);
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"train/db"
+)
+
+const (
+	// defaultAnomalyThreshold is the modified z-score beyond which an entry
+	// is an outlier, as Iglewicz and Hoaglin recommend
+	defaultAnomalyThreshold = 3.5
+	// defaultAnomalyWindow is how many entries either side of an entry its
+	// expected value is the median of
+	defaultAnomalyWindow = 7
+	// maxAnomalyWindow bounds the window parameter
+	maxAnomalyWindow = 90
+)
+
+// median returns the median of values, which must not be empty
+func median(values []float64) float64 {
+	sorted := slices.Sorted(slices.Values(values))
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// findAnomalies lists the entries worth reviewing, newest first, from a
+// metric's entries oldest first. An entry outside limits is below_min or
+// above_max. One that changes more than max_daily_change a day from the
+// last earlier-dated entry not flagged itself is a jump, so the entry after
+// a typo isn't flagged as well. Otherwise it is an outlier when it stands
+// out from its neighbours: its expected value is the median of up to
+// window entries either side of it, so a steady trend isn't flagged, and
+// its score the modified z-score of its residual among all residuals – the
+// distance from the median residual over the median absolute deviation
+// (MAD) scaled to a standard deviation. When most residuals are equal the
+// MAD is 0 and the mean absolute deviation, scaled likewise, stands in.
+func findAnomalies(entries []db.MetricEntry, limits db.MetricLimits, window int, threshold float64) []MetricAnomaly {
+	residuals := make([]float64, len(entries))
+	expected := make([]float64, len(entries))
+	for i, e := range entries {
+		neighbours := []float64{}
+		for j := max(0, i-window); j <= min(len(entries)-1, i+window); j++ {
+			if j != i {
+				neighbours = append(neighbours, entries[j].Value)
+			}
+		}
+		expected[i] = e.Value
+		if len(neighbours) > 0 {
+			expected[i] = median(neighbours)
+		}
+		residuals[i] = e.Value - expected[i]
+	}
+
+	var center, scale float64
+	if len(entries) >= 3 {
+		center = median(residuals)
+		deviations := make([]float64, len(residuals))
+		sum := 0.0
+		for i, r := range residuals {
+			deviations[i] = math.Abs(r - center)
+			sum += deviations[i]
+		}
+		if scale = median(deviations) / 0.6745; scale == 0 {
+			scale = sum / float64(len(deviations)) * 1.2533
+		}
+	}
+
+	reasons := make([]string, len(entries))
+	// base is the last unflagged entry on an earlier date than entry i,
+	// latest the last unflagged entry so far
+	var base, latest *db.MetricEntry
+	for i := range entries {
+		e := &entries[i]
+		if latest != nil && entryDay(*latest) != entryDay(*e) {
+			base = latest
+		}
+		switch {
+		case limits.Min != nil && e.Value < *limits.Min:
+			reasons[i] = "below_min"
+		case limits.Max != nil && e.Value > *limits.Max:
+			reasons[i] = "above_max"
+		case base != nil && jumped(*base, *e, limits.MaxDailyChange):
+			reasons[i] = "jump"
+		default:
+			latest = e
+		}
+	}
+
+	anomalies := []MetricAnomaly{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		a := MetricAnomaly{MetricEntry: entries[i], Reason: reasons[i]}
+		if scale > 0 {
+			want := math.Round(expected[i]*100) / 100
+			score := math.Round((residuals[i]-center)/scale*100) / 100
+			a.Expected, a.Score = &want, &score
+		}
+		if a.Reason == "" && a.Score != nil && math.Abs(*a.Score) > threshold {
+			a.Reason = "outlier"
+		}
+		if a.Reason != "" {
+			anomalies = append(anomalies, a)
+		}
+	}
+	return anomalies
+}
+
+// entryDay is the date of an entry, without any time part
+func entryDay(e db.MetricEntry) string {
+	return e.EntryDate[:min(len(e.EntryDate), len(dateLayout))]
+}
+
+// jumped reports whether e changed more than limit a day from base, an
+// entry on an earlier date. A nil limit never jumps.
+func jumped(base, e db.MetricEntry, limit *float64) bool {
+	if limit == nil {
+		return false
+	}
+	from, err := time.Parse(dateLayout, entryDay(base))
+	if err != nil {
+		return false
+	}
+	to, err := time.Parse(dateLayout, entryDay(e))
+	if err != nil {
+		return false
+	}
+	return math.Abs(e.Value-base.Value) > *limit*float64(daysBetween(from, to))
+}
+
+// getAnomalies lists a metric's entries worth reviewing (see
+// findAnomalies). threshold and window tune the outlier test. Computed
+// metrics have no entries of their own, so they have no anomalies.
+func (h *MetricsHandler) getAnomalies(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		invalidID(w, r, "metric type")
+		return
+	}
+	q := r.URL.Query()
+	threshold := defaultAnomalyThreshold
+	if s := q.Get("threshold"); s != "" {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || !(v > 0) || math.IsInf(v, 0) {
+			fieldError(w, r, "threshold", "threshold must be a positive number")
+			return
+		}
+		threshold = v
+	}
+	window := defaultAnomalyWindow
+	if s := q.Get("window"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 1 || v > maxAnomalyWindow {
+			fieldError(w, r, "window", "window must be between 1 and "+strconv.Itoa(maxAnomalyWindow))
+			return
+		}
+		window = v
+	}
+
+	mt, err := h.DB.GetMetricType(id)
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	if mt == nil {
+		notFound(w, r, "Metric type not found")
+		return
+	}
+	entries, err := h.DB.GetMetricEntries(id)
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MetricAnomaliesResponse{
+		Anomalies: findAnomalies(entries, mt.Limits, window, threshold),
+		Threshold: threshold,
+		Window:    window,
+	})
+}
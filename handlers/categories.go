@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"train/db"
+)
+
+// CategoriesHandler manages the exercise categories
+type CategoriesHandler struct {
+	DB *db.DB
+}
+
+// routes mounts the category endpoints
+func (h *CategoriesHandler) routes(rt *router) {
+	rt.handle(http.MethodGet, "/categories", h.listCategories)
+	rt.handle(http.MethodPost, "/categories", h.createCategory)
+	rt.handle(http.MethodPost, "/categories/reorder", h.reorderCategories)
+	rt.handle(http.MethodPut, "/categories/{id}", h.updateCategory)
+	rt.handle(http.MethodDelete, "/categories/{id}", h.deleteCategory)
+}
+
+// ServeHTTP serves the category endpoints on their own
+func (h *CategoriesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveRoutes(w, r, h.routes)
+}
+
+// listCategories returns the categories in display order with the number of
+// exercises in each
+func (h *CategoriesHandler) listCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.DB.GetCategories()
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	counts, err := countBy(h.DB, "SELECT category, COUNT(*) FROM exercises WHERE category IS NOT NULL GROUP BY category")
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+
+	resp := CategoryListResponse{Categories: []CategorySummary{}}
+	for _, c := range categories {
+		resp.Categories = append(resp.Categories, CategorySummary{Category: c, ExerciseCount: counts[c.Name]})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// createCategory adds a category
+func (h *CategoriesHandler) createCategory(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name       string `json:"name"`
+		OrderIndex int    `json:"order_index"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		invalidJSON(w, r)
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		fieldError(w, r, "name", "name is required")
+		return
+	}
+
+	id, err := h.DB.CreateCategory(strings.TrimSpace(req.Name), req.OrderIndex)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			conflict(w, r, "name", "Category with this name already exists")
+			return
+		}
+		internalError(w, r, "Failed to create category", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, CreatedResponse{ID: id, Message: "Category created successfully"})
+}
+
+// updateCategory renames or reorders a category. Exercises follow a rename.
+func (h *CategoriesHandler) updateCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		invalidID(w, r, "category")
+		return
+	}
+
+	var req struct {
+		Name       *string `json:"name"`
+		OrderIndex *int    `json:"order_index"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		invalidJSON(w, r)
+		return
+	}
+
+	updates := []string{}
+	args := []interface{}{}
+	if req.Name != nil {
+		if strings.TrimSpace(*req.Name) == "" {
+			fieldError(w, r, "name", "Name cannot be empty")
+			return
+		}
+		updates = append(updates, "name = ?")
+		args = append(args, strings.TrimSpace(*req.Name))
+	}
+	if req.OrderIndex != nil {
+		updates = append(updates, "order_index = ?")
+		args = append(args, *req.OrderIndex)
+	}
+	if len(updates) == 0 {
+		badRequest(w, r, "No fields to update")
+		return
+	}
+
+	result, err := h.DB.Exec("UPDATE categories SET "+strings.Join(updates, ", ")+" WHERE id = ?", append(args, id)...)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			conflict(w, r, "name", "Category with this name already exists")
+			return
+		}
+		internalError(w, r, "Failed to update category", err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		notFound(w, r, "Category not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Category updated successfully"})
+}
+
+// deleteCategory removes a category; its exercises become uncategorised
+func (h *CategoriesHandler) deleteCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		invalidID(w, r, "category")
+		return
+	}
+
+	result, err := h.DB.Exec("DELETE FROM categories WHERE id = ?", id)
+	if err != nil {
+		internalError(w, r, "Failed to delete category", err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		notFound(w, r, "Category not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Category deleted successfully"})
+}
+
+// reorderCategories updates the order_index of several categories
+func (h *CategoriesHandler) reorderCategories(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Categories []struct {
+			ID         int `json:"id"`
+			OrderIndex int `json:"order_index"`
+		} `json:"categories"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		invalidJSON(w, r)
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		internalError(w, r, "Failed to begin transaction", err)
+		return
+	}
+	defer tx.Rollback()
+
+	for _, c := range req.Categories {
+		if _, err := tx.Exec("UPDATE categories SET order_index = ? WHERE id = ?", c.OrderIndex, c.ID); err != nil {
+			internalError(w, r, "Failed to update category order", err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		internalError(w, r, "Failed to commit transaction", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Categories reordered successfully"})
+}
+
+// requireCategory writes a field error on field and returns false unless
+// name is a category
+func requireCategory(w http.ResponseWriter, r *http.Request, database *db.DB, field, name string) bool {
+	categories, err := database.GetCategories()
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return false
+	}
+	names := make([]string, len(categories))
+	for i, c := range categories {
+		if c.Name == name {
+			return true
+		}
+		names[i] = c.Name
+	}
+	fieldError(w, r, field, "Invalid category. Must be one of "+strings.Join(names, ", "))
+	return false
+}
+
+// countBy runs a query returning (name, count) rows and collects them by name
+func countBy(database *db.DB, query string) (map[string]int, error) {
+	rows, err := database.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var name string
+		var n int
+		if err := rows.Scan(&name, &n); err != nil {
+			return nil, err
+		}
+		counts[name] = n
+	}
+	return counts, rows.Err()
+}
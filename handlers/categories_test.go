@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"train/db"
+)
+
+func newCategoriesHandler(t *testing.T) *CategoriesHandler {
+	t.Helper()
+	database, err := db.OpenForTesting()
+	if err != nil {
+		t.Fatalf("OpenForTesting: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	if _, err := database.CreateExercise("Squat", "weight", "Legs-Push", nil, nil, nil); err != nil {
+		t.Fatalf("CreateExercise: %v", err)
+	}
+	return &CategoriesHandler{DB: database}
+}
+
+func serveCategories(h *CategoriesHandler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func squatCategory(t *testing.T, database *db.DB) *string {
+	t.Helper()
+	ex, err := database.GetExerciseByName("Squat")
+	if err != nil || ex == nil {
+		t.Fatalf("GetExerciseByName: %+v, %v", ex, err)
+	}
+	if ex.Category == "" {
+		return nil
+	}
+	return &ex.Category
+}
+
+func TestCategory_RenameAndDeleteFollowThroughToExercises(t *testing.T) {
+	h := newCategoriesHandler(t)
+	var id string
+	h.DB.QueryRow("SELECT id FROM categories WHERE name = 'Legs-Push'").Scan(&id)
+
+	if w := serveCategories(h, http.MethodPut, "/api/v1/categories/"+id, `{"name": "Quads"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := squatCategory(t, h.DB); got == nil || *got != "Quads" {
+		t.Errorf("expected Squat to move to Quads, got %v", got)
+	}
+
+	if w := serveCategories(h, http.MethodDelete, "/api/v1/categories/"+id, ""); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := squatCategory(t, h.DB); got != nil {
+		t.Errorf("expected Squat to be uncategorised, got %q", *got)
+	}
+	if w := serveCategories(h, http.MethodDelete, "/api/v1/categories/"+id, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 deleting twice, got %d", w.Code)
+	}
+}
+
+func TestCategory_DuplicateNameIsConflict(t *testing.T) {
+	h := newCategoriesHandler(t)
+
+	if w := serveCategories(h, http.MethodPost, "/api/v1/categories", `{"name": "Legs-Pull"}`); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 creating a duplicate, got %d: %s", w.Code, w.Body.String())
+	}
+	var id string
+	h.DB.QueryRow("SELECT id FROM categories WHERE name = 'Legs-Push'").Scan(&id)
+	if w := serveCategories(h, http.MethodPut, "/api/v1/categories/"+id, `{"name": "Legs-Pull"}`); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 renaming onto another category, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -1,10 +1,9 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
-	"fmt"
 	"net/http"
-	"strings"
 
 	"train/db"
 )
@@ -12,96 +11,68 @@ import (
 // DaysHandler handles day title operations
 type DaysHandler struct {
 	DB *db.DB
+	// Events is notified after each change; nil disables notifications
+	Events *Hub
 }
 
-// ServeHTTP handles day-related requests
-func (h *DaysHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	path := strings.TrimPrefix(r.URL.Path, "/api/days")
-	path = strings.TrimPrefix(path, "/")
-
-	if path == "" {
-		http.Error(w, "Day of week is required", http.StatusBadRequest)
-		return
-	}
+// routes mounts the day title endpoints
+func (h *DaysHandler) routes(rt *router) {
+	rt.handle(http.MethodGet, "/days/{day}", h.getDayTitle)
+	rt.handle(http.MethodPut, "/days/{day}", h.updateDayTitle)
+}
 
-	switch r.Method {
-	case http.MethodGet:
-		h.getDayTitle(w, r, path)
-	case http.MethodPut:
-		h.updateDayTitle(w, r, path)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
+// ServeHTTP serves the day title endpoints on their own
+func (h *DaysHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveRoutes(w, r, h.routes)
 }
 
 // getDayTitle returns the title for a specific day
-func (h *DaysHandler) getDayTitle(w http.ResponseWriter, r *http.Request, day string) {
+func (h *DaysHandler) getDayTitle(w http.ResponseWriter, r *http.Request) {
+	day := r.PathValue("day")
+
 	// Validate day
-	validDays := []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"}
-	valid := false
-	for _, d := range validDays {
-		if day == d {
-			valid = true
-			break
-		}
-	}
-	if !valid {
-		http.Error(w, "Invalid day of week", http.StatusBadRequest)
+	if !isWeekDay(day) {
+		fieldError(w, r, "day", "Invalid day of week")
 		return
 	}
 
+	// A day that was never titled has no row; treat it as an empty title
 	var title string
 	err := h.DB.QueryRow("SELECT title FROM day_titles WHERE day_of_week = ?", day).Scan(&title)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+	if err != nil && err != sql.ErrNoRows {
+		internalError(w, r, "Database error", err)
 		return
 	}
 
-	response := map[string]interface{}{
-		"day_of_week": day,
-		"title":       title,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, http.StatusOK, DayTitleResponse{DayOfWeek: day, Title: title})
 }
 
 // updateDayTitle updates the title for a specific day
-func (h *DaysHandler) updateDayTitle(w http.ResponseWriter, r *http.Request, day string) {
+func (h *DaysHandler) updateDayTitle(w http.ResponseWriter, r *http.Request) {
+	day := r.PathValue("day")
+
 	var req struct {
 		Title string `json:"title"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		invalidJSON(w, r)
 		return
 	}
 
 	// Validate day
-	validDays := []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"}
-	valid := false
-	for _, d := range validDays {
-		if day == d {
-			valid = true
-			break
-		}
-	}
-	if !valid {
-		http.Error(w, "Invalid day of week", http.StatusBadRequest)
+	if !isWeekDay(day) {
+		fieldError(w, r, "day", "Invalid day of week")
 		return
 	}
 
 	// Update day title
 	err := h.DB.CreateDayTitle(day, req.Title)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to update day title: %v", err), http.StatusInternalServerError)
+		internalError(w, r, "Failed to update day title", err)
 		return
 	}
 
-	response := map[string]interface{}{
-		"message": "Day title updated successfully",
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	h.Events.Publish(Event{Type: "day.updated", Day: day})
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Day title updated successfully"})
 }
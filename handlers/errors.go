@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// internalError logs err against the request ID and sends the client only a
+// generic message carrying that ID, so database details never leave the
+// server but a reported failure can still be found in the logs.
+func internalError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	id := RequestID(r)
+	slog.ErrorContext(r.Context(), message,
+		"request_id", id,
+		"method", r.Method,
+		"path", r.URL.Path,
+		"error", err,
+	)
+	writeError(w, r, http.StatusInternalServerError, APIError{
+		Code:    CodeInternal,
+		Message: message + " (error id " + id + ")",
+		ID:      id,
+	})
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Event tells connected clients that data changed, so they can refetch the
+// affected views. Type is "<resource>.<action>", e.g. "history.created".
+type Event struct {
+	Type       string `json:"type"`
+	ID         int64  `json:"id,omitempty"`
+	ExerciseID int    `json:"exercise_id,omitempty"`
+	Day        string `json:"day,omitempty"`
+}
+
+// eventBuffer is how many events a subscriber may fall behind by before it
+// is dropped. Its stream then ends and EventSource reconnects, at which
+// point the PWA reloads everything anyway.
+const eventBuffer = 32
+
+// Hub fans events out from the handlers to every open event stream. A nil
+// *Hub drops events and has no streams, so handlers built without one keep
+// working.
+type Hub struct {
+	mu     sync.Mutex
+	subs   map[chan Event]struct{}
+	closed bool
+}
+
+// NewHub returns a hub with no subscribers
+func NewHub() *Hub {
+	return &Hub{subs: map[chan Event]struct{}{}}
+}
+
+// Publish sends e to every subscriber without blocking
+func (h *Hub) Publish(e Event) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- e:
+		default:
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe returns a channel of future events and a function that stops
+// them. The channel is closed when the subscriber is dropped or the hub
+// closes, and straight away on a nil hub.
+func (h *Hub) subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, eventBuffer)
+	if h == nil {
+		close(ch)
+		return ch, func() {}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	h.subs[ch] = struct{}{}
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subs[ch]; ok {
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Close ends every open stream; register it with http.Server.RegisterOnShutdown
+// so streams don't hold up a graceful shutdown
+func (h *Hub) Close() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for ch := range h.subs {
+		delete(h.subs, ch)
+		close(ch)
+	}
+}
+
+// EventsHandler streams hub events to the PWA as server-sent events
+type EventsHandler struct {
+	Hub *Hub
+	// heartbeat is how often an idle stream sends a comment line so proxies
+	// and phones don't drop it; tests shorten it
+	heartbeat time.Duration
+}
+
+// routes mounts the event stream
+func (h *EventsHandler) routes(rt *router) {
+	rt.handle(http.MethodGet, "/events", h.stream)
+}
+
+// ServeHTTP serves the event stream on its own
+func (h *EventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveRoutes(w, r, h.routes)
+}
+
+// stream writes each event as a data line until the client goes away or the
+// hub closes
+func (h *EventsHandler) stream(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	// The stream is meant to outlive the server's write timeout
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		internalError(w, r, "Failed to start event stream", err)
+		return
+	}
+
+	events, unsubscribe := h.Hub.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := h.heartbeat
+	if heartbeat == 0 {
+		heartbeat = 25 * time.Second
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(e)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		case <-ticker.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
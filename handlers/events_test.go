@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"train/db"
+)
+
+func TestHub_DropsSlowSubscribersAndCloses(t *testing.T) {
+	hub := NewHub()
+	fast, stopFast := hub.subscribe()
+	defer stopFast()
+	slow, stopSlow := hub.subscribe()
+	defer stopSlow()
+
+	for i := range eventBuffer + 1 {
+		hub.Publish(Event{Type: "history.created", ID: int64(i)})
+		if e := <-fast; e.ID != int64(i) {
+			t.Fatalf("fast subscriber got %+v, want id %d", e, i)
+		}
+	}
+	// The slow subscriber's buffer filled up, so it was dropped
+	n := 0
+	for range slow {
+		n++
+	}
+	if n != eventBuffer {
+		t.Errorf("slow subscriber got %d events before being dropped, want %d", n, eventBuffer)
+	}
+
+	hub.Close()
+	if _, ok := <-fast; ok {
+		t.Error("Close should end every subscription")
+	}
+	var nilHub *Hub
+	nilHub.Publish(Event{Type: "ignored"})
+	events, stop := nilHub.subscribe()
+	defer stop()
+	if _, ok := <-events; ok {
+		t.Error("a nil hub should hand out closed subscriptions")
+	}
+	nilHub.Close()
+}
+
+func TestEvents_StreamBroadcastsChanges(t *testing.T) {
+	database, err := db.OpenForTesting()
+	if err != nil {
+		t.Fatalf("OpenForTesting: %v", err)
+	}
+	defer database.Close()
+	id, err := database.CreateExercise("Squat", "weight", "Legs-Push", nil, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateExercise: %v", err)
+	}
+
+	hub := NewHub()
+	mux := http.NewServeMux()
+	Register(mux, database, Options{Events: hub})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/v1/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /events: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	lines := bufio.NewScanner(resp.Body)
+	if !lines.Scan() || lines.Text() != "retry: 3000" {
+		t.Fatalf("expected the retry preamble, got %q", lines.Text())
+	}
+
+	// Another device logs a session
+	body := `{"exercise_id": ` + strconv.Itoa(int(id)) + `, "session_date": "2026-01-12", "weight": 100, "sets_completed": [5, 5, 5], "completed": true}`
+	post, err := http.Post(srv.URL+"/api/v1/history", "application/json", strings.NewReader(body))
+	if err != nil || post.StatusCode != http.StatusCreated {
+		t.Fatalf("POST /history: %v %v", post, err)
+	}
+	post.Body.Close()
+
+	var got Event
+	for lines.Scan() {
+		if data, ok := strings.CutPrefix(lines.Text(), "data: "); ok {
+			if err := json.Unmarshal([]byte(data), &got); err != nil {
+				t.Fatalf("bad event %q: %v", data, err)
+			}
+			break
+		}
+	}
+	if got.Type != "history.created" || got.ExerciseID != int(id) || got.ID == 0 {
+		t.Errorf("unexpected event: %+v", got)
+	}
+
+	// Shutdown ends the stream
+	hub.Close()
+	for lines.Scan() {
+	}
+	if ctx.Err() != nil {
+		t.Error("stream should end when the hub closes")
+	}
+}
@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"train/db"
+)
+
+// ExerciseTypesHandler manages the exercise types, which describe how
+// sessions of each type are recorded, scored and progressed
+type ExerciseTypesHandler struct {
+	DB *db.DB
+}
+
+// Values allowed for each exercise type descriptor
+var (
+	setUnits        = []string{"reps", "laps", "seconds", "none"}
+	prMetrics       = []string{"weight", "volume", "none"}
+	prDirections    = []string{"higher", "lower"}
+	progressions    = []string{"weight", "completion", "none"}
+	bodyweightLoads = []string{"none", "add", "subtract"}
+)
+
+// typeName matches exercise type names, which are stored on each exercise
+var typeName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// routes mounts the exercise type endpoints
+func (h *ExerciseTypesHandler) routes(rt *router) {
+	rt.handle(http.MethodGet, "/exercise-types", h.listTypes)
+	rt.handle(http.MethodPost, "/exercise-types", h.createType)
+	rt.handle(http.MethodPut, "/exercise-types/{name}", h.updateType)
+	rt.handle(http.MethodDelete, "/exercise-types/{name}", h.deleteType)
+}
+
+// ServeHTTP serves the exercise type endpoints on their own
+func (h *ExerciseTypesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveRoutes(w, r, h.routes)
+}
+
+// exerciseTypeInput is the body of POST and PUT /exercise-types. Omitted
+// fields keep their current value, or the weight-style default on create.
+type exerciseTypeInput struct {
+	Name           string  `json:"name"`
+	Label          *string `json:"label"`
+	UsesWeight     *bool   `json:"uses_weight"`
+	SetUnit        *string `json:"set_unit"`
+	PRMetric       *string `json:"pr_metric"`
+	PRDirection    *string `json:"pr_direction"`
+	Progression    *string `json:"progression"`
+	CountsVolume   *bool   `json:"counts_volume"`
+	CountsTonnage  *bool   `json:"counts_tonnage"`
+	BodyweightLoad *string `json:"bodyweight_load"`
+	OrderIndex     *int    `json:"order_index"`
+}
+
+// apply copies the supplied fields onto t
+func (in exerciseTypeInput) apply(t *db.ExerciseType) {
+	if in.Label != nil {
+		t.Label = strings.TrimSpace(*in.Label)
+	}
+	if in.UsesWeight != nil {
+		t.UsesWeight = *in.UsesWeight
+	}
+	if in.SetUnit != nil {
+		t.SetUnit = *in.SetUnit
+	}
+	if in.PRMetric != nil {
+		t.PRMetric = *in.PRMetric
+	}
+	if in.PRDirection != nil {
+		t.PRDirection = *in.PRDirection
+	}
+	if in.Progression != nil {
+		t.Progression = *in.Progression
+	}
+	if in.CountsVolume != nil {
+		t.CountsVolume = *in.CountsVolume
+	}
+	if in.CountsTonnage != nil {
+		t.CountsTonnage = *in.CountsTonnage
+	}
+	if in.BodyweightLoad != nil {
+		t.BodyweightLoad = *in.BodyweightLoad
+	}
+	if in.OrderIndex != nil {
+		t.OrderIndex = *in.OrderIndex
+	}
+}
+
+// validateExerciseType writes a field error and returns false if t's
+// descriptors are not allowed values
+func validateExerciseType(w http.ResponseWriter, r *http.Request, t db.ExerciseType) bool {
+	if t.Label == "" {
+		fieldError(w, r, "label", "label is required")
+		return false
+	}
+	for _, f := range []struct {
+		field, value string
+		allowed      []string
+	}{
+		{"set_unit", t.SetUnit, setUnits},
+		{"pr_metric", t.PRMetric, prMetrics},
+		{"pr_direction", t.PRDirection, prDirections},
+		{"progression", t.Progression, progressions},
+		{"bodyweight_load", t.BodyweightLoad, bodyweightLoads},
+	} {
+		if !slices.Contains(f.allowed, f.value) {
+			fieldError(w, r, f.field, f.field+" must be one of "+strings.Join(f.allowed, ", "))
+			return false
+		}
+	}
+	if t.CountsTonnage && !t.UsesWeight {
+		fieldError(w, r, "counts_tonnage", "counts_tonnage requires uses_weight")
+		return false
+	}
+	return true
+}
+
+// listTypes returns the exercise types in display order with the number of
+// exercises of each
+func (h *ExerciseTypesHandler) listTypes(w http.ResponseWriter, r *http.Request) {
+	types, err := h.DB.GetExerciseTypes()
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	counts, err := countBy(h.DB, "SELECT type, COUNT(*) FROM exercises GROUP BY type")
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+
+	resp := ExerciseTypeListResponse{ExerciseTypes: []ExerciseTypeSummary{}}
+	for _, t := range types {
+		resp.ExerciseTypes = append(resp.ExerciseTypes, ExerciseTypeSummary{ExerciseType: t, ExerciseCount: counts[t.Name]})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// createType adds an exercise type
+func (h *ExerciseTypesHandler) createType(w http.ResponseWriter, r *http.Request) {
+	var req exerciseTypeInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		invalidJSON(w, r)
+		return
+	}
+	if !typeName.MatchString(req.Name) {
+		fieldError(w, r, "name", "name must be lower case letters, digits and underscores, e.g. sled_push")
+		return
+	}
+
+	t := db.ExerciseType{
+		Name:           req.Name,
+		SetUnit:        "reps",
+		PRMetric:       "weight",
+		PRDirection:    "higher",
+		Progression:    "weight",
+		CountsVolume:   true,
+		BodyweightLoad: "none",
+	}
+	req.apply(&t)
+	if req.UsesWeight == nil {
+		t.UsesWeight = true
+	}
+	if req.CountsTonnage == nil {
+		t.CountsTonnage = t.UsesWeight
+	}
+	if !validateExerciseType(w, r, t) {
+		return
+	}
+
+	if err := h.DB.CreateExerciseType(t); err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			conflict(w, r, "name", "Exercise type with this name already exists")
+			return
+		}
+		internalError(w, r, "Failed to create exercise type", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, t)
+}
+
+// updateType changes an exercise type's label or descriptors. The name is
+// fixed because exercises refer to it.
+func (h *ExerciseTypesHandler) updateType(w http.ResponseWriter, r *http.Request) {
+	t, err := h.DB.GetExerciseType(r.PathValue("name"))
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	if t == nil {
+		notFound(w, r, "Exercise type not found")
+		return
+	}
+
+	var req exerciseTypeInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		invalidJSON(w, r)
+		return
+	}
+	if req.Name != "" && req.Name != t.Name {
+		fieldError(w, r, "name", "Exercise types cannot be renamed")
+		return
+	}
+	req.apply(t)
+	if !validateExerciseType(w, r, *t) {
+		return
+	}
+
+	_, err = h.DB.Exec(`
+		UPDATE exercise_types
+		SET label = ?, uses_weight = ?, set_unit = ?, pr_metric = ?, pr_direction = ?, progression = ?,
+			counts_volume = ?, counts_tonnage = ?, bodyweight_load = ?, order_index = ?
+		WHERE name = ?
+	`, t.Label, t.UsesWeight, t.SetUnit, t.PRMetric, t.PRDirection, t.Progression,
+		t.CountsVolume, t.CountsTonnage, t.BodyweightLoad, t.OrderIndex, t.Name)
+	if err != nil {
+		internalError(w, r, "Failed to update exercise type", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, t)
+}
+
+// deleteType removes an exercise type no exercise uses
+func (h *ExerciseTypesHandler) deleteType(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	var inUse int
+	if err := h.DB.QueryRow("SELECT COUNT(*) FROM exercises WHERE type = ?", name).Scan(&inUse); err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	if inUse > 0 {
+		conflict(w, r, "name", "Exercise type is used by exercises; change their type first")
+		return
+	}
+
+	result, err := h.DB.Exec("DELETE FROM exercise_types WHERE name = ?", name)
+	if err != nil {
+		internalError(w, r, "Failed to delete exercise type", err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		notFound(w, r, "Exercise type not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Exercise type deleted successfully"})
+}
+
+// exerciseType returns the named type, or writes a field error on field
+// listing the known types and returns nil
+func exerciseType(w http.ResponseWriter, r *http.Request, database *db.DB, field, name string) *db.ExerciseType {
+	t, err := database.GetExerciseType(name)
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return nil
+	}
+	if t != nil {
+		return t
+	}
+	types, err := database.GetExerciseTypes()
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return nil
+	}
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = t.Name
+	}
+	fieldError(w, r, field, "Invalid type. Must be one of "+strings.Join(names, ", "))
+	return nil
+}
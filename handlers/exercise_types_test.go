@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func serveExerciseTypes(h *ExerciseTypesHandler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestExerciseType_CustomTypeDrivesPR(t *testing.T) {
+	h, _ := newTestHandler(t, "weight")
+	types := &ExerciseTypesHandler{DB: h.DB}
+
+	// Band tension: a lighter band at the same reps is the better session
+	w := serveExerciseTypes(types, http.MethodPost, "/api/v1/exercise-types",
+		`{"name": "band", "label": "Resistance band", "pr_direction": "lower"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	id, err := h.DB.CreateExercise("Band Row", "band", "", nil, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateExercise with custom type: %v", err)
+	}
+
+	postHistory(t, h, int(id), 30, "2026-01-05")
+	if resp := postHistory(t, h, int(id), 20, "2026-01-07"); resp["is_pr"] != true {
+		t.Errorf("expected lighter band to be a PR, got %v", resp["is_pr"])
+	}
+	if resp := postHistory(t, h, int(id), 25, "2026-01-09"); resp["is_pr"] != false {
+		t.Errorf("expected heavier band not to be a PR, got %v", resp["is_pr"])
+	}
+
+	if w := serveExerciseTypes(types, http.MethodDelete, "/api/v1/exercise-types/band", ""); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 deleting a type in use, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestExerciseType_InvalidDescriptorRejected(t *testing.T) {
+	h, _ := newTestHandler(t, "weight")
+	types := &ExerciseTypesHandler{DB: h.DB}
+
+	for name, tc := range map[string]struct{ body, field string }{
+		"bad name":           {`{"name": "Sled Push", "label": "Sled"}`, "name"},
+		"missing label":      {`{"name": "sled"}`, "label"},
+		"bad set unit":       {`{"name": "sled", "label": "Sled", "set_unit": "metres"}`, "set_unit"},
+		"bad direction":      {`{"name": "sled", "label": "Sled", "pr_direction": "up"}`, "pr_direction"},
+		"tonnage unweighted": {`{"name": "sled", "label": "Sled", "uses_weight": false, "counts_tonnage": true}`, "counts_tonnage"},
+	} {
+		w := serveExerciseTypes(types, http.MethodPost, "/api/v1/exercise-types", tc.body)
+		if w.Code != http.StatusBadRequest || !bytes.Contains(w.Body.Bytes(), []byte(`"field":"`+tc.field+`"`)) {
+			t.Errorf("%s: expected 400 on %s, got %d: %s", name, tc.field, w.Code, w.Body.String())
+		}
+	}
+
+	if w := serveExerciseTypes(types, http.MethodPut, "/api/v1/exercise-types/weight", `{"name": "lift"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 renaming a type, got %d", w.Code)
+	}
+	if w := serveExerciseTypes(types, http.MethodPut, "/api/v1/exercise-types/missing", `{"label": "Missing"}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 updating an unknown type, got %d", w.Code)
+	}
+}
@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -13,127 +12,136 @@ import (
 // ExercisesHandler handles CRUD operations for exercises
 type ExercisesHandler struct {
 	DB *db.DB
+	// Events is notified after each change; nil disables notifications
+	Events *Hub
 }
 
-// ServeHTTP handles exercise-related requests
+// routes mounts the exercise endpoints
+func (h *ExercisesHandler) routes(rt *router) {
+	rt.handle(http.MethodGet, "/exercises", h.listExercises)
+	rt.handle(http.MethodPost, "/exercises", h.createExercise)
+	rt.handle(http.MethodGet, "/exercises/{id}", h.getExercise)
+	rt.handle(http.MethodPut, "/exercises/{id}", h.updateExercise)
+	rt.handle(http.MethodDelete, "/exercises/{id}", h.deleteExercise)
+}
+
+// ServeHTTP serves the exercise endpoints on their own
 func (h *ExercisesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Parse path to extract ID if present
-	path := strings.TrimPrefix(r.URL.Path, "/api/exercises")
-	path = strings.TrimPrefix(path, "/")
-
-	switch r.Method {
-	case http.MethodGet:
-		if path == "" {
-			h.listExercises(w, r)
-		} else {
-			h.getExercise(w, r, path)
-		}
-	case http.MethodPost:
-		h.createExercise(w, r)
-	case http.MethodPut:
-		h.updateExercise(w, r, path)
-	case http.MethodDelete:
-		h.deleteExercise(w, r, path)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
+	serveRoutes(w, r, h.routes)
 }
 
-// listExercises returns all exercises with optional filtering
+// exercisePages pages GET /exercises; from/to filter on the creation date
+var exercisePages = pageSpec{
+	defaultLimit: 50,
+	defaultSort:  "name",
+	sorts: map[string]sortColumn{
+		"name": {column: "name"},
+		"type": {column: "type"},
+		"id":   {column: "id", numeric: true},
+	},
+	idColumn:   "id",
+	dateColumn: "date(created_at)",
+}
+
+// listExercises returns a page of exercises with optional filtering
 func (h *ExercisesHandler) listExercises(w http.ResponseWriter, r *http.Request) {
+	pg, ok := exercisePages.parse(w, r)
+	if !ok {
+		return
+	}
+
 	search := r.URL.Query().Get("search")
 	exerciseType := r.URL.Query().Get("type")
 	category := r.URL.Query().Get("category")
 
-	// Build query
-	query := "SELECT id, name, type, category, target_sets, target_reps, target_weight, created_at FROM exercises WHERE 1=1"
+	// Build filters
+	where := []string{}
 	args := []interface{}{}
 
 	if search != "" {
-		query += " AND name LIKE ?"
+		where = append(where, "name LIKE ?")
 		args = append(args, "%"+search+"%")
 	}
 	if exerciseType != "" {
-		query += " AND type = ?"
+		where = append(where, "type = ?")
 		args = append(args, exerciseType)
 	}
 	if category != "" {
-		query += " AND category = ?"
+		where = append(where, "category = ?")
 		args = append(args, category)
 	}
+	where, args = pg.filter(where, args)
 
-	query += " ORDER BY name LIMIT 50"
+	countQuery := "SELECT COUNT(*) FROM exercises"
+	if len(where) > 0 {
+		countQuery += " WHERE " + strings.Join(where, " AND ")
+	}
+	var total int
+	if err := h.DB.QueryRow(countQuery, args...).Scan(&total); err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+
+	clause, args := pg.clause(where, args)
+	query := "SELECT id, name, type, COALESCE(category, ''), target_sets, target_reps, target_weight, created_at, " +
+		pg.column.keyExpr() + " FROM exercises" + clause
 
 	rows, err := h.DB.Query(query, args...)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		internalError(w, r, "Database error", err)
 		return
 	}
 	defer rows.Close()
 
-	exercises := []map[string]interface{}{}
+	exercises := []db.Exercise{}
+	var lastKey interface{}
+	n := 0
 	for rows.Next() {
-		var id int
-		var name, exerciseType, createdAt string
-		var category *string
-		var targetSets, targetReps *int
-		var targetWeight *float64
-
-		if err := rows.Scan(&id, &name, &exerciseType, &category, &targetSets, &targetReps, &targetWeight, &createdAt); err != nil {
-			http.Error(w, fmt.Sprintf("Scan error: %v", err), http.StatusInternalServerError)
+		var ex db.Exercise
+		var key interface{}
+		if err := rows.Scan(&ex.ID, &ex.Name, &ex.Type, &ex.Category, &ex.TargetSets, &ex.TargetReps, &ex.TargetWeight, &ex.CreatedAt, &key); err != nil {
+			internalError(w, r, "Scan error", err)
 			return
 		}
-
-		exercise := map[string]interface{}{
-			"id":         id,
-			"name":       name,
-			"type":       exerciseType,
-			"created_at": createdAt,
-		}
-		if category != nil {
-			exercise["category"] = *category
-		}
-		if targetSets != nil {
-			exercise["target_sets"] = *targetSets
-		}
-		if targetReps != nil {
-			exercise["target_reps"] = *targetReps
-		}
-		if targetWeight != nil {
-			exercise["target_weight"] = *targetWeight
+		if n++; n <= pg.limit {
+			exercises = append(exercises, ex)
+			lastKey = key
 		}
-
-		exercises = append(exercises, exercise)
 	}
-
-	response := map[string]interface{}{
-		"exercises": exercises,
+	if err := rows.Err(); err != nil {
+		internalError(w, r, "Database error", err)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	lastID := 0
+	if len(exercises) > 0 {
+		lastID = exercises[len(exercises)-1].ID
+	}
+	writeJSON(w, http.StatusOK, ExerciseListResponse{
+		Exercises: exercises,
+		Page:      pg.result(total, n, lastKey, lastID),
+	})
 }
 
 // getExercise returns a single exercise by ID
-func (h *ExercisesHandler) getExercise(w http.ResponseWriter, r *http.Request, idStr string) {
-	id, err := strconv.Atoi(idStr)
+func (h *ExercisesHandler) getExercise(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		http.Error(w, "Invalid exercise ID", http.StatusBadRequest)
+		invalidID(w, r, "exercise")
 		return
 	}
 
 	exercise, err := h.DB.GetExerciseByID(id)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		internalError(w, r, "Database error", err)
 		return
 	}
 	if exercise == nil {
-		http.Error(w, "Exercise not found", http.StatusNotFound)
+		notFound(w, r, "Exercise not found")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(exercise)
+	writeJSON(w, http.StatusOK, exercise)
 }
 
 // createExercise creates a new exercise
@@ -148,69 +156,58 @@ func (h *ExercisesHandler) createExercise(w http.ResponseWriter, r *http.Request
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		invalidJSON(w, r)
 		return
 	}
 
 	// Validate required fields
-	if req.Name == "" || req.Type == "" {
-		http.Error(w, "Name and type are required", http.StatusBadRequest)
+	if strings.TrimSpace(req.Name) == "" {
+		fieldError(w, r, "name", "Name is required")
+		return
+	}
+	if req.Type == "" {
+		fieldError(w, r, "type", "Type is required")
 		return
 	}
 
 	// Validate type
-	validTypes := map[string]bool{"cardio": true, "weight": true, "bodyweight": true, "assisted": true, "carry": true, "timed_hold": true}
-	if !validTypes[req.Type] {
-		http.Error(w, "Invalid type. Must be cardio, weight, bodyweight, assisted, carry, or timed_hold", http.StatusBadRequest)
+	if exerciseType(w, r, h.DB, "type", req.Type) == nil {
 		return
 	}
 
 	// Validate category if provided
-	validCategories := []string{"Legs-Push", "Legs-Pull", "Arms-Push", "Arms-Pull", "Core-Push", "Core-Pull"}
-	if req.Category != nil && *req.Category != "" {
-		valid := false
-		for _, cat := range validCategories {
-			if *req.Category == cat {
-				valid = true
-				break
-			}
-		}
-		if !valid {
-			http.Error(w, "Invalid category", http.StatusBadRequest)
-			return
-		}
-	}
-
 	category := ""
 	if req.Category != nil {
 		category = *req.Category
 	}
+	if category != "" && !requireCategory(w, r, h.DB, "category", category) {
+		return
+	}
+
+	if field, msg := validateTargets(req.TargetSets, req.TargetReps, req.TargetWeight); field != "" {
+		fieldError(w, r, field, msg)
+		return
+	}
 
 	id, err := h.DB.CreateExercise(req.Name, req.Type, category, req.TargetSets, req.TargetReps, req.TargetWeight)
 	if err != nil {
 		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
-			http.Error(w, "Exercise with this name already exists", http.StatusConflict)
+			conflict(w, r, "name", "Exercise with this name already exists")
 			return
 		}
-		http.Error(w, fmt.Sprintf("Failed to create exercise: %v", err), http.StatusInternalServerError)
+		internalError(w, r, "Failed to create exercise", err)
 		return
 	}
 
-	response := map[string]interface{}{
-		"id":      id,
-		"message": "Exercise created successfully",
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(response)
+	h.Events.Publish(Event{Type: "exercise.created", ID: id})
+	writeJSON(w, http.StatusCreated, CreatedResponse{ID: id, Message: "Exercise created successfully"})
 }
 
 // updateExercise updates an existing exercise
-func (h *ExercisesHandler) updateExercise(w http.ResponseWriter, r *http.Request, idStr string) {
-	id, err := strconv.Atoi(idStr)
+func (h *ExercisesHandler) updateExercise(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		http.Error(w, "Invalid exercise ID", http.StatusBadRequest)
+		invalidID(w, r, "exercise")
 		return
 	}
 
@@ -224,7 +221,22 @@ func (h *ExercisesHandler) updateExercise(w http.ResponseWriter, r *http.Request
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		invalidJSON(w, r)
+		return
+	}
+
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		fieldError(w, r, "name", "Name cannot be empty")
+		return
+	}
+	if req.Type != nil && exerciseType(w, r, h.DB, "type", *req.Type) == nil {
+		return
+	}
+	if req.Category != nil && *req.Category != "" && !requireCategory(w, r, h.DB, "category", *req.Category) {
+		return
+	}
+	if field, msg := validateTargets(req.TargetSets, req.TargetReps, req.TargetWeight); field != "" {
+		fieldError(w, r, field, msg)
 		return
 	}
 
@@ -242,7 +254,7 @@ func (h *ExercisesHandler) updateExercise(w http.ResponseWriter, r *http.Request
 	}
 	if req.Category != nil {
 		updates = append(updates, "category = ?")
-		args = append(args, *req.Category)
+		args = append(args, nullIfEmpty(*req.Category))
 	}
 	if req.TargetSets != nil {
 		updates = append(updates, "target_sets = ?")
@@ -258,7 +270,7 @@ func (h *ExercisesHandler) updateExercise(w http.ResponseWriter, r *http.Request
 	}
 
 	if len(updates) == 0 {
-		http.Error(w, "No fields to update", http.StatusBadRequest)
+		badRequest(w, r, "No fields to update")
 		return
 	}
 
@@ -268,48 +280,65 @@ func (h *ExercisesHandler) updateExercise(w http.ResponseWriter, r *http.Request
 	query := "UPDATE exercises SET " + strings.Join(updates, ", ") + " WHERE id = ?"
 	result, err := h.DB.Exec(query, args...)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to update exercise: %v", err), http.StatusInternalServerError)
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			conflict(w, r, "name", "Exercise with this name already exists")
+			return
+		}
+		internalError(w, r, "Failed to update exercise", err)
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		http.Error(w, "Exercise not found", http.StatusNotFound)
+		notFound(w, r, "Exercise not found")
 		return
 	}
 
-	response := map[string]interface{}{
-		"message": "Exercise updated successfully",
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	h.Events.Publish(Event{Type: "exercise.updated", ID: int64(id)})
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Exercise updated successfully"})
 }
 
 // deleteExercise deletes an exercise
-func (h *ExercisesHandler) deleteExercise(w http.ResponseWriter, r *http.Request, idStr string) {
-	id, err := strconv.Atoi(idStr)
+func (h *ExercisesHandler) deleteExercise(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		http.Error(w, "Invalid exercise ID", http.StatusBadRequest)
+		invalidID(w, r, "exercise")
 		return
 	}
 
 	result, err := h.DB.Exec("DELETE FROM exercises WHERE id = ?", id)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to delete exercise: %v", err), http.StatusInternalServerError)
+		internalError(w, r, "Failed to delete exercise", err)
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		http.Error(w, "Exercise not found", http.StatusNotFound)
+		notFound(w, r, "Exercise not found")
 		return
 	}
 
-	response := map[string]interface{}{
-		"message": "Exercise deleted successfully",
-	}
+	h.Events.Publish(Event{Type: "exercise.deleted", ID: int64(id)})
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Exercise deleted successfully"})
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+// validateTargets returns the first target field that is negative, if any
+func validateTargets(sets, reps *int, weight *float64) (field, message string) {
+	switch {
+	case sets != nil && *sets < 0:
+		return "target_sets", "target_sets cannot be negative"
+	case reps != nil && *reps < 0:
+		return "target_reps", "target_reps cannot be negative"
+	case weight != nil && *weight < 0:
+		return "target_weight", "target_weight cannot be negative"
+	}
+	return "", ""
+}
+
+// nullIfEmpty stores empty strings as NULL
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
 }
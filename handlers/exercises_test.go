@@ -75,3 +75,39 @@ func TestExercise_DuplicateNameRejected(t *testing.T) {
 		t.Errorf("duplicate exercise name should return 409, got %d", code)
 	}
 }
+
+// --- Error envelope tests ---
+
+func TestExercise_ValidationErrorNamesField(t *testing.T) {
+	h := newExercisesHandler(t)
+	body, _ := json.Marshal(map[string]interface{}{
+		"name":     "Leg Press",
+		"type":     "weight",
+		"category": "Legs-Sideways",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/exercises", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid category should return 400, got %d", w.Code)
+	}
+	var resp ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("error body should be a JSON envelope: %v", err)
+	}
+	if resp.Error.Code != CodeValidation || resp.Error.Field != "category" {
+		t.Errorf("expected validation_failed on category, got %+v", resp.Error)
+	}
+}
+
+func TestExercise_DuplicateNameIsConflictCode(t *testing.T) {
+	h := newExercisesHandler(t)
+	createExerciseRequest(t, h, "Leg Press", "weight")
+	_, resp := createExerciseRequest(t, h, "Leg Press", "weight")
+
+	apiErr, _ := resp["error"].(map[string]interface{})
+	if apiErr["code"] != CodeConflict || apiErr["field"] != "name" {
+		t.Errorf("duplicate name should be a conflict on name, got %v", resp)
+	}
+}
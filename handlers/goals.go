@@ -0,0 +1,618 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"train/db"
+)
+
+// GoalsHandler handles goals for body metrics and lifts
+type GoalsHandler struct {
+	DB *db.DB
+	// Events is notified after each change; nil disables notifications
+	Events *Hub
+}
+
+// goalDirections are the ways a metric goal can be met
+var goalDirections = []string{"at_most", "at_least"}
+
+// goalTrendDays is how far back from the latest value the ETA's trend
+// line is fitted
+const goalTrendDays = 90
+
+// routes mounts the goal endpoints
+func (h *GoalsHandler) routes(rt *router) {
+	rt.handle(http.MethodGet, "/goals", h.listGoals)
+	rt.handle(http.MethodPost, "/goals", h.createGoal)
+	rt.handle(http.MethodGet, "/goals/{id}", h.getGoal)
+	rt.handle(http.MethodPut, "/goals/{id}", h.updateGoal)
+	rt.handle(http.MethodDelete, "/goals/{id}", h.deleteGoal)
+}
+
+// ServeHTTP serves the goal endpoints on their own
+func (h *GoalsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveRoutes(w, r, h.routes)
+}
+
+// goalMet reports whether current meets the goal
+func goalMet(g db.Goal, current *float64) bool {
+	if current == nil {
+		return false
+	}
+	if g.Direction == "at_most" {
+		return *current <= g.Target
+	}
+	return *current >= g.Target
+}
+
+// metricValues returns a metric's values, newest first: its entries, or
+// for a computed metric its evaluated series
+func metricValues(database *db.DB, mt db.MetricType) ([]MetricPoint, error) {
+	points := []MetricPoint{}
+	if mt.Formula != nil {
+		entries, err := database.ComputeMetricEntries(mt, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			points = append(points, MetricPoint{Date: e.EntryDate, Value: e.Value})
+		}
+		return points, nil
+	}
+
+	rows, err := database.Query(`SELECT CAST(entry_date AS TEXT), value FROM metric_entries
+		WHERE metric_type_id = ? ORDER BY entry_date DESC, id DESC`, mt.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p MetricPoint
+		if err := rows.Scan(&p.Date, &p.Value); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// liftValues returns the heaviest effective load lifted for at least reps
+// reps and its date, plus each session's estimated load for reps reps
+// (from its e1RM, Epley), oldest first
+func liftValues(database *db.DB, t *db.ExerciseType, exerciseID, reps int) (best *float64, bestDate *string, estimates []MetricPoint, err error) {
+	type session struct {
+		date               string
+		weight, bodyweight *float64
+		sets               []int
+	}
+	var sessions []session
+	rows, err := database.Query(`
+		SELECT CAST(h.session_date AS TEXT), h.weight, h.sets_completed, `+db.BodyweightSQL("h.session_date")+`
+		FROM history h WHERE h.exercise_id = ? ORDER BY h.session_date, h.id
+	`, exerciseID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for rows.Next() {
+		var s session
+		var setsJSON string
+		if err := rows.Scan(&s.date, &s.weight, &setsJSON, &s.bodyweight); err != nil {
+			rows.Close()
+			return nil, nil, nil, err
+		}
+		json.Unmarshal([]byte(setsJSON), &s.sets)
+		sessions = append(sessions, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	for _, s := range sessions {
+		l := sessionLoad(t, s.weight, s.bodyweight, s.sets)
+		if l.EffectiveLoad == nil || len(s.sets) == 0 {
+			continue
+		}
+		if slices.Max(s.sets) >= reps && (best == nil || *l.EffectiveLoad > *best) {
+			best, bestDate = l.EffectiveLoad, &s.date
+		}
+		if l.E1RM != nil {
+			estimate := *l.E1RM
+			if reps > 1 {
+				estimate /= 1 + float64(reps)/30
+			}
+			estimates = append(estimates, MetricPoint{Date: s.date, Value: estimate})
+		}
+	}
+	return best, bestDate, estimates, nil
+}
+
+// evaluateGoal works out where a goal stands. A metric goal's current value
+// is its latest; a lift goal's is the heaviest load lifted for the reps. The
+// ETA is when a line fitted through the last goalTrendDays of values (for
+// lifts, of estimated loads for the reps) reaches the target. Progress is
+// measured from the start value. Missing metrics or exercises leave it empty.
+func evaluateGoal(database *db.DB, g db.Goal) (GoalResponse, error) {
+	resp := GoalResponse{Goal: g}
+	var series []MetricPoint
+	if g.MetricTypeID != nil {
+		resp.Kind = "metric"
+		mt, err := database.GetMetricType(*g.MetricTypeID)
+		if err != nil || mt == nil {
+			return resp, err
+		}
+		resp.Name, resp.Unit = mt.Name, mt.Unit
+		if series, err = metricValues(database, *mt); err != nil {
+			return resp, err
+		}
+		if len(series) > 0 {
+			resp.Progress.Current, resp.Progress.CurrentDate = &series[0].Value, &series[0].Date
+		}
+	} else {
+		resp.Kind, resp.Unit = "lift", "kg"
+		ex, err := database.GetExerciseByID(*g.ExerciseID)
+		if err != nil || ex == nil {
+			return resp, err
+		}
+		resp.Name = ex.Name
+		t, err := database.GetExerciseType(ex.Type)
+		if err != nil || t == nil {
+			return resp, err
+		}
+		reps := 1
+		if g.Reps != nil {
+			reps = *g.Reps
+		}
+		if resp.Progress.Current, resp.Progress.CurrentDate, series, err = liftValues(database, t, ex.ID, reps); err != nil {
+			return resp, err
+		}
+	}
+
+	if g.AchievedAt != nil || goalMet(g, resp.Progress.Current) {
+		done := 100.0
+		resp.Progress.Percent = &done
+		return resp, nil
+	}
+	if start, current := g.StartValue, resp.Progress.Current; start != nil && current != nil && *start != g.Target {
+		resp.Progress.Percent = roundTenth(min(max((*current-*start)/(g.Target-*start)*100, 0), 100))
+	}
+
+	points := dailyMeans(series)
+	if len(points) > 0 {
+		cutoff := points[len(points)-1].date.AddDate(0, 0, -goalTrendDays)
+		for len(points) > 0 && points[0].date.Before(cutoff) {
+			points = points[1:]
+		}
+	}
+	if last, perDay, ok := fitLine(points); ok {
+		resp.Progress.ETA = projectDate(points[len(points)-1].date, last, perDay, g.Target)
+	}
+	if resp.Progress.ETA != nil && g.TargetDate != nil {
+		onTrack := *resp.Progress.ETA <= *g.TargetDate
+		resp.Progress.OnTrack = &onTrack
+	}
+	return resp, nil
+}
+
+// goalScope is what a write touched. Only the goals it can have met are
+// checked: those on its metrics or exercises, every computed metric's when
+// computed is set (their inputs changed), and every bodyweight exercise's
+// when one of its metrics is the bodyweight.
+type goalScope struct {
+	metricTypeIDs []int
+	exerciseIDs   []int
+	computed      bool
+}
+
+// metricScope is the scope of a write to a metric's entries, which computed
+// metrics may read
+func metricScope(metricTypeID int) goalScope {
+	return goalScope{metricTypeIDs: []int{metricTypeID}, computed: true}
+}
+
+// add widens scope to cover other too
+func (scope *goalScope) add(other goalScope) {
+	scope.metricTypeIDs = append(scope.metricTypeIDs, other.metricTypeIDs...)
+	scope.exerciseIDs = append(scope.exerciseIDs, other.exerciseIDs...)
+	scope.computed = scope.computed || other.computed
+}
+
+// goalScopeOf is the scope of a goal's own metric or exercise
+func goalScopeOf(g db.Goal) goalScope {
+	if g.MetricTypeID != nil {
+		return goalScope{metricTypeIDs: []int{*g.MetricTypeID}}
+	}
+	return goalScope{exerciseIDs: []int{*g.ExerciseID}}
+}
+
+// touches reports whether scope's write can have changed g's current value.
+// bodyweight is set when the write changed the bodyweight.
+func (scope goalScope) touches(database *db.DB, g db.Goal, bodyweight bool) (bool, error) {
+	if g.MetricTypeID != nil {
+		if slices.Contains(scope.metricTypeIDs, *g.MetricTypeID) {
+			return true, nil
+		}
+		if !scope.computed {
+			return false, nil
+		}
+		mt, err := database.GetMetricType(*g.MetricTypeID)
+		return mt != nil && mt.Formula != nil, err
+	}
+
+	if slices.Contains(scope.exerciseIDs, *g.ExerciseID) {
+		return true, nil
+	}
+	if !bodyweight {
+		return false, nil
+	}
+	ex, err := database.GetExerciseByID(*g.ExerciseID)
+	if err != nil || ex == nil {
+		return false, err
+	}
+	t, err := database.GetExerciseType(ex.Type)
+	return t != nil && t.BodyweightLoad != "none", err
+}
+
+// goalCurrent returns a goal's current value as evaluateGoal works it out,
+// without the series its ETA needs
+func goalCurrent(database *db.DB, g db.Goal) (*float64, error) {
+	if g.MetricTypeID != nil {
+		mt, err := database.GetMetricType(*g.MetricTypeID)
+		if err != nil || mt == nil {
+			return nil, err
+		}
+		if mt.Formula != nil {
+			entries, err := database.ComputeMetricEntries(*mt, "")
+			if err != nil || len(entries) == 0 {
+				return nil, err
+			}
+			return &entries[0].Value, nil
+		}
+		var value float64
+		err = database.QueryRow(`SELECT value FROM metric_entries WHERE metric_type_id = ?
+			ORDER BY entry_date DESC, id DESC LIMIT 1`, mt.ID).Scan(&value)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &value, nil
+	}
+
+	ex, err := database.GetExerciseByID(*g.ExerciseID)
+	if err != nil || ex == nil {
+		return nil, err
+	}
+	t, err := database.GetExerciseType(ex.Type)
+	if err != nil || t == nil {
+		return nil, err
+	}
+	reps := 1
+	if g.Reps != nil {
+		reps = *g.Reps
+	}
+	best, _, _, err := liftValues(database, t, ex.ID, reps)
+	return best, err
+}
+
+// checkGoals stamps the unachieved goals in scope that are now met as
+// achieved and announces each. It runs after every save that can meet a
+// goal, once the save is committed, so a failure is logged rather than
+// failing the save.
+func checkGoals(ctx context.Context, database *db.DB, events *Hub, scope goalScope) {
+	if err := achieveGoals(database, events, scope); err != nil {
+		slog.ErrorContext(ctx, "failed to update goals", "err", err)
+	}
+}
+
+// achieveGoals does checkGoals' work
+func achieveGoals(database *db.DB, events *Hub, scope goalScope) error {
+	bodyweight := false
+	if len(scope.metricTypeIDs) > 0 {
+		id, err := database.BodyweightMetricID()
+		if err != nil {
+			return err
+		}
+		bodyweight = id != 0 && slices.Contains(scope.metricTypeIDs, id)
+	}
+
+	goals, err := database.GetGoals()
+	if err != nil {
+		return err
+	}
+	for _, g := range goals {
+		if g.AchievedAt != nil {
+			continue
+		}
+		touched, err := scope.touches(database, g, bodyweight)
+		if err != nil {
+			return err
+		}
+		if !touched {
+			continue
+		}
+		current, err := goalCurrent(database, g)
+		if err != nil {
+			return err
+		}
+		if !goalMet(g, current) {
+			continue
+		}
+		if err := database.SetGoalAchieved(g.ID); err != nil {
+			return err
+		}
+		events.Publish(Event{Type: "goal.achieved", ID: int64(g.ID)})
+	}
+	return nil
+}
+
+// checkGoal validates a goal for its kind, defaulting a lift goal's
+// direction and reps. It returns the field at fault and why, or a database
+// error.
+func (h *GoalsHandler) checkGoal(g *db.Goal) (field, message string, err error) {
+	if math.IsNaN(g.Target) || math.IsInf(g.Target, 0) {
+		return "target", "target must be a finite number", nil
+	}
+	if g.TargetDate != nil && !isDate(*g.TargetDate) {
+		return "target_date", "target_date must be a YYYY-MM-DD date", nil
+	}
+
+	if g.MetricTypeID != nil {
+		mt, err := h.DB.GetMetricType(*g.MetricTypeID)
+		if err != nil {
+			return "", "", err
+		}
+		if mt == nil {
+			return "metric_type_id", "Metric type not found", nil
+		}
+		if !slices.Contains(goalDirections, g.Direction) {
+			return "direction", "direction must be at_most or at_least", nil
+		}
+		if g.Reps != nil {
+			return "reps", "reps only apply to lift goals", nil
+		}
+		return "", "", nil
+	}
+
+	ex, err := h.DB.GetExerciseByID(*g.ExerciseID)
+	if err != nil {
+		return "", "", err
+	}
+	if ex == nil {
+		return "exercise_id", "Exercise not found", nil
+	}
+	t, err := h.DB.GetExerciseType(ex.Type)
+	if err != nil || t == nil {
+		return "", "", err
+	}
+	if t.SetUnit != "reps" {
+		return "exercise_id", "Lift goals need an exercise counted in reps", nil
+	}
+	if g.Direction == "" {
+		g.Direction = "at_least"
+	}
+	if g.Direction != "at_least" {
+		return "direction", "Lift goals are always at_least", nil
+	}
+	if g.Reps == nil {
+		one := 1
+		g.Reps = &one
+	}
+	if *g.Reps < 1 {
+		return "reps", "reps must be at least 1", nil
+	}
+	if g.Target <= 0 {
+		return "target", "target must be positive", nil
+	}
+	return "", "", nil
+}
+
+// listGoals returns every goal with its progress, unachieved first
+func (h *GoalsHandler) listGoals(w http.ResponseWriter, r *http.Request) {
+	goals, err := h.DB.GetGoals()
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	resp := GoalListResponse{Goals: []GoalResponse{}}
+	for _, g := range goals {
+		status, err := evaluateGoal(h.DB, g)
+		if err != nil {
+			internalError(w, r, "Database error", err)
+			return
+		}
+		resp.Goals = append(resp.Goals, status)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// getGoal returns one goal with its progress
+func (h *GoalsHandler) getGoal(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		invalidID(w, r, "goal")
+		return
+	}
+	g, err := h.DB.GetGoal(id)
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	if g == nil {
+		notFound(w, r, "Goal not found")
+		return
+	}
+	status, err := evaluateGoal(h.DB, *g)
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// createGoal creates a goal for a metric or a lift, recording where it
+// stands now as the start value. A goal that is already met is achieved
+// straight away.
+func (h *GoalsHandler) createGoal(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		MetricTypeID *int     `json:"metric_type_id"`
+		ExerciseID   *int     `json:"exercise_id"`
+		Direction    string   `json:"direction"`
+		Target       *float64 `json:"target"`
+		Reps         *int     `json:"reps"`
+		TargetDate   *string  `json:"target_date"`
+		Notes        *string  `json:"notes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		invalidJSON(w, r)
+		return
+	}
+
+	if (req.MetricTypeID == nil) == (req.ExerciseID == nil) {
+		fieldError(w, r, "metric_type_id", "Set either metric_type_id or exercise_id")
+		return
+	}
+	if req.Target == nil {
+		fieldError(w, r, "target", "target is required")
+		return
+	}
+	g := db.Goal{
+		MetricTypeID: req.MetricTypeID,
+		ExerciseID:   req.ExerciseID,
+		Direction:    req.Direction,
+		Target:       *req.Target,
+		Reps:         req.Reps,
+		TargetDate:   emptyToNil(req.TargetDate),
+		Notes:        emptyToNil(req.Notes),
+	}
+	field, msg, err := h.checkGoal(&g)
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	if field != "" {
+		fieldError(w, r, field, msg)
+		return
+	}
+
+	start, err := evaluateGoal(h.DB, g)
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	g.StartValue = start.Progress.Current
+	id, err := h.DB.CreateGoal(g)
+	if err != nil {
+		internalError(w, r, "Failed to create goal", err)
+		return
+	}
+	checkGoals(r.Context(), h.DB, h.Events, goalScopeOf(g))
+
+	h.Events.Publish(Event{Type: "goal.created", ID: id})
+	writeJSON(w, http.StatusCreated, CreatedResponse{ID: id, Message: "Goal created successfully"})
+}
+
+// updateGoal changes the supplied fields of a goal; its metric or exercise
+// can't change. Changing what meets it clears achieved_at until it is met
+// again. An empty target_date or notes clears it.
+func (h *GoalsHandler) updateGoal(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		invalidID(w, r, "goal")
+		return
+	}
+	var req struct {
+		Direction  *string  `json:"direction"`
+		Target     *float64 `json:"target"`
+		Reps       *int     `json:"reps"`
+		TargetDate *string  `json:"target_date"`
+		Notes      *string  `json:"notes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		invalidJSON(w, r)
+		return
+	}
+
+	g, err := h.DB.GetGoal(id)
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	if g == nil {
+		notFound(w, r, "Goal not found")
+		return
+	}
+	if req.Direction != nil || req.Target != nil || req.Reps != nil {
+		g.AchievedAt = nil
+	}
+	if req.Direction != nil {
+		g.Direction = *req.Direction
+	}
+	if req.Target != nil {
+		g.Target = *req.Target
+	}
+	if req.Reps != nil {
+		g.Reps = req.Reps
+	}
+	if req.TargetDate != nil {
+		g.TargetDate = emptyToNil(req.TargetDate)
+	}
+	if req.Notes != nil {
+		g.Notes = emptyToNil(req.Notes)
+	}
+	field, msg, err := h.checkGoal(g)
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	if field != "" {
+		fieldError(w, r, field, msg)
+		return
+	}
+
+	if err := h.DB.UpdateGoal(*g); err != nil {
+		internalError(w, r, "Failed to update goal", err)
+		return
+	}
+	checkGoals(r.Context(), h.DB, h.Events, goalScopeOf(*g))
+
+	h.Events.Publish(Event{Type: "goal.updated", ID: int64(id)})
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Goal updated successfully"})
+}
+
+// deleteGoal deletes a goal
+func (h *GoalsHandler) deleteGoal(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		invalidID(w, r, "goal")
+		return
+	}
+	found, err := h.DB.DeleteGoal(id)
+	if err != nil {
+		internalError(w, r, "Failed to delete goal", err)
+		return
+	}
+	if !found {
+		notFound(w, r, "Goal not found")
+		return
+	}
+
+	h.Events.Publish(Event{Type: "goal.deleted", ID: int64(id)})
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Goal deleted successfully"})
+}
+
+// emptyToNil treats an empty string as unset
+func emptyToNil(s *string) *string {
+	if s == nil || *s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"train/db"
+)
+
+func serveGoals(h *GoalsHandler, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, path, bytes.NewBufferString(body)))
+	return w
+}
+
+func getGoal(t *testing.T, h *GoalsHandler, id int64) GoalResponse {
+	t.Helper()
+	w := serveGoals(h, http.MethodGet, "/api/v1/goals/"+strconv.FormatInt(id, 10), "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var g GoalResponse
+	json.NewDecoder(w.Body).Decode(&g)
+	return g
+}
+
+func createGoal(t *testing.T, h *GoalsHandler, body string) int64 {
+	t.Helper()
+	w := serveGoals(h, http.MethodPost, "/api/v1/goals", body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created CreatedResponse
+	json.NewDecoder(w.Body).Decode(&created)
+	return created.ID
+}
+
+func TestGoals_MetricGoalProgressAndAchievement(t *testing.T) {
+	database, err := db.OpenForTesting()
+	if err != nil {
+		t.Fatalf("OpenForTesting: %v", err)
+	}
+	defer database.Close()
+	daysAgo := func(n int) string { return time.Now().AddDate(0, 0, -n).Format(dateLayout) }
+	// Waist is metric type 3 in the seeds, shrinking 0.2 cm a day
+	database.CreateMetricEntry(3, daysAgo(20), 90, nil)
+	database.CreateMetricEntry(3, daysAgo(10), 88, nil)
+	h := &GoalsHandler{DB: database}
+	entries := &MetricEntriesHandler{DB: database}
+
+	id := createGoal(t, h, `{"metric_type_id": 3, "direction": "at_most", "target": 85, "target_date": "`+daysAgo(-60)+`"}`)
+	g := getGoal(t, h, id)
+	if g.Kind != "metric" || g.Name != "Waist" || g.StartValue == nil || *g.StartValue != 88 || *g.Progress.Percent != 0 {
+		t.Fatalf("unexpected new goal: %+v", g)
+	}
+	// 3 cm to go at 0.2 cm a day from the last entry
+	if eta := daysAgo(-5); g.Progress.ETA == nil || *g.Progress.ETA != eta || g.Progress.OnTrack == nil || !*g.Progress.OnTrack {
+		t.Errorf("expected an on-track ETA of %s, got %+v", eta, g.Progress)
+	}
+
+	serveMetrics(entries, http.MethodPost, "/api/v1/metric-entries", `{"metric_type_id": 3, "entry_date": "`+daysAgo(0)+`", "value": 86.5}`)
+	if g := getGoal(t, h, id); g.AchievedAt != nil || *g.Progress.Current != 86.5 || *g.Progress.Percent != 50 {
+		t.Fatalf("expected halfway, got %+v", g)
+	}
+	serveMetrics(entries, http.MethodPost, "/api/v1/metric-entries", `{"metric_type_id": 3, "entry_date": "`+daysAgo(0)+`", "value": 84.9}`)
+	if g := getGoal(t, h, id); g.AchievedAt == nil || *g.Progress.Percent != 100 || g.Progress.ETA != nil {
+		t.Fatalf("expected the goal achieved, got %+v", g)
+	}
+
+	// A harder target is no longer achieved
+	if w := serveGoals(h, http.MethodPut, "/api/v1/goals/"+strconv.FormatInt(id, 10), `{"target": 80}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if g := getGoal(t, h, id); g.AchievedAt != nil {
+		t.Errorf("expected achieved_at cleared, got %s", *g.AchievedAt)
+	}
+}
+
+func TestGoals_LiftGoalMetBySession(t *testing.T) {
+	history, squat := newTestHandler(t, "weight")
+	h := &GoalsHandler{DB: history.DB}
+
+	single := createGoal(t, h, `{"exercise_id": `+strconv.Itoa(squat)+`, "target": 140}`)
+	highReps := createGoal(t, h, `{"exercise_id": `+strconv.Itoa(squat)+`, "target": 135, "reps": 12}`)
+
+	postHistory(t, history, squat, 130, "2026-01-05")
+	if g := getGoal(t, h, single); g.Kind != "lift" || g.Direction != "at_least" || *g.Reps != 1 || *g.Progress.Current != 130 || g.AchievedAt != nil {
+		t.Fatalf("unexpected goal after 130kg: %+v", g)
+	}
+	postHistory(t, history, squat, 140, "2026-01-12")
+	if g := getGoal(t, h, single); g.AchievedAt == nil || *g.Progress.CurrentDate != "2026-01-12" {
+		t.Errorf("expected 140kg x1 achieved, got %+v", g)
+	}
+	// Sets of 10 don't count toward 12 reps
+	if g := getGoal(t, h, highReps); g.Progress.Current != nil || g.AchievedAt != nil {
+		t.Errorf("expected no qualifying sets for 12 reps, got %+v", g)
+	}
+
+	var list GoalListResponse
+	json.NewDecoder(serveGoals(h, http.MethodGet, "/api/v1/goals", "").Body).Decode(&list)
+	if len(list.Goals) != 2 || list.Goals[0].ID != int(highReps) {
+		t.Errorf("expected the unachieved goal first, got %+v", list.Goals)
+	}
+	if w := serveGoals(h, http.MethodDelete, "/api/v1/goals/"+strconv.FormatInt(single, 10), ""); w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+	if w := serveGoals(h, http.MethodGet, "/api/v1/goals/"+strconv.FormatInt(single, 10), ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 after delete, got %d", w.Code)
+	}
+}
+
+func TestGoals_OnlyTouchedGoalsChecked(t *testing.T) {
+	history, dips := newTestHandler(t, "bodyweight")
+	h := &GoalsHandler{DB: history.DB}
+	entries := &MetricEntriesHandler{DB: history.DB}
+
+	history.DB.CreateMetricEntry(3, "2026-01-01", 88, nil)
+	waist := createGoal(t, h, `{"metric_type_id": 3, "direction": "at_most", "target": 85}`)
+	dipLoad := createGoal(t, h, `{"exercise_id": `+strconv.Itoa(dips)+`, "target": 85}`)
+	// Met behind the handlers' backs, so only a check finds it
+	history.DB.CreateMetricEntry(3, "2026-01-02", 84, nil)
+
+	// Without a bodyweight the dips have no load yet
+	postHistory(t, history, dips, 10, "2026-01-05")
+	if g := getGoal(t, h, dipLoad); g.AchievedAt != nil {
+		t.Fatalf("expected no load without a bodyweight, got %+v", g)
+	}
+	if g := getGoal(t, h, waist); g.AchievedAt != nil {
+		t.Errorf("a session shouldn't check a waist goal, got %+v", g)
+	}
+
+	// The bodyweight gives the dips a load of 86 kg; the waist is untouched
+	if w := serveMetrics(entries, http.MethodPost, "/api/v1/metric-entries", `{"metric_type_id": 1, "entry_date": "2026-01-05", "value": 76}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if g := getGoal(t, h, dipLoad); g.AchievedAt == nil {
+		t.Errorf("expected the bodyweight to meet the dips goal, got %+v", g)
+	}
+	if g := getGoal(t, h, waist); g.AchievedAt != nil {
+		t.Errorf("a weight entry shouldn't check a waist goal, got %+v", g)
+	}
+
+	serveMetrics(entries, http.MethodPost, "/api/v1/metric-entries", `{"metric_type_id": 3, "entry_date": "2026-01-03", "value": 84.5}`)
+	if g := getGoal(t, h, waist); g.AchievedAt == nil {
+		t.Errorf("expected a waist entry to meet the waist goal, got %+v", g)
+	}
+}
+
+func TestGoals_InvalidGoalsRejected(t *testing.T) {
+	history, squat := newTestHandler(t, "weight")
+	h := &GoalsHandler{DB: history.DB}
+	ex := strconv.Itoa(squat)
+
+	for name, tc := range map[string]struct{ body, field string }{
+		"neither":         {`{"target": 80}`, "metric_type_id"},
+		"both":            {`{"metric_type_id": 1, "exercise_id": ` + ex + `, "target": 80}`, "metric_type_id"},
+		"no target":       {`{"metric_type_id": 1, "direction": "at_most"}`, "target"},
+		"no direction":    {`{"metric_type_id": 1, "target": 80}`, "direction"},
+		"metric reps":     {`{"metric_type_id": 1, "direction": "at_most", "target": 80, "reps": 3}`, "reps"},
+		"unknown metric":  {`{"metric_type_id": 99, "direction": "at_most", "target": 80}`, "metric_type_id"},
+		"lift at_most":    {`{"exercise_id": ` + ex + `, "direction": "at_most", "target": 100}`, "direction"},
+		"zero reps":       {`{"exercise_id": ` + ex + `, "target": 100, "reps": 0}`, "reps"},
+		"bad target date": {`{"exercise_id": ` + ex + `, "target": 100, "target_date": "soon"}`, "target_date"},
+	} {
+		w := serveGoals(h, http.MethodPost, "/api/v1/goals", tc.body)
+		if w.Code != http.StatusBadRequest || !bytes.Contains(w.Body.Bytes(), []byte(`"field":"`+tc.field+`"`)) {
+			t.Errorf("%s: expected 400 on %s, got %d: %s", name, tc.field, w.Code, w.Body.String())
+		}
+	}
+}
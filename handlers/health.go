@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"train/db"
+)
+
+// HealthHandler serves liveness (/healthz) and readiness (/readyz) probes
+type HealthHandler struct {
+	DB *db.DB
+	// Draining is set once shutdown starts so /readyz turns away new traffic
+	// while in-flight requests finish
+	Draining *atomic.Bool
+}
+
+// ServeHTTP handles health probe requests
+func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, r)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/healthz":
+		h.liveness(w, r)
+	case "/readyz":
+		h.readiness(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// liveness reports whether the process can still reach its database
+func (h *HealthHandler) liveness(w http.ResponseWriter, r *http.Request) {
+	if err := h.ping(r.Context()); err != nil {
+		writeHealth(w, http.StatusServiceUnavailable, "database unreachable")
+		return
+	}
+	writeHealth(w, http.StatusOK, "ok")
+}
+
+// readiness additionally fails while the server is draining for shutdown
+func (h *HealthHandler) readiness(w http.ResponseWriter, r *http.Request) {
+	if h.Draining != nil && h.Draining.Load() {
+		writeHealth(w, http.StatusServiceUnavailable, "shutting down")
+		return
+	}
+	if err := h.ping(r.Context()); err != nil {
+		writeHealth(w, http.StatusServiceUnavailable, "database unreachable")
+		return
+	}
+
+	// A ping only proves the file opened; make sure the schema is usable too
+	var n int
+	if err := h.DB.QueryRowContext(r.Context(), "SELECT COUNT(*) FROM exercises").Scan(&n); err != nil {
+		writeHealth(w, http.StatusServiceUnavailable, "database not ready")
+		return
+	}
+	writeHealth(w, http.StatusOK, "ready")
+}
+
+func (h *HealthHandler) ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	return h.DB.PingContext(ctx)
+}
+
+func writeHealth(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, status, HealthResponse{Status: message})
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"train/db"
+)
+
+func TestHealth_ReadyUntilDraining(t *testing.T) {
+	database, err := db.OpenForTesting()
+	if err != nil {
+		t.Fatalf("OpenForTesting: %v", err)
+	}
+	defer database.Close()
+
+	var draining atomic.Bool
+	h := &HealthHandler{DB: database, Draining: &draining}
+
+	probe := func(path string) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	if code := probe("/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz should be 200, got %d", code)
+	}
+	if code := probe("/readyz"); code != http.StatusOK {
+		t.Errorf("/readyz should be 200, got %d", code)
+	}
+
+	draining.Store(true)
+	if code := probe("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz should be 503 while draining, got %d", code)
+	}
+	if code := probe("/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz should stay 200 while draining, got %d", code)
+	}
+
+	database.Close()
+	if code := probe("/healthz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/healthz should be 503 with a closed database, got %d", code)
+	}
+}
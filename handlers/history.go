@@ -4,9 +4,12 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"train/db"
 )
@@ -14,170 +17,338 @@ import (
 // HistoryHandler handles workout history operations
 type HistoryHandler struct {
 	DB *db.DB
+	// Events is notified after each change; nil disables notifications
+	Events *Hub
 }
 
-// ServeHTTP handles history-related requests
-func (h *HistoryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	path := strings.TrimPrefix(r.URL.Path, "/api/history")
-	path = strings.TrimPrefix(path, "/")
-
-	// Split path to handle /api/history/:exercise_id/pr
-	parts := strings.Split(path, "/")
+// routes mounts the history endpoints
+func (h *HistoryHandler) routes(rt *router) {
+	rt.handle(http.MethodGet, "/history/{exerciseID}", h.getHistory)
+	rt.handle(http.MethodGet, "/history/{exerciseID}/pr", h.getPR)
+	rt.handle(http.MethodGet, "/history", h.getHistoryLog)
+	rt.handle(http.MethodPost, "/history", h.createHistory)
+	rt.handle(http.MethodPut, "/history/{id}", h.updateHistory)
+	rt.handle(http.MethodDelete, "/history/{id}", h.deleteHistory)
+}
 
-	if len(parts) >= 2 && parts[1] == "pr" {
-		// GET /api/history/:exercise_id/pr
-		h.getPR(w, r, parts[0])
-		return
-	}
+// ServeHTTP serves the history endpoints on their own
+func (h *HistoryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveRoutes(w, r, h.routes)
+}
 
-	switch r.Method {
-	case http.MethodGet:
-		// GET /api/history/:exercise_id
-		h.getHistory(w, r, parts[0])
-	case http.MethodPost:
-		h.createHistory(w, r)
-	case http.MethodPut:
-		h.updateHistory(w, r, parts[0])
-	case http.MethodDelete:
-		h.deleteHistory(w, r, parts[0])
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
+// historyPages pages an exercise's sessions, newest first by default
+var historyPages = pageSpec{
+	defaultLimit: 200,
+	defaultSort:  "-session_date",
+	sorts: map[string]sortColumn{
+		"session_date": {column: "session_date"},
+		"id":           {column: "id", numeric: true},
+	},
+	idColumn:   "id",
+	dateColumn: "session_date",
 }
 
-// getHistory returns all workout sessions for an exercise
-func (h *HistoryHandler) getHistory(w http.ResponseWriter, r *http.Request, exerciseIDStr string) {
-	exerciseID, err := strconv.Atoi(exerciseIDStr)
+// getHistory returns a page of workout sessions for an exercise
+func (h *HistoryHandler) getHistory(w http.ResponseWriter, r *http.Request) {
+	exerciseID, err := strconv.Atoi(r.PathValue("exerciseID"))
 	if err != nil {
-		http.Error(w, "Invalid exercise ID", http.StatusBadRequest)
+		invalidID(w, r, "exercise")
+		return
+	}
+	pg, ok := historyPages.parse(w, r)
+	if !ok {
 		return
 	}
 
 	// Get exercise name
 	exercise, err := h.DB.GetExerciseByID(exerciseID)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		internalError(w, r, "Database error", err)
 		return
 	}
 	if exercise == nil {
-		http.Error(w, "Exercise not found", http.StatusNotFound)
+		notFound(w, r, "Exercise not found")
+		return
+	}
+	exType, err := h.DB.GetExerciseType(exercise.Type)
+	if err != nil || exType == nil {
+		internalError(w, r, "Failed to load exercise type", err)
 		return
 	}
 
-	// Get history
-	query := `
-		SELECT id, session_date, weight, sets_completed, completed, volume, is_pr, notes
-		FROM history
-		WHERE exercise_id = ?
-		ORDER BY session_date DESC
-		LIMIT 200
-	`
+	where := []string{"exercise_id = ?"}
+	args := []interface{}{exerciseID}
+	where, args = pg.filter(where, args)
+
+	var total int
+	if err := h.DB.QueryRow("SELECT COUNT(*) FROM history WHERE "+strings.Join(where, " AND "), args...).Scan(&total); err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
 
-	rows, err := h.DB.Query(query, exerciseID)
+	clause, args := pg.clause(where, args)
+	query := "SELECT id, session_date, weight, sets_completed, completed, volume, is_pr, notes, " + db.CardioColumns + ", " +
+		db.CardioPRSQL("history") + ", " + db.BodyweightSQL("history.session_date") + ", " + pg.column.keyExpr() + " FROM history" + clause
+
+	rows, err := h.DB.Query(query, args...)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		internalError(w, r, "Database error", err)
 		return
 	}
 	defer rows.Close()
 
-	history := []map[string]interface{}{}
+	history := []db.History{}
+	var lastKey interface{}
+	n := 0
 	for rows.Next() {
-		var id int
-		var sessionDate string
-		var weight, volume *float64
+		entry := db.History{ExerciseID: exerciseID}
 		var setsCompletedJSON string
-		var completed, isPR bool
-		var notes *string
+		var bodyweight *float64
+		var key interface{}
 
-		err := rows.Scan(&id, &sessionDate, &weight, &setsCompletedJSON, &completed, &volume, &isPR, &notes)
+		dest := []interface{}{&entry.ID, &entry.SessionDate, &entry.Weight, &setsCompletedJSON, &entry.Completed, &entry.Volume, &entry.IsPR, &entry.Notes}
+		dest = append(append(append(dest, entry.Cardio.ScanFields()...), entry.CardioPRs.ScanFields()...), &bodyweight, &key)
+		err := rows.Scan(dest...)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Scan error: %v", err), http.StatusInternalServerError)
+			internalError(w, r, "Scan error", err)
 			return
 		}
+		if n++; n > pg.limit {
+			continue
+		}
 
 		// Parse sets_completed JSON
-		var setsCompleted []int
-		if err := json.Unmarshal([]byte(setsCompletedJSON), &setsCompleted); err != nil {
-			http.Error(w, fmt.Sprintf("JSON parse error: %v", err), http.StatusInternalServerError)
+		if err := json.Unmarshal([]byte(setsCompletedJSON), &entry.SetsCompleted); err != nil {
+			internalError(w, r, "JSON parse error", err)
 			return
 		}
-
-		entry := map[string]interface{}{
-			"id":             id,
-			"session_date":   sessionDate,
-			"sets_completed": setsCompleted,
-			"completed":      completed,
-			"is_pr":          isPR,
-		}
-
-		if weight != nil {
-			entry["weight"] = *weight
-		}
-		if volume != nil {
-			entry["volume"] = *volume
-		}
-		if notes != nil {
-			entry["notes"] = *notes
-		}
+		entry.Load = sessionLoad(exType, entry.Weight, bodyweight, entry.SetsCompleted)
 
 		history = append(history, entry)
+		lastKey = key
 	}
-
-	response := map[string]interface{}{
-		"exercise_id":   exerciseID,
-		"exercise_name": exercise.Name,
-		"history":       history,
+	if err := rows.Err(); err != nil {
+		internalError(w, r, "Database error", err)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	lastID := 0
+	if len(history) > 0 {
+		lastID = history[len(history)-1].ID
+	}
+	writeJSON(w, http.StatusOK, HistoryResponse{
+		ExerciseID:   exerciseID,
+		ExerciseName: exercise.Name,
+		History:      history,
+		Page:         pg.result(total, n, lastKey, lastID),
+	})
 }
 
 // getPR returns the personal record for an exercise
-func (h *HistoryHandler) getPR(w http.ResponseWriter, r *http.Request, exerciseIDStr string) {
-	exerciseID, err := strconv.Atoi(exerciseIDStr)
+func (h *HistoryHandler) getPR(w http.ResponseWriter, r *http.Request) {
+	exerciseID, err := strconv.Atoi(r.PathValue("exerciseID"))
 	if err != nil {
-		http.Error(w, "Invalid exercise ID", http.StatusBadRequest)
+		invalidID(w, r, "exercise")
 		return
 	}
 
 	// Query for PR entry
-	var sessionDate string
-	var weight, volume float64
+	var pr PersonalRecord
+	var weight, volume, bodyweight *float64
+	var exType db.ExerciseType
+	var setsJSON string
 
 	err = h.DB.QueryRow(`
-		SELECT session_date, weight, volume
-		FROM history
-		WHERE exercise_id = ? AND is_pr = 1
-		ORDER BY session_date DESC
+		SELECT h.session_date, h.weight, h.volume, h.sets_completed, `+db.BodyweightSQL("h.session_date")+`,
+		       t.set_unit, t.bodyweight_load
+		FROM history h
+		JOIN exercises e ON e.id = h.exercise_id
+		JOIN exercise_types t ON t.name = e.type
+		WHERE h.exercise_id = ? AND h.is_pr = 1
+		ORDER BY h.session_date DESC
 		LIMIT 1
-	`, exerciseID).Scan(&sessionDate, &weight, &volume)
+	`, exerciseID).Scan(&pr.Date, &weight, &volume, &setsJSON, &bodyweight, &exType.SetUnit, &exType.BodyweightLoad)
+
+	var resp PRResponse
+	switch {
+	case err == nil:
+		if weight != nil {
+			pr.Weight = *weight
+		}
+		if volume != nil {
+			pr.Volume = *volume
+		}
+		var sets []int
+		json.Unmarshal([]byte(setsJSON), &sets)
+		pr.Load = sessionLoad(&exType, weight, bodyweight, sets)
+		resp.PR = &pr
+	case err != sql.ErrNoRows:
+		internalError(w, r, "Database error", err)
+		return
+	}
 
+	if resp.LongestDistance, err = h.cardioRecord(exerciseID, "distance_km DESC"); err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	if resp.FastestPace, err = h.cardioRecord(exerciseID, "pace_seconds_per_km"); err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// cardioRecord returns the exercise's best session by order (earliest
+// first on ties) among sessions with a positive value in the ordered
+// column, or nil if there are none
+func (h *HistoryHandler) cardioRecord(exerciseID int, order string) (*CardioRecord, error) {
+	column, _, _ := strings.Cut(order, " ")
+	var rec CardioRecord
+	err := h.DB.QueryRow(`
+		SELECT CAST(session_date AS TEXT), `+db.CardioColumns+`
+		FROM history
+		WHERE exercise_id = ? AND `+column+` > 0
+		ORDER BY `+order+`, session_date
+		LIMIT 1
+	`, exerciseID).Scan(append([]interface{}{&rec.Date}, rec.Cardio.ScanFields()...)...)
 	if err == sql.ErrNoRows {
-		// No PR found
-		response := map[string]interface{}{
-			"pr": nil,
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// historyLogDays is the window GET /history covers when from is omitted
+const historyLogDays = 30
+
+// historyLogPages pages the cross-exercise log, newest first by default.
+// from and to are applied by dateRange, with defaults, rather than the page.
+var historyLogPages = pageSpec{
+	defaultLimit: 200,
+	defaultSort:  "-session_date",
+	sorts: map[string]sortColumn{
+		"session_date": {column: "h.session_date"},
+	},
+	idColumn: "h.id",
+}
+
+// getHistoryLog returns a page of sessions across all exercises, grouped by
+// date (newest first) and filtered by date range, category, type and
+// weekday. A date's sessions can continue on the next page.
+func (h *HistoryHandler) getHistoryLog(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	from, to, ok := dateRange(w, r, time.Now(), historyLogDays)
+	if !ok {
+		return
+	}
+	pg, ok := historyLogPages.parse(w, r)
+	if !ok {
+		return
+	}
+
+	where := []string{"h.session_date >= ?", "h.session_date <= ?"}
+	args := []interface{}{from, to}
+
+	if category := q.Get("category"); category != "" {
+		if !requireCategory(w, r, h.DB, "category", category) {
+			return
+		}
+		where = append(where, "e.category = ?")
+		args = append(args, category)
+	}
+	if name := q.Get("type"); name != "" {
+		if exerciseType(w, r, h.DB, "type", name) == nil {
+			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+		where = append(where, "e.type = ?")
+		args = append(args, name)
+	}
+	if day := q.Get("day"); day != "" {
+		if !isWeekDay(day) {
+			fieldError(w, r, "day", "Invalid day of week")
+			return
+		}
+		// strftime('%w') numbers days from Sunday = 0; weekDays starts on Monday
+		where = append(where, "strftime('%w', h.session_date) = ?")
+		args = append(args, strconv.Itoa((slices.Index(weekDays, day)+1)%7))
+	}
+
+	types, err := h.DB.GetExerciseTypes()
+	if err != nil {
+		internalError(w, r, "Database error", err)
 		return
 	}
+	typesByName := map[string]*db.ExerciseType{}
+	for i := range types {
+		typesByName[types[i].Name] = &types[i]
+	}
 
+	var total int
+	if err := h.DB.QueryRow("SELECT COUNT(*) FROM history h JOIN exercises e ON e.id = h.exercise_id WHERE "+
+		strings.Join(where, " AND "), args...).Scan(&total); err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+
+	clause, args := pg.clause(where, args)
+	rows, err := h.DB.Query(`
+		SELECT CAST(h.session_date AS TEXT), h.id, h.exercise_id, h.session_date, h.weight, h.sets_completed,
+		       h.completed, h.volume, h.is_pr, h.notes, e.name, e.type, e.category,
+		       h.duration_seconds, h.distance_km, h.pace_seconds_per_km, h.avg_heart_rate, h.elevation_gain_m, h.calories,
+		       `+db.CardioPRSQL("h")+`, `+db.BodyweightSQL("h.session_date")+`
+		FROM history h
+		JOIN exercises e ON e.id = h.exercise_id`+clause, args...)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		internalError(w, r, "Database error", err)
 		return
 	}
+	defer rows.Close()
+
+	resp := HistoryLogResponse{From: from, To: to, Days: []HistoryLogDay{}, TotalSessions: total}
+	var lastDate string
+	lastID, n := 0, 0
+	for rows.Next() {
+		var date, setsCompletedJSON string
+		var bodyweight *float64
+		var s LoggedSession
+		dest := []interface{}{&date, &s.ID, &s.ExerciseID, &s.SessionDate, &s.Weight, &setsCompletedJSON,
+			&s.Completed, &s.Volume, &s.IsPR, &s.Notes, &s.ExerciseName, &s.ExerciseType, &s.Category}
+		err := rows.Scan(append(append(append(dest, s.Cardio.ScanFields()...), s.CardioPRs.ScanFields()...), &bodyweight)...)
+		if err != nil {
+			internalError(w, r, "Scan error", err)
+			return
+		}
+		if n++; n > pg.limit {
+			continue
+		}
+		if err := json.Unmarshal([]byte(setsCompletedJSON), &s.SetsCompleted); err != nil {
+			internalError(w, r, "JSON parse error", err)
+			return
+		}
+		if t := typesByName[s.ExerciseType]; t != nil {
+			s.Load = sessionLoad(t, s.Weight, bodyweight, s.SetsCompleted)
+		}
 
-	response := map[string]interface{}{
-		"pr": map[string]interface{}{
-			"weight": weight,
-			"date":   sessionDate,
-			"volume": volume,
-		},
+		if n := len(resp.Days); n == 0 || resp.Days[n-1].Date != date {
+			weekday := ""
+			if t, err := time.Parse(dateLayout, date); err == nil {
+				weekday = t.Weekday().String()
+			}
+			resp.Days = append(resp.Days, HistoryLogDay{Date: date, DayOfWeek: weekday})
+		}
+		day := &resp.Days[len(resp.Days)-1]
+		day.Sessions = append(day.Sessions, s)
+		lastDate, lastID = date, s.ID
+	}
+	if err := rows.Err(); err != nil {
+		internalError(w, r, "Database error", err)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	resp.Page = pg.result(total, n, lastDate, lastID)
+	writeJSON(w, http.StatusOK, resp)
 }
 
 // createHistory records a new workout session
@@ -190,72 +361,94 @@ func (h *HistoryHandler) createHistory(w http.ResponseWriter, r *http.Request) {
 		Completed     bool     `json:"completed"`
 		Volume        *float64 `json:"volume"`
 		Notes         *string  `json:"notes"`
+		ClientID      *string  `json:"client_id"`
+		db.Cardio
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		invalidJSON(w, r)
+		return
+	}
+	key, ok := idempotencyKey(w, r, req.ClientID)
+	if !ok {
 		return
 	}
 
 	// Validate required fields
-	if req.ExerciseID == 0 || req.SessionDate == "" || len(req.SetsCompleted) == 0 {
-		http.Error(w, "exercise_id, session_date, and sets_completed are required", http.StatusBadRequest)
-		return
-	}
-
-	// Check if this is a new PR. PR definition varies by exercise type:
-	//   timed_hold: highest volume (client sends volume = max hold time in seconds)
-	//   assisted:   lowest weight (less assistance = better)
-	//   all others with weight: highest weight
-	isPR := false
-	var exerciseType string
-	h.DB.QueryRow(`SELECT type FROM exercises WHERE id = ?`, req.ExerciseID).Scan(&exerciseType)
-
-	if exerciseType == "timed_hold" {
-		// PR = longest single hold; client stores max(sets_completed) in volume field
-		if req.Volume != nil && *req.Volume > 0 {
-			var prevMaxVol sql.NullFloat64
-			err := h.DB.QueryRow(`SELECT MAX(volume) FROM history WHERE exercise_id = ?`, req.ExerciseID).Scan(&prevMaxVol)
-			if err == nil && (!prevMaxVol.Valid || *req.Volume > prevMaxVol.Float64) {
-				isPR = true
-				_, err = h.DB.Exec("UPDATE history SET is_pr = 0 WHERE exercise_id = ?", req.ExerciseID)
-				if err != nil {
-					http.Error(w, fmt.Sprintf("Failed to update PR flags: %v", err), http.StatusInternalServerError)
-					return
-				}
-			}
-		}
-	} else if req.Weight != nil && *req.Weight > 0 {
-		// Weight-based PR: assisted = lower is better; all others = higher is better
-		var compareWeight sql.NullFloat64
-		var err error
-		if exerciseType == "assisted" {
-			err = h.DB.QueryRow(`SELECT MIN(weight) FROM history WHERE exercise_id = ?`, req.ExerciseID).Scan(&compareWeight)
-		} else {
-			err = h.DB.QueryRow(`SELECT MAX(weight) FROM history WHERE exercise_id = ?`, req.ExerciseID).Scan(&compareWeight)
+	if req.ExerciseID == 0 {
+		fieldError(w, r, "exercise_id", "exercise_id is required")
+		return
+	}
+	if req.SessionDate == "" {
+		fieldError(w, r, "session_date", "session_date is required")
+		return
+	}
+	if !isDate(req.SessionDate) {
+		fieldError(w, r, "session_date", "session_date must be a YYYY-MM-DD date")
+		return
+	}
+	for _, reps := range req.SetsCompleted {
+		if reps < 0 {
+			fieldError(w, r, "sets_completed", "sets_completed cannot contain negative values")
+			return
 		}
+	}
+	if req.Weight != nil && *req.Weight < 0 {
+		fieldError(w, r, "weight", "weight cannot be negative")
+		return
+	}
+	if field, msg := validateCardio(req.Cardio); field != "" {
+		fieldError(w, r, field, msg)
+		return
+	}
 
-		isPRCondition := !compareWeight.Valid
-		if compareWeight.Valid {
-			if exerciseType == "assisted" {
-				isPRCondition = *req.Weight < compareWeight.Float64
-			} else {
-				isPRCondition = *req.Weight > compareWeight.Float64
-			}
-		}
+	exercise, err := h.DB.GetExerciseByID(req.ExerciseID)
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	if exercise == nil {
+		fieldError(w, r, "exercise_id", "Exercise not found")
+		return
+	}
 
-		if err == nil && isPRCondition {
-			isPR = true
-			_, err = h.DB.Exec("UPDATE history SET is_pr = 0 WHERE exercise_id = ?", req.ExerciseID)
-			if err != nil {
-				http.Error(w, fmt.Sprintf("Failed to update PR flags: %v", err), http.StatusInternalServerError)
-				return
-			}
+	exType, err := h.DB.GetExerciseType(exercise.Type)
+	if err != nil || exType == nil {
+		internalError(w, r, "Failed to load exercise type", err)
+		return
+	}
+	// Types without sets (cardio) are logged by their cardio fields instead
+	if len(req.SetsCompleted) == 0 && exType.SetUnit != "none" {
+		fieldError(w, r, "sets_completed", "sets_completed is required")
+		return
+	}
+	cardio := withPace(req.Cardio)
+	row := historyRow(req.ExerciseID, req.SessionDate, req.Weight, req.SetsCompleted, req.Completed, req.Volume, req.Notes, cardio)
+
+	// A known client_id is a retry or an edit of the same session: update
+	// it in place rather than logging it twice
+	if key != nil {
+		if deleted, err := isDeleted(h.DB, "history", *key); err != nil {
+			internalError(w, r, "Database error", err)
+			return
+		} else if deleted {
+			conflict(w, r, "client_id", "The history entry with this client_id was deleted")
+			return
+		}
+		if h.upsert(w, r, *key, row) {
+			return
 		}
 	}
 
+	isPR, err := h.checkPR(exType, req.ExerciseID, req.SessionDate, req.Weight, req.Volume, req.SetsCompleted)
+	if err != nil {
+		internalError(w, r, "Failed to update PR flags", err)
+		return
+	}
+
 	// Insert history entry
-	id, err := h.DB.CreateHistory(
+	id, err := h.DB.CreateHistoryWithClientID(
+		key,
 		req.ExerciseID,
 		req.SessionDate,
 		req.Weight,
@@ -264,28 +457,39 @@ func (h *HistoryHandler) createHistory(w http.ResponseWriter, r *http.Request) {
 		req.Volume,
 		isPR,
 		req.Notes,
+		cardio,
 	)
+	if err != nil && key != nil && strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		// A concurrent retry inserted it first
+		if h.upsert(w, r, *key, row) {
+			return
+		}
+	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to create history: %v", err), http.StatusInternalServerError)
+		internalError(w, r, "Failed to create history", err)
 		return
 	}
-
-	response := map[string]interface{}{
-		"id":      id,
-		"is_pr":   isPR,
-		"message": "History entry created successfully",
+	prs, err := h.DB.GetCardioPRs(id)
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
 	}
+	checkGoals(r.Context(), h.DB, h.Events, row.goals)
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(response)
+	h.Events.Publish(Event{Type: "history.created", ID: id, ExerciseID: req.ExerciseID})
+	writeJSON(w, http.StatusCreated, HistoryCreatedResponse{
+		ID:        id,
+		IsPR:      isPR,
+		CardioPRs: prs,
+		Message:   "History entry created successfully",
+	})
 }
 
 // updateHistory updates a history entry
-func (h *HistoryHandler) updateHistory(w http.ResponseWriter, r *http.Request, idStr string) {
-	id, err := strconv.Atoi(idStr)
+func (h *HistoryHandler) updateHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		http.Error(w, "Invalid history ID", http.StatusBadRequest)
+		invalidID(w, r, "history")
 		return
 	}
 
@@ -295,10 +499,15 @@ func (h *HistoryHandler) updateHistory(w http.ResponseWriter, r *http.Request, i
 		Completed     *bool    `json:"completed"`
 		Volume        *float64 `json:"volume"`
 		Notes         *string  `json:"notes"`
+		db.Cardio
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		invalidJSON(w, r)
+		return
+	}
+	if field, msg := validateCardio(req.Cardio); field != "" {
+		fieldError(w, r, field, msg)
 		return
 	}
 
@@ -327,9 +536,47 @@ func (h *HistoryHandler) updateHistory(w http.ResponseWriter, r *http.Request, i
 		updates = append(updates, "notes = ?")
 		args = append(args, *req.Notes)
 	}
+	c := req.Cardio
+	for _, f := range []struct {
+		column string
+		value  interface{}
+		set    bool
+	}{
+		{"duration_seconds", c.DurationSeconds, c.DurationSeconds != nil},
+		{"distance_km", c.DistanceKm, c.DistanceKm != nil},
+		{"pace_seconds_per_km", c.PaceSecondsPerKm, c.PaceSecondsPerKm != nil},
+		{"avg_heart_rate", c.AvgHeartRate, c.AvgHeartRate != nil},
+		{"elevation_gain_m", c.ElevationGainM, c.ElevationGainM != nil},
+		{"calories", c.Calories, c.Calories != nil},
+	} {
+		if f.set {
+			updates = append(updates, f.column+" = ?")
+			args = append(args, f.value)
+		}
+	}
+	// Re-derive the pace from the stored and updated duration and distance
+	if c.PaceSecondsPerKm == nil && (c.DurationSeconds != nil || c.DistanceKm != nil) {
+		updates = append(updates, `pace_seconds_per_km = CASE
+			WHEN COALESCE(?, duration_seconds) > 0 AND COALESCE(?, distance_km) > 0
+			THEN ROUND(CAST(COALESCE(?, duration_seconds) AS REAL) / COALESCE(?, distance_km), 1)
+		END`)
+		args = append(args, c.DurationSeconds, c.DistanceKm, c.DurationSeconds, c.DistanceKm)
+	}
 
 	if len(updates) == 0 {
-		http.Error(w, "No fields to update", http.StatusBadRequest)
+		badRequest(w, r, "No fields to update")
+		return
+	}
+
+	// The exercise names the PRs and goals the edit can change
+	var exerciseID int
+	err = h.DB.QueryRow("SELECT exercise_id FROM history WHERE id = ?", id).Scan(&exerciseID)
+	if err == sql.ErrNoRows {
+		notFound(w, r, "History entry not found")
+		return
+	}
+	if err != nil {
+		internalError(w, r, "Database error", err)
 		return
 	}
 
@@ -338,48 +585,292 @@ func (h *HistoryHandler) updateHistory(w http.ResponseWriter, r *http.Request, i
 
 	result, err := h.DB.Exec(query, args...)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to update history: %v", err), http.StatusInternalServerError)
+		internalError(w, r, "Failed to update history", err)
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		http.Error(w, "History entry not found", http.StatusNotFound)
+		notFound(w, r, "History entry not found")
 		return
 	}
-
-	response := map[string]interface{}{
-		"message": "History entry updated successfully",
+	if req.Weight != nil || req.Volume != nil || req.SetsCompleted != nil {
+		if err := h.refreshPR(exerciseID); err != nil {
+			internalError(w, r, "Failed to update PR flags", err)
+			return
+		}
 	}
+	checkGoals(r.Context(), h.DB, h.Events, goalScope{exerciseIDs: []int{exerciseID}})
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	h.Events.Publish(Event{Type: "history.updated", ID: int64(id)})
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "History entry updated successfully"})
 }
 
 // deleteHistory deletes a history entry
-func (h *HistoryHandler) deleteHistory(w http.ResponseWriter, r *http.Request, idStr string) {
-	id, err := strconv.Atoi(idStr)
+func (h *HistoryHandler) deleteHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		http.Error(w, "Invalid history ID", http.StatusBadRequest)
+		invalidID(w, r, "history")
+		return
+	}
+	// A deleted PR hands the flag to the exercise's next best session
+	var exerciseID int
+	err = h.DB.QueryRow("SELECT exercise_id FROM history WHERE id = ?", id).Scan(&exerciseID)
+	if err == sql.ErrNoRows {
+		notFound(w, r, "History entry not found")
+		return
+	}
+	if err != nil {
+		internalError(w, r, "Database error", err)
 		return
 	}
 
 	result, err := h.DB.Exec("DELETE FROM history WHERE id = ?", id)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to delete history: %v", err), http.StatusInternalServerError)
+		internalError(w, r, "Failed to delete history", err)
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		http.Error(w, "History entry not found", http.StatusNotFound)
+		notFound(w, r, "History entry not found")
+		return
+	}
+	if err := h.refreshPR(exerciseID); err != nil {
+		internalError(w, r, "Failed to update PR flags", err)
 		return
 	}
 
-	response := map[string]interface{}{
-		"message": "History entry deleted successfully",
+	h.Events.Publish(Event{Type: "history.deleted", ID: int64(id)})
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "History entry deleted successfully"})
+}
+
+// upsert updates the session with clientID to row and writes the response.
+// It returns false, having written nothing, if there is no such session.
+func (h *HistoryHandler) upsert(w http.ResponseWriter, r *http.Request, clientID string, row syncRow) bool {
+	id, found, err := h.upsertSession(clientID, row)
+	if err != nil {
+		internalError(w, r, "Failed to update history", err)
+		return true
+	}
+	if !found {
+		return false
+	}
+	var isPR bool
+	if err := h.DB.QueryRow(`SELECT is_pr FROM history WHERE id = ?`, id).Scan(&isPR); err != nil {
+		internalError(w, r, "Database error", err)
+		return true
+	}
+	prs, err := h.DB.GetCardioPRs(id)
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return true
+	}
+	checkGoals(r.Context(), h.DB, h.Events, row.goals)
+	h.Events.Publish(Event{Type: "history.updated", ID: id})
+	writeJSON(w, http.StatusOK, HistoryCreatedResponse{ID: id, IsPR: isPR, CardioPRs: prs, Message: "History entry updated successfully"})
+	return true
+}
+
+// upsertSession is upsertByClientID for a history entry. When the edit
+// changes what decides PRs, the PR flags are worked out again.
+func (h *HistoryHandler) upsertSession(clientID string, row syncRow) (id int64, found bool, err error) {
+	before, err := sessionPRFields(h.DB, clientID)
+	if err != nil {
+		return 0, false, err
+	}
+	if id, found, err = upsertByClientID(h.DB, "history", clientID, row.columns, row.args); err != nil || !found {
+		return id, found, err
+	}
+	return id, true, h.refreshPRs(before, *row.pr)
+}
+
+// historyRow is the columns and values of a whole history entry
+func historyRow(exerciseID int, sessionDate string, weight *float64, setsCompleted []int, completed bool, volume *float64, notes *string, c db.Cardio) syncRow {
+	if setsCompleted == nil {
+		setsCompleted = []int{}
+	}
+	sets, _ := json.Marshal(setsCompleted)
+	return syncRow{
+		pr:    &prFields{exerciseID: exerciseID, weight: weight, volume: volume, sets: string(sets)},
+		goals: goalScope{exerciseIDs: []int{exerciseID}},
+		columns: []string{"exercise_id", "session_date", "weight", "sets_completed", "completed", "volume", "notes",
+			"duration_seconds", "distance_km", "pace_seconds_per_km", "avg_heart_rate", "elevation_gain_m", "calories"},
+		args: []interface{}{exerciseID, sessionDate, weight, string(sets), completed, volume, notes,
+			c.DurationSeconds, c.DistanceKm, c.PaceSecondsPerKm, c.AvgHeartRate, c.ElevationGainM, c.Calories},
+	}
+}
+
+// checkPR reports whether a new session is a PR. It changes nothing: the
+// insert takes the flag from the exercise's earlier sessions once it has
+// succeeded (see db.ClearOtherPRs). The exercise type says which column is
+// compared and in which direction, e.g. timed_hold compares volume (the
+// longest hold in seconds) and assisted wants the lowest weight. Once a
+// bodyweight is logged, types whose load includes it compare effective
+// load instead (see effectivePR).
+func (h *HistoryHandler) checkPR(exType *db.ExerciseType, exerciseID int, sessionDate string, weight, volume *float64, sets []int) (bool, error) {
+	isPR, ok, err := h.effectivePR(exType, exerciseID, sessionDate, weight, sets)
+	if err != nil {
+		return false, err
+	}
+	if ok {
+		return isPR, nil
+	}
+
+	var value *float64
+	switch exType.PRMetric {
+	case "weight":
+		value = weight
+	case "volume":
+		value = volume
+	}
+	if value == nil || *value <= 0 {
+		return false, nil
+	}
+	best := "MAX"
+	if exType.PRDirection == "lower" {
+		best = "MIN"
+	}
+	var previous sql.NullFloat64
+	err = h.DB.QueryRow(`SELECT `+best+`(`+exType.PRMetric+`) FROM history WHERE exercise_id = ?`, exerciseID).Scan(&previous)
+	if err != nil {
+		return false, nil
+	}
+	return !previous.Valid || (exType.PRDirection == "lower" && *value < previous.Float64) ||
+		(exType.PRDirection == "higher" && *value > previous.Float64), nil
+}
+
+// prFields are the values of a session that decide which session is the PR
+type prFields struct {
+	exerciseID     int
+	weight, volume *float64
+	sets           string
+}
+
+// sessionPRFields reads the prFields of the session with clientID, or nil
+// if there is none
+func sessionPRFields(database *db.DB, clientID string) (*prFields, error) {
+	var f prFields
+	err := database.QueryRow(`SELECT exercise_id, weight, volume, sets_completed FROM history WHERE client_id = ?`, clientID).
+		Scan(&f.exerciseID, &f.weight, &f.volume, &f.sets)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// refreshPRs works the PR flags out again for the exercises of a session
+// edited from before to after, if the edit changed anything that decides
+// them. before is nil for a session that didn't exist.
+func (h *HistoryHandler) refreshPRs(before *prFields, after prFields) error {
+	if before == nil || (before.exerciseID == after.exerciseID && sameValue(before.weight, after.weight) &&
+		sameValue(before.volume, after.volume) && before.sets == after.sets) {
+		return nil
+	}
+	if before.exerciseID != after.exerciseID {
+		if err := h.refreshPR(before.exerciseID); err != nil {
+			return err
+		}
+	}
+	return h.refreshPR(after.exerciseID)
+}
+
+// sameValue reports whether two optional values are equal
+func sameValue(a, b *float64) bool {
+	return (a == nil) == (b == nil) && (a == nil || *a == *b)
+}
+
+// refreshPR flags the exercise's best session as its PR, comparing as
+// checkPR does. Ties go to the session logged first, since checkPR never
+// flags a session that only equals the best.
+func (h *HistoryHandler) refreshPR(exerciseID int) error {
+	exercise, err := h.DB.GetExerciseByID(exerciseID)
+	if err != nil || exercise == nil {
+		return err
+	}
+	exType, err := h.DB.GetExerciseType(exercise.Type)
+	if err != nil {
+		return err
+	}
+	if exType == nil {
+		return fmt.Errorf("exercise type %q not found", exercise.Type)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	rows, err := h.DB.Query(`SELECT h.id, h.weight, h.volume, h.sets_completed, `+db.BodyweightSQL("h.session_date")+`
+		FROM history h WHERE h.exercise_id = ? ORDER BY h.id`, exerciseID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var best int64
+	var bestValue float64
+	for rows.Next() {
+		var id int64
+		var weight, volume, bodyweight *float64
+		var setsJSON string
+		if err := rows.Scan(&id, &weight, &volume, &setsJSON, &bodyweight); err != nil {
+			return err
+		}
+		// As in checkPR, a logged bodyweight switches types whose load
+		// includes it to effective load, where higher is always better
+		var value *float64
+		lower := exType.PRDirection == "lower"
+		switch {
+		case exType.PRMetric == "none":
+		case exType.BodyweightLoad != "none" && bodyweight != nil:
+			var sets []int
+			json.Unmarshal([]byte(setsJSON), &sets)
+			l := sessionLoad(exType, weight, bodyweight, sets)
+			value, lower = l.EffectiveLoad, false
+			if exType.PRMetric == "volume" {
+				value = l.EffectiveVolume
+			}
+		case exType.PRMetric == "weight":
+			value = weight
+		case exType.PRMetric == "volume":
+			value = volume
+		}
+		if value == nil || *value <= 0 {
+			continue
+		}
+		if best == 0 || (lower && *value < bestValue) || (!lower && *value > bestValue) {
+			best, bestValue = id, *value
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return h.DB.SetPR(exerciseID, best)
+}
+
+// validateCardio returns the first cardio field that is out of range, if any
+func validateCardio(c db.Cardio) (field, message string) {
+	switch {
+	case c.DurationSeconds != nil && *c.DurationSeconds < 0:
+		return "duration_seconds", "duration_seconds cannot be negative"
+	case c.DistanceKm != nil && *c.DistanceKm < 0:
+		return "distance_km", "distance_km cannot be negative"
+	case c.PaceSecondsPerKm != nil && *c.PaceSecondsPerKm < 0:
+		return "pace_seconds_per_km", "pace_seconds_per_km cannot be negative"
+	case c.AvgHeartRate != nil && (*c.AvgHeartRate < 20 || *c.AvgHeartRate > 250):
+		return "avg_heart_rate", "avg_heart_rate must be between 20 and 250"
+	case c.ElevationGainM != nil && *c.ElevationGainM < 0:
+		return "elevation_gain_m", "elevation_gain_m cannot be negative"
+	case c.Calories != nil && *c.Calories < 0:
+		return "calories", "calories cannot be negative"
+	}
+	return "", ""
+}
+
+// withPace fills in the average pace from duration and distance when the
+// client didn't send one
+func withPace(c db.Cardio) db.Cardio {
+	if c.PaceSecondsPerKm == nil && c.DurationSeconds != nil && c.DistanceKm != nil && *c.DurationSeconds > 0 && *c.DistanceKm > 0 {
+		pace := math.Round(float64(*c.DurationSeconds) / *c.DistanceKm * 10) / 10
+		c.PaceSecondsPerKm = &pace
+	}
+	return c
 }
@@ -3,8 +3,11 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 
 	"train/db"
@@ -141,3 +144,379 @@ func TestWeightAndAssistedPR_AreIndependent(t *testing.T) {
 		t.Errorf("assisted exercise: 60 > 50 should NOT be PR")
 	}
 }
+
+// --- Cross-exercise log tests ---
+
+func getHistoryLog(t *testing.T, h *HistoryHandler, query string) (int, HistoryLogResponse) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/history?"+query, nil))
+	var resp HistoryLogResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	return w.Code, resp
+}
+
+func TestHistoryLog_GroupsSessionsByDate(t *testing.T) {
+	h, squat := newTestHandler(t, "weight")
+	pullUp, err := h.DB.CreateExercise("Pull-up", "bodyweight", "Arms-Pull", nil, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateExercise: %v", err)
+	}
+
+	postHistory(t, h, squat, 50, "2026-03-03")      // Tuesday
+	postHistory(t, h, int(pullUp), 0, "2026-03-03") // Tuesday
+	postHistory(t, h, squat, 55, "2026-03-05")      // Thursday
+	postHistory(t, h, squat, 60, "2026-04-01")      // outside the range
+
+	code, resp := getHistoryLog(t, h, "from=2026-03-01&to=2026-03-31")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if resp.TotalSessions != 3 || len(resp.Days) != 2 {
+		t.Fatalf("expected 3 sessions on 2 days, got %+v", resp)
+	}
+	if resp.Days[0].Date != "2026-03-05" || resp.Days[1].Date != "2026-03-03" {
+		t.Errorf("days should be newest first, got %s, %s", resp.Days[0].Date, resp.Days[1].Date)
+	}
+	if resp.Days[1].DayOfWeek != "Tuesday" || len(resp.Days[1].Sessions) != 2 {
+		t.Errorf("unexpected Tuesday group: %+v", resp.Days[1])
+	}
+	// Within a date the latest logged comes first
+	if s := resp.Days[1].Sessions[0]; s.ExerciseName != "Pull-up" || s.ExerciseType != "bodyweight" {
+		t.Errorf("session should carry its exercise, got %+v", s)
+	}
+
+	// Pages split a date's sessions and keep the range total
+	_, first := getHistoryLog(t, h, "from=2026-03-01&to=2026-03-31&limit=2")
+	_, second := getHistoryLog(t, h, "from=2026-03-01&to=2026-03-31&limit=2&cursor="+first.NextCursor)
+	if first.TotalSessions != 3 || len(first.Days) != 2 || len(first.Days[1].Sessions) != 1 || first.NextCursor == "" {
+		t.Errorf("unexpected first page: %+v", first)
+	}
+	if len(second.Days) != 1 || second.Days[0].Sessions[0].ExerciseName != "Test Exercise" || second.NextCursor != "" {
+		t.Errorf("unexpected second page: %+v", second)
+	}
+
+	_, resp = getHistoryLog(t, h, "from=2026-03-01&to=2026-03-31&day=Tuesday&category=Arms-Pull")
+	if resp.TotalSessions != 1 || resp.Days[0].Sessions[0].ExerciseName != "Pull-up" {
+		t.Errorf("day and category filters should leave only the pull-up, got %+v", resp)
+	}
+
+	_, resp = getHistoryLog(t, h, "from=2026-03-01&to=2026-03-31&type=weight")
+	if resp.TotalSessions != 2 {
+		t.Errorf("type filter should leave the two squat sessions, got %d", resp.TotalSessions)
+	}
+}
+
+func TestHistoryLog_InvalidFiltersRejected(t *testing.T) {
+	h, _ := newTestHandler(t, "weight")
+	for _, query := range []string{"from=2026-03-10&to=2026-03-01", "to=soon", "limit=0", "sort=weight", "day=Funday", "category=Legs", "type=machine"} {
+		if code, _ := getHistoryLog(t, h, query); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, code)
+		}
+	}
+}
+
+// --- Idempotency tests ---
+
+func TestHistory_RetriedCreateIsIdempotent(t *testing.T) {
+	h, id := newTestHandler(t, "weight")
+	body := `{"exercise_id": ` + strconv.Itoa(id) + `, "session_date": "2026-01-12", "weight": 60, "sets_completed": [10, 10, 10], "completed": true}`
+
+	post := func(body, key string) (int, HistoryCreatedResponse) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/history", bytes.NewBufferString(body))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		var resp HistoryCreatedResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		return w.Code, resp
+	}
+
+	code, first := post(body, "4f7c2a9e-0b1d-4c3e-9f6a-1d2e3f4a5b6c")
+	if code != http.StatusCreated || !first.IsPR {
+		t.Fatalf("first attempt should create a PR, got %d %+v", code, first)
+	}
+	// The retry finds the same session and keeps it a PR
+	code, retry := post(body, "4f7c2a9e-0b1d-4c3e-9f6a-1d2e3f4a5b6c")
+	if code != http.StatusOK || retry.ID != first.ID || !retry.IsPR {
+		t.Errorf("retry should return the same PR session with 200, got %d %+v", code, retry)
+	}
+	var version int
+	if h.DB.QueryRow(`SELECT version FROM history`).Scan(&version); version != 1 {
+		t.Errorf("an identical retry should not bump the sync version, got %d", version)
+	}
+	// The key can also come in the body; a changed body updates the session
+	code, edited := post(strings.Replace(body, `"weight": 60`, `"client_id": "4f7c2a9e-0b1d-4c3e-9f6a-1d2e3f4a5b6c", "weight": 62.5`, 1), "")
+	if code != http.StatusOK || edited.ID != first.ID {
+		t.Errorf("upsert should update the session, got %d %+v", code, edited)
+	}
+
+	var count int
+	var weight float64
+	h.DB.QueryRow(`SELECT COUNT(*), MAX(weight) FROM history`).Scan(&count, &weight)
+	if count != 1 || weight != 62.5 {
+		t.Errorf("expected one session at 62.5, got %d at %v", count, weight)
+	}
+
+	// A key from a deleted session can't bring it back
+	h.DB.Exec(`DELETE FROM history`)
+	if code, _ := post(body, "4f7c2a9e-0b1d-4c3e-9f6a-1d2e3f4a5b6c"); code != http.StatusConflict {
+		t.Errorf("expected 409 for a deleted key, got %d", code)
+	}
+	if code, _ := post(strings.Replace(body, `"weight"`, `"client_id": "a", "weight"`, 1), "b"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 when body and header keys differ, got %d", code)
+	}
+}
+
+func TestHistory_UpsertRecomputesPRs(t *testing.T) {
+	h, id := newTestHandler(t, "weight")
+	post := func(key string, weight float64) HistoryCreatedResponse {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/history", bytes.NewBufferString(fmt.Sprintf(
+			`{"exercise_id": %d, "session_date": "2026-01-12", "weight": %v, "sets_completed": [5], "completed": true, "client_id": %q}`, id, weight, key)))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated && w.Code != http.StatusOK {
+			t.Fatalf("expected 201 or 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp HistoryCreatedResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp
+	}
+	prs := func() string {
+		var keys string
+		h.DB.QueryRow(`SELECT group_concat(client_id) FROM history WHERE is_pr = 1`).Scan(&keys)
+		return keys
+	}
+
+	post("a", 100)
+	if !post("b", 110).IsPR || prs() != "b" {
+		t.Fatalf("the heavier session should be the PR, got %q", prs())
+	}
+	// Correcting the PR session down hands the flag back
+	if post("b", 90).IsPR || prs() != "a" {
+		t.Errorf("after lowering b the earlier session should be the PR, got %q", prs())
+	}
+	// Raising an older session past the best makes it the PR
+	if !post("b", 120).IsPR || prs() != "b" {
+		t.Errorf("after raising b it should be the PR again, got %q", prs())
+	}
+
+	var b int
+	h.DB.QueryRow(`SELECT id FROM history WHERE client_id = 'b'`).Scan(&b)
+	serve := func(method, body string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, "/api/v1/history/"+strconv.Itoa(b), bytes.NewBufferString(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", method, w.Code, w.Body.String())
+		}
+	}
+	// So do edits and deletes by id
+	serve(http.MethodPut, `{"weight": 95}`)
+	if prs() != "a" {
+		t.Errorf("after a PUT lowering b the earlier session should be the PR, got %q", prs())
+	}
+	serve(http.MethodPut, `{"weight": 130}`)
+	if prs() != "b" {
+		t.Errorf("after a PUT raising b it should be the PR, got %q", prs())
+	}
+	serve(http.MethodDelete, "")
+	if prs() != "a" {
+		t.Errorf("after deleting b the remaining session should be the PR, got %q", prs())
+	}
+}
+
+// --- Cardio tests ---
+
+func postCardio(t *testing.T, h *HistoryHandler, exerciseID int, date, body string) (int, HistoryCreatedResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/history", bytes.NewBufferString(
+		`{"exercise_id": `+strconv.Itoa(exerciseID)+`, "session_date": "`+date+`", "completed": true, `+body+`}`))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	var resp HistoryCreatedResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	return w.Code, resp
+}
+
+func TestCardio_DistanceAndPacePRs(t *testing.T) {
+	h, id := newTestHandler(t, "cardio")
+
+	// 5 km in 25:00 – no sets needed, pace derived as 300 s/km
+	code, resp := postCardio(t, h, id, "2026-03-02", `"duration_seconds": 1500, "distance_km": 5, "avg_heart_rate": 150`)
+	if code != http.StatusCreated || !resp.DistancePR || !resp.PacePR || resp.IsPR {
+		t.Fatalf("first run should set both cardio PRs only, got %d %+v", code, resp)
+	}
+	// Longer but slower
+	if _, resp = postCardio(t, h, id, "2026-03-04", `"duration_seconds": 2400, "distance_km": 7.5`); !resp.DistancePR || resp.PacePR {
+		t.Errorf("7.5 km at 320 s/km should be a distance PR only, got %+v", resp)
+	}
+	// Shorter but faster
+	if _, resp = postCardio(t, h, id, "2026-03-06", `"duration_seconds": 870, "distance_km": 3`); resp.DistancePR || !resp.PacePR {
+		t.Errorf("3 km at 290 s/km should be a pace PR only, got %+v", resp)
+	}
+
+	// A longer, back-dated run re-ranks the sessions after it when read
+	if _, resp = postCardio(t, h, id, "2026-03-01", `"duration_seconds": 3000, "distance_km": 10`); !resp.DistancePR || !resp.PacePR {
+		t.Errorf("the earliest run should set both cardio PRs, got %+v", resp)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/history/"+strconv.Itoa(id)+"?sort=session_date", nil))
+	var list HistoryResponse
+	json.NewDecoder(w.Body).Decode(&list)
+	var flags []string
+	for _, e := range list.History {
+		flags = append(flags, fmt.Sprintf("%s:%t/%t", e.SessionDate[:10], e.DistancePR, e.PacePR))
+	}
+	if got := strings.Join(flags, " "); got != "2026-03-01:true/true 2026-03-02:false/false 2026-03-04:false/false 2026-03-06:false/true" {
+		t.Errorf("unexpected cardio PRs in history: %s", got)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/history/"+strconv.Itoa(id)+"/pr", nil))
+	var pr PRResponse
+	json.NewDecoder(w.Body).Decode(&pr)
+	if pr.LongestDistance == nil || pr.LongestDistance.Date != "2026-03-01" || pr.FastestPace == nil || *pr.FastestPace.PaceSecondsPerKm != 290 {
+		t.Errorf("unexpected cardio records: %+v %+v", pr.LongestDistance, pr.FastestPace)
+	}
+}
+
+func TestCardio_InvalidFieldsRejected(t *testing.T) {
+	h, id := newTestHandler(t, "cardio")
+	weight, _ := newTestHandler(t, "weight")
+
+	for body, field := range map[string]string{
+		`"distance_km": -1`:      "distance_km",
+		`"duration_seconds": -5`: "duration_seconds",
+		`"avg_heart_rate": 400`:  "avg_heart_rate",
+	} {
+		if code, _ := postCardio(t, h, id, "2026-03-02", body); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400 on %s, got %d", body, field, code)
+		}
+	}
+	// Types with sets still need them
+	if code, _ := postCardio(t, weight, 1, "2026-03-02", `"duration_seconds": 600`); code != http.StatusBadRequest {
+		t.Errorf("weight session without sets: expected 400, got %d", code)
+	}
+}
+
+// --- Bodyweight-aware load tests ---
+
+func TestAssistedExercise_EffectiveLoadUsesBodyweight(t *testing.T) {
+	h, id := newTestHandler(t, "assisted")
+	if _, err := h.DB.CreateMetricEntry(1, "2026-01-01", 80, nil); err != nil {
+		t.Fatalf("CreateMetricEntry: %v", err)
+	}
+
+	postHistory(t, h, id, 30.0, "2026-01-05")
+	// Less assistance at a lighter bodyweight moves less: 72 − 25 < 80 − 30
+	if _, err := h.DB.CreateMetricEntry(1, "2026-01-10", 72, nil); err != nil {
+		t.Fatalf("CreateMetricEntry: %v", err)
+	}
+	if r := postHistory(t, h, id, 25.0, "2026-01-12"); r["is_pr"] != false {
+		t.Errorf("47 kg net load should not beat 50 kg, got is_pr=%v", r["is_pr"])
+	}
+	if r := postHistory(t, h, id, 20.0, "2026-01-19"); r["is_pr"] != true {
+		t.Errorf("52 kg net load should be a PR, got is_pr=%v", r["is_pr"])
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/history/"+strconv.Itoa(id), nil))
+	var resp HistoryResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.History) != 3 {
+		t.Fatalf("expected 3 sessions, got %d", len(resp.History))
+	}
+	first := resp.History[2]
+	if first.Bodyweight == nil || *first.Bodyweight != 80 || first.EffectiveLoad == nil || *first.EffectiveLoad != 50 {
+		t.Fatalf("first session should use 80 kg bodyweight for a 50 kg load, got %+v", first.Load)
+	}
+	// 3 × 10 reps at 50 kg; e1RM = 50 × (1 + 10/30)
+	if *first.EffectiveVolume != 1500 || *first.E1RM != 66.7 {
+		t.Errorf("unexpected volume or e1RM: %v %v", *first.EffectiveVolume, *first.E1RM)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/history/"+strconv.Itoa(id)+"/pr", nil))
+	var pr PRResponse
+	json.NewDecoder(w.Body).Decode(&pr)
+	if pr.PR == nil || !strings.HasPrefix(pr.PR.Date, "2026-01-19") || pr.PR.EffectiveLoad == nil || *pr.PR.EffectiveLoad != 52 {
+		t.Errorf("PR should be the 52 kg session, got %+v", pr.PR)
+	}
+}
+
+func TestBodyweightExercise_AddedWeightCounts(t *testing.T) {
+	h, id := newTestHandler(t, "bodyweight")
+
+	// Without a logged bodyweight the stored weight is compared as before
+	if r := postHistory(t, h, id, 10, "2026-01-01"); r["is_pr"] != true {
+		t.Errorf("first session should be a PR, got is_pr=%v", r["is_pr"])
+	}
+	if _, err := h.DB.CreateMetricEntry(1, "2026-01-01", 75, nil); err != nil {
+		t.Fatalf("CreateMetricEntry: %v", err)
+	}
+	// 75 kg unweighted is less than 75 + 10
+	if r := postHistory(t, h, id, 0, "2026-01-08"); r["is_pr"] != false {
+		t.Errorf("unweighted session should not be a PR, got is_pr=%v", r["is_pr"])
+	}
+	if r := postHistory(t, h, id, 12.5, "2026-01-15"); r["is_pr"] != true {
+		t.Errorf("bodyweight + 12.5 kg should be a PR, got is_pr=%v", r["is_pr"])
+	}
+
+	// The added weight is entered like any weight, and the bodyweight
+	// metric is found by its flag, not its name
+	if exType, err := h.DB.GetExerciseType("bodyweight"); err != nil || !exType.UsesWeight {
+		t.Errorf("the bodyweight type should use weight, got %+v (%v)", exType, err)
+	}
+	name := "Body Weight"
+	if err := h.DB.UpdateMetricType(1, &name, nil, nil, nil, nil, nil); err != nil {
+		t.Fatalf("UpdateMetricType: %v", err)
+	}
+	if bw, err := h.DB.BodyweightOn("2026-01-15"); err != nil || bw == nil || *bw != 75 {
+		t.Errorf("renaming the metric should keep the bodyweight, got %v (%v)", bw, err)
+	}
+}
+
+func TestBodyweightExercise_WeighInsReRankPRs(t *testing.T) {
+	h, id := newTestHandler(t, "bodyweight")
+	entries := &MetricEntriesHandler{DB: h.DB}
+	pr := func() int {
+		t.Helper()
+		var prID int
+		if err := h.DB.QueryRow(`SELECT id FROM history WHERE exercise_id = ? AND is_pr = 1`, id).Scan(&prID); err != nil {
+			t.Fatalf("expected one PR: %v", err)
+		}
+		return prID
+	}
+	heavier := int(postHistory(t, h, id, 10, "2026-01-05")["id"].(float64))
+	later := int(postHistory(t, h, id, 5, "2026-01-20")["id"].(float64))
+	if pr() != heavier {
+		t.Fatalf("without a bodyweight the heavier added weight should be the PR")
+	}
+
+	// 90 kg from the 15th: 100 kg for the first session (the first later
+	// weigh-in) against 95 kg
+	if w := serveMetrics(entries, http.MethodPost, "/api/v1/metric-entries", `{"metric_type_id": 1, "entry_date": "2026-01-15", "value": 90}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if pr() != heavier {
+		t.Errorf("expected the first session to stay the PR at 100 kg")
+	}
+	// An earlier 80 kg weigh-in drops the first session to 90 kg
+	w := serveMetrics(entries, http.MethodPost, "/api/v1/metric-entries", `{"metric_type_id": 1, "entry_date": "2026-01-01", "value": 80}`)
+	var saved MetricEntrySavedResponse
+	json.NewDecoder(w.Body).Decode(&saved)
+	if pr() != later {
+		t.Errorf("expected the later session at 95 kg to become the PR")
+	}
+	// Deleting it hands the flag back
+	if w := serveMetrics(entries, http.MethodDelete, "/api/v1/metric-entries/"+strconv.FormatInt(saved.ID, 10), ""); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if pr() != heavier {
+		t.Errorf("expected the first session to be the PR again after the delete")
+	}
+}
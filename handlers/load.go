@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"slices"
+
+	"train/db"
+)
+
+// effectiveLoad is the load a session moved. Types whose load includes
+// the lifter move their bodyweight plus the logged weight ("add", e.g.
+// weighted pull-ups) or minus it ("subtract", where the weight is
+// assistance). Other types move the logged weight. It is nil when there is
+// no load: no weight was logged, or no bodyweight for a type that needs one.
+func effectiveLoad(bodyweightLoad string, weight, bodyweight *float64) *float64 {
+	added := 0.0
+	if weight != nil {
+		added = *weight
+	}
+	var load float64
+	switch {
+	case bodyweightLoad == "add" && bodyweight != nil:
+		load = *bodyweight + added
+	case bodyweightLoad == "subtract" && bodyweight != nil:
+		load = *bodyweight - added
+	case bodyweightLoad == "none" && weight != nil:
+		load = added
+	default:
+		return nil
+	}
+	if load <= 0 {
+		return nil
+	}
+	return &load
+}
+
+// epley estimates the one-rep max from a set of reps at load
+func epley(load float64, reps int) float64 {
+	if reps == 1 {
+		return load
+	}
+	return load * (1 + float64(reps)/30)
+}
+
+// sessionLoad works out a session's bodyweight-aware figures: the
+// effective load, effective volume (load × reps, for types counting reps
+// or laps) and the estimated one-rep max of its best set (Epley, for types
+// counting reps)
+func sessionLoad(t *db.ExerciseType, weight, bodyweight *float64, sets []int) db.Load {
+	var l db.Load
+	if t.BodyweightLoad != "none" {
+		l.Bodyweight = bodyweight
+	}
+	load := effectiveLoad(t.BodyweightLoad, weight, bodyweight)
+	if load == nil || (t.SetUnit != "reps" && t.SetUnit != "laps") {
+		l.EffectiveLoad = load
+		return l
+	}
+	l.EffectiveLoad = roundTenth(*load)
+
+	reps, best := 0, 0.0
+	for _, n := range sets {
+		if n <= 0 {
+			continue
+		}
+		reps += n
+		best = max(best, epley(*load, n))
+	}
+	if reps > 0 {
+		l.EffectiveVolume = roundTenth(*load * float64(reps))
+	}
+	if t.SetUnit == "reps" && best > 0 {
+		l.E1RM = roundTenth(best)
+	}
+	return l
+}
+
+// roundTenth returns v rounded to one decimal
+func roundTenth(v float64) *float64 {
+	v = math.Round(v*10) / 10
+	return &v
+}
+
+// effectivePR compares a new session of a type whose load includes
+// bodyweight against the exercise's history by effective load (pr_metric
+// weight) or effective volume (pr_metric volume). More is better whatever
+// the type's pr_direction, since less assistance means more load. ok is
+// false when the comparison doesn't apply – the type has no bodyweight
+// load or no bodyweight has been logged – and the stored weight or volume
+// should be compared instead.
+func (h *HistoryHandler) effectivePR(t *db.ExerciseType, exerciseID int, sessionDate string, weight *float64, sets []int) (isPR, ok bool, err error) {
+	if t.BodyweightLoad == "none" || t.PRMetric == "none" {
+		return false, false, nil
+	}
+	bodyweight, err := h.DB.BodyweightOn(sessionDate)
+	if err != nil || bodyweight == nil {
+		return false, false, err
+	}
+	score := func(weight, bodyweight *float64, sets []int) float64 {
+		l := sessionLoad(t, weight, bodyweight, sets)
+		value := l.EffectiveLoad
+		if t.PRMetric == "volume" {
+			value = l.EffectiveVolume
+		}
+		if value == nil {
+			return 0
+		}
+		return *value
+	}
+	value := score(weight, bodyweight, sets)
+	if value <= 0 {
+		return false, true, nil
+	}
+
+	rows, err := h.DB.Query(`SELECT h.weight, h.sets_completed, `+db.BodyweightSQL("h.session_date")+`
+		FROM history h WHERE h.exercise_id = ?`, exerciseID)
+	if err != nil {
+		return false, true, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var weight, bodyweight *float64
+		var setsJSON string
+		if err := rows.Scan(&weight, &setsJSON, &bodyweight); err != nil {
+			return false, true, err
+		}
+		var sets []int
+		json.Unmarshal([]byte(setsJSON), &sets)
+		if score(weight, bodyweight, sets) >= value {
+			return false, true, nil
+		}
+	}
+	return true, true, rows.Err()
+}
+
+// refreshBodyweightPRs works the PR flags out again for the exercises whose
+// load includes the bodyweight, when a write to one of metricTypeIDs'
+// entries was to the bodyweight metric: their effective loads moved with
+// it. It runs once the write is committed, so a failure is logged rather
+// than failing the write.
+func refreshBodyweightPRs(ctx context.Context, database *db.DB, metricTypeIDs ...int) {
+	if err := refreshBodyweightPRsOf(database, metricTypeIDs); err != nil {
+		slog.ErrorContext(ctx, "failed to update PR flags", "err", err)
+	}
+}
+
+// refreshBodyweightPRsOf does refreshBodyweightPRs' work
+func refreshBodyweightPRsOf(database *db.DB, metricTypeIDs []int) error {
+	bodyweightID, err := database.BodyweightMetricID()
+	if err != nil || bodyweightID == 0 || !slices.Contains(metricTypeIDs, bodyweightID) {
+		return err
+	}
+	rows, err := database.Query(`SELECT e.id FROM exercises e
+		JOIN exercise_types t ON t.name = e.type WHERE t.bodyweight_load != 'none'`)
+	if err != nil {
+		return err
+	}
+	var exerciseIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		exerciseIDs = append(exerciseIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	history := &HistoryHandler{DB: database}
+	for _, id := range exerciseIDs {
+		if err := history.refreshPR(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,11 +1,13 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
-	"fmt"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"train/db"
 )
@@ -13,96 +15,169 @@ import (
 // MetricsHandler handles CRUD operations for metrics
 type MetricsHandler struct {
 	DB *db.DB
+	// Events is notified after each change; nil disables notifications
+	Events *Hub
 }
 
-// ServeHTTP handles metric-related requests
-func (h *MetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Parse path to extract ID and route
-	path := strings.TrimPrefix(r.URL.Path, "/api/metrics")
-	path = strings.TrimPrefix(path, "/")
-
-	// Route to appropriate handler
-	if path == "" {
-		// /api/metrics - metric types CRUD
-		h.handleMetricTypes(w, r)
-	} else if path == "dashboard" {
-		// /api/metrics/dashboard - dashboard data
-		h.getDashboardData(w, r)
-	} else if path == "reorder" {
-		// /api/metrics/reorder - reorder metric types
-		h.reorderMetricTypes(w, r)
-	} else if strings.HasSuffix(path, "/entries") {
-		// /api/metrics/:id/entries - entries for a metric type
-		idStr := strings.TrimSuffix(path, "/entries")
-		h.getEntriesByType(w, r, idStr)
-	} else {
-		// /api/metrics/:id - single metric type CRUD
-		h.handleSingleMetricType(w, r, path)
-	}
-}
-
-// handleMetricTypes handles listing and creating metric types
-func (h *MetricsHandler) handleMetricTypes(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		h.listMetricTypes(w, r)
-	case http.MethodPost:
-		h.createMetricType(w, r)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
+// routes mounts the metric type endpoints
+func (h *MetricsHandler) routes(rt *router) {
+	rt.handle(http.MethodGet, "/metrics", h.listMetricTypes)
+	rt.handle(http.MethodPost, "/metrics", h.createMetricType)
+	rt.handle(http.MethodGet, "/metrics/dashboard", h.getDashboardData)
+	rt.handle(http.MethodPost, "/metrics/reorder", h.reorderMetricTypes)
+	rt.handle(http.MethodPut, "/metrics/{id}", h.updateMetricType)
+	rt.handle(http.MethodDelete, "/metrics/{id}", h.deleteMetricType)
+	rt.handle(http.MethodGet, "/metrics/{id}/entries", h.getEntriesByType)
+	rt.handle(http.MethodGet, "/metrics/{id}/anomalies", h.getAnomalies)
 }
 
-// handleSingleMetricType handles operations on a single metric type
-func (h *MetricsHandler) handleSingleMetricType(w http.ResponseWriter, r *http.Request, idStr string) {
-	switch r.Method {
-	case http.MethodPut:
-		h.updateMetricType(w, r, idStr)
-	case http.MethodDelete:
-		h.deleteMetricType(w, r, idStr)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
+// ServeHTTP serves the metric type endpoints on their own
+func (h *MetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveRoutes(w, r, h.routes)
 }
 
 // listMetricTypes returns all metric types with their latest entry
 func (h *MetricsHandler) listMetricTypes(w http.ResponseWriter, r *http.Request) {
 	metricTypes, err := h.DB.GetMetricTypes()
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		internalError(w, r, "Database error", err)
 		return
 	}
 
 	// Enrich with latest entry for each metric type
-	result := []map[string]interface{}{}
+	result := []MetricTypeSummary{}
 	for _, mt := range metricTypes {
-		metricData := map[string]interface{}{
-			"id":          mt.ID,
-			"name":        mt.Name,
-			"unit":        mt.Unit,
-			"color":       mt.Color,
-			"order_index": mt.OrderIndex,
-			"is_default":  mt.IsDefault,
+		summary := MetricTypeSummary{
+			ID:         mt.ID,
+			Name:       mt.Name,
+			Unit:       mt.Unit,
+			Color:      mt.Color,
+			OrderIndex: mt.OrderIndex,
+			IsDefault:  mt.IsDefault,
+			Formula:    mt.Formula,
+			Limits:     mt.Limits,
 		}
 
 		// Get latest entry
-		latestEntry, err := h.DB.GetLatestEntry(mt.ID)
-		if err == nil && latestEntry != nil {
-			metricData["latest_entry"] = map[string]interface{}{
-				"date":  latestEntry.EntryDate,
-				"value": latestEntry.Value,
+		if mt.Formula != nil {
+			computed, err := h.DB.ComputeMetricEntries(mt, "")
+			if err == nil && len(computed) > 0 {
+				summary.LatestEntry = &MetricPoint{Date: computed[0].EntryDate, Value: computed[0].Value}
 			}
+		} else if latestEntry, err := h.DB.GetLatestEntry(mt.ID); err == nil && latestEntry != nil {
+			summary.LatestEntry = &MetricPoint{Date: latestEntry.EntryDate, Value: latestEntry.Value}
 		}
 
-		result = append(result, metricData)
+		result = append(result, summary)
 	}
 
-	response := map[string]interface{}{
-		"metric_types": result,
+	writeJSON(w, http.StatusOK, MetricTypeListResponse{MetricTypes: result})
+}
+
+// checkFormula reports whether formula parses and reads only measured
+// metrics other than the metric named self, writing the field error if not
+func (h *MetricsHandler) checkFormula(w http.ResponseWriter, r *http.Request, formula, self string) bool {
+	f, err := db.ParseFormula(formula)
+	if err != nil {
+		fieldError(w, r, "formula", "Invalid formula: "+err.Error())
+		return false
+	}
+	if len(f.Metrics) == 0 {
+		fieldError(w, r, "formula", "formula must reference a metric, e.g. {Weight}")
+		return false
+	}
+	metricTypes, err := h.DB.GetMetricTypes()
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return false
+	}
+	measured := map[string]bool{}
+	for _, mt := range metricTypes {
+		measured[mt.Name] = mt.Formula == nil && mt.Name != self
+	}
+	for _, name := range f.Metrics {
+		if !measured[name] {
+			fieldError(w, r, "formula", "formula must reference measured metrics; {"+name+"} is not one")
+			return false
+		}
+	}
+	return true
+}
+
+// checkLimits reports whether limits are finite, in order and, for the
+// daily change, positive, writing the field error if not
+func checkLimits(w http.ResponseWriter, r *http.Request, limits db.MetricLimits) bool {
+	for _, v := range []*float64{limits.Min, limits.Max, limits.MaxDailyChange} {
+		if v != nil && (math.IsNaN(*v) || math.IsInf(*v, 0)) {
+			fieldError(w, r, "limits", "limits must be finite numbers")
+			return false
+		}
+	}
+	if limits.Min != nil && limits.Max != nil && *limits.Min > *limits.Max {
+		fieldError(w, r, "limits", "limits.min must not be above limits.max")
+		return false
+	}
+	if limits.MaxDailyChange != nil && *limits.MaxDailyChange <= 0 {
+		fieldError(w, r, "limits", "limits.max_daily_change must be positive")
+		return false
+	}
+	return true
+}
+
+// hasLimits reports whether any limit is set
+func hasLimits(limits db.MetricLimits) bool {
+	return limits.Min != nil || limits.Max != nil || limits.MaxDailyChange != nil
+}
+
+// outOfLimits describes why value is outside mt's min and max, or returns ""
+func outOfLimits(mt db.MetricType, value float64) string {
+	if mt.Limits.Min != nil && value < *mt.Limits.Min {
+		return "value must be at least " + formatValue(*mt.Limits.Min) + " " + mt.Unit
+	}
+	if mt.Limits.Max != nil && value > *mt.Limits.Max {
+		return "value must be at most " + formatValue(*mt.Limits.Max) + " " + mt.Unit
+	}
+	return ""
+}
+
+// changeWarnings describes how an entry of value on date changes faster per
+// day than mt's max_daily_change from the nearest entries on other dates
+// before and after it. excludeID is the entry itself.
+func changeWarnings(database *db.DB, mt db.MetricType, date string, value float64, excludeID int) ([]string, error) {
+	limit := mt.Limits.MaxDailyChange
+	if limit == nil {
+		return nil, nil
+	}
+	before, after, err := database.AdjacentMetricEntries(mt.ID, date, excludeID)
+	if err != nil {
+		return nil, err
+	}
+	day, err := time.Parse(dateLayout, date)
+	if err != nil {
+		return nil, nil
+	}
+	var warnings []string
+	for _, e := range []*db.MetricEntry{before, after} {
+		if e == nil {
+			continue
+		}
+		other, err := time.Parse(dateLayout, e.EntryDate[:min(len(e.EntryDate), len(dateLayout))])
+		if err != nil {
+			continue
+		}
+		days := math.Abs(float64(daysBetween(other, day)))
+		if change := math.Abs(value - e.Value); change > *limit*days {
+			warnings = append(warnings, "Changed "+formatValue(change)+" "+mt.Unit+" in "+strconv.Itoa(int(days))+
+				" day(s) from "+formatValue(e.Value)+" on "+e.EntryDate+"; expected at most "+
+				formatValue(*limit)+" "+mt.Unit+" a day")
+		}
 	}
+	return warnings, nil
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+// formatValue formats a metric value to at most two decimals
+func formatValue(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
 }
 
 // createMetricType creates a new metric type
@@ -112,100 +187,143 @@ func (h *MetricsHandler) createMetricType(w http.ResponseWriter, r *http.Request
 		Unit       string `json:"unit"`
 		Color      string `json:"color"`
 		OrderIndex int    `json:"order_index"`
+		// Formula makes the metric computed; empty for a measured metric
+		Formula string          `json:"formula"`
+		Limits  db.MetricLimits `json:"limits"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		invalidJSON(w, r)
 		return
 	}
 
 	// Validate required fields
-	if req.Name == "" || req.Unit == "" || req.Color == "" {
-		http.Error(w, "Name, unit, and color are required", http.StatusBadRequest)
+	for _, f := range []struct{ name, value string }{{"name", req.Name}, {"unit", req.Unit}, {"color", req.Color}} {
+		if strings.TrimSpace(f.value) == "" {
+			fieldError(w, r, f.name, f.name+" is required")
+			return
+		}
+	}
+	var formula *string
+	if strings.TrimSpace(req.Formula) != "" {
+		if !h.checkFormula(w, r, req.Formula, req.Name) {
+			return
+		}
+		formula = &req.Formula
+	}
+	if !checkLimits(w, r, req.Limits) {
+		return
+	}
+	if formula != nil && hasLimits(req.Limits) {
+		fieldError(w, r, "limits", "Computed metrics have no entries to limit")
 		return
 	}
 
-	id, err := h.DB.CreateMetricType(req.Name, req.Unit, req.Color, req.OrderIndex, false)
+	id, err := h.DB.CreateMetricType(req.Name, req.Unit, req.Color, formula, req.Limits, req.OrderIndex, false)
 	if err != nil {
 		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
-			http.Error(w, "Metric type with this name already exists", http.StatusConflict)
+			conflict(w, r, "name", "Metric type with this name already exists")
 			return
 		}
-		http.Error(w, fmt.Sprintf("Failed to create metric type: %v", err), http.StatusInternalServerError)
+		internalError(w, r, "Failed to create metric type", err)
 		return
 	}
 
-	response := map[string]interface{}{
-		"id":      id,
-		"message": "Metric type created successfully",
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(response)
+	h.Events.Publish(Event{Type: "metric_type.created", ID: id})
+	writeJSON(w, http.StatusCreated, CreatedResponse{ID: id, Message: "Metric type created successfully"})
 }
 
-// updateMetricType updates an existing metric type
-func (h *MetricsHandler) updateMetricType(w http.ResponseWriter, r *http.Request, idStr string) {
-	id, err := strconv.Atoi(idStr)
+// updateMetricType updates an existing metric type. Only a computed metric's
+// formula can change; measured metrics can't become computed. Limits
+// replace all three limits, so {} clears them; entries already outside new
+// limits are kept and listed as anomalies.
+func (h *MetricsHandler) updateMetricType(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		http.Error(w, "Invalid metric type ID", http.StatusBadRequest)
+		invalidID(w, r, "metric type")
 		return
 	}
 
 	var req struct {
-		Name       *string `json:"name"`
-		Unit       *string `json:"unit"`
-		Color      *string `json:"color"`
-		OrderIndex *int    `json:"order_index"`
+		Name       *string          `json:"name"`
+		Unit       *string          `json:"unit"`
+		Color      *string          `json:"color"`
+		Formula    *string          `json:"formula"`
+		Limits     *db.MetricLimits `json:"limits"`
+		OrderIndex *int             `json:"order_index"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		invalidJSON(w, r)
 		return
 	}
 
-	if err := h.DB.UpdateMetricType(id, req.Name, req.Unit, req.Color, req.OrderIndex); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to update metric type: %v", err), http.StatusInternalServerError)
-		return
+	if req.Formula != nil || req.Limits != nil {
+		mt, err := h.DB.GetMetricType(id)
+		if err != nil {
+			internalError(w, r, "Database error", err)
+			return
+		}
+		if mt == nil {
+			notFound(w, r, "Metric type not found")
+			return
+		}
+		if req.Formula != nil {
+			if mt.Formula == nil {
+				fieldError(w, r, "formula", "Measured metrics can't become computed")
+				return
+			}
+			name := mt.Name
+			if req.Name != nil {
+				name = *req.Name
+			}
+			if !h.checkFormula(w, r, *req.Formula, name) {
+				return
+			}
+		}
+		if req.Limits != nil {
+			if !checkLimits(w, r, *req.Limits) {
+				return
+			}
+			if mt.Formula != nil && hasLimits(*req.Limits) {
+				fieldError(w, r, "limits", "Computed metrics have no entries to limit")
+				return
+			}
+		}
 	}
 
-	response := map[string]interface{}{
-		"message": "Metric type updated successfully",
+	if err := h.DB.UpdateMetricType(id, req.Name, req.Unit, req.Color, req.Formula, req.Limits, req.OrderIndex); err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			conflict(w, r, "name", "Metric type with this name already exists")
+			return
+		}
+		internalError(w, r, "Failed to update metric type", err)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	h.Events.Publish(Event{Type: "metric_type.updated", ID: int64(id)})
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Metric type updated successfully"})
 }
 
 // deleteMetricType deletes a metric type
-func (h *MetricsHandler) deleteMetricType(w http.ResponseWriter, r *http.Request, idStr string) {
-	id, err := strconv.Atoi(idStr)
+func (h *MetricsHandler) deleteMetricType(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		http.Error(w, "Invalid metric type ID", http.StatusBadRequest)
+		invalidID(w, r, "metric type")
 		return
 	}
 
 	if err := h.DB.DeleteMetricType(id); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to delete metric type: %v", err), http.StatusInternalServerError)
+		internalError(w, r, "Failed to delete metric type", err)
 		return
 	}
 
-	response := map[string]interface{}{
-		"message": "Metric type deleted successfully",
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	h.Events.Publish(Event{Type: "metric_type.deleted", ID: int64(id)})
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Metric type deleted successfully"})
 }
 
 // reorderMetricTypes updates the order_index for multiple metric types
 func (h *MetricsHandler) reorderMetricTypes(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	var req struct {
 		MetricTypes []struct {
 			ID         int `json:"id"`
@@ -214,69 +332,99 @@ func (h *MetricsHandler) reorderMetricTypes(w http.ResponseWriter, r *http.Reque
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		invalidJSON(w, r)
 		return
 	}
 
 	// Update each metric type's order_index
 	for _, mt := range req.MetricTypes {
-		if err := h.DB.UpdateMetricType(mt.ID, nil, nil, nil, &mt.OrderIndex); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to update metric type order: %v", err), http.StatusInternalServerError)
+		if err := h.DB.UpdateMetricType(mt.ID, nil, nil, nil, nil, nil, &mt.OrderIndex); err != nil {
+			internalError(w, r, "Failed to update metric type order", err)
 			return
 		}
 	}
 
-	response := map[string]interface{}{
-		"message": "Metric types reordered successfully",
-	}
+	h.Events.Publish(Event{Type: "metric_type.reordered"})
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Metric types reordered successfully"})
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+// entryPages pages a metric type's entries, newest first by default
+var entryPages = pageSpec{
+	defaultLimit: 30,
+	defaultSort:  "-entry_date",
+	sorts: map[string]sortColumn{
+		"entry_date": {column: "entry_date"},
+		"value":      {column: "value", numeric: true},
+		"id":         {column: "id", numeric: true},
+	},
+	idColumn:   "id",
+	dateColumn: "entry_date",
 }
 
-// getEntriesByType returns entries for a specific metric type
-func (h *MetricsHandler) getEntriesByType(w http.ResponseWriter, r *http.Request, idStr string) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// getEntriesByType returns a page of entries for a specific metric type.
+// Computed metrics have no stored entries, so their pages are empty; their
+// values are in the dashboard.
+func (h *MetricsHandler) getEntriesByType(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		invalidID(w, r, "metric type")
 		return
 	}
-
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		http.Error(w, "Invalid metric type ID", http.StatusBadRequest)
+	pg, ok := entryPages.parse(w, r)
+	if !ok {
 		return
 	}
 
-	// Parse limit parameter
-	limitStr := r.URL.Query().Get("limit")
-	limit := 30 // default
-	if limitStr != "" {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil {
-			limit = parsedLimit
-		}
+	where := []string{"metric_type_id = ?"}
+	args := []interface{}{id}
+	where, args = pg.filter(where, args)
+
+	var total int
+	if err := h.DB.QueryRow("SELECT COUNT(*) FROM metric_entries WHERE "+strings.Join(where, " AND "), args...).Scan(&total); err != nil {
+		internalError(w, r, "Database error", err)
+		return
 	}
 
-	entries, err := h.DB.GetEntriesByType(id, limit)
+	clause, args := pg.clause(where, args)
+	rows, err := h.DB.Query("SELECT id, metric_type_id, entry_date, value, notes, created_at, "+
+		pg.column.keyExpr()+" FROM metric_entries"+clause, args...)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		internalError(w, r, "Database error", err)
 		return
 	}
+	defer rows.Close()
 
-	response := map[string]interface{}{
-		"entries": entries,
+	entries := []db.MetricEntry{}
+	var lastKey interface{}
+	n := 0
+	for rows.Next() {
+		var e db.MetricEntry
+		var key interface{}
+		if err := rows.Scan(&e.ID, &e.MetricTypeID, &e.EntryDate, &e.Value, &e.Notes, &e.CreatedAt, &key); err != nil {
+			internalError(w, r, "Scan error", err)
+			return
+		}
+		if n++; n <= pg.limit {
+			entries = append(entries, e)
+			lastKey = key
+		}
+	}
+	if err := rows.Err(); err != nil {
+		internalError(w, r, "Database error", err)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	lastID := 0
+	if len(entries) > 0 {
+		lastID = entries[len(entries)-1].ID
+	}
+	writeJSON(w, http.StatusOK, MetricEntriesResponse{Entries: entries, Page: pg.result(total, n, lastKey, lastID)})
 }
 
-// getDashboardData returns entries for all metrics within the specified time range
+// getDashboardData returns entries for all metrics within the specified time
+// range. With trend=true each metric also gets its smoothed series, slope and
+// the date it is projected to reach its goal (see metricTrend).
 func (h *MetricsHandler) getDashboardData(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	// Parse days parameter
 	daysStr := r.URL.Query().Get("days")
 	days := 30 // default
@@ -285,55 +433,61 @@ func (h *MetricsHandler) getDashboardData(w http.ResponseWriter, r *http.Request
 			days = parsedDays
 		}
 	}
+	withTrend := false
+	if s := r.URL.Query().Get("trend"); s != "" {
+		var err error
+		if withTrend, err = strconv.ParseBool(s); err != nil {
+			fieldError(w, r, "trend", "trend must be true or false")
+			return
+		}
+	}
+	alpha, goals, ok := parseTrendOptions(w, r)
+	if !ok {
+		return
+	}
 
 	// Get all metric types
 	metricTypes, err := h.DB.GetMetricTypes()
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		internalError(w, r, "Database error", err)
 		return
 	}
 
 	// Get dashboard data
 	entriesMap, err := h.DB.GetDashboardData(days)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		internalError(w, r, "Database error", err)
 		return
 	}
 
 	// Build response with metric types and their entries
-	metrics := []map[string]interface{}{}
+	metrics := []DashboardMetric{}
 	for _, mt := range metricTypes {
-		metricData := map[string]interface{}{
-			"id":    mt.ID,
-			"name":  mt.Name,
-			"unit":  mt.Unit,
-			"color": mt.Color,
+		metric := DashboardMetric{
+			ID:      mt.ID,
+			Name:    mt.Name,
+			Unit:    mt.Unit,
+			Color:   mt.Color,
+			Formula: mt.Formula,
+			Entries: []MetricPoint{},
 		}
 
-		// Add entries if they exist
-		if entries, ok := entriesMap[mt.ID]; ok {
-			// Convert to simple format for graph
-			simpleEntries := []map[string]interface{}{}
-			for _, e := range entries {
-				simpleEntries = append(simpleEntries, map[string]interface{}{
-					"date":  e.EntryDate,
-					"value": e.Value,
-				})
+		// Convert to simple format for graph
+		for _, e := range entriesMap[mt.ID] {
+			metric.Entries = append(metric.Entries, MetricPoint{Date: e.EntryDate, Value: e.Value})
+		}
+		if withTrend {
+			var goal *float64
+			if v, ok := goals[mt.ID]; ok {
+				goal = &v
 			}
-			metricData["entries"] = simpleEntries
-		} else {
-			metricData["entries"] = []map[string]interface{}{}
+			metric.Trend = metricTrend(metric.Entries, alpha, goal)
 		}
 
-		metrics = append(metrics, metricData)
-	}
-
-	response := map[string]interface{}{
-		"metrics": metrics,
+		metrics = append(metrics, metric)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, http.StatusOK, DashboardResponse{Metrics: metrics})
 }
 
 // Metric Entry Handlers
@@ -341,79 +495,140 @@ func (h *MetricsHandler) getDashboardData(w http.ResponseWriter, r *http.Request
 // MetricEntriesHandler handles CRUD operations for metric entries
 type MetricEntriesHandler struct {
 	DB *db.DB
+	// Events is notified after each change; nil disables notifications
+	Events *Hub
 }
 
-// ServeHTTP handles metric entry requests
+// routes mounts the metric entry endpoints
+func (h *MetricEntriesHandler) routes(rt *router) {
+	rt.handle(http.MethodPost, "/metric-entries", h.createEntry)
+	rt.handle(http.MethodPut, "/metric-entries/{id}", h.updateEntry)
+	rt.handle(http.MethodDelete, "/metric-entries/{id}", h.deleteEntry)
+}
+
+// ServeHTTP serves the metric entry endpoints on their own
 func (h *MetricEntriesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Parse path to extract ID if present
-	path := strings.TrimPrefix(r.URL.Path, "/api/metric-entries")
-	path = strings.TrimPrefix(path, "/")
-
-	switch r.Method {
-	case http.MethodPost:
-		if path == "" {
-			h.createEntry(w, r)
-		} else {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		}
-	case http.MethodPut:
-		if path != "" {
-			h.updateEntry(w, r, path)
-		} else {
-			http.Error(w, "Entry ID required", http.StatusBadRequest)
-		}
-	case http.MethodDelete:
-		if path != "" {
-			h.deleteEntry(w, r, path)
-		} else {
-			http.Error(w, "Entry ID required", http.StatusBadRequest)
-		}
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
+	serveRoutes(w, r, h.routes)
 }
 
-// createEntry creates a new metric entry
+// createEntry creates a new metric entry. Values outside the metric's
+// limits are refused; fast changes are saved with warnings.
 func (h *MetricEntriesHandler) createEntry(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		MetricTypeID int     `json:"metric_type_id"`
-		EntryDate    string  `json:"entry_date"`
-		Value        float64 `json:"value"`
-		Notes        *string `json:"notes"`
+		MetricTypeID int      `json:"metric_type_id"`
+		EntryDate    string   `json:"entry_date"`
+		Value        *float64 `json:"value"`
+		Notes        *string  `json:"notes"`
+		ClientID     *string  `json:"client_id"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		invalidJSON(w, r)
+		return
+	}
+	key, ok := idempotencyKey(w, r, req.ClientID)
+	if !ok {
 		return
 	}
 
 	// Validate required fields
-	if req.MetricTypeID == 0 || req.EntryDate == "" {
-		http.Error(w, "Metric type ID and entry date are required", http.StatusBadRequest)
+	if req.MetricTypeID == 0 {
+		fieldError(w, r, "metric_type_id", "metric_type_id is required")
+		return
+	}
+	if req.EntryDate == "" {
+		fieldError(w, r, "entry_date", "entry_date is required")
+		return
+	}
+	if !isDate(req.EntryDate) {
+		fieldError(w, r, "entry_date", "entry_date must be a YYYY-MM-DD date")
+		return
+	}
+	if req.Value == nil {
+		fieldError(w, r, "value", "value is required")
+		return
+	}
+	if math.IsNaN(*req.Value) || math.IsInf(*req.Value, 0) {
+		fieldError(w, r, "value", "value must be a finite number")
 		return
 	}
 
-	id, err := h.DB.CreateMetricEntry(req.MetricTypeID, req.EntryDate, req.Value, req.Notes)
+	mt, err := h.DB.GetMetricType(req.MetricTypeID)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to create metric entry: %v", err), http.StatusInternalServerError)
+		internalError(w, r, "Database error", err)
+		return
+	}
+	if mt == nil {
+		fieldError(w, r, "metric_type_id", "Metric type not found")
+		return
+	}
+	if mt.Formula != nil {
+		fieldError(w, r, "metric_type_id", "Computed metrics are read-only")
 		return
 	}
+	if msg := outOfLimits(*mt, *req.Value); msg != "" {
+		fieldError(w, r, "value", msg)
+		return
+	}
+	saved := func(id int64, status int, message string) {
+		warnings, err := changeWarnings(h.DB, *mt, req.EntryDate, *req.Value, int(id))
+		if err != nil {
+			internalError(w, r, "Database error", err)
+			return
+		}
+		refreshBodyweightPRs(r.Context(), h.DB, mt.ID)
+		checkGoals(r.Context(), h.DB, h.Events, metricScope(mt.ID))
+		event := "metric_entry.updated"
+		if status == http.StatusCreated {
+			event = "metric_entry.created"
+		}
+		h.Events.Publish(Event{Type: event, ID: id})
+		writeJSON(w, status, MetricEntrySavedResponse{ID: id, Message: message, Warnings: warnings})
+	}
 
-	response := map[string]interface{}{
-		"id":      id,
-		"message": "Metric entry created successfully",
+	// A known client_id is a retry or an edit of the same measurement
+	columns := []string{"metric_type_id", "entry_date", "value", "notes"}
+	args := []interface{}{req.MetricTypeID, req.EntryDate, *req.Value, req.Notes}
+	if key != nil {
+		if deleted, err := isDeleted(h.DB, "metric_entries", *key); err != nil {
+			internalError(w, r, "Database error", err)
+			return
+		} else if deleted {
+			conflict(w, r, "client_id", "The metric entry with this client_id was deleted")
+			return
+		}
+		id, found, err := upsertByClientID(h.DB, "metric_entries", *key, columns, args)
+		if err != nil {
+			internalError(w, r, "Failed to update metric entry", err)
+			return
+		}
+		if found {
+			saved(id, http.StatusOK, "Metric entry updated successfully")
+			return
+		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(response)
+	id, err := h.DB.CreateMetricEntryWithClientID(key, req.MetricTypeID, req.EntryDate, *req.Value, req.Notes)
+	if err != nil && key != nil && strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		// A concurrent retry inserted it first
+		if existing, found, upsertErr := upsertByClientID(h.DB, "metric_entries", *key, columns, args); upsertErr == nil && found {
+			saved(existing, http.StatusOK, "Metric entry updated successfully")
+			return
+		}
+	}
+	if err != nil {
+		internalError(w, r, "Failed to create metric entry", err)
+		return
+	}
+	saved(id, http.StatusCreated, "Metric entry created successfully")
 }
 
-// updateEntry updates an existing metric entry
-func (h *MetricEntriesHandler) updateEntry(w http.ResponseWriter, r *http.Request, idStr string) {
-	id, err := strconv.Atoi(idStr)
+// updateEntry updates an existing metric entry, checking the result against
+// its metric's limits like createEntry
+func (h *MetricEntriesHandler) updateEntry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		http.Error(w, "Invalid entry ID", http.StatusBadRequest)
+		invalidID(w, r, "entry")
 		return
 	}
 
@@ -424,40 +639,89 @@ func (h *MetricEntriesHandler) updateEntry(w http.ResponseWriter, r *http.Reques
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		invalidJSON(w, r)
 		return
 	}
 
-	if err := h.DB.UpdateMetricEntry(id, req.Value, req.EntryDate, req.Notes); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to update metric entry: %v", err), http.StatusInternalServerError)
+	if req.EntryDate != nil && !isDate(*req.EntryDate) {
+		fieldError(w, r, "entry_date", "entry_date must be a YYYY-MM-DD date")
+		return
+	}
+	if req.Value != nil && (math.IsNaN(*req.Value) || math.IsInf(*req.Value, 0)) {
+		fieldError(w, r, "value", "value must be a finite number")
+		return
+	}
+
+	entry, err := h.DB.GetMetricEntry(id)
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	if entry == nil {
+		notFound(w, r, "Metric entry not found")
+		return
+	}
+	mt, err := h.DB.GetMetricType(entry.MetricTypeID)
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	if mt == nil {
+		notFound(w, r, "Metric type not found")
+		return
+	}
+	value, date := entry.Value, entry.EntryDate
+	if req.Value != nil {
+		value = *req.Value
+	}
+	if req.EntryDate != nil {
+		date = *req.EntryDate
+	}
+	if msg := outOfLimits(*mt, value); msg != "" {
+		fieldError(w, r, "value", msg)
 		return
 	}
 
-	response := map[string]interface{}{
-		"message": "Metric entry updated successfully",
+	if err := h.DB.UpdateMetricEntry(id, req.Value, req.EntryDate, req.Notes); err != nil {
+		internalError(w, r, "Failed to update metric entry", err)
+		return
+	}
+	warnings, err := changeWarnings(h.DB, *mt, date, value, id)
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
 	}
+	refreshBodyweightPRs(r.Context(), h.DB, mt.ID)
+	checkGoals(r.Context(), h.DB, h.Events, metricScope(mt.ID))
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	h.Events.Publish(Event{Type: "metric_entry.updated", ID: int64(id)})
+	writeJSON(w, http.StatusOK, MetricEntrySavedResponse{ID: int64(id), Message: "Metric entry updated successfully", Warnings: warnings})
 }
 
 // deleteEntry deletes a metric entry
-func (h *MetricEntriesHandler) deleteEntry(w http.ResponseWriter, r *http.Request, idStr string) {
-	id, err := strconv.Atoi(idStr)
+func (h *MetricEntriesHandler) deleteEntry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		http.Error(w, "Invalid entry ID", http.StatusBadRequest)
+		invalidID(w, r, "entry")
+		return
+	}
+	// Its metric says whose PRs and goals the delete can change
+	var metricTypeID int
+	err = h.DB.QueryRow("SELECT metric_type_id FROM metric_entries WHERE id = ?", id).Scan(&metricTypeID)
+	if err != nil && err != sql.ErrNoRows {
+		internalError(w, r, "Database error", err)
 		return
 	}
 
 	if err := h.DB.DeleteMetricEntry(id); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to delete metric entry: %v", err), http.StatusInternalServerError)
+		internalError(w, r, "Failed to delete metric entry", err)
 		return
 	}
-
-	response := map[string]interface{}{
-		"message": "Metric entry deleted successfully",
+	if metricTypeID != 0 {
+		refreshBodyweightPRs(r.Context(), h.DB, metricTypeID)
+		checkGoals(r.Context(), h.DB, h.Events, metricScope(metricTypeID))
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	h.Events.Publish(Event{Type: "metric_entry.deleted", ID: int64(id)})
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Metric entry deleted successfully"})
 }
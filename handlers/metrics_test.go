@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"train/db"
+)
+
+func serveMetrics(h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, path, bytes.NewBufferString(body)))
+	return w
+}
+
+func TestComputedMetrics_EvaluatedOnDashboard(t *testing.T) {
+	database, err := db.OpenForTesting()
+	if err != nil {
+		t.Fatalf("OpenForTesting: %v", err)
+	}
+	defer database.Close()
+	if err := database.SetSetting(db.SettingHeight, "180"); err != nil {
+		t.Fatalf("SetSetting: %v", err)
+	}
+	daysAgo := func(n int) string { return time.Now().AddDate(0, 0, -n).Format(dateLayout) }
+	// Weight is 1 and Body Fat % 2 in the seeds; BMI is 4 and Lean Mass 5
+	database.CreateMetricEntry(1, daysAgo(3), 81, nil)
+	database.CreateMetricEntry(2, daysAgo(2), 20, nil)
+	database.CreateMetricEntry(1, daysAgo(1), 79.2, nil)
+
+	h := &MetricsHandler{DB: database}
+	w := serveMetrics(h, http.MethodGet, "/api/v1/metrics/dashboard?days=30", "")
+	var resp DashboardResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	series := map[string][]MetricPoint{}
+	for _, m := range resp.Metrics {
+		series[m.Name] = m.Entries
+	}
+
+	// Each input carries forward to the later dates; Lean Mass starts once
+	// Body Fat % has a value, and BMI only has points where Weight does
+	want := map[string][]MetricPoint{
+		"BMI":       {{Date: daysAgo(1), Value: 24.44}, {Date: daysAgo(3), Value: 25}},
+		"Lean Mass": {{Date: daysAgo(1), Value: 63.36}, {Date: daysAgo(2), Value: 64.8}},
+	}
+	for name, points := range want {
+		got := series[name]
+		if len(got) != len(points) {
+			t.Fatalf("%s: expected %v, got %v", name, points, got)
+		}
+		for i := range points {
+			if got[i] != points[i] {
+				t.Errorf("%s: expected %v, got %v", name, points, got)
+				break
+			}
+		}
+	}
+	if len(series["Waist-to-Height"]) != 0 {
+		t.Errorf("expected no Waist-to-Height without waist entries, got %v", series["Waist-to-Height"])
+	}
+
+	entries := &MetricEntriesHandler{DB: database}
+	w = serveMetrics(entries, http.MethodPost, "/api/v1/metric-entries", `{"metric_type_id": 4, "entry_date": "2026-01-01", "value": 24}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an entry of a computed metric, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestComputedMetrics_FormulaValidationAndRename(t *testing.T) {
+	database, err := db.OpenForTesting()
+	if err != nil {
+		t.Fatalf("OpenForTesting: %v", err)
+	}
+	defer database.Close()
+	h := &MetricsHandler{DB: database}
+
+	for name, formula := range map[string]string{
+		"syntax":          `{Weight} *`,
+		"unknown metric":  `{Height} * 2`,
+		"computed metric": `{BMI} * 2`,
+		"no metric":       `height / 100`,
+	} {
+		w := serveMetrics(h, http.MethodPost, "/api/v1/metrics", `{"name": "X", "unit": "x", "color": "#000", "formula": "`+formula+`"}`)
+		if w.Code != http.StatusBadRequest || !bytes.Contains(w.Body.Bytes(), []byte(`"field":"formula"`)) {
+			t.Errorf("%s: expected 400 on formula, got %d: %s", name, w.Code, w.Body.String())
+		}
+	}
+
+	w := serveMetrics(h, http.MethodPost, "/api/v1/metrics", `{"name": "Waist (in)", "unit": "in", "color": "#000", "formula": "{Waist} / 2.54"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	// Renaming an input keeps the formulas that read it working
+	serveMetrics(h, http.MethodPut, "/api/v1/metrics/3", `{"name": "Waist Circumference"}`)
+	// Measured metrics can't become computed
+	if w := serveMetrics(h, http.MethodPut, "/api/v1/metrics/1", `{"formula": "{Waist Circumference} * 2"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 making Weight computed, got %d: %s", w.Code, w.Body.String())
+	}
+	// A duplicate name is a conflict and leaves the formulas alone
+	if w := serveMetrics(h, http.MethodPut, "/api/v1/metrics/3", `{"name": "Weight"}`); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 renaming to an existing name, got %d: %s", w.Code, w.Body.String())
+	}
+
+	types, err := database.GetMetricTypes()
+	if err != nil {
+		t.Fatalf("GetMetricTypes: %v", err)
+	}
+	formulas := map[string]string{}
+	for _, mt := range types {
+		if mt.Formula != nil {
+			formulas[mt.Name] = *mt.Formula
+		}
+	}
+	if formulas["Waist (in)"] != "{Waist Circumference} / 2.54" || formulas["Waist-to-Height"] != "{Waist Circumference} / height" {
+		t.Errorf("expected renamed references, got %v", formulas)
+	}
+}
+
+func TestComputedMetrics_PrecedenceAndWhitespace(t *testing.T) {
+	database, err := db.OpenForTesting()
+	if err != nil {
+		t.Fatalf("OpenForTesting: %v", err)
+	}
+	defer database.Close()
+	database.CreateMetricEntry(1, "2026-01-01", 3, nil)
+	h := &MetricsHandler{DB: database}
+
+	// ^ binds tighter than unary minus, and tabs and newlines separate tokens
+	w := serveMetrics(h, http.MethodPost, "/api/v1/metrics", `{"name": "X", "unit": "x", "color": "#000", "formula": "-{Weight}^2\n\t+ 2^-1"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created CreatedResponse
+	json.NewDecoder(w.Body).Decode(&created)
+	mt, err := database.GetMetricType(int(created.ID))
+	if err != nil || mt == nil {
+		t.Fatalf("GetMetricType: %v", err)
+	}
+	entries, err := database.ComputeMetricEntries(*mt, "")
+	if err != nil || len(entries) != 1 || entries[0].Value != -8.5 {
+		t.Errorf("expected -3^2 + 2^-1 = -8.5, got %+v (%v)", entries, err)
+	}
+}
+
+func TestDashboard_TrendSmoothsAndProjects(t *testing.T) {
+	database, err := db.OpenForTesting()
+	if err != nil {
+		t.Fatalf("OpenForTesting: %v", err)
+	}
+	defer database.Close()
+	daysAgo := func(n int) string { return time.Now().AddDate(0, 0, -n).Format(dateLayout) }
+	database.CreateMetricEntry(1, daysAgo(10), 82, nil)
+	database.CreateMetricEntry(1, daysAgo(5), 81, nil)
+	// Entries on the same date are averaged
+	database.CreateMetricEntry(1, daysAgo(0), 80.4, nil)
+	database.CreateMetricEntry(1, daysAgo(0), 79.6, nil)
+	h := &MetricsHandler{DB: database}
+
+	weightTrend := func(query string) *MetricTrend {
+		t.Helper()
+		w := serveMetrics(h, http.MethodGet, "/api/v1/metrics/dashboard?trend=true&alpha=0.5"+query, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp DashboardResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		if resp.Metrics[0].Trend == nil {
+			t.Fatalf("expected a trend, got %+v", resp.Metrics[0])
+		}
+		return resp.Metrics[0].Trend
+	}
+
+	// Losing 0.2 kg a day: 25 more days to 75 kg
+	trend := weightTrend("&goal=1:75")
+	wantEWMA := []MetricPoint{{Date: daysAgo(0), Value: 80.03}, {Date: daysAgo(5), Value: 81.03}, {Date: daysAgo(10), Value: 82}}
+	wantMean := []MetricPoint{{Date: daysAgo(0), Value: 80.5}, {Date: daysAgo(5), Value: 81.5}, {Date: daysAgo(10), Value: 82}}
+	for i := range wantEWMA {
+		if len(trend.EWMA) != 3 || trend.EWMA[i] != wantEWMA[i] || len(trend.RollingMean) != 3 || trend.RollingMean[i] != wantMean[i] {
+			t.Fatalf("expected EWMA %v and rolling mean %v, got %v and %v", wantEWMA, wantMean, trend.EWMA, trend.RollingMean)
+		}
+	}
+	if trend.SlopePerWeek == nil || *trend.SlopePerWeek != -1.4 {
+		t.Errorf("expected a slope of -1.4/week, got %v", trend.SlopePerWeek)
+	}
+	if want := time.Now().AddDate(0, 0, 25).Format(dateLayout); trend.ProjectedDate == nil || *trend.ProjectedDate != want {
+		t.Errorf("expected projected date %s, got %v", want, trend.ProjectedDate)
+	}
+
+	// A goal the trend moves away from has no projection
+	if trend := weightTrend("&goal=1:90"); trend.ProjectedDate != nil {
+		t.Errorf("expected no projected date, got %s", *trend.ProjectedDate)
+	}
+
+	for _, query := range []string{"trend=maybe", "trend=true&alpha=0", "trend=true&goal=75"} {
+		if w := serveMetrics(h, http.MethodGet, "/api/v1/metrics/dashboard?"+query, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", query, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestMetricLimits_RefuseOutOfRangeAndWarnOnJumps(t *testing.T) {
+	database, err := db.OpenForTesting()
+	if err != nil {
+		t.Fatalf("OpenForTesting: %v", err)
+	}
+	defer database.Close()
+	types := &MetricsHandler{DB: database}
+	entries := &MetricEntriesHandler{DB: database}
+
+	for name, body := range map[string]string{
+		"min above max":   `{"limits": {"min": 200, "max": 30}}`,
+		"no daily change": `{"limits": {"max_daily_change": 0}}`,
+		"computed metric": `{"limits": {"max": 50}}`,
+	} {
+		path := "/api/v1/metrics/1"
+		if name == "computed metric" {
+			path = "/api/v1/metrics/4"
+		}
+		if w := serveMetrics(types, http.MethodPut, path, body); w.Code != http.StatusBadRequest || !bytes.Contains(w.Body.Bytes(), []byte(`"field":"limits"`)) {
+			t.Errorf("%s: expected 400 on limits, got %d: %s", name, w.Code, w.Body.String())
+		}
+	}
+	if w := serveMetrics(types, http.MethodPut, "/api/v1/metrics/1", `{"limits": {"min": 30, "max": 250, "max_daily_change": 1}}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	create := func(date string, value float64) (int, MetricEntrySavedResponse) {
+		t.Helper()
+		w := serveMetrics(entries, http.MethodPost, "/api/v1/metric-entries",
+			`{"metric_type_id": 1, "entry_date": "`+date+`", "value": `+formatValue(value)+`}`)
+		var resp MetricEntrySavedResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		return w.Code, resp
+	}
+	if code, resp := create("2026-01-10", 78); code != http.StatusCreated || len(resp.Warnings) != 0 {
+		t.Fatalf("expected 201 without warnings, got %d: %+v", code, resp)
+	}
+	// A typo outside the range is refused
+	if code, _ := create("2026-01-11", 780); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for 780 kg, got %d", code)
+	}
+	// 1.5 kg over two days is fine; 3 kg over one day is saved with a warning
+	if code, resp := create("2026-01-12", 79.5); code != http.StatusCreated || len(resp.Warnings) != 0 {
+		t.Errorf("expected 201 without warnings, got %d: %+v", code, resp)
+	}
+	code, jump := create("2026-01-13", 82.5)
+	if code != http.StatusCreated || len(jump.Warnings) != 1 {
+		t.Fatalf("expected 201 with a warning, got %d: %+v", code, jump)
+	}
+
+	// Edits are checked against the entries either side, not themselves
+	path := "/api/v1/metric-entries/" + strconv.FormatInt(jump.ID, 10)
+	if w := serveMetrics(entries, http.MethodPut, path, `{"value": 80}`); w.Code != http.StatusOK || bytes.Contains(w.Body.Bytes(), []byte("warnings")) {
+		t.Errorf("expected 200 without warnings, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := serveMetrics(entries, http.MethodPut, path, `{"value": 20}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for 20 kg, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := serveMetrics(entries, http.MethodPut, "/api/v1/metric-entries/999", `{"value": 80}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing entry, got %d", w.Code)
+	}
+}
+
+func TestMetricAnomalies_FlagOutliersAndOutOfRangeEntries(t *testing.T) {
+	database, err := db.OpenForTesting()
+	if err != nil {
+		t.Fatalf("OpenForTesting: %v", err)
+	}
+	defer database.Close()
+	// A steady loss with a typo and a one-off spike; the trend isn't anomalous
+	values := []float64{80, 79.8, 79.9, 79.5, 780, 79.2, 79.3, 78.9, 84, 78.7, 78.5, 78.6}
+	ids := map[float64]int64{}
+	for i, v := range values {
+		id, err := database.CreateMetricEntry(1, time.Date(2026, 1, i+1, 0, 0, 0, 0, time.UTC).Format(dateLayout), v, nil)
+		if err != nil {
+			t.Fatalf("CreateMetricEntry: %v", err)
+		}
+		ids[v] = id
+	}
+	h := &MetricsHandler{DB: database}
+	// Limits set afterwards still flag the entries before them
+	serveMetrics(h, http.MethodPut, "/api/v1/metrics/1", `{"limits": {"max": 250}}`)
+
+	w := serveMetrics(h, http.MethodGet, "/api/v1/metrics/1/anomalies", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp MetricAnomaliesResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Anomalies) != 2 {
+		t.Fatalf("expected two anomalies, got %+v", resp.Anomalies)
+	}
+	spike, typo := resp.Anomalies[0], resp.Anomalies[1]
+	if spike.ID != int(ids[84]) || spike.Reason != "outlier" || spike.Expected == nil || *spike.Expected != 79.25 {
+		t.Errorf("expected the 84 kg spike as an outlier from 79.25, got %+v", spike)
+	}
+	if typo.ID != int(ids[780]) || typo.Reason != "above_max" {
+		t.Errorf("expected 780 kg above max, got %+v", typo)
+	}
+
+	// A looser threshold lets the spike through
+	w = serveMetrics(h, http.MethodGet, "/api/v1/metrics/1/anomalies?threshold=100", "")
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Anomalies) != 1 || resp.Anomalies[0].Reason != "above_max" {
+		t.Errorf("expected only the out-of-range entry, got %+v", resp.Anomalies)
+	}
+
+	// With a daily change limit the spike is a jump; the entries after it
+	// and the typo are measured from the last good entry, so they aren't
+	serveMetrics(h, http.MethodPut, "/api/v1/metrics/1", `{"limits": {"max": 250, "max_daily_change": 2}}`)
+	w = serveMetrics(h, http.MethodGet, "/api/v1/metrics/1/anomalies", "")
+	resp = MetricAnomaliesResponse{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Anomalies) != 2 || resp.Anomalies[0].ID != int(ids[84]) || resp.Anomalies[0].Reason != "jump" ||
+		resp.Anomalies[1].Reason != "above_max" {
+		t.Errorf("expected the spike as a jump and the typo above max, got %+v", resp.Anomalies)
+	}
+
+	for path, code := range map[string]int{
+		"/api/v1/metrics/1/anomalies?threshold=0": http.StatusBadRequest,
+		"/api/v1/metrics/1/anomalies?window=0":    http.StatusBadRequest,
+		"/api/v1/metrics/99/anomalies":            http.StatusNotFound,
+	} {
+		if w := serveMetrics(h, http.MethodGet, path, ""); w.Code != code {
+			t.Errorf("%s: expected %d, got %d", path, code, w.Code)
+		}
+	}
+}
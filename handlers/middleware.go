@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"train/db"
+)
+
+type contextKey int
+
+const requestIDKey contextKey = iota
+
+// RequestIDHeader carries the request ID to and from clients
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID returns the ID assigned to r by Telemetry.Wrap, or "-" outside it
+func RequestID(r *http.Request) string {
+	if id, ok := r.Context().Value(requestIDKey).(string); ok {
+		return id
+	}
+	return "-"
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// durationBuckets are the upper bounds, in seconds, of the latency histogram
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+type requestKey struct {
+	Method string
+	Route  string
+	Status int
+}
+
+type latencyKey struct {
+	Method string
+	Route  string
+}
+
+type latencyHistogram struct {
+	Buckets []uint64 // cumulative counts, one per durationBuckets entry
+	Count   uint64
+	Sum     float64
+}
+
+// Telemetry logs every request and serves Prometheus-format counters for
+// them, along with SQLite connection pool stats, at /metrics
+type Telemetry struct {
+	DB *db.DB
+
+	inFlight  atomic.Int64
+	mu        sync.Mutex
+	requests  map[requestKey]uint64
+	latencies map[latencyKey]*latencyHistogram
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer (Flush,
+// write deadlines)
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// Wrap assigns each request an ID (reusing a client-supplied X-Request-ID),
+// echoes it in the response, and logs and counts the request when it ends
+func (t *Telemetry) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > 64 {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey, id))
+
+		t.inFlight.Add(1)
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			t.inFlight.Add(-1)
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			elapsed := time.Since(start)
+
+			// The mux records the matched pattern on the request, which keeps
+			// the route label bounded no matter what paths clients send
+			route := r.Pattern
+			if route == "" {
+				route = "unmatched"
+			}
+			t.observe(r.Method, route, rec.status, elapsed)
+
+			level := slog.LevelInfo
+			if route == "/healthz" || route == "/readyz" || route == "/metrics" {
+				level = slog.LevelDebug
+			}
+			slog.Log(r.Context(), level, "request",
+				"request_id", id,
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", rec.status,
+				"bytes", rec.bytes,
+				"latency", elapsed,
+				"remote", r.RemoteAddr,
+			)
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
+
+func (t *Telemetry) observe(method, route string, status int, elapsed time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.requests == nil {
+		t.requests = make(map[requestKey]uint64)
+		t.latencies = make(map[latencyKey]*latencyHistogram)
+	}
+	t.requests[requestKey{method, route, status}]++
+
+	lk := latencyKey{method, route}
+	hist := t.latencies[lk]
+	if hist == nil {
+		hist = &latencyHistogram{Buckets: make([]uint64, len(durationBuckets))}
+		t.latencies[lk] = hist
+	}
+	seconds := elapsed.Seconds()
+	for i, bound := range durationBuckets {
+		if seconds <= bound {
+			hist.Buckets[i]++
+		}
+	}
+	hist.Count++
+	hist.Sum += seconds
+}
+
+// ServeHTTP writes all metrics in the Prometheus text exposition format
+func (t *Telemetry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, r)
+		return
+	}
+
+	var sb strings.Builder
+
+	t.mu.Lock()
+	requestKeys := make([]requestKey, 0, len(t.requests))
+	for k := range t.requests {
+		requestKeys = append(requestKeys, k)
+	}
+	sort.Slice(requestKeys, func(i, j int) bool {
+		a, b := requestKeys[i], requestKeys[j]
+		if a.Route != b.Route {
+			return a.Route < b.Route
+		}
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		return a.Status < b.Status
+	})
+	sb.WriteString("# HELP train_http_requests_total HTTP requests by method, route and status code.\n")
+	sb.WriteString("# TYPE train_http_requests_total counter\n")
+	for _, k := range requestKeys {
+		fmt.Fprintf(&sb, "train_http_requests_total{method=%q,route=%q,status=\"%d\"} %d\n", k.Method, k.Route, k.Status, t.requests[k])
+	}
+
+	latencyKeys := make([]latencyKey, 0, len(t.latencies))
+	for k := range t.latencies {
+		latencyKeys = append(latencyKeys, k)
+	}
+	sort.Slice(latencyKeys, func(i, j int) bool {
+		a, b := latencyKeys[i], latencyKeys[j]
+		if a.Route != b.Route {
+			return a.Route < b.Route
+		}
+		return a.Method < b.Method
+	})
+	sb.WriteString("# HELP train_http_request_duration_seconds HTTP request latency by method and route.\n")
+	sb.WriteString("# TYPE train_http_request_duration_seconds histogram\n")
+	for _, k := range latencyKeys {
+		hist := t.latencies[k]
+		for i, bound := range durationBuckets {
+			fmt.Fprintf(&sb, "train_http_request_duration_seconds_bucket{method=%q,route=%q,le=\"%g\"} %d\n", k.Method, k.Route, bound, hist.Buckets[i])
+		}
+		fmt.Fprintf(&sb, "train_http_request_duration_seconds_bucket{method=%q,route=%q,le=\"+Inf\"} %d\n", k.Method, k.Route, hist.Count)
+		fmt.Fprintf(&sb, "train_http_request_duration_seconds_sum{method=%q,route=%q} %g\n", k.Method, k.Route, hist.Sum)
+		fmt.Fprintf(&sb, "train_http_request_duration_seconds_count{method=%q,route=%q} %d\n", k.Method, k.Route, hist.Count)
+	}
+	t.mu.Unlock()
+
+	sb.WriteString("# HELP train_http_requests_in_flight HTTP requests currently being served.\n")
+	sb.WriteString("# TYPE train_http_requests_in_flight gauge\n")
+	fmt.Fprintf(&sb, "train_http_requests_in_flight %d\n", t.inFlight.Load())
+
+	if t.DB != nil {
+		stats := t.DB.Stats()
+		gauges := []struct {
+			name, help string
+			value      int
+		}{
+			{"train_db_open_connections", "Open SQLite connections, in use or idle.", stats.OpenConnections},
+			{"train_db_in_use_connections", "SQLite connections currently in use.", stats.InUse},
+			{"train_db_idle_connections", "Idle SQLite connections.", stats.Idle},
+			{"train_db_max_open_connections", "Maximum open SQLite connections (0 = unlimited).", stats.MaxOpenConnections},
+		}
+		for _, g := range gauges {
+			fmt.Fprintf(&sb, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", g.name, g.help, g.name, g.name, g.value)
+		}
+		counters := []struct {
+			name, help string
+			value      float64
+		}{
+			{"train_db_wait_count_total", "Times a request waited for a free SQLite connection.", float64(stats.WaitCount)},
+			{"train_db_wait_duration_seconds_total", "Total time spent waiting for a free SQLite connection.", stats.WaitDuration.Seconds()},
+			{"train_db_max_idle_closed_total", "Connections closed due to the idle pool limit.", float64(stats.MaxIdleClosed)},
+			{"train_db_max_lifetime_closed_total", "Connections closed due to their maximum lifetime.", float64(stats.MaxLifetimeClosed)},
+		}
+		for _, c := range counters {
+			fmt.Fprintf(&sb, "# HELP %s %s\n# TYPE %s counter\n%s %g\n", c.name, c.help, c.name, c.name, c.value)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(sb.String()))
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTelemetry_ErrorResponseCarriesRequestID(t *testing.T) {
+	tel := &Telemetry{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/broken", func(w http.ResponseWriter, r *http.Request) {
+		internalError(w, r, "Database error", errors.New("no such table: secrets"))
+	})
+
+	w := httptest.NewRecorder()
+	tel.Wrap(mux).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/broken", nil))
+
+	id := w.Header().Get(RequestIDHeader)
+	if id == "" {
+		t.Fatalf("response should carry an %s header", RequestIDHeader)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, id) {
+		t.Errorf("error body should include the request ID %q, got %q", id, body)
+	}
+	if strings.Contains(body, "secrets") {
+		t.Errorf("error body should not leak the underlying error, got %q", body)
+	}
+}
+
+func TestTelemetry_ReusesClientRequestID(t *testing.T) {
+	tel := &Telemetry{}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "abc123")
+	w := httptest.NewRecorder()
+	tel.Wrap(http.NotFoundHandler()).ServeHTTP(w, req)
+
+	if got := w.Header().Get(RequestIDHeader); got != "abc123" {
+		t.Errorf("expected client request ID to be echoed, got %q", got)
+	}
+}
+
+func TestTelemetry_MetricsCountRequestsByRoute(t *testing.T) {
+	tel := &Telemetry{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/exercises/", func(w http.ResponseWriter, r *http.Request) {})
+	mux.Handle("/metrics", tel)
+	h := tel.Wrap(mux)
+
+	for _, path := range []string{"/api/exercises/1", "/api/exercises/2"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+
+	want := `train_http_requests_total{method="GET",route="/api/exercises/",status="200"} 2`
+	if !strings.Contains(body, want) {
+		t.Errorf("metrics should contain %q, got:\n%s", want, body)
+	}
+	if !strings.Contains(body, `train_http_request_duration_seconds_count{method="GET",route="/api/exercises/"} 2`) {
+		t.Errorf("metrics should contain a latency histogram, got:\n%s", body)
+	}
+}
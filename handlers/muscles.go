@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"train/db"
+)
+
+// MusclesHandler manages muscles and the muscles each exercise works
+type MusclesHandler struct {
+	DB *db.DB
+}
+
+// Muscle roles and the share of a set each one is credited by default
+const (
+	RolePrimary   = "primary"
+	RoleSecondary = "secondary"
+)
+
+var defaultMuscleWeights = map[string]float64{RolePrimary: 1, RoleSecondary: 0.5}
+
+// routes mounts the muscle endpoints
+func (h *MusclesHandler) routes(rt *router) {
+	rt.handle(http.MethodGet, "/muscles", h.listMuscles)
+	rt.handle(http.MethodPost, "/muscles", h.createMuscle)
+	rt.handle(http.MethodPut, "/muscles/{id}", h.updateMuscle)
+	rt.handle(http.MethodDelete, "/muscles/{id}", h.deleteMuscle)
+	rt.handle(http.MethodGet, "/exercises/{id}/muscles", h.getExerciseMuscles)
+	rt.handle(http.MethodPut, "/exercises/{id}/muscles", h.setExerciseMuscles)
+}
+
+// ServeHTTP serves the muscle endpoints on their own
+func (h *MusclesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveRoutes(w, r, h.routes)
+}
+
+// listMuscles returns every muscle with the number of exercises mapped to it
+func (h *MusclesHandler) listMuscles(w http.ResponseWriter, r *http.Request) {
+	muscles, err := h.DB.GetMuscles()
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	counts, err := h.exerciseCounts()
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+
+	resp := MuscleListResponse{Muscles: []MuscleSummary{}}
+	for _, m := range muscles {
+		resp.Muscles = append(resp.Muscles, MuscleSummary{Muscle: m, ExerciseCount: counts[m.ID]})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *MusclesHandler) exerciseCounts() (map[int]int, error) {
+	rows, err := h.DB.Query("SELECT muscle_id, COUNT(*) FROM exercise_muscles GROUP BY muscle_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[int]int{}
+	for rows.Next() {
+		var id, n int
+		if err := rows.Scan(&id, &n); err != nil {
+			return nil, err
+		}
+		counts[id] = n
+	}
+	return counts, rows.Err()
+}
+
+// createMuscle adds a muscle
+func (h *MusclesHandler) createMuscle(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name       string `json:"name"`
+		OrderIndex int    `json:"order_index"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		invalidJSON(w, r)
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		fieldError(w, r, "name", "name is required")
+		return
+	}
+
+	id, err := h.DB.CreateMuscle(strings.TrimSpace(req.Name), req.OrderIndex)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			conflict(w, r, "name", "Muscle with this name already exists")
+			return
+		}
+		internalError(w, r, "Failed to create muscle", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, CreatedResponse{ID: id, Message: "Muscle created successfully"})
+}
+
+// updateMuscle renames or reorders a muscle
+func (h *MusclesHandler) updateMuscle(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		invalidID(w, r, "muscle")
+		return
+	}
+
+	var req struct {
+		Name       *string `json:"name"`
+		OrderIndex *int    `json:"order_index"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		invalidJSON(w, r)
+		return
+	}
+
+	updates := []string{}
+	args := []interface{}{}
+	if req.Name != nil {
+		if strings.TrimSpace(*req.Name) == "" {
+			fieldError(w, r, "name", "Name cannot be empty")
+			return
+		}
+		updates = append(updates, "name = ?")
+		args = append(args, strings.TrimSpace(*req.Name))
+	}
+	if req.OrderIndex != nil {
+		updates = append(updates, "order_index = ?")
+		args = append(args, *req.OrderIndex)
+	}
+	if len(updates) == 0 {
+		badRequest(w, r, "No fields to update")
+		return
+	}
+
+	result, err := h.DB.Exec("UPDATE muscles SET "+strings.Join(updates, ", ")+" WHERE id = ?", append(args, id)...)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			conflict(w, r, "name", "Muscle with this name already exists")
+			return
+		}
+		internalError(w, r, "Failed to update muscle", err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		notFound(w, r, "Muscle not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Muscle updated successfully"})
+}
+
+// deleteMuscle removes a muscle and its exercise mappings
+func (h *MusclesHandler) deleteMuscle(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		invalidID(w, r, "muscle")
+		return
+	}
+
+	result, err := h.DB.Exec("DELETE FROM muscles WHERE id = ?", id)
+	if err != nil {
+		internalError(w, r, "Failed to delete muscle", err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		notFound(w, r, "Muscle not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Muscle deleted successfully"})
+}
+
+// getExerciseMuscles returns the muscles an exercise works
+func (h *MusclesHandler) getExerciseMuscles(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.exerciseID(w, r)
+	if !ok {
+		return
+	}
+	muscles, err := h.DB.GetExerciseMuscles(id)
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ExerciseMusclesResponse{ExerciseID: id, Muscles: muscles})
+}
+
+// setExerciseMuscles replaces the muscles an exercise works. weight defaults
+// to 1 for primary and 0.5 for secondary muscles; an empty list clears the
+// mapping.
+func (h *MusclesHandler) setExerciseMuscles(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.exerciseID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Muscles []struct {
+			MuscleID int      `json:"muscle_id"`
+			Role     string   `json:"role"`
+			Weight   *float64 `json:"weight"`
+		} `json:"muscles"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		invalidJSON(w, r)
+		return
+	}
+
+	known, err := h.DB.GetMuscles()
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	exists := map[int]bool{}
+	for _, m := range known {
+		exists[m.ID] = true
+	}
+
+	mapping := []db.ExerciseMuscle{}
+	seen := map[int]bool{}
+	for _, m := range req.Muscles {
+		if !exists[m.MuscleID] {
+			fieldError(w, r, "muscles", "Unknown muscle "+strconv.Itoa(m.MuscleID))
+			return
+		}
+		if seen[m.MuscleID] {
+			fieldError(w, r, "muscles", "Muscle "+strconv.Itoa(m.MuscleID)+" is listed more than once")
+			return
+		}
+		seen[m.MuscleID] = true
+
+		weight, ok := defaultMuscleWeights[m.Role]
+		if !ok {
+			fieldError(w, r, "muscles", "role must be primary or secondary")
+			return
+		}
+		if m.Weight != nil {
+			if *m.Weight <= 0 || *m.Weight > 1 {
+				fieldError(w, r, "muscles", "weight must be greater than 0 and at most 1")
+				return
+			}
+			weight = *m.Weight
+		}
+		mapping = append(mapping, db.ExerciseMuscle{MuscleID: m.MuscleID, Role: m.Role, Weight: weight})
+	}
+
+	if err := h.DB.SetExerciseMuscles(id, mapping); err != nil {
+		internalError(w, r, "Failed to set exercise muscles", err)
+		return
+	}
+	muscles, err := h.DB.GetExerciseMuscles(id)
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ExerciseMusclesResponse{ExerciseID: id, Muscles: muscles})
+}
+
+// exerciseID reads the {id} path value and checks that the exercise exists
+func (h *MusclesHandler) exerciseID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		invalidID(w, r, "exercise")
+		return 0, false
+	}
+	ex, err := h.DB.GetExerciseByID(id)
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return 0, false
+	}
+	if ex == nil {
+		notFound(w, r, "Exercise not found")
+		return 0, false
+	}
+	return id, true
+}
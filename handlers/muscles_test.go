@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"train/db"
+)
+
+func newMusclesHandler(t *testing.T) (*MusclesHandler, int) {
+	t.Helper()
+	database, err := db.OpenForTesting()
+	if err != nil {
+		t.Fatalf("OpenForTesting: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	id, err := database.CreateExercise("Squat", "weight", "Legs-Push", nil, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateExercise: %v", err)
+	}
+	return &MusclesHandler{DB: database}, int(id)
+}
+
+// muscleID returns the ID of a seeded muscle
+func muscleID(t *testing.T, database *db.DB, name string) int {
+	t.Helper()
+	var id int
+	if err := database.QueryRow("SELECT id FROM muscles WHERE name = ?", name).Scan(&id); err != nil {
+		t.Fatalf("muscle %s: %v", name, err)
+	}
+	return id
+}
+
+func putExerciseMuscles(h *MusclesHandler, exerciseID int, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/exercises/"+strconv.Itoa(exerciseID)+"/muscles", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestExerciseMuscles_DefaultWeightsAndReplace(t *testing.T) {
+	h, id := newMusclesHandler(t)
+	quads, glutes := muscleID(t, h.DB, "Quads"), muscleID(t, h.DB, "Glutes")
+
+	w := putExerciseMuscles(h, id, `{"muscles": [
+		{"muscle_id": `+strconv.Itoa(glutes)+`, "role": "secondary"},
+		{"muscle_id": `+strconv.Itoa(quads)+`, "role": "primary"}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp ExerciseMusclesResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Muscles) != 2 || resp.Muscles[0].Name != "Quads" || resp.Muscles[0].Weight != 1 || resp.Muscles[1].Weight != 0.5 {
+		t.Errorf("expected Quads primary at 1 then Glutes secondary at 0.5, got %+v", resp.Muscles)
+	}
+
+	// A second PUT replaces the mapping rather than adding to it
+	if w := putExerciseMuscles(h, id, `{"muscles": [{"muscle_id": `+strconv.Itoa(glutes)+`, "role": "primary", "weight": 0.8}]}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	muscles, _ := h.DB.GetExerciseMuscles(id)
+	if len(muscles) != 1 || muscles[0].Weight != 0.8 {
+		t.Errorf("expected only Glutes at 0.8, got %+v", muscles)
+	}
+}
+
+func TestExerciseMuscles_InvalidMappingRejected(t *testing.T) {
+	h, id := newMusclesHandler(t)
+	quads := strconv.Itoa(muscleID(t, h.DB, "Quads"))
+
+	for name, body := range map[string]string{
+		"unknown muscle": `{"muscles": [{"muscle_id": 9999, "role": "primary"}]}`,
+		"bad role":       `{"muscles": [{"muscle_id": ` + quads + `, "role": "main"}]}`,
+		"zero weight":    `{"muscles": [{"muscle_id": ` + quads + `, "role": "primary", "weight": 0}]}`,
+		"weight above 1": `{"muscles": [{"muscle_id": ` + quads + `, "role": "primary", "weight": 1.5}]}`,
+		"duplicate": `{"muscles": [{"muscle_id": ` + quads + `, "role": "primary"},
+			{"muscle_id": ` + quads + `, "role": "secondary"}]}`,
+	} {
+		if w := putExerciseMuscles(h, id, body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, w.Code)
+		}
+	}
+	if w := putExerciseMuscles(h, 9999, `{"muscles": []}`); w.Code != http.StatusNotFound {
+		t.Errorf("unknown exercise: expected 404, got %d", w.Code)
+	}
+}
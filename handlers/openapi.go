@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec is the OpenAPI 3 description of the /api endpoints. It is kept
+// in sync with the handlers by openapi_test.go.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPIHandler serves the API specification at /api/v1/openapi.json
+type OpenAPIHandler struct{}
+
+// routes mounts the spec endpoint
+func (h OpenAPIHandler) routes(rt *router) {
+	rt.handle(http.MethodGet, "/openapi.json", h.ServeHTTP)
+}
+
+// ServeHTTP returns the embedded OpenAPI document
+func (OpenAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(openAPISpec)
+	}
+}
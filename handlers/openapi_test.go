@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"train/db"
+)
+
+// The tests below drive every operation in openapi.json against the real
+// handlers and check that the status codes and JSON bodies they return are
+// the ones the document promises.
+
+type apiSpec struct {
+	Paths      map[string]map[string]specOperation `json:"paths"`
+	Components struct {
+		Schemas   map[string]*specSchema  `json:"schemas"`
+		Responses map[string]specResponse `json:"responses"`
+	} `json:"components"`
+}
+
+type specOperation struct {
+	OperationID string                  `json:"operationId"`
+	Parameters  []specParameter         `json:"parameters"`
+	RequestBody *specBody               `json:"requestBody"`
+	Responses   map[string]specResponse `json:"responses"`
+}
+
+type specParameter struct {
+	Name    string          `json:"name"`
+	In      string          `json:"in"`
+	Schema  *specSchema     `json:"schema"`
+	Example json.RawMessage `json:"example"`
+}
+
+type specBody struct {
+	Content map[string]struct {
+		Schema *specSchema `json:"schema"`
+	} `json:"content"`
+}
+
+type specResponse struct {
+	Ref string `json:"$ref"`
+	specBody
+}
+
+type specSchema struct {
+	Ref        string                 `json:"$ref"`
+	Type       string                 `json:"type"`
+	Nullable   bool                   `json:"nullable"`
+	Enum       []interface{}          `json:"enum"`
+	Required   []string               `json:"required"`
+	Properties map[string]*specSchema `json:"properties"`
+	Items      *specSchema            `json:"items"`
+	AllOf      []*specSchema          `json:"allOf"`
+	Example    json.RawMessage        `json:"example"`
+}
+
+func loadSpec(t *testing.T) *apiSpec {
+	t.Helper()
+	var spec apiSpec
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	return &spec
+}
+
+// newAPIServer mounts every route on a fresh database seeded so that ID 1
+// exists for each resource
+func newAPIServer(t *testing.T) http.Handler {
+	t.Helper()
+	database, err := db.OpenForTesting()
+	if err != nil {
+		t.Fatalf("OpenForTesting: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	sets, reps := 3, 5
+	weight := 100.0
+	exerciseID, err := database.CreateExercise("Squat", "weight", "Legs-Push", &sets, &reps, &weight)
+	if err != nil {
+		t.Fatalf("CreateExercise: %v", err)
+	}
+	if _, err := database.CreateRoutine(int(exerciseID), "Monday", 0, nil); err != nil {
+		t.Fatalf("CreateRoutine: %v", err)
+	}
+	if _, err := database.CreateHistory(int(exerciseID), "2026-01-12", &weight, []int{5, 5, 5}, true, nil, true, nil, db.Cardio{}); err != nil {
+		t.Fatalf("CreateHistory: %v", err)
+	}
+	if _, err := database.CreateMetricEntry(1, "2026-01-12", 80.2, nil); err != nil {
+		t.Fatalf("CreateMetricEntry: %v", err)
+	}
+	weightID := 1
+	if _, err := database.CreateGoal(db.Goal{MetricTypeID: &weightID, Direction: "at_most", Target: 75}); err != nil {
+		t.Fatalf("CreateGoal: %v", err)
+	}
+	// A shared session whose code and host token match the spec examples
+	if _, err := database.Exec(`INSERT INTO shared_sessions (code, day_of_week, session_date) VALUES ('K7M2QX', 'Monday', '2026-01-12')`); err != nil {
+		t.Fatalf("insert shared session: %v", err)
+	}
+	if _, err := database.Exec(`INSERT INTO shared_session_participants (session_id, name, token, is_host)
+		VALUES (last_insert_rowid(), 'Sam', '8d3f1c2a9b7e4d6f8a1c3e5b7d9f0a2c', 1)`); err != nil {
+		t.Fatalf("insert shared session host: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	Register(mux, database, Options{PlanImport: true})
+	return mux
+}
+
+func (s *apiSpec) resolve(schema *specSchema) *specSchema {
+	for schema != nil && schema.Ref != "" {
+		schema = s.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
+
+// validate reports the first way value does not match schema. It covers the
+// subset of JSON Schema that openapi.json uses.
+func (s *apiSpec) validate(schema *specSchema, value interface{}, path string) error {
+	if ref := schema.Ref; ref != "" {
+		if schema = s.resolve(schema); schema == nil {
+			return fmt.Errorf("%s: unresolved %s", path, ref)
+		}
+	}
+	if value == nil {
+		if schema.Nullable {
+			return nil
+		}
+		return fmt.Errorf("%s: null is not allowed", path)
+	}
+	for _, sub := range schema.AllOf {
+		if err := s.validate(sub, value, path); err != nil {
+			return err
+		}
+	}
+	if len(schema.Enum) > 0 {
+		found := false
+		for _, allowed := range schema.Enum {
+			if allowed == value {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not in %v", path, value, schema.Enum)
+		}
+	}
+
+	switch schema.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected object, got %T", path, value)
+		}
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+		for name, v := range obj {
+			prop, ok := schema.Properties[name]
+			if !ok {
+				if schema.Properties != nil {
+					return fmt.Errorf("%s: undocumented property %q", path, name)
+				}
+				continue
+			}
+			if err := s.validate(prop, v, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected array, got %T", path, value)
+		}
+		for i, v := range arr {
+			if err := s.validate(schema.Items, v, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s: expected string, got %T", path, value)
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != float64(int64(n)) {
+			return fmt.Errorf("%s: expected integer, got %v", path, value)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s: expected number, got %T", path, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean, got %T", path, value)
+		}
+	}
+	return nil
+}
+
+// sampleBody builds a request body from the examples on a schema's properties
+func (s *apiSpec) sampleBody(schema *specSchema) map[string]json.RawMessage {
+	schema = s.resolve(schema)
+	body := map[string]json.RawMessage{}
+	for name, prop := range schema.Properties {
+		if prop.Example != nil {
+			body[name] = prop.Example
+		}
+	}
+	return body
+}
+
+type specCall struct {
+	method, path, operationID string
+	op                        specOperation
+}
+
+func (s *apiSpec) calls() []specCall {
+	var calls []specCall
+	for path, ops := range s.Paths {
+		for method, op := range ops {
+			calls = append(calls, specCall{strings.ToUpper(method), path, op.OperationID, op})
+		}
+	}
+	sort.Slice(calls, func(i, j int) bool { return calls[i].operationID < calls[j].operationID })
+	return calls
+}
+
+// checkResponse asserts that the recorded response is documented for op
+func (s *apiSpec) checkResponse(t *testing.T, op specOperation, w *httptest.ResponseRecorder) {
+	t.Helper()
+	resp, ok := op.Responses[fmt.Sprint(w.Code)]
+	if !ok {
+		t.Fatalf("status %d is not documented: %s", w.Code, w.Body.String())
+	}
+	if resp.Ref != "" {
+		resp = s.Components.Responses[strings.TrimPrefix(resp.Ref, "#/components/responses/")]
+	}
+
+	contentType := w.Header().Get("Content-Type")
+	if !strings.HasPrefix(contentType, "application/json") {
+		if _, ok := resp.Content[strings.Split(contentType, ";")[0]]; !ok {
+			t.Fatalf("content type %q is not documented", contentType)
+		}
+		return
+	}
+	media, ok := resp.Content["application/json"]
+	if !ok {
+		t.Fatalf("JSON body is not documented for status %d", w.Code)
+	}
+	var body interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON body: %v", err)
+	}
+	if err := s.validate(media.Schema, body, "body"); err != nil {
+		t.Errorf("response does not match schema: %v\n%s", err, w.Body.String())
+	}
+}
+
+func (c specCall) request(s *apiSpec, pathValue func(specParameter) string) *http.Request {
+	path := c.path
+	query := url.Values{}
+	for _, p := range c.op.Parameters {
+		switch {
+		case p.In == "path":
+			path = strings.Replace(path, "{"+p.Name+"}", pathValue(p), 1)
+		case p.In == "query" && p.Example != nil:
+			var v interface{}
+			json.Unmarshal(p.Example, &v)
+			query.Set(p.Name, fmt.Sprint(v))
+		}
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var body []byte
+	if c.op.RequestBody != nil {
+		body, _ = json.Marshal(s.sampleBody(c.op.RequestBody.Content["application/json"].Schema))
+	}
+	req := httptest.NewRequest(c.method, path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	return req
+}
+
+func TestOpenAPI_OperationsMatchHandlers(t *testing.T) {
+	spec := loadSpec(t)
+	for _, call := range spec.calls() {
+		t.Run(call.operationID, func(t *testing.T) {
+			req := call.request(spec, func(p specParameter) string {
+				var v interface{}
+				json.Unmarshal(p.Example, &v)
+				return fmt.Sprint(v)
+			})
+			// Streaming endpoints run until the client leaves
+			ctx, cancel := context.WithTimeout(req.Context(), 200*time.Millisecond)
+			defer cancel()
+			req = req.WithContext(ctx)
+			w := httptest.NewRecorder()
+			newAPIServer(t).ServeHTTP(w, req)
+
+			if w.Code >= 400 {
+				t.Fatalf("%s %s with documented examples failed: %d %s", call.method, req.URL.Path, w.Code, w.Body.String())
+			}
+			spec.checkResponse(t, call.op, w)
+		})
+	}
+}
+
+func TestOpenAPI_ErrorsMatchHandlers(t *testing.T) {
+	spec := loadSpec(t)
+	for _, call := range spec.calls() {
+		hasID := false
+		for _, p := range call.op.Parameters {
+			hasID = hasID || (p.In == "path" && p.Schema.Type == "integer")
+		}
+		if !hasID {
+			continue
+		}
+		t.Run(call.operationID, func(t *testing.T) {
+			req := call.request(spec, func(specParameter) string { return "abc" })
+			w := httptest.NewRecorder()
+			newAPIServer(t).ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("non-numeric ID should be rejected with 400, got %d", w.Code)
+			}
+			spec.checkResponse(t, call.op, w)
+		})
+	}
+}
+
+func TestOpenAPI_Served(t *testing.T) {
+	w := httptest.NewRecorder()
+	newAPIServer(t).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if !bytes.Equal(w.Body.Bytes(), openAPISpec) {
+		t.Error("served document differs from the embedded spec")
+	}
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxPageLimit caps ?limit= on every paginated listing
+const maxPageLimit = 500
+
+// Page describes where a listing's rows sit in the full result. NextCursor
+// is set when more rows follow; pass it back as ?cursor= to continue.
+type Page struct {
+	Total      int    `json:"total"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// sortColumn is a column a listing can be ordered by
+type sortColumn struct {
+	column  string
+	numeric bool
+}
+
+// keyExpr selects the column for the cursor. Text columns are cast so that
+// DATE/DATETIME values come back as the stored string, not a time.Time.
+func (c sortColumn) keyExpr() string {
+	if c.numeric {
+		return c.column
+	}
+	return "CAST(" + c.column + " AS TEXT)"
+}
+
+// pageSpec describes how one listing is paged, sorted and date-filtered
+type pageSpec struct {
+	defaultLimit int
+	// defaultSort is a key of sorts, prefixed with "-" for descending
+	defaultSort string
+	sorts       map[string]sortColumn
+	// idColumn breaks ties so that cursors are stable
+	idColumn string
+	// dateColumn is compared against ?from= and ?to= (inclusive)
+	dateColumn string
+}
+
+// page is a parsed listing request
+type page struct {
+	limit, offset int
+	sort          string
+	desc          bool
+	column        sortColumn
+	idColumn      string
+	after         *pageCursor
+	dateColumn    string
+	from, to      string
+}
+
+// pageCursor marks the last row of the previous page
+type pageCursor struct {
+	Sort string      `json:"s"`
+	Key  interface{} `json:"k"`
+	ID   int         `json:"id"`
+}
+
+// parse reads limit, offset, cursor, sort, from and to. On invalid input
+// it writes a field error and returns false.
+func (s pageSpec) parse(w http.ResponseWriter, r *http.Request) (*page, bool) {
+	q := r.URL.Query()
+	p := &page{limit: s.defaultLimit, idColumn: s.idColumn, dateColumn: s.dateColumn, from: q.Get("from"), to: q.Get("to")}
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageLimit {
+			fieldError(w, r, "limit", "limit must be between 1 and "+strconv.Itoa(maxPageLimit))
+			return nil, false
+		}
+		p.limit = n
+	}
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			fieldError(w, r, "offset", "offset must be a non-negative integer")
+			return nil, false
+		}
+		p.offset = n
+	}
+
+	sort := q.Get("sort")
+	if sort == "" {
+		sort = s.defaultSort
+	}
+	p.sort = sort
+	p.desc = strings.HasPrefix(sort, "-")
+	column, ok := s.sorts[strings.TrimPrefix(sort, "-")]
+	if !ok {
+		names := slices.Sorted(maps.Keys(s.sorts))
+		fieldError(w, r, "sort", "sort must be one of "+strings.Join(names, ", ")+", optionally prefixed with -")
+		return nil, false
+	}
+	p.column = column
+
+	if v := q.Get("cursor"); v != "" {
+		if p.offset != 0 {
+			fieldError(w, r, "cursor", "cursor cannot be combined with offset")
+			return nil, false
+		}
+		var c pageCursor
+		data, err := base64.RawURLEncoding.DecodeString(v)
+		if err != nil || json.Unmarshal(data, &c) != nil || c.Key == nil {
+			fieldError(w, r, "cursor", "Invalid cursor")
+			return nil, false
+		}
+		if c.Sort != sort {
+			fieldError(w, r, "cursor", "cursor was issued for a different sort")
+			return nil, false
+		}
+		p.after = &c
+	}
+
+	for _, f := range []struct{ name, value string }{{"from", p.from}, {"to", p.to}} {
+		if f.value != "" && !isDate(f.value) {
+			fieldError(w, r, f.name, f.name+" must be a YYYY-MM-DD date")
+			return nil, false
+		}
+	}
+	return p, true
+}
+
+// filter appends the from/to conditions to where
+func (p *page) filter(where []string, args []interface{}) ([]string, []interface{}) {
+	if p.from != "" {
+		where = append(where, p.dateColumn+" >= ?")
+		args = append(args, p.from)
+	}
+	if p.to != "" {
+		where = append(where, p.dateColumn+" <= ?")
+		args = append(args, p.to)
+	}
+	return where, args
+}
+
+// clause returns the cursor condition, ORDER BY and LIMIT for a query whose
+// filters are already in where. It fetches one extra row to detect whether
+// another page follows.
+func (p *page) clause(where []string, args []interface{}) (string, []interface{}) {
+	dir, cmp := "ASC", ">"
+	if p.desc {
+		dir, cmp = "DESC", "<"
+	}
+	if p.after != nil {
+		col := p.column.column
+		where = append(where, "("+col+" "+cmp+" ? OR ("+col+" = ? AND "+p.idColumn+" "+cmp+" ?))")
+		args = append(args, p.after.Key, p.after.Key, p.after.ID)
+	}
+
+	sql := ""
+	if len(where) > 0 {
+		sql = " WHERE " + strings.Join(where, " AND ")
+	}
+	sql += " ORDER BY " + p.column.column + " " + dir + ", " + p.idColumn + " " + dir
+	sql += " LIMIT ? OFFSET ?"
+	return sql, append(args, p.limit+1, p.offset)
+}
+
+// result builds the Page for a listing that scanned n rows (including the
+// extra one) and whose last returned row had the given sort key and ID
+func (p *page) result(total, n int, lastKey interface{}, lastID int) Page {
+	pg := Page{Total: total, Limit: p.limit, Offset: p.offset}
+	if n > p.limit {
+		data, _ := json.Marshal(pageCursor{Sort: p.sort, Key: lastKey, ID: lastID})
+		pg.NextCursor = base64.RawURLEncoding.EncodeToString(data)
+	}
+	return pg
+}
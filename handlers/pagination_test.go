@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"train/db"
+)
+
+func newHistoryPagesHandler(t *testing.T, sessions int) *HistoryHandler {
+	t.Helper()
+	database, err := db.OpenForTesting()
+	if err != nil {
+		t.Fatalf("OpenForTesting: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	exerciseID, err := database.CreateExercise("Squat", "weight", "", nil, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateExercise: %v", err)
+	}
+	for i := 1; i <= sessions; i++ {
+		// Two sessions share each date so that ties must be broken by ID
+		date := fmt.Sprintf("2026-01-%02d", (i+1)/2)
+		if _, err := database.CreateHistory(int(exerciseID), date, nil, []int{5}, true, nil, false, nil, db.Cardio{}); err != nil {
+			t.Fatalf("CreateHistory: %v", err)
+		}
+	}
+	return &HistoryHandler{DB: database}
+}
+
+func getHistoryPage(t *testing.T, h *HistoryHandler, query url.Values) (int, HistoryResponse) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/history/1?"+query.Encode(), nil))
+	var resp HistoryResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	return w.Code, resp
+}
+
+func TestPagination_CursorWalksEveryRowOnce(t *testing.T) {
+	h := newHistoryPagesHandler(t, 7)
+
+	seen := map[int]bool{}
+	var dates []string
+	query := url.Values{"limit": {"3"}}
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("cursor never ran out")
+		}
+		code, resp := getHistoryPage(t, h, query)
+		if code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", code)
+		}
+		if resp.Total != 7 {
+			t.Errorf("total should count all rows, got %d", resp.Total)
+		}
+		for _, entry := range resp.History {
+			if seen[entry.ID] {
+				t.Errorf("row %d returned twice", entry.ID)
+			}
+			seen[entry.ID] = true
+			dates = append(dates, entry.SessionDate)
+		}
+		if resp.NextCursor == "" {
+			break
+		}
+		query.Set("cursor", resp.NextCursor)
+	}
+
+	if len(seen) != 7 {
+		t.Errorf("expected 7 rows, got %d", len(seen))
+	}
+	for i := 1; i < len(dates); i++ {
+		if dates[i] > dates[i-1] {
+			t.Errorf("default sort should be newest first: %v", dates)
+			break
+		}
+	}
+}
+
+func TestPagination_OffsetSortAndDateRange(t *testing.T) {
+	h := newHistoryPagesHandler(t, 6)
+
+	_, resp := getHistoryPage(t, h, url.Values{"sort": {"session_date"}, "limit": {"2"}, "offset": {"2"}})
+	if len(resp.History) != 2 || resp.History[0].ID != 3 || resp.Offset != 2 {
+		t.Errorf("unexpected ascending page: %+v", resp)
+	}
+
+	_, resp = getHistoryPage(t, h, url.Values{"from": {"2026-01-02"}, "to": {"2026-01-02"}})
+	if resp.Total != 2 || len(resp.History) != 2 {
+		t.Errorf("date range should match the two sessions on 2026-01-02, got %+v", resp)
+	}
+}
+
+func TestPagination_InvalidParametersRejected(t *testing.T) {
+	h := newHistoryPagesHandler(t, 3)
+	_, first := getHistoryPage(t, h, url.Values{"limit": {"1"}})
+
+	for _, tc := range []struct {
+		query url.Values
+		field string
+	}{
+		{url.Values{"limit": {"0"}}, "limit"},
+		{url.Values{"limit": {"1000"}}, "limit"},
+		{url.Values{"offset": {"-1"}}, "offset"},
+		{url.Values{"sort": {"weight"}}, "sort"},
+		{url.Values{"from": {"yesterday"}}, "from"},
+		{url.Values{"cursor": {"not-a-cursor"}}, "cursor"},
+		{url.Values{"cursor": {first.NextCursor}, "offset": {"1"}}, "cursor"},
+		{url.Values{"cursor": {first.NextCursor}, "sort": {"id"}}, "cursor"},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/history/1?"+tc.query.Encode(), nil))
+		var resp ErrorResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		if w.Code != http.StatusBadRequest || resp.Error.Field != tc.field {
+			t.Errorf("%v: expected 400 on %s, got %d %+v", tc.query, tc.field, w.Code, resp.Error)
+		}
+	}
+}
+
+func TestPagination_MetricEntriesSortByValue(t *testing.T) {
+	database, err := db.OpenForTesting()
+	if err != nil {
+		t.Fatalf("OpenForTesting: %v", err)
+	}
+	defer database.Close()
+	for i, v := range []float64{80.4, 79.9, 81.2} {
+		database.CreateMetricEntry(1, fmt.Sprintf("2026-02-%02d", i+1), v, nil)
+	}
+
+	h := &MetricsHandler{DB: database}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/metrics/1/entries?sort=-value&limit=2", nil))
+
+	var resp MetricEntriesResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Entries) != 2 || resp.Entries[0].Value != 81.2 || resp.Entries[1].Value != 80.4 {
+		t.Errorf("unexpected entries: %+v", resp.Entries)
+	}
+	if resp.Total != 3 || resp.NextCursor == "" {
+		t.Errorf("expected total 3 and a next cursor, got %+v", resp.Page)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -15,27 +16,38 @@ import (
 // PlanHandler handles bulk plan import/export
 type PlanHandler struct {
 	DB *db.DB
+	// Events is notified after each change; nil disables notifications
+	Events *Hub
+	// ImportEnabled allows POST to replace routines; export is always on
+	ImportEnabled bool
 }
 
 var weekDays = []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"}
 
+// routes mounts the plan endpoints
+func (h *PlanHandler) routes(rt *router) {
+	rt.handle(http.MethodGet, "/plan", h.exportPlan)
+	rt.handle(http.MethodPost, "/plan", h.importPlan)
+}
+
+// ServeHTTP serves the plan endpoints on their own
 func (h *PlanHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		h.exportPlan(w, r)
-	case http.MethodPost:
-		h.importPlan(w, r)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
+	serveRoutes(w, r, h.routes)
 }
 
-// exportPlan renders the current workout plan as plain text
+// exportPlan renders the current workout plan as plain text, or wrapped in
+// JSON for clients that prefer application/json
 func (h *PlanHandler) exportPlan(w http.ResponseWriter, r *http.Request) {
+	types, categories, err := h.taxonomy()
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+
 	var sb strings.Builder
 
-	sb.WriteString("# Types: weight | bodyweight | cardio | assisted\n")
-	sb.WriteString("# Categories: Legs-Push | Legs-Pull | Arms-Push | Arms-Pull | Core-Push | Core-Pull\n\n")
+	sb.WriteString("# Types: " + strings.Join(types, " | ") + "\n")
+	sb.WriteString("# Categories: " + strings.Join(categories, " | ") + "\n\n")
 
 	for _, day := range weekDays {
 		var title string
@@ -56,7 +68,7 @@ func (h *PlanHandler) exportPlan(w http.ResponseWriter, r *http.Request) {
 			ORDER BY r.order_index
 		`, day)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+			internalError(w, r, "Database error", err)
 			return
 		}
 
@@ -68,7 +80,7 @@ func (h *PlanHandler) exportPlan(w http.ResponseWriter, r *http.Request) {
 
 			if err := rows.Scan(&name, &exType, &category, &targetSets, &targetReps, &targetWeight); err != nil {
 				rows.Close()
-				http.Error(w, fmt.Sprintf("Scan error: %v", err), http.StatusInternalServerError)
+				internalError(w, r, "Scan error", err)
 				return
 			}
 
@@ -93,8 +105,14 @@ func (h *PlanHandler) exportPlan(w http.ResponseWriter, r *http.Request) {
 		sb.WriteString("\n")
 	}
 
+	plan := strings.TrimRight(sb.String(), "\n")
+	w.Header().Set("Vary", "Accept")
+	if acceptQuality(r, "application/json") > acceptQuality(r, "text/plain") {
+		writeJSON(w, http.StatusOK, PlanResponse{Plan: plan})
+		return
+	}
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	w.Write([]byte(strings.TrimRight(sb.String(), "\n")))
+	w.Write([]byte(plan))
 }
 
 // --- Plan parsing ---
@@ -117,15 +135,6 @@ var (
 	dayHeaderRe = regexp.MustCompile(`(?i)^#\s*(monday|tuesday|wednesday|thursday|friday|saturday|sunday)\s*(?::\s*(.*))?$`)
 	leadingRe   = regexp.MustCompile(`^(?:\d+\.\s*|-\s*)`)
 	setsRepsRe  = regexp.MustCompile(`^(\d+)[xX](\d+)$`)
-
-	validExerciseTypes = map[string]bool{
-		"weight": true, "bodyweight": true, "assisted": true, "cardio": true,
-	}
-	validExerciseCategories = map[string]bool{
-		"Legs-Push": true, "Legs-Pull": true,
-		"Arms-Push": true, "Arms-Pull": true,
-		"Core-Push": true, "Core-Pull": true,
-	}
 )
 
 func capitalizeFirst(s string) string {
@@ -135,7 +144,28 @@ func capitalizeFirst(s string) string {
 	return strings.ToUpper(s[:1]) + s[1:]
 }
 
-func parsePlan(text string) map[string]planDay {
+// taxonomy returns the exercise type and category names in display order
+func (h *PlanHandler) taxonomy() (types, categories []string, err error) {
+	exTypes, err := h.DB.GetExerciseTypes()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, t := range exTypes {
+		types = append(types, t.Name)
+	}
+	cats, err := h.DB.GetCategories()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, c := range cats {
+		categories = append(categories, c.Name)
+	}
+	return types, categories, nil
+}
+
+// parsePlan reads plan text into days. Lines with a type not in types are
+// skipped and categories not in categories are dropped.
+func parsePlan(text string, types, categories []string) map[string]planDay {
 	result := make(map[string]planDay)
 	var currentDay string
 
@@ -171,10 +201,10 @@ func parsePlan(text string) map[string]planDay {
 		category := strings.TrimSpace(parts[2])
 		setsRepsStr := strings.TrimSpace(parts[3])
 
-		if name == "" || !validExerciseTypes[exType] {
+		if name == "" || !slices.Contains(types, exType) {
 			continue
 		}
-		if !validExerciseCategories[category] {
+		if !slices.Contains(categories, category) {
 			category = ""
 		}
 
@@ -216,23 +246,33 @@ func parsePlan(text string) map[string]planDay {
 
 // importPlan parses the pasted plan text and applies it to the database
 func (h *PlanHandler) importPlan(w http.ResponseWriter, r *http.Request) {
+	if !h.ImportEnabled {
+		writeError(w, r, http.StatusForbidden, APIError{Code: CodeForbidden, Message: "Plan import is disabled"})
+		return
+	}
+
 	var req struct {
 		Plan string `json:"plan"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		invalidJSON(w, r)
 		return
 	}
 
-	days := parsePlan(req.Plan)
+	types, categories, err := h.taxonomy()
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	days := parsePlan(req.Plan, types, categories)
 	if len(days) == 0 {
-		http.Error(w, "No valid days found in plan text", http.StatusBadRequest)
+		fieldError(w, r, "plan", "No valid days found in plan text")
 		return
 	}
 
 	tx, err := h.DB.Begin()
 	if err != nil {
-		http.Error(w, "Failed to begin transaction", http.StatusInternalServerError)
+		internalError(w, r, "Failed to begin transaction", err)
 		return
 	}
 	defer tx.Rollback()
@@ -244,13 +284,13 @@ func (h *PlanHandler) importPlan(w http.ResponseWriter, r *http.Request) {
 				ON CONFLICT(day_of_week) DO UPDATE SET title = excluded.title
 			`, dayName, dayData.Title)
 			if err != nil {
-				http.Error(w, fmt.Sprintf("Failed to update title for %s: %v", dayName, err), http.StatusInternalServerError)
+				internalError(w, r, fmt.Sprintf("Failed to update title for %s", dayName), err)
 				return
 			}
 		}
 
 		if _, err = tx.Exec("DELETE FROM routines WHERE day_of_week = ?", dayName); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to clear routines for %s: %v", dayName, err), http.StatusInternalServerError)
+			internalError(w, r, fmt.Sprintf("Failed to clear routines for %s", dayName), err)
 			return
 		}
 
@@ -261,24 +301,24 @@ func (h *PlanHandler) importPlan(w http.ResponseWriter, r *http.Request) {
 			if err == sql.ErrNoRows {
 				result, err := tx.Exec(
 					`INSERT INTO exercises (name, type, category, target_sets, target_reps, target_weight) VALUES (?, ?, ?, ?, ?, ?)`,
-					ex.Name, ex.Type, ex.Category, ex.TargetSets, ex.TargetReps, ex.TargetWeight,
+					ex.Name, ex.Type, nullIfEmpty(ex.Category), ex.TargetSets, ex.TargetReps, ex.TargetWeight,
 				)
 				if err != nil {
-					http.Error(w, fmt.Sprintf("Failed to create exercise '%s': %v", ex.Name, err), http.StatusInternalServerError)
+					internalError(w, r, fmt.Sprintf("Failed to create exercise '%s'", ex.Name), err)
 					return
 				}
 				exerciseID, _ = result.LastInsertId()
 			} else if err != nil {
-				http.Error(w, fmt.Sprintf("Failed to look up exercise '%s': %v", ex.Name, err), http.StatusInternalServerError)
+				internalError(w, r, fmt.Sprintf("Failed to look up exercise '%s'", ex.Name), err)
 				return
 			} else {
 				_, err = tx.Exec(`
 					UPDATE exercises
 					SET type = ?, category = ?, target_sets = ?, target_reps = ?, target_weight = ?, updated_at = CURRENT_TIMESTAMP
 					WHERE id = ?
-				`, ex.Type, ex.Category, ex.TargetSets, ex.TargetReps, ex.TargetWeight, exerciseID)
+				`, ex.Type, nullIfEmpty(ex.Category), ex.TargetSets, ex.TargetReps, ex.TargetWeight, exerciseID)
 				if err != nil {
-					http.Error(w, fmt.Sprintf("Failed to update exercise '%s': %v", ex.Name, err), http.StatusInternalServerError)
+					internalError(w, r, fmt.Sprintf("Failed to update exercise '%s'", ex.Name), err)
 					return
 				}
 			}
@@ -287,20 +327,20 @@ func (h *PlanHandler) importPlan(w http.ResponseWriter, r *http.Request) {
 				`INSERT INTO routines (exercise_id, day_of_week, order_index) VALUES (?, ?, ?)`,
 				exerciseID, dayName, i,
 			); err != nil {
-				http.Error(w, fmt.Sprintf("Failed to add '%s' to %s: %v", ex.Name, dayName, err), http.StatusInternalServerError)
+				internalError(w, r, fmt.Sprintf("Failed to add '%s' to %s", ex.Name, dayName), err)
 				return
 			}
 		}
 	}
 
 	if err := tx.Commit(); err != nil {
-		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		internalError(w, r, "Failed to commit transaction", err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message":      "Plan applied successfully",
-		"days_updated": len(days),
+	h.Events.Publish(Event{Type: "plan.imported"})
+	writeJSON(w, http.StatusOK, PlanImportResponse{
+		Message:     "Plan applied successfully",
+		DaysUpdated: len(days),
 	})
 }
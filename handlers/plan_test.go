@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"train/db"
+)
+
+func newPlanHandler(t *testing.T) *PlanHandler {
+	t.Helper()
+	database, err := db.OpenForTesting()
+	if err != nil {
+		t.Fatalf("OpenForTesting: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	sets, reps := 3, 10
+	weight := 80.0
+	id, err := database.CreateExercise("Squat", "weight", "Legs-Push", &sets, &reps, &weight)
+	if err != nil {
+		t.Fatalf("CreateExercise: %v", err)
+	}
+	if _, err := database.CreateRoutine(int(id), "Monday", 0, nil); err != nil {
+		t.Fatalf("CreateRoutine: %v", err)
+	}
+	return &PlanHandler{DB: database, ImportEnabled: true}
+}
+
+func TestPlanExport_PlainTextByDefault(t *testing.T) {
+	h := newPlanHandler(t)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/plan", nil))
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("expected text/plain, got %q", ct)
+	}
+	if !strings.Contains(w.Body.String(), "1. Squat | weight | Legs-Push | 3x10 | 80kg") {
+		t.Errorf("unexpected plan text:\n%s", w.Body.String())
+	}
+}
+
+func TestPlanExport_JSONWhenPreferred(t *testing.T) {
+	h := newPlanHandler(t)
+	req := httptest.NewRequest(http.MethodGet, "/api/plan", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	var resp PlanResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("expected JSON body: %v", err)
+	}
+	if !strings.Contains(resp.Plan, "Squat") {
+		t.Errorf("plan should include the routine, got %q", resp.Plan)
+	}
+}
+
+func TestPlanImport_DisabledIsForbidden(t *testing.T) {
+	h := newPlanHandler(t)
+	h.ImportEnabled = false
+	req := httptest.NewRequest(http.MethodPost, "/api/plan", strings.NewReader(`{"plan":"# Monday\n1. Squat | weight | | 3x5"}`))
+	req.Header.Set("Accept", "text/plain")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("text/plain clients should get a plain error, got %q", ct)
+	}
+}
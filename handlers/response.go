@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Error codes let clients branch on the kind of failure without parsing
+// messages
+const (
+	CodeInvalidJSON      = "invalid_json"
+	CodeValidation       = "validation_failed"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeForbidden        = "forbidden"
+	CodeInternal         = "internal_error"
+)
+
+// APIError describes why a request failed
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Field names the offending request field for validation errors
+	Field string `json:"field,omitempty"`
+	// ID matches the request ID in the server log for internal errors
+	ID string `json:"id,omitempty"`
+}
+
+// ErrorResponse is the body of every non-2xx API response
+type ErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+// writeJSON sends v as the JSON response body with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError sends an error envelope. Clients that only accept text/plain
+// (such as curl against the plan export) get the bare message instead.
+func writeError(w http.ResponseWriter, r *http.Request, status int, apiErr APIError) {
+	if prefersText(r) {
+		w.Header().Set("X-Error-Code", apiErr.Code)
+		http.Error(w, apiErr.Message, status)
+		return
+	}
+	writeJSON(w, status, ErrorResponse{Error: apiErr})
+}
+
+// invalidJSON reports a request body that could not be decoded
+func invalidJSON(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusBadRequest, APIError{Code: CodeInvalidJSON, Message: "Invalid request body"})
+}
+
+// badRequest reports a request that is malformed as a whole
+func badRequest(w http.ResponseWriter, r *http.Request, message string) {
+	writeError(w, r, http.StatusBadRequest, APIError{Code: CodeValidation, Message: message})
+}
+
+// fieldError reports a validation failure on a single request field
+func fieldError(w http.ResponseWriter, r *http.Request, field, message string) {
+	writeError(w, r, http.StatusBadRequest, APIError{Code: CodeValidation, Message: message, Field: field})
+}
+
+// notFound reports a missing resource
+func notFound(w http.ResponseWriter, r *http.Request, message string) {
+	writeError(w, r, http.StatusNotFound, APIError{Code: CodeNotFound, Message: message})
+}
+
+// conflict reports a request that clashes with existing data
+func conflict(w http.ResponseWriter, r *http.Request, field, message string) {
+	writeError(w, r, http.StatusConflict, APIError{Code: CodeConflict, Message: message, Field: field})
+}
+
+// methodNotAllowed reports an unsupported HTTP method
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusMethodNotAllowed, APIError{Code: CodeMethodNotAllowed, Message: "Method not allowed"})
+}
+
+// invalidID reports a path ID that is not an integer
+func invalidID(w http.ResponseWriter, r *http.Request, what string) {
+	writeError(w, r, http.StatusBadRequest, APIError{Code: CodeValidation, Message: "Invalid " + what + " ID", Field: "id"})
+}
+
+// prefersText reports whether the client ranks text/plain above JSON in its
+// Accept header. Browsers' fetch sends */* and therefore gets JSON.
+func prefersText(r *http.Request) bool {
+	return acceptQuality(r, "text/plain") > acceptQuality(r, "application/json")
+}
+
+// acceptQuality returns the q-value the request's Accept header gives
+// mediaType, honouring type/* and */* wildcards. A missing header accepts
+// everything equally.
+func acceptQuality(r *http.Request, mediaType string) float64 {
+	header := r.Header.Get("Accept")
+	if header == "" {
+		return 1
+	}
+	typ, _, _ := strings.Cut(mediaType, "/")
+
+	best, bestSpecificity := 0.0, -1
+	for _, part := range strings.Split(header, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		specificity := -1
+		switch {
+		case mt == mediaType:
+			specificity = 2
+		case mt == typ+"/*":
+			specificity = 1
+		case mt == "*/*":
+			specificity = 0
+		}
+		if specificity <= bestSpecificity {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		best, bestSpecificity = q, specificity
+	}
+	return best
+}
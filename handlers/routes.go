@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"train/db"
+)
+
+const (
+	// APIPrefix is the mount point of the current API version
+	APIPrefix = "/api/v1"
+	// legacyAPIPrefix serves the same routes for PWAs cached before /api/v1
+	// existed. Responses carry a Deprecation header pointing at the new path.
+	legacyAPIPrefix = "/api"
+)
+
+// Options carries deployment settings the API handlers depend on
+type Options struct {
+	PlanImport bool
+	// Events carries change notifications to /events streams; Register
+	// creates one when nil
+	Events *Hub
+}
+
+// Register mounts every API route on mux under /api/v1, with deprecated
+// aliases under /api
+func Register(mux *http.ServeMux, database *db.DB, opts Options) {
+	rt := newRouter(mux)
+	events := opts.Events
+	if events == nil {
+		events = NewHub()
+	}
+	(&ExercisesHandler{DB: database, Events: events}).routes(rt)
+	(&CategoriesHandler{DB: database}).routes(rt)
+	(&ExerciseTypesHandler{DB: database}).routes(rt)
+	(&RoutinesHandler{DB: database, Events: events}).routes(rt)
+	(&HistoryHandler{DB: database, Events: events}).routes(rt)
+	(&DaysHandler{DB: database, Events: events}).routes(rt)
+	(&MetricsHandler{DB: database, Events: events}).routes(rt)
+	(&MetricEntriesHandler{DB: database, Events: events}).routes(rt)
+	(&PlanHandler{DB: database, ImportEnabled: opts.PlanImport, Events: events}).routes(rt)
+	(&MusclesHandler{DB: database}).routes(rt)
+	(&StatsHandler{DB: database}).routes(rt)
+	(&SyncHandler{DB: database, Events: events}).routes(rt)
+	(&SharedSessionsHandler{DB: database, Events: events}).routes(rt)
+	(&SettingsHandler{DB: database, Events: events}).routes(rt)
+	(&GoalsHandler{DB: database, Events: events}).routes(rt)
+	(&EventsHandler{Hub: events}).routes(rt)
+	OpenAPIHandler{}.routes(rt)
+	rt.finish()
+
+	// Unknown API paths get the JSON envelope rather than the static file
+	// server's 404 page
+	unknown := func(w http.ResponseWriter, r *http.Request) { notFound(w, r, "No such API endpoint") }
+	mux.HandleFunc(APIPrefix+"/", unknown)
+	mux.HandleFunc(legacyAPIPrefix+"/", unknown)
+}
+
+// router registers each route under both API prefixes and answers wrong
+// methods with the error envelope instead of ServeMux's plain-text 405
+type router struct {
+	mux   *http.ServeMux
+	paths map[string]*routePath
+}
+
+// routePath collects the methods registered for one path shape
+type routePath struct {
+	pattern string
+	methods []string
+}
+
+// wildcard matches a {name} path segment
+var wildcard = regexp.MustCompile(`\{[^}]*\}`)
+
+func newRouter(mux *http.ServeMux) *router {
+	return &router{mux: mux, paths: map[string]*routePath{}}
+}
+
+// handle mounts h for method and path, e.g. ("GET", "/history/{exerciseID}/pr")
+func (rt *router) handle(method, path string, h http.HandlerFunc) {
+	rt.mux.Handle(method+" "+APIPrefix+path, h)
+	rt.mux.Handle(method+" "+legacyAPIPrefix+path, deprecated(h))
+
+	// /history/{exerciseID} and /history/{id} are the same path to the mux
+	key := wildcard.ReplaceAllString(path, "{}")
+	if rt.paths[key] == nil {
+		rt.paths[key] = &routePath{pattern: path}
+	}
+	rt.paths[key].methods = append(rt.paths[key].methods, method)
+}
+
+// routeMethods are the methods finish answers with 405 on paths that don't
+// support them
+var routeMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// finish registers a 405 handler for every other method on each path.
+// Method-less fallbacks would conflict with routes like GET /routines/{day}
+// vs /routines/reorder, so each method is registered explicitly.
+func (rt *router) finish() {
+	for _, p := range rt.paths {
+		allowed := append([]string(nil), p.methods...)
+		if slices.Contains(allowed, http.MethodGet) {
+			allowed = append(allowed, http.MethodHead)
+		}
+		slices.Sort(allowed)
+		allow := strings.Join(allowed, ", ")
+
+		fallback := func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Allow", allow)
+			methodNotAllowed(w, r)
+		}
+		for _, method := range routeMethods {
+			if !slices.Contains(p.methods, method) {
+				rt.mux.HandleFunc(method+" "+APIPrefix+p.pattern, fallback)
+				rt.mux.Handle(method+" "+legacyAPIPrefix+p.pattern, deprecated(fallback))
+			}
+		}
+	}
+}
+
+// deprecated marks responses from the legacy /api prefix and links to the
+// /api/v1 equivalent
+func deprecated(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		successor := APIPrefix + strings.TrimPrefix(r.URL.Path, legacyAPIPrefix)
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+		h(w, r)
+	}
+}
+
+// serveRoutes serves a single handler's routes, so each handler still works
+// as an http.Handler on its own
+func serveRoutes(w http.ResponseWriter, r *http.Request, routes func(*router)) {
+	mux := http.NewServeMux()
+	rt := newRouter(mux)
+	routes(rt)
+	rt.finish()
+	mux.ServeHTTP(w, r)
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRoutes_LegacyAliasIsDeprecated(t *testing.T) {
+	srv := newAPIServer(t)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/history/1/pr", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("legacy path should still work, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Deprecation") == "" {
+		t.Error("legacy path should carry a Deprecation header")
+	}
+	if link := w.Header().Get("Link"); !strings.Contains(link, "</api/v1/history/1/pr>") {
+		t.Errorf("Link should point at the v1 path, got %q", link)
+	}
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/history/1/pr", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Deprecation") != "" {
+		t.Errorf("v1 path: status %d, Deprecation %q", w.Code, w.Header().Get("Deprecation"))
+	}
+}
+
+func TestRoutes_WrongMethodUsesEnvelope(t *testing.T) {
+	srv := newAPIServer(t)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/api/v1/exercises/1", nil))
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "DELETE, GET, HEAD, PUT" {
+		t.Errorf("unexpected Allow header %q", allow)
+	}
+	var resp ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Error.Code != CodeMethodNotAllowed {
+		t.Errorf("expected method_not_allowed envelope, got %q", w.Body.String())
+	}
+}
+
+func TestRoutes_LiteralSegmentsWinOverWildcards(t *testing.T) {
+	srv := newAPIServer(t)
+	for _, tc := range []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/api/v1/metrics/dashboard", http.StatusOK},
+		{http.MethodGet, "/api/v1/routines/reorder", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/api/v1/routines/Monday", http.StatusOK},
+		{http.MethodPut, "/api/v1/metrics/dashboard", http.StatusMethodNotAllowed},
+	} {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		if w.Code != tc.want {
+			t.Errorf("%s %s: expected %d, got %d", tc.method, tc.path, tc.want, w.Code)
+		}
+	}
+}
+
+func TestRoutes_UnknownEndpointIsJSON404(t *testing.T) {
+	srv := newAPIServer(t)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/nope", nil))
+
+	var resp ErrorResponse
+	if w.Code != http.StatusNotFound || json.NewDecoder(w.Body).Decode(&resp) != nil || resp.Error.Code != CodeNotFound {
+		t.Errorf("expected not_found envelope, got %d %q", w.Code, w.Body.String())
+	}
+}
@@ -3,7 +3,6 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -14,52 +13,31 @@ import (
 // RoutinesHandler handles routine-related operations
 type RoutinesHandler struct {
 	DB *db.DB
+	// Events is notified after each change; nil disables notifications
+	Events *Hub
 }
 
-// ServeHTTP handles routine-related requests
-func (h *RoutinesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	path := strings.TrimPrefix(r.URL.Path, "/api/routines")
-	path = strings.TrimPrefix(path, "/")
-
-	// Handle reorder endpoint
-	if r.Method == http.MethodPost && path == "reorder" {
-		h.reorderRoutines(w, r)
-		return
-	}
+// routes mounts the routine endpoints
+func (h *RoutinesHandler) routes(rt *router) {
+	rt.handle(http.MethodGet, "/routines/{day}", h.getRoutinesByDay)
+	rt.handle(http.MethodPost, "/routines", h.createRoutine)
+	rt.handle(http.MethodPost, "/routines/reorder", h.reorderRoutines)
+	rt.handle(http.MethodPut, "/routines/{id}", h.updateRoutine)
+	rt.handle(http.MethodDelete, "/routines/{id}", h.deleteRoutine)
+}
 
-	switch r.Method {
-	case http.MethodGet:
-		// GET /api/routines/:day
-		h.getRoutinesByDay(w, r, path)
-	case http.MethodPost:
-		h.createRoutine(w, r)
-	case http.MethodPut:
-		h.updateRoutine(w, r, path)
-	case http.MethodDelete:
-		h.deleteRoutine(w, r, path)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
+// ServeHTTP serves the routine endpoints on their own
+func (h *RoutinesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveRoutes(w, r, h.routes)
 }
 
 // getRoutinesByDay returns all exercises for a specific day
-func (h *RoutinesHandler) getRoutinesByDay(w http.ResponseWriter, r *http.Request, day string) {
-	if day == "" {
-		http.Error(w, "Day of week is required", http.StatusBadRequest)
-		return
-	}
+func (h *RoutinesHandler) getRoutinesByDay(w http.ResponseWriter, r *http.Request) {
+	day := r.PathValue("day")
 
 	// Validate day
-	validDays := []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"}
-	valid := false
-	for _, d := range validDays {
-		if day == d {
-			valid = true
-			break
-		}
-	}
-	if !valid {
-		http.Error(w, "Invalid day of week", http.StatusBadRequest)
+	if !isWeekDay(day) {
+		fieldError(w, r, "day", "Invalid day of week")
 		return
 	}
 
@@ -67,7 +45,7 @@ func (h *RoutinesHandler) getRoutinesByDay(w http.ResponseWriter, r *http.Reques
 	var title string
 	err := h.DB.QueryRow("SELECT title FROM day_titles WHERE day_of_week = ?", day).Scan(&title)
 	if err != nil && err != sql.ErrNoRows {
-		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		internalError(w, r, "Database error", err)
 		return
 	}
 
@@ -85,8 +63,8 @@ func (h *RoutinesHandler) getRoutinesByDay(w http.ResponseWriter, r *http.Reques
 			e.target_reps,
 			e.target_weight,
 			(SELECT MAX(session_date) FROM history WHERE exercise_id = e.id) as last_done,
-			CASE
-				WHEN e.type IN ('bodyweight', 'timed_hold') THEN
+			CASE t.progression
+				WHEN 'completion' THEN
 					(SELECT COUNT(*) FROM history
 					 WHERE exercise_id = e.id AND completed = 1
 					   AND session_date >= COALESCE(
@@ -95,7 +73,7 @@ func (h *RoutinesHandler) getRoutinesByDay(w http.ResponseWriter, r *http.Reques
 						 '0000-01-01'
 					   )
 					)
-				ELSE
+				WHEN 'weight' THEN
 					(SELECT COUNT(*) FROM history
 					 WHERE exercise_id = e.id AND completed = 1 AND weight = e.target_weight
 					   AND session_date >= COALESCE(
@@ -104,82 +82,46 @@ func (h *RoutinesHandler) getRoutinesByDay(w http.ResponseWriter, r *http.Reques
 						 '0000-01-01'
 					   )
 					)
+				ELSE 0
 			END as consecutive_successes
 		FROM routines r
 		JOIN exercises e ON r.exercise_id = e.id
+		JOIN exercise_types t ON t.name = e.type
 		WHERE r.day_of_week = ?
 		ORDER BY r.order_index
 	`
 
 	rows, err := h.DB.Query(query, day)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		internalError(w, r, "Database error", err)
 		return
 	}
 	defer rows.Close()
 
-	exercises := []map[string]interface{}{}
+	exercises := []RoutineExercise{}
 	for rows.Next() {
-		var routineID, exerciseID, orderIndex int
-		var targetSets, targetReps *int
-		var targetWeight *float64
-		var notes, lastDone *string
-		var name, exerciseType string
-		var category *string
-		var consecutiveSuccesses int
-
+		var ex RoutineExercise
 		err := rows.Scan(
-			&routineID, &exerciseID, &orderIndex,
-			&notes,
-			&name, &exerciseType, &category,
-			&targetSets, &targetReps, &targetWeight,
-			&lastDone, &consecutiveSuccesses,
+			&ex.RoutineID, &ex.ExerciseID, &ex.OrderIndex,
+			&ex.Notes,
+			&ex.Name, &ex.Type, &ex.Category,
+			&ex.TargetSets, &ex.TargetReps, &ex.TargetWeight,
+			&ex.LastDone, &ex.ConsecutiveSuccesses,
 		)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Scan error: %v", err), http.StatusInternalServerError)
+			internalError(w, r, "Scan error", err)
 			return
 		}
+		ex.ReadyToProgress = ex.ConsecutiveSuccesses >= 3
 
-		exercise := map[string]interface{}{
-			"routine_id":            routineID,
-			"exercise_id":           exerciseID,
-			"order_index":           orderIndex,
-			"name":                  name,
-			"type":                  exerciseType,
-			"consecutive_successes": consecutiveSuccesses,
-			"ready_to_progress":     consecutiveSuccesses >= 3,
-		}
-
-		if category != nil {
-			exercise["category"] = *category
-		}
-		if targetSets != nil {
-			exercise["target_sets"] = *targetSets
-		}
-		if targetReps != nil {
-			exercise["target_reps"] = *targetReps
-		}
-		if targetWeight != nil {
-			exercise["target_weight"] = *targetWeight
-		}
-		if notes != nil {
-			exercise["notes"] = *notes
-		}
-		if lastDone != nil {
-			exercise["last_done"] = *lastDone
-		}
-
-		exercises = append(exercises, exercise)
+		exercises = append(exercises, ex)
 	}
 
-	response := map[string]interface{}{
-		"day":       day,
-		"title":     title,
-		"exercises": exercises,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, http.StatusOK, RoutineDayResponse{
+		Day:       day,
+		Title:     title,
+		Exercises: exercises,
+	})
 }
 
 // createRoutine adds an exercise to a day
@@ -192,22 +134,40 @@ func (h *RoutinesHandler) createRoutine(w http.ResponseWriter, r *http.Request)
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		invalidJSON(w, r)
 		return
 	}
 
 	// Validate required fields
-	if req.ExerciseID == 0 || req.DayOfWeek == "" {
-		http.Error(w, "exercise_id and day_of_week are required", http.StatusBadRequest)
+	if req.ExerciseID == 0 {
+		fieldError(w, r, "exercise_id", "exercise_id is required")
+		return
+	}
+	if req.DayOfWeek == "" {
+		fieldError(w, r, "day_of_week", "day_of_week is required")
+		return
+	}
+	if !isWeekDay(req.DayOfWeek) {
+		fieldError(w, r, "day_of_week", "Invalid day of week")
+		return
+	}
+
+	exercise, err := h.DB.GetExerciseByID(req.ExerciseID)
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	if exercise == nil {
+		fieldError(w, r, "exercise_id", "Exercise not found")
 		return
 	}
 
 	// Auto-calculate order_index to avoid conflicts
 	// Get the max order_index for this day and add 1
 	var maxOrder int
-	err := h.DB.QueryRow("SELECT COALESCE(MAX(order_index), -1) FROM routines WHERE day_of_week = ?", req.DayOfWeek).Scan(&maxOrder)
+	err = h.DB.QueryRow("SELECT COALESCE(MAX(order_index), -1) FROM routines WHERE day_of_week = ?", req.DayOfWeek).Scan(&maxOrder)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get max order_index: %v", err), http.StatusInternalServerError)
+		internalError(w, r, "Failed to get max order_index", err)
 		return
 	}
 	orderIndex := maxOrder + 1
@@ -219,25 +179,19 @@ func (h *RoutinesHandler) createRoutine(w http.ResponseWriter, r *http.Request)
 		req.Notes,
 	)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to create routine: %v", err), http.StatusInternalServerError)
+		internalError(w, r, "Failed to create routine", err)
 		return
 	}
 
-	response := map[string]interface{}{
-		"id":      id,
-		"message": "Routine created successfully",
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(response)
+	h.Events.Publish(Event{Type: "routine.created", ID: id, ExerciseID: req.ExerciseID, Day: req.DayOfWeek})
+	writeJSON(w, http.StatusCreated, CreatedResponse{ID: id, Message: "Routine created successfully"})
 }
 
 // updateRoutine updates a routine entry
-func (h *RoutinesHandler) updateRoutine(w http.ResponseWriter, r *http.Request, idStr string) {
-	id, err := strconv.Atoi(idStr)
+func (h *RoutinesHandler) updateRoutine(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		http.Error(w, "Invalid routine ID", http.StatusBadRequest)
+		invalidID(w, r, "routine")
 		return
 	}
 
@@ -247,7 +201,7 @@ func (h *RoutinesHandler) updateRoutine(w http.ResponseWriter, r *http.Request,
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		invalidJSON(w, r)
 		return
 	}
 
@@ -271,51 +225,43 @@ func (h *RoutinesHandler) updateRoutine(w http.ResponseWriter, r *http.Request,
 
 		result, err := h.DB.Exec(query, args...)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to update routine: %v", err), http.StatusInternalServerError)
+			internalError(w, r, "Failed to update routine", err)
 			return
 		}
 
 		rowsAffected, _ := result.RowsAffected()
 		if rowsAffected == 0 {
-			http.Error(w, "Routine not found", http.StatusNotFound)
+			notFound(w, r, "Routine not found")
 			return
 		}
 	}
 
-	response := map[string]interface{}{
-		"message": "Routine updated successfully",
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	h.Events.Publish(Event{Type: "routine.updated", ID: int64(id)})
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Routine updated successfully"})
 }
 
 // deleteRoutine removes an exercise from a day
-func (h *RoutinesHandler) deleteRoutine(w http.ResponseWriter, r *http.Request, idStr string) {
-	id, err := strconv.Atoi(idStr)
+func (h *RoutinesHandler) deleteRoutine(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		http.Error(w, "Invalid routine ID", http.StatusBadRequest)
+		invalidID(w, r, "routine")
 		return
 	}
 
 	result, err := h.DB.Exec("DELETE FROM routines WHERE id = ?", id)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to delete routine: %v", err), http.StatusInternalServerError)
+		internalError(w, r, "Failed to delete routine", err)
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		http.Error(w, "Routine not found", http.StatusNotFound)
+		notFound(w, r, "Routine not found")
 		return
 	}
 
-	response := map[string]interface{}{
-		"message": "Routine deleted successfully",
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	h.Events.Publish(Event{Type: "routine.deleted", ID: int64(id)})
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Routine deleted successfully"})
 }
 
 // reorderRoutines updates the order of exercises for a day
@@ -326,19 +272,19 @@ func (h *RoutinesHandler) reorderRoutines(w http.ResponseWriter, r *http.Request
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		invalidJSON(w, r)
 		return
 	}
 
 	if req.DayOfWeek == "" || len(req.RoutineIDs) == 0 {
-		http.Error(w, "day_of_week and routine_ids are required", http.StatusBadRequest)
+		badRequest(w, r, "day_of_week and routine_ids are required")
 		return
 	}
 
 	// Begin transaction
 	tx, err := h.DB.Begin()
 	if err != nil {
-		http.Error(w, "Failed to begin transaction", http.StatusInternalServerError)
+		internalError(w, r, "Failed to begin transaction", err)
 		return
 	}
 	defer tx.Rollback()
@@ -350,7 +296,7 @@ func (h *RoutinesHandler) reorderRoutines(w http.ResponseWriter, r *http.Request
 		_, err := tx.Exec("UPDATE routines SET order_index = ? WHERE id = ? AND day_of_week = ?",
 			-(i + 1), routineID, req.DayOfWeek)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to reorder routines: %v", err), http.StatusInternalServerError)
+			internalError(w, r, "Failed to reorder routines", err)
 			return
 		}
 	}
@@ -358,21 +304,17 @@ func (h *RoutinesHandler) reorderRoutines(w http.ResponseWriter, r *http.Request
 		_, err := tx.Exec("UPDATE routines SET order_index = ? WHERE id = ? AND day_of_week = ?",
 			i, routineID, req.DayOfWeek)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to reorder routines: %v", err), http.StatusInternalServerError)
+			internalError(w, r, "Failed to reorder routines", err)
 			return
 		}
 	}
 
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
-		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		internalError(w, r, "Failed to commit transaction", err)
 		return
 	}
 
-	response := map[string]interface{}{
-		"message": "Routines reordered successfully",
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	h.Events.Publish(Event{Type: "routine.reordered", Day: req.DayOfWeek})
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Routines reordered successfully"})
 }
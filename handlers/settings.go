@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"train/db"
+)
+
+// SettingsHandler handles the profile settings the stats are computed with
+type SettingsHandler struct {
+	DB *db.DB
+	// Events is notified after each change; nil disables notifications
+	Events *Hub
+}
+
+// sexes are the values allowed for the sex setting
+var sexes = []string{"male", "female"}
+
+// routes mounts the settings endpoints
+func (h *SettingsHandler) routes(rt *router) {
+	rt.handle(http.MethodGet, "/settings", h.getSettings)
+	rt.handle(http.MethodPut, "/settings", h.updateSettings)
+}
+
+// ServeHTTP serves the settings endpoints on their own
+func (h *SettingsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveRoutes(w, r, h.routes)
+}
+
+// loadSettings reads the stored settings into their API shape
+func loadSettings(database *db.DB) (Settings, error) {
+	stored, err := database.GetSettings()
+	if err != nil {
+		return Settings{}, err
+	}
+	var s Settings
+	if sex, ok := stored[db.SettingSex]; ok {
+		s.Sex = &sex
+	}
+	if height, err := strconv.ParseFloat(stored[db.SettingHeight], 64); err == nil {
+		s.HeightCm = &height
+	}
+	for _, lift := range powerLifts {
+		if id, err := strconv.Atoi(stored[lift.setting]); err == nil {
+			*lift.field(&s.Lifts) = &id
+		}
+	}
+	return s, nil
+}
+
+// getSettings returns the profile settings
+func (h *SettingsHandler) getSettings(w http.ResponseWriter, r *http.Request) {
+	s, err := loadSettings(h.DB)
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+// updateSettings changes the supplied settings. An empty sex, a height of 0
+// or a lift exercise ID of 0 unsets that setting.
+func (h *SettingsHandler) updateSettings(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Sex      *string       `json:"sex"`
+		HeightCm *float64      `json:"height_cm"`
+		Lifts    *LiftSettings `json:"lifts"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		invalidJSON(w, r)
+		return
+	}
+
+	changes := map[string]string{}
+	if req.Sex != nil {
+		if *req.Sex != "" && !slices.Contains(sexes, *req.Sex) {
+			fieldError(w, r, "sex", "sex must be male or female")
+			return
+		}
+		changes[db.SettingSex] = *req.Sex
+	}
+	if req.HeightCm != nil {
+		switch {
+		case *req.HeightCm == 0:
+			changes[db.SettingHeight] = ""
+		case math.IsNaN(*req.HeightCm) || *req.HeightCm < 0 || *req.HeightCm > 300:
+			fieldError(w, r, "height_cm", "height_cm must be between 0 and 300")
+			return
+		default:
+			changes[db.SettingHeight] = strconv.FormatFloat(*req.HeightCm, 'f', -1, 64)
+		}
+	}
+	if req.Lifts != nil {
+		for _, lift := range powerLifts {
+			id := *lift.field(req.Lifts)
+			if id == nil {
+				continue
+			}
+			if *id == 0 {
+				changes[lift.setting] = ""
+				continue
+			}
+			ex, err := h.DB.GetExerciseByID(*id)
+			if err != nil {
+				internalError(w, r, "Database error", err)
+				return
+			}
+			if ex == nil {
+				fieldError(w, r, "lifts."+lift.name, "Exercise not found")
+				return
+			}
+			changes[lift.setting] = strconv.Itoa(*id)
+		}
+	}
+
+	for key, value := range changes {
+		if err := h.DB.SetSetting(key, value); err != nil {
+			internalError(w, r, "Failed to update settings", err)
+			return
+		}
+	}
+	// The height changes computed metrics, which goals can track
+	if _, ok := changes[db.SettingHeight]; ok {
+		checkGoals(r.Context(), h.DB, h.Events, goalScope{computed: true})
+	}
+	s, err := loadSettings(h.DB)
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+
+	h.Events.Publish(Event{Type: "settings.updated"})
+	writeJSON(w, http.StatusOK, s)
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func serveSettings(h *SettingsHandler, method, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/api/v1/settings", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestSettings_UpdateAndClear(t *testing.T) {
+	history, squat := newTestHandler(t, "weight")
+	h := &SettingsHandler{DB: history.DB}
+
+	w := serveSettings(h, http.MethodPut, `{"sex": "female", "lifts": {"squat": `+strconv.Itoa(squat)+`}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	// Omitted settings keep their value; empty and 0 unset them
+	serveSettings(h, http.MethodPut, `{"lifts": {"bench": 0}}`)
+	var s Settings
+	json.NewDecoder(serveSettings(h, http.MethodGet, "").Body).Decode(&s)
+	if s.Sex == nil || *s.Sex != "female" || s.Lifts.Squat == nil || *s.Lifts.Squat != squat || s.Lifts.Bench != nil {
+		t.Fatalf("unexpected settings: %+v", s)
+	}
+	serveSettings(h, http.MethodPut, `{"sex": "", "lifts": {"squat": 0}}`)
+	json.NewDecoder(serveSettings(h, http.MethodGet, "").Body).Decode(&s)
+	if s.Sex != nil || s.Lifts.Squat != nil {
+		t.Errorf("expected cleared settings, got %+v", s)
+	}
+
+	for name, tc := range map[string]struct{ body, field string }{
+		"bad sex":          {`{"sex": "x"}`, "sex"},
+		"bad height":       {`{"height_cm": 400}`, "height_cm"},
+		"unknown exercise": {`{"lifts": {"deadlift": 999}}`, "lifts.deadlift"},
+	} {
+		w := serveSettings(h, http.MethodPut, tc.body)
+		if w.Code != http.StatusBadRequest || !bytes.Contains(w.Body.Bytes(), []byte(`"field":"`+tc.field+`"`)) {
+			t.Errorf("%s: expected 400 on %s, got %d: %s", name, tc.field, w.Code, w.Body.String())
+		}
+	}
+}
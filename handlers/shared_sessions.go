@@ -0,0 +1,586 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"train/db"
+)
+
+// SharedSessionsHandler runs workouts shared by training partners. The host
+// starts a session on a day's routine, partners join with its code, and
+// every completed set is visible to all of them (and announced on /events)
+// until the host finishes it.
+type SharedSessionsHandler struct {
+	DB *db.DB
+	// Events is notified after each change; nil disables notifications
+	Events *Hub
+}
+
+// routes mounts the shared session endpoints
+func (h *SharedSessionsHandler) routes(rt *router) {
+	rt.handle(http.MethodPost, "/shared-sessions", h.createSession)
+	rt.handle(http.MethodGet, "/shared-sessions/{code}", h.getSession)
+	rt.handle(http.MethodPost, "/shared-sessions/{code}/join", h.joinSession)
+	rt.handle(http.MethodPut, "/shared-sessions/{code}/sets", h.recordSet)
+	rt.handle(http.MethodPost, "/shared-sessions/{code}/finish", h.finishSession)
+}
+
+// ServeHTTP serves the shared session endpoints on their own
+func (h *SharedSessionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveRoutes(w, r, h.routes)
+}
+
+// sessionCodeAlphabet leaves out characters that are easy to misread (0/O,
+// 1/I). Its 32 letters divide 256, so every code is equally likely.
+const sessionCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+func newSessionCode() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	for i := range b {
+		b[i] = sessionCodeAlphabet[int(b[i])%len(sessionCodeAlphabet)]
+	}
+	return string(b)
+}
+
+func newParticipantToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// createSession starts a session on a day's routine with the caller as host
+func (h *SharedSessionsHandler) createSession(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		DayOfWeek   string `json:"day_of_week"`
+		SessionDate string `json:"session_date"`
+		Name        string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		invalidJSON(w, r)
+		return
+	}
+	if !isWeekDay(req.DayOfWeek) {
+		fieldError(w, r, "day_of_week", "Invalid day of week")
+		return
+	}
+	if req.SessionDate == "" {
+		req.SessionDate = time.Now().Format(dateLayout)
+	}
+	if !isDate(req.SessionDate) {
+		fieldError(w, r, "session_date", "session_date must be a YYYY-MM-DD date")
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		fieldError(w, r, "name", "name is required")
+		return
+	}
+
+	var planned int
+	if err := h.DB.QueryRow("SELECT COUNT(*) FROM routines WHERE day_of_week = ?", req.DayOfWeek).Scan(&planned); err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	if planned == 0 {
+		fieldError(w, r, "day_of_week", "No exercises are planned for this day")
+		return
+	}
+
+	// Codes are short, so retry the rare collision with an earlier session
+	var code string
+	var sessionID int64
+	for range 5 {
+		code = newSessionCode()
+		result, err := h.DB.Exec("INSERT INTO shared_sessions (code, day_of_week, session_date) VALUES (?, ?, ?)",
+			code, req.DayOfWeek, req.SessionDate)
+		if err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			continue
+		}
+		if err != nil {
+			internalError(w, r, "Failed to create shared session", err)
+			return
+		}
+		sessionID, _ = result.LastInsertId()
+		break
+	}
+	if sessionID == 0 {
+		internalError(w, r, "Failed to create shared session", fmt.Errorf("no free session code"))
+		return
+	}
+
+	participantID, token, err := h.addParticipant(sessionID, name, true)
+	if err != nil {
+		internalError(w, r, "Failed to create shared session", err)
+		return
+	}
+	h.respondJoined(w, r, code, participantID, token, "shared_session.created")
+}
+
+// joinSession adds a partner to a running session
+func (h *SharedSessionsHandler) joinSession(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		invalidJSON(w, r)
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		fieldError(w, r, "name", "name is required")
+		return
+	}
+
+	session, ok := h.runningSession(w, r)
+	if !ok {
+		return
+	}
+	participantID, token, err := h.addParticipant(int64(session.ID), name, false)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			conflict(w, r, "name", "Someone in this session already uses this name")
+			return
+		}
+		internalError(w, r, "Failed to join shared session", err)
+		return
+	}
+	h.respondJoined(w, r, session.Code, participantID, token, "shared_session.joined")
+}
+
+func (h *SharedSessionsHandler) addParticipant(sessionID int64, name string, isHost bool) (int64, string, error) {
+	token := newParticipantToken()
+	result, err := h.DB.Exec("INSERT INTO shared_session_participants (session_id, name, token, is_host) VALUES (?, ?, ?, ?)",
+		sessionID, name, token, isHost)
+	if err != nil {
+		return 0, "", err
+	}
+	id, err := result.LastInsertId()
+	return id, token, err
+}
+
+// respondJoined announces a new participant and sends them the session
+// with their token
+func (h *SharedSessionsHandler) respondJoined(w http.ResponseWriter, r *http.Request, code string, participantID int64, token, event string) {
+	session, err := h.loadSession(code)
+	if err != nil || session == nil {
+		internalError(w, r, "Failed to load shared session", err)
+		return
+	}
+	h.Events.Publish(Event{Type: event, ID: int64(session.ID)})
+	writeJSON(w, http.StatusCreated, SharedSessionJoinResponse{ParticipantID: participantID, Token: token, Session: *session})
+}
+
+// getSession returns the session with everyone's sets, and their history
+// entries once it is finished
+func (h *SharedSessionsHandler) getSession(w http.ResponseWriter, r *http.Request) {
+	session, err := h.loadSession(strings.ToUpper(r.PathValue("code")))
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	if session == nil {
+		notFound(w, r, "Shared session not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, session)
+}
+
+// recordSet records or corrects one of the caller's sets and returns the
+// session so the device shows everyone's progress
+func (h *SharedSessionsHandler) recordSet(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token      string   `json:"token"`
+		ExerciseID int      `json:"exercise_id"`
+		SetNumber  int      `json:"set_number"`
+		Reps       int      `json:"reps"`
+		Weight     *float64 `json:"weight"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		invalidJSON(w, r)
+		return
+	}
+	if req.Token == "" {
+		fieldError(w, r, "token", "token is required")
+		return
+	}
+	if req.ExerciseID == 0 {
+		fieldError(w, r, "exercise_id", "exercise_id is required")
+		return
+	}
+	if req.SetNumber < 1 {
+		fieldError(w, r, "set_number", "set_number must be at least 1")
+		return
+	}
+	if req.Reps < 0 {
+		fieldError(w, r, "reps", "reps cannot be negative")
+		return
+	}
+	if req.Weight != nil && *req.Weight < 0 {
+		fieldError(w, r, "weight", "weight cannot be negative")
+		return
+	}
+
+	session, ok := h.runningSession(w, r)
+	if !ok {
+		return
+	}
+	participantID, ok := h.participant(w, r, session, req.Token)
+	if !ok {
+		return
+	}
+	var exercise *SharedExercise
+	for i := range session.Exercises {
+		if session.Exercises[i].ExerciseID == req.ExerciseID {
+			exercise = &session.Exercises[i]
+		}
+	}
+	if exercise == nil {
+		fieldError(w, r, "exercise_id", "Exercise is not on this session's routine")
+		return
+	}
+	exType, err := h.DB.GetExerciseType(exercise.Type)
+	if err != nil || exType == nil {
+		internalError(w, r, "Failed to load exercise type", err)
+		return
+	}
+	if exType.SetUnit == "none" {
+		fieldError(w, r, "exercise_id", "Exercises without sets can't be recorded in a shared session")
+		return
+	}
+
+	_, err = h.DB.Exec(`
+		INSERT INTO shared_session_sets (participant_id, exercise_id, set_number, reps, weight)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (participant_id, exercise_id, set_number)
+		DO UPDATE SET reps = excluded.reps, weight = excluded.weight, completed_at = CURRENT_TIMESTAMP`,
+		participantID, req.ExerciseID, req.SetNumber, req.Reps, req.Weight)
+	if err != nil {
+		internalError(w, r, "Failed to record set", err)
+		return
+	}
+
+	session, err = h.loadSession(session.Code)
+	if err != nil || session == nil {
+		internalError(w, r, "Failed to load shared session", err)
+		return
+	}
+	h.Events.Publish(Event{Type: "shared_session.updated", ID: int64(session.ID), ExerciseID: req.ExerciseID})
+	writeJSON(w, http.StatusOK, session)
+}
+
+// finishSession ends the session, logs the host's results to history and
+// returns everyone's entries. Only the host may finish.
+func (h *SharedSessionsHandler) finishSession(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		invalidJSON(w, r)
+		return
+	}
+	if req.Token == "" {
+		fieldError(w, r, "token", "token is required")
+		return
+	}
+
+	session, ok := h.runningSession(w, r)
+	if !ok {
+		return
+	}
+	participantID, ok := h.participant(w, r, session, req.Token)
+	if !ok {
+		return
+	}
+	var host *SharedParticipant
+	for i := range session.Participants {
+		if session.Participants[i].ID == participantID && session.Participants[i].IsHost {
+			host = &session.Participants[i]
+		}
+	}
+	if host == nil {
+		fieldError(w, r, "token", "Only the host can finish the session")
+		return
+	}
+
+	entries, err := h.entries(session, *host)
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	// Log before marking the session finished, so a failed finish can be
+	// retried; the entries' client_ids keep a retry from logging twice
+	history := &HistoryHandler{DB: h.DB}
+	created := []Event{}
+	for _, e := range entries {
+		row := historyRow(e.ExerciseID, e.SessionDate, e.Weight, e.SetsCompleted, e.Completed, e.Volume, nil, db.Cardio{})
+		if _, found, err := history.upsertSession(e.ClientID, row); err != nil {
+			internalError(w, r, "Failed to log shared session", err)
+			return
+		} else if found {
+			continue
+		}
+		exType, err := h.exerciseType(session, e.ExerciseID)
+		if err != nil {
+			internalError(w, r, "Failed to load exercise type", err)
+			return
+		}
+		isPR, err := history.checkPR(exType, e.ExerciseID, e.SessionDate, e.Weight, e.Volume, e.SetsCompleted)
+		if err != nil {
+			internalError(w, r, "Failed to update PR flags", err)
+			return
+		}
+		id, err := h.DB.CreateHistoryWithClientID(&e.ClientID, e.ExerciseID, e.SessionDate, e.Weight, e.SetsCompleted,
+			e.Completed, e.Volume, isPR, nil, db.Cardio{})
+		if err != nil {
+			internalError(w, r, "Failed to log shared session", err)
+			return
+		}
+		created = append(created, Event{Type: "history.created", ID: id, ExerciseID: e.ExerciseID})
+	}
+
+	result, err := h.DB.Exec("UPDATE shared_sessions SET finished_at = CURRENT_TIMESTAMP WHERE id = ? AND finished_at IS NULL", session.ID)
+	if err != nil {
+		internalError(w, r, "Failed to finish shared session", err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		conflict(w, r, "code", "The session has finished")
+		return
+	}
+
+	session, err = h.loadSession(session.Code)
+	if err != nil || session == nil {
+		internalError(w, r, "Failed to load shared session", err)
+		return
+	}
+	scope := goalScope{}
+	for _, e := range entries {
+		scope.exerciseIDs = append(scope.exerciseIDs, e.ExerciseID)
+	}
+	checkGoals(r.Context(), h.DB, h.Events, scope)
+	for _, e := range created {
+		h.Events.Publish(e)
+	}
+	h.Events.Publish(Event{Type: "shared_session.finished", ID: int64(session.ID)})
+	writeJSON(w, http.StatusOK, session)
+}
+
+// runningSession loads the session named in the path. It writes a 404 if
+// there is none and a 409 if it has finished.
+func (h *SharedSessionsHandler) runningSession(w http.ResponseWriter, r *http.Request) (*SharedSession, bool) {
+	session, err := h.loadSession(strings.ToUpper(r.PathValue("code")))
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return nil, false
+	}
+	if session == nil {
+		notFound(w, r, "Shared session not found")
+		return nil, false
+	}
+	if session.FinishedAt != nil {
+		conflict(w, r, "code", "The session has finished")
+		return nil, false
+	}
+	return session, true
+}
+
+// participant returns the id of the session's participant holding token
+func (h *SharedSessionsHandler) participant(w http.ResponseWriter, r *http.Request, session *SharedSession, token string) (int, bool) {
+	var id int
+	err := h.DB.QueryRow("SELECT id FROM shared_session_participants WHERE session_id = ? AND token = ?", session.ID, token).Scan(&id)
+	if err == sql.ErrNoRows {
+		fieldError(w, r, "token", "Not a participant of this session")
+		return 0, false
+	}
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return 0, false
+	}
+	return id, true
+}
+
+// loadSession returns the session with code, or nil if there is none
+func (h *SharedSessionsHandler) loadSession(code string) (*SharedSession, error) {
+	var s SharedSession
+	err := h.DB.QueryRow(`
+		SELECT id, code, day_of_week, CAST(session_date AS TEXT), CAST(finished_at AS TEXT)
+		FROM shared_sessions WHERE code = ?`, code).
+		Scan(&s.ID, &s.Code, &s.DayOfWeek, &s.SessionDate, &s.FinishedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Exercises, err = h.sessionExercises(s.DayOfWeek); err != nil {
+		return nil, err
+	}
+	if s.Participants, err = h.participants(s.ID); err != nil {
+		return nil, err
+	}
+	if s.FinishedAt != nil {
+		for i := range s.Participants {
+			if s.Participants[i].Entries, err = h.entries(&s, s.Participants[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &s, nil
+}
+
+// sessionExercises lists the day's routine in order
+func (h *SharedSessionsHandler) sessionExercises(day string) ([]SharedExercise, error) {
+	rows, err := h.DB.Query(`
+		SELECT e.id, e.name, e.type, e.target_sets, e.target_reps, e.target_weight
+		FROM routines r
+		JOIN exercises e ON r.exercise_id = e.id
+		WHERE r.day_of_week = ?
+		ORDER BY r.order_index`, day)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exercises := []SharedExercise{}
+	for rows.Next() {
+		var e SharedExercise
+		if err := rows.Scan(&e.ExerciseID, &e.Name, &e.Type, &e.TargetSets, &e.TargetReps, &e.TargetWeight); err != nil {
+			return nil, err
+		}
+		exercises = append(exercises, e)
+	}
+	return exercises, rows.Err()
+}
+
+// participants lists the session's participants, host first, with their sets
+func (h *SharedSessionsHandler) participants(sessionID int) ([]SharedParticipant, error) {
+	rows, err := h.DB.Query(`
+		SELECT id, name, is_host FROM shared_session_participants
+		WHERE session_id = ? ORDER BY is_host DESC, id`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	participants := []SharedParticipant{}
+	index := map[int]int{}
+	for rows.Next() {
+		p := SharedParticipant{Sets: []SharedSet{}}
+		if err := rows.Scan(&p.ID, &p.Name, &p.IsHost); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		index[p.ID] = len(participants)
+		participants = append(participants, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = h.DB.Query(`
+		SELECT s.participant_id, s.exercise_id, s.set_number, s.reps, s.weight, CAST(s.completed_at AS TEXT)
+		FROM shared_session_sets s
+		JOIN shared_session_participants p ON p.id = s.participant_id
+		WHERE p.session_id = ?
+		ORDER BY s.exercise_id, s.set_number`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var participantID int
+		var set SharedSet
+		if err := rows.Scan(&participantID, &set.ExerciseID, &set.SetNumber, &set.Reps, &set.Weight, &set.CompletedAt); err != nil {
+			return nil, err
+		}
+		p := &participants[index[participantID]]
+		p.Sets = append(p.Sets, set)
+	}
+	return participants, rows.Err()
+}
+
+// entries turns a participant's sets into one history entry per exercise,
+// scored the way the workout page scores a logged session: the heaviest
+// set's weight, volume by exercise type, and completed when every target
+// set met the target reps
+func (h *SharedSessionsHandler) entries(s *SharedSession, p SharedParticipant) ([]SharedEntry, error) {
+	entries := []SharedEntry{}
+	for _, ex := range s.Exercises {
+		var sets []SharedSet
+		for _, set := range p.Sets {
+			if set.ExerciseID == ex.ExerciseID {
+				sets = append(sets, set)
+			}
+		}
+		if len(sets) == 0 {
+			continue
+		}
+		exType, err := h.exerciseType(s, ex.ExerciseID)
+		if err != nil {
+			return nil, err
+		}
+
+		e := SharedEntry{
+			ClientID:      fmt.Sprintf("shared-%s-%d-%d", s.Code, p.ID, ex.ExerciseID),
+			ExerciseID:    ex.ExerciseID,
+			ExerciseName:  ex.Name,
+			SessionDate:   s.SessionDate,
+			SetsCompleted: []int{},
+			Completed:     ex.TargetSets == nil || len(sets) >= *ex.TargetSets,
+		}
+		var volume float64
+		for _, set := range sets {
+			e.SetsCompleted = append(e.SetsCompleted, set.Reps)
+			if ex.TargetReps != nil && set.Reps < *ex.TargetReps {
+				e.Completed = false
+			}
+			if exType.UsesWeight && set.Weight != nil && (e.Weight == nil || *set.Weight > *e.Weight) {
+				e.Weight = set.Weight
+			}
+			switch {
+			case exType.SetUnit == "seconds":
+				volume = max(volume, float64(set.Reps))
+			case !exType.UsesWeight || exType.BodyweightLoad == "add":
+				volume += float64(set.Reps)
+			case set.Weight != nil:
+				volume += float64(set.Reps) * *set.Weight
+			}
+		}
+		e.Volume = &volume
+
+		if p.IsHost {
+			var id int64
+			err := h.DB.QueryRow("SELECT id, is_pr FROM history WHERE client_id = ?", e.ClientID).Scan(&id, &e.IsPR)
+			if err != nil && err != sql.ErrNoRows {
+				return nil, err
+			}
+			if err == nil {
+				e.HistoryID = &id
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// exerciseType loads the type of one of the session's exercises
+func (h *SharedSessionsHandler) exerciseType(s *SharedSession, exerciseID int) (*db.ExerciseType, error) {
+	for _, ex := range s.Exercises {
+		if ex.ExerciseID == exerciseID {
+			exType, err := h.DB.GetExerciseType(ex.Type)
+			if err == nil && exType == nil {
+				err = fmt.Errorf("exercise type %q not found", ex.Type)
+			}
+			return exType, err
+		}
+	}
+	return nil, fmt.Errorf("exercise %d is not on the routine", exerciseID)
+}
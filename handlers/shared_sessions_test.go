@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"train/db"
+)
+
+// newSharedSessionsHandler creates a handler over a database whose Monday
+// routine is Squat (3x5) then Plank
+func newSharedSessionsHandler(t *testing.T) (*SharedSessionsHandler, int) {
+	t.Helper()
+	database, err := db.OpenForTesting()
+	if err != nil {
+		t.Fatalf("OpenForTesting: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	sets, reps := 3, 5
+	squat, err := database.CreateExercise("Squat", "weight", "Legs-Push", &sets, &reps, nil)
+	if err != nil {
+		t.Fatalf("CreateExercise: %v", err)
+	}
+	plank, err := database.CreateExercise("Plank", "timed_hold", "Core-Push", nil, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateExercise: %v", err)
+	}
+	database.CreateRoutine(int(squat), "Monday", 0, nil)
+	database.CreateRoutine(int(plank), "Monday", 1, nil)
+	return &SharedSessionsHandler{DB: database}, int(squat)
+}
+
+func sharedRequest(t *testing.T, h *SharedSessionsHandler, method, path, body string, wantStatus int, out interface{}) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, "/api/v1/shared-sessions"+path, strings.NewReader(body)))
+	if w.Code != wantStatus {
+		t.Fatalf("%s %s: expected %d, got %d: %s", method, path, wantStatus, w.Code, w.Body.String())
+	}
+	if out != nil {
+		if err := json.NewDecoder(w.Body).Decode(out); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+}
+
+func TestSharedSession_PartnersLogTheirOwnResults(t *testing.T) {
+	h, squat := newSharedSessionsHandler(t)
+
+	var host, partner SharedSessionJoinResponse
+	sharedRequest(t, h, http.MethodPost, "", `{"day_of_week":"Monday","session_date":"2026-01-12","name":"Sam"}`, http.StatusCreated, &host)
+	code := host.Session.Code
+	if len(code) != 6 || len(host.Session.Exercises) != 2 || !host.Session.Participants[0].IsHost {
+		t.Fatalf("unexpected session: %+v", host.Session)
+	}
+	sharedRequest(t, h, http.MethodPost, "/"+strings.ToLower(code)+"/join", `{"name":"Alex"}`, http.StatusCreated, &partner)
+	sharedRequest(t, h, http.MethodPost, "/"+code+"/join", `{"name":"Alex"}`, http.StatusConflict, nil)
+
+	set := func(token string, number, reps int, weight string) SharedSession {
+		var s SharedSession
+		sharedRequest(t, h, http.MethodPut, "/"+code+"/sets", `{"token":"`+token+`","exercise_id":`+strconv.Itoa(squat)+
+			`,"set_number":`+strconv.Itoa(number)+`,"reps":`+strconv.Itoa(reps)+`,"weight":`+weight+`}`, http.StatusOK, &s)
+		return s
+	}
+	for n := 1; n <= 3; n++ {
+		set(host.Token, n, 5, "100")
+	}
+	set(partner.Token, 1, 5, "60")
+	set(partner.Token, 2, 4, "60")
+	s := set(partner.Token, 2, 5, "62.5") // corrected
+	if got := s.Participants[1].Sets; len(got) != 2 || got[1].Reps != 5 || *got[1].Weight != 62.5 {
+		t.Fatalf("partner's sets should show the correction, got %+v", got)
+	}
+	if len(s.Participants[0].Sets) != 3 {
+		t.Errorf("each participant should see the host's sets, got %+v", s.Participants[0].Sets)
+	}
+
+	sharedRequest(t, h, http.MethodPost, "/"+code+"/finish", `{"token":"`+partner.Token+`"}`, http.StatusBadRequest, nil)
+	sharedRequest(t, h, http.MethodPost, "/"+code+"/finish", `{"token":"`+host.Token+`"}`, http.StatusOK, &s)
+
+	// Only the host's entry lands in this server's history
+	hostEntry := s.Participants[0].Entries[0]
+	if !hostEntry.Completed || *hostEntry.Weight != 100 || *hostEntry.Volume != 1500 || hostEntry.HistoryID == nil || !hostEntry.IsPR {
+		t.Errorf("unexpected host entry: %+v", hostEntry)
+	}
+	var logged int
+	h.DB.QueryRow("SELECT COUNT(*) FROM history").Scan(&logged)
+	if logged != 1 {
+		t.Errorf("expected only the host's entry in history, got %d rows", logged)
+	}
+
+	// The partner's entry is handed back to post to their own server
+	partnerEntry := s.Participants[1].Entries[0]
+	if partnerEntry.Completed || *partnerEntry.Weight != 62.5 || *partnerEntry.Volume != 612.5 ||
+		partnerEntry.HistoryID != nil || partnerEntry.ExerciseName != "Squat" || !strings.HasPrefix(partnerEntry.ClientID, "shared-"+code) {
+		t.Errorf("unexpected partner entry: %+v", partnerEntry)
+	}
+
+	sharedRequest(t, h, http.MethodPost, "/"+code+"/finish", `{"token":"`+host.Token+`"}`, http.StatusConflict, nil)
+	late := `{"token":"` + partner.Token + `","exercise_id":` + strconv.Itoa(squat) + `,"set_number":3,"reps":5}`
+	sharedRequest(t, h, http.MethodPut, "/"+code+"/sets", late, http.StatusConflict, nil)
+}
+
+func TestSharedSession_InvalidRequestsRejected(t *testing.T) {
+	h, squat := newSharedSessionsHandler(t)
+
+	sharedRequest(t, h, http.MethodPost, "", `{"day_of_week":"Tuesday","name":"Sam"}`, http.StatusBadRequest, nil)
+	sharedRequest(t, h, http.MethodPost, "", `{"day_of_week":"Monday"}`, http.StatusBadRequest, nil)
+	sharedRequest(t, h, http.MethodGet, "/NOPE23", "", http.StatusNotFound, nil)
+
+	var host SharedSessionJoinResponse
+	sharedRequest(t, h, http.MethodPost, "", `{"day_of_week":"Monday","name":"Sam"}`, http.StatusCreated, &host)
+	path := "/" + host.Session.Code + "/sets"
+	for _, body := range []string{
+		`{"token":"nobody","exercise_id":` + strconv.Itoa(squat) + `,"set_number":1,"reps":5}`,
+		`{"token":"` + host.Token + `","exercise_id":999,"set_number":1,"reps":5}`,
+		`{"token":"` + host.Token + `","exercise_id":` + strconv.Itoa(squat) + `,"set_number":0,"reps":5}`,
+		`{"token":"` + host.Token + `","exercise_id":` + strconv.Itoa(squat) + `,"set_number":1,"reps":-1}`,
+	} {
+		sharedRequest(t, h, http.MethodPut, path, body, http.StatusBadRequest, nil)
+	}
+}
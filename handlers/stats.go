@@ -0,0 +1,687 @@
+package handlers
+
+import (
+	"cmp"
+	"encoding/json"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"train/db"
+)
+
+// StatsHandler serves training summaries derived from history and routines
+type StatsHandler struct {
+	DB *db.DB
+	// now is replaced in tests; nil means time.Now
+	now func() time.Time
+}
+
+// Calendar day statuses
+const (
+	DayTrained   = "trained"   // at least one session was logged
+	DayMissed    = "missed"    // a routine day in the past with no session
+	DayRest      = "rest"      // no routine that weekday and no session
+	DayPlanned   = "planned"   // a routine day that hasn't happened yet
+	DayUntracked = "untracked" // before the first ever session
+)
+
+// routes mounts the stats endpoints
+func (h *StatsHandler) routes(rt *router) {
+	rt.handle(http.MethodGet, "/stats/calendar", h.getCalendar)
+	rt.handle(http.MethodGet, "/stats/volume", h.getVolume)
+	rt.handle(http.MethodGet, "/stats/muscles", h.getMuscleVolume)
+	rt.handle(http.MethodGet, "/stats/cardio", h.getCardio)
+	rt.handle(http.MethodGet, "/stats/strength", h.getStrength)
+}
+
+// ServeHTTP serves the stats endpoints on their own
+func (h *StatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveRoutes(w, r, h.routes)
+}
+
+func (h *StatsHandler) today() time.Time {
+	now := time.Now
+	if h.now != nil {
+		now = h.now
+	}
+	t := now()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// getCalendar returns each day of a year with its training status, weekly
+// adherence and streaks.
+//
+// A day is planned when its weekday has at least one routine. Routines are
+// not versioned, so past days are judged against the current plan. Streaks
+// count trained days; rest days neither extend nor break them, a missed day
+// breaks them, and today never breaks the current streak because it isn't
+// over yet.
+func (h *StatsHandler) getCalendar(w http.ResponseWriter, r *http.Request) {
+	today := h.today()
+	year := today.Year()
+	if v := r.URL.Query().Get("year"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1970 || n > 9999 {
+			fieldError(w, r, "year", "year must be a four-digit year")
+			return
+		}
+		year = n
+	}
+
+	planned, err := h.plannedWeekdays()
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	sessions, first, err := h.sessionsByDate()
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+
+	status := func(d time.Time) string {
+		key := d.Format(dateLayout)
+		switch {
+		case sessions[key] > 0:
+			return DayTrained
+		case first == "" || key < first:
+			return DayUntracked
+		case !planned[d.Weekday()]:
+			return DayRest
+		case !d.Before(today):
+			return DayPlanned
+		default:
+			return DayMissed
+		}
+	}
+
+	resp := CalendarResponse{Year: year, PlannedWeekdays: []string{}, Days: []CalendarDay{}, Weeks: []CalendarWeek{}}
+	for _, day := range weekDays {
+		if planned[weekdayOf(day)] {
+			resp.PlannedWeekdays = append(resp.PlannedWeekdays, day)
+		}
+	}
+
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+	var week *CalendarWeek
+	streak := 0
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		s := status(d)
+		resp.Days = append(resp.Days, CalendarDay{Date: d.Format(dateLayout), Status: s, Sessions: sessions[d.Format(dateLayout)]})
+
+		if week == nil || d.Weekday() == time.Monday {
+			resp.Weeks = append(resp.Weeks, CalendarWeek{WeekStart: mondayOf(d).Format(dateLayout)})
+			week = &resp.Weeks[len(resp.Weeks)-1]
+		}
+
+		switch s {
+		case DayTrained:
+			resp.TrainedDays++
+			if planned[d.Weekday()] {
+				week.Planned++
+				week.Trained++
+			}
+			streak++
+			resp.LongestStreak = max(resp.LongestStreak, streak)
+		case DayMissed:
+			resp.MissedDays++
+			week.Planned++
+			week.Missed++
+			streak = 0
+		}
+	}
+
+	totalPlanned, totalTrained := 0, 0
+	for i := range resp.Weeks {
+		wk := &resp.Weeks[i]
+		wk.Adherence = percentage(wk.Trained, wk.Planned)
+		totalPlanned += wk.Planned
+		totalTrained += wk.Trained
+	}
+	resp.Adherence = percentage(totalTrained, totalPlanned)
+
+	// The current streak runs back from today and may cross into earlier years
+	if first != "" {
+		firstDate, _ := time.Parse(dateLayout, first)
+		for d := today; !d.Before(firstDate); d = d.AddDate(0, 0, -1) {
+			s := status(d)
+			if s == DayMissed {
+				break
+			}
+			if s == DayTrained {
+				resp.CurrentStreak++
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// volumeDays is the default range of GET /stats/volume: twelve weeks
+const volumeDays = 84
+
+// getVolume sums hard sets, reps and tonnage per week, category and exercise
+// over ?from= to ?to= (the twelve weeks ending today by default), and
+// compares push and pull sets for each body region. Cardio is left out.
+func (h *StatsHandler) getVolume(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := dateRange(w, r, h.today(), volumeDays)
+	if !ok {
+		return
+	}
+	fromDate, _ := time.Parse(dateLayout, from)
+	toDate, _ := time.Parse(dateLayout, to)
+
+	sessions, err := h.volumeSessions(from, to)
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+
+	firstWeek := mondayOf(fromDate)
+	weeks := make([]*volumeTally, daysBetween(firstWeek, mondayOf(toDate))/7+1)
+	for i := range weeks {
+		weeks[i] = newVolumeTally()
+	}
+	total := newVolumeTally()
+	for _, s := range sessions {
+		v := s.volume()
+		weeks[daysBetween(firstWeek, s.date)/7].add(s.exercise, v)
+		total.add(s.exercise, v)
+	}
+
+	resp := VolumeResponse{From: from, To: to, Weeks: make([]VolumeWeek, len(weeks)), Totals: total.totals.rounded()}
+	for i, t := range weeks {
+		week := VolumeWeek{WeekStart: firstWeek.AddDate(0, 0, 7*i).Format(dateLayout), VolumeTotals: t.totals.rounded()}
+		week.Categories, week.Exercises = t.lists()
+		resp.Weeks[i] = week
+	}
+	resp.Categories, resp.Exercises = total.lists()
+
+	rangeWeeks := float64(daysBetween(fromDate, toDate)+1) / 7
+	balance := map[string]*PushPullBalance{}
+	for i := range resp.Categories {
+		c := &resp.Categories[i]
+		weekly := math.Round(float64(c.Sets)/rangeWeeks*10) / 10
+		c.WeeklySets = &weekly
+
+		region, side, _ := strings.Cut(c.Category, "-")
+		if side != "Push" && side != "Pull" {
+			continue
+		}
+		if balance[region] == nil {
+			balance[region] = &PushPullBalance{Region: region}
+		}
+		if side == "Push" {
+			balance[region].PushSets += c.Sets
+		} else {
+			balance[region].PullSets += c.Sets
+		}
+	}
+	resp.Balance = []PushPullBalance{}
+	for _, region := range slices.Sorted(maps.Keys(balance)) {
+		b := balance[region]
+		if b.PullSets > 0 {
+			ratio := math.Round(float64(b.PushSets)/float64(b.PullSets)*100) / 100
+			b.Ratio = &ratio
+		}
+		resp.Balance = append(resp.Balance, *b)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// getMuscleVolume credits each hard set to the muscles its exercise works,
+// scaled by the mapping weight (a set of squats with quads primary and
+// glutes secondary is 1 quad set and 0.5 glute sets). Totals list every
+// muscle so neglected ones show as zero; weeks list only muscles worked.
+// Sets of exercises without a mapping are reported as unmapped.
+func (h *StatsHandler) getMuscleVolume(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := dateRange(w, r, h.today(), volumeDays)
+	if !ok {
+		return
+	}
+	fromDate, _ := time.Parse(dateLayout, from)
+	toDate, _ := time.Parse(dateLayout, to)
+
+	muscles, err := h.DB.GetMuscles()
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	mappings, err := h.exerciseMuscles()
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	sessions, err := h.volumeSessions(from, to)
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+
+	firstWeek := mondayOf(fromDate)
+	weeks := make([]map[int]*MuscleSets, daysBetween(firstWeek, mondayOf(toDate))/7+1)
+	for i := range weeks {
+		weeks[i] = map[int]*MuscleSets{}
+	}
+	total := map[int]*MuscleSets{}
+	credit := func(tally map[int]*MuscleSets, m db.ExerciseMuscle, sets float64) {
+		if tally[m.MuscleID] == nil {
+			tally[m.MuscleID] = &MuscleSets{MuscleID: m.MuscleID, Name: m.Name}
+		}
+		t := tally[m.MuscleID]
+		t.Sets += sets
+		if m.Role == RolePrimary {
+			t.PrimarySets += sets
+		} else {
+			t.SecondarySets += sets
+		}
+	}
+
+	resp := MuscleVolumeResponse{From: from, To: to, Weeks: make([]MuscleWeek, len(weeks)), Muscles: []MuscleSets{}}
+	for _, s := range sessions {
+		sets := s.volume().Sets
+		mapping := mappings[s.exercise.ExerciseID]
+		if len(mapping) == 0 {
+			resp.UnmappedSets += sets
+			continue
+		}
+		for _, m := range mapping {
+			credit(weeks[daysBetween(firstWeek, s.date)/7], m, float64(sets)*m.Weight)
+			credit(total, m, float64(sets)*m.Weight)
+		}
+	}
+
+	rangeWeeks := float64(daysBetween(fromDate, toDate)+1) / 7
+	for i, tally := range weeks {
+		week := MuscleWeek{WeekStart: firstWeek.AddDate(0, 0, 7*i).Format(dateLayout), Muscles: []MuscleSets{}}
+		for _, m := range muscles {
+			if t := tally[m.ID]; t != nil {
+				week.Muscles = append(week.Muscles, t.rounded())
+			}
+		}
+		resp.Weeks[i] = week
+	}
+	for _, m := range muscles {
+		t := MuscleSets{MuscleID: m.ID, Name: m.Name}
+		if total[m.ID] != nil {
+			t = total[m.ID].rounded()
+		}
+		weekly := math.Round(t.Sets/rangeWeeks*10) / 10
+		t.WeeklySets = &weekly
+		resp.Muscles = append(resp.Muscles, t)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// rounded returns m with its set counts rounded to two decimals
+func (m MuscleSets) rounded() MuscleSets {
+	for _, v := range []*float64{&m.Sets, &m.PrimarySets, &m.SecondarySets} {
+		*v = math.Round(*v*100) / 100
+	}
+	return m
+}
+
+// exerciseMuscles returns every exercise's muscle mapping by exercise ID
+func (h *StatsHandler) exerciseMuscles() (map[int][]db.ExerciseMuscle, error) {
+	rows, err := h.DB.Query(`
+		SELECT em.exercise_id, em.muscle_id, m.name, em.role, em.weight
+		FROM exercise_muscles em
+		JOIN muscles m ON m.id = em.muscle_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mappings := map[int][]db.ExerciseMuscle{}
+	for rows.Next() {
+		var exerciseID int
+		var m db.ExerciseMuscle
+		if err := rows.Scan(&exerciseID, &m.MuscleID, &m.Name, &m.Role, &m.Weight); err != nil {
+			return nil, err
+		}
+		mappings[exerciseID] = append(mappings[exerciseID], m)
+	}
+	return mappings, rows.Err()
+}
+
+// getCardio sums duration, distance, elevation, calories and records set per week and
+// exercise over ?from= to ?to= (the twelve weeks ending today by default).
+// Any session that logged a duration or distance counts, whatever its type.
+func (h *StatsHandler) getCardio(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := dateRange(w, r, h.today(), volumeDays)
+	if !ok {
+		return
+	}
+	fromDate, _ := time.Parse(dateLayout, from)
+	toDate, _ := time.Parse(dateLayout, to)
+
+	rows, err := h.DB.Query(`
+		SELECT CAST(h.session_date AS TEXT), h.exercise_id, e.name, `+db.CardioColumns+`, `+db.CardioPRSQL("h")+`
+		FROM history h
+		JOIN exercises e ON e.id = h.exercise_id
+		WHERE h.session_date >= ? AND h.session_date <= ?
+		  AND (h.duration_seconds IS NOT NULL OR h.distance_km IS NOT NULL)
+		ORDER BY h.session_date, h.id
+	`, from, to)
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	defer rows.Close()
+
+	firstWeek := mondayOf(fromDate)
+	weeks := make([]cardioTally, daysBetween(firstWeek, mondayOf(toDate))/7+1)
+	var total cardioTally
+	exercises := map[int]*cardioTally{}
+	var order []CardioExercise
+	for rows.Next() {
+		var date string
+		var ex CardioExercise
+		var c db.Cardio
+		var prs db.CardioPRs
+		if err := rows.Scan(append(append([]interface{}{&date, &ex.ExerciseID, &ex.Name}, c.ScanFields()...), prs.ScanFields()...)...); err != nil {
+			internalError(w, r, "Scan error", err)
+			return
+		}
+		day, err := time.Parse(dateLayout, date)
+		if err != nil {
+			internalError(w, r, "Database error", err)
+			return
+		}
+		weeks[daysBetween(firstWeek, day)/7].add(c, prs)
+		total.add(c, prs)
+		if exercises[ex.ExerciseID] == nil {
+			exercises[ex.ExerciseID] = &cardioTally{}
+			order = append(order, ex)
+		}
+		exercises[ex.ExerciseID].add(c, prs)
+	}
+	if err := rows.Err(); err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+
+	resp := CardioResponse{From: from, To: to, Weeks: make([]CardioWeek, len(weeks)), Totals: total.totals(), Exercises: []CardioExercise{}}
+	for i, t := range weeks {
+		resp.Weeks[i] = CardioWeek{WeekStart: firstWeek.AddDate(0, 0, 7*i).Format(dateLayout), CardioTotals: t.totals()}
+	}
+	for _, ex := range order {
+		ex.CardioTotals = exercises[ex.ExerciseID].totals()
+		resp.Exercises = append(resp.Exercises, ex)
+	}
+	slices.SortFunc(resp.Exercises, func(a, b CardioExercise) int {
+		return cmp.Or(cmp.Compare(b.DistanceKm, a.DistanceKm), cmp.Compare(b.DurationSeconds, a.DurationSeconds), strings.Compare(a.Name, b.Name))
+	})
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// cardioTally accumulates cardio sessions, keeping the duration and
+// distance of sessions that logged both for the average pace
+type cardioTally struct {
+	CardioTotals
+	pacedSeconds, pacedKm float64
+}
+
+func (t *cardioTally) add(c db.Cardio, prs db.CardioPRs) {
+	t.Sessions++
+	if prs.DistancePR {
+		t.DistancePRs++
+	}
+	if prs.PacePR {
+		t.PacePRs++
+	}
+	if c.DurationSeconds != nil {
+		t.DurationSeconds += *c.DurationSeconds
+	}
+	if c.DistanceKm != nil {
+		t.DistanceKm += *c.DistanceKm
+	}
+	if c.ElevationGainM != nil {
+		t.ElevationGainM += *c.ElevationGainM
+	}
+	if c.Calories != nil {
+		t.Calories += *c.Calories
+	}
+	if c.DurationSeconds != nil && c.DistanceKm != nil && *c.DurationSeconds > 0 && *c.DistanceKm > 0 {
+		t.pacedSeconds += float64(*c.DurationSeconds)
+		t.pacedKm += *c.DistanceKm
+	}
+}
+
+// totals returns the tally rounded for output, with the average pace
+func (t cardioTally) totals() CardioTotals {
+	c := t.CardioTotals
+	c.DistanceKm = math.Round(c.DistanceKm*100) / 100
+	c.ElevationGainM = math.Round(c.ElevationGainM*10) / 10
+	if t.pacedKm > 0 {
+		pace := math.Round(t.pacedSeconds/t.pacedKm*10) / 10
+		c.PaceSecondsPerKm = &pace
+	}
+	return c
+}
+
+// volumeSession is a session of a type that counts towards training volume
+type volumeSession struct {
+	date           time.Time
+	exercise       ExerciseVolume
+	setUnit        string
+	countsTonnage  bool
+	bodyweightLoad string
+	weight, stored *float64
+	bodyweight     *float64
+	sets           []int
+}
+
+// volumeSessions returns the sessions from from to to whose exercise type
+// counts towards volume, oldest first
+func (h *StatsHandler) volumeSessions(from, to string) ([]volumeSession, error) {
+	rows, err := h.DB.Query(`
+		SELECT CAST(h.session_date AS TEXT), h.exercise_id, e.name, e.type, COALESCE(e.category, ''),
+		       t.set_unit, t.counts_tonnage, t.bodyweight_load, h.weight, h.volume, h.sets_completed,
+		       `+db.BodyweightSQL("h.session_date")+`
+		FROM history h
+		JOIN exercises e ON e.id = h.exercise_id
+		JOIN exercise_types t ON t.name = e.type
+		WHERE h.session_date >= ? AND h.session_date <= ? AND t.counts_volume = 1
+		ORDER BY h.session_date, h.id
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []volumeSession
+	for rows.Next() {
+		var date, setsJSON string
+		var s volumeSession
+		ex := &s.exercise
+		if err := rows.Scan(&date, &ex.ExerciseID, &ex.Name, &ex.Type, &ex.Category, &s.setUnit, &s.countsTonnage, &s.bodyweightLoad,
+			&s.weight, &s.stored, &setsJSON, &s.bodyweight); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(setsJSON), &s.sets); err != nil {
+			return nil, err
+		}
+		if s.date, err = time.Parse(dateLayout, date); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// volume counts the session's hard sets (sets with any reps or seconds
+// logged), reps and tonnage. Laps count as reps and timed sets add sets
+// only; for types that count tonnage it is weight × reps, falling back to
+// the stored volume when no weight was recorded. Types whose load includes
+// bodyweight count effective load × reps once a bodyweight is logged;
+// without one, assistance weight isn't load lifted and counts nothing.
+func (s volumeSession) volume() VolumeTotals {
+	var v VolumeTotals
+	for _, n := range s.sets {
+		if n <= 0 {
+			continue
+		}
+		v.Sets++
+		if s.setUnit == "reps" || s.setUnit == "laps" {
+			v.Reps += n
+		}
+	}
+	switch {
+	case s.bodyweightLoad != "none":
+		if load := effectiveLoad(s.bodyweightLoad, s.weight, s.bodyweight); load != nil {
+			v.Tonnage = *load * float64(v.Reps)
+		}
+	case s.countsTonnage && s.weight != nil:
+		v.Tonnage = *s.weight * float64(v.Reps)
+	case s.countsTonnage && s.stored != nil:
+		v.Tonnage = *s.stored
+	}
+	return v
+}
+
+func (v *VolumeTotals) add(o VolumeTotals) {
+	v.Sets += o.Sets
+	v.Reps += o.Reps
+	v.Tonnage += o.Tonnage
+}
+
+// rounded returns v with tonnage rounded to one decimal
+func (v VolumeTotals) rounded() VolumeTotals {
+	v.Tonnage = math.Round(v.Tonnage*10) / 10
+	return v
+}
+
+// volumeTally accumulates volume by category and exercise
+type volumeTally struct {
+	totals     VolumeTotals
+	categories map[string]*CategoryVolume
+	exercises  map[int]*ExerciseVolume
+}
+
+func newVolumeTally() *volumeTally {
+	return &volumeTally{categories: map[string]*CategoryVolume{}, exercises: map[int]*ExerciseVolume{}}
+}
+
+// add counts v towards the totals, ex's category and ex itself
+func (t *volumeTally) add(ex ExerciseVolume, v VolumeTotals) {
+	t.totals.add(v)
+	if t.categories[ex.Category] == nil {
+		t.categories[ex.Category] = &CategoryVolume{Category: ex.Category}
+	}
+	t.categories[ex.Category].add(v)
+	if t.exercises[ex.ExerciseID] == nil {
+		t.exercises[ex.ExerciseID] = &ex
+	}
+	t.exercises[ex.ExerciseID].add(v)
+}
+
+// lists returns the categories by name and the exercises with the most sets
+// first
+func (t *volumeTally) lists() ([]CategoryVolume, []ExerciseVolume) {
+	categories := []CategoryVolume{}
+	for _, c := range t.categories {
+		categories = append(categories, CategoryVolume{Category: c.Category, VolumeTotals: c.rounded()})
+	}
+	slices.SortFunc(categories, func(a, b CategoryVolume) int { return strings.Compare(a.Category, b.Category) })
+
+	exercises := []ExerciseVolume{}
+	for _, e := range t.exercises {
+		ex := *e
+		ex.VolumeTotals = e.rounded()
+		exercises = append(exercises, ex)
+	}
+	slices.SortFunc(exercises, func(a, b ExerciseVolume) int {
+		return cmp.Or(cmp.Compare(b.Sets, a.Sets), strings.Compare(a.Name, b.Name))
+	})
+	return categories, exercises
+}
+
+// plannedWeekdays returns the weekdays that have at least one routine
+func (h *StatsHandler) plannedWeekdays() (map[time.Weekday]bool, error) {
+	rows, err := h.DB.Query("SELECT DISTINCT day_of_week FROM routines")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	planned := map[time.Weekday]bool{}
+	for rows.Next() {
+		var day string
+		if err := rows.Scan(&day); err != nil {
+			return nil, err
+		}
+		planned[weekdayOf(day)] = true
+	}
+	return planned, rows.Err()
+}
+
+// sessionsByDate counts sessions per YYYY-MM-DD date and returns the first
+// date with any session ("" when there is no history)
+func (h *StatsHandler) sessionsByDate() (map[string]int, string, error) {
+	rows, err := h.DB.Query(`
+		SELECT CAST(session_date AS TEXT), COUNT(*)
+		FROM history
+		GROUP BY session_date
+		ORDER BY session_date
+	`)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	first := ""
+	for rows.Next() {
+		var date string
+		var n int
+		if err := rows.Scan(&date, &n); err != nil {
+			return nil, "", err
+		}
+		if first == "" {
+			first = date
+		}
+		counts[date] = n
+	}
+	return counts, first, rows.Err()
+}
+
+// weekdayOf converts a day name from weekDays to a time.Weekday
+func weekdayOf(day string) time.Weekday {
+	for i := time.Sunday; i <= time.Saturday; i++ {
+		if i.String() == day {
+			return i
+		}
+	}
+	return -1
+}
+
+// daysBetween returns the whole days from a to b
+func daysBetween(a, b time.Time) int {
+	return int(b.Sub(a).Hours() / 24)
+}
+
+// mondayOf returns the Monday starting d's week
+func mondayOf(d time.Time) time.Time {
+	return d.AddDate(0, 0, -((int(d.Weekday()) + 6) % 7))
+}
+
+// percentage returns part/whole as a percentage rounded to one decimal, or
+// nil when whole is zero
+func percentage(part, whole int) *float64 {
+	if whole == 0 {
+		return nil
+	}
+	p := math.Round(float64(part)/float64(whole)*1000) / 10
+	return &p
+}
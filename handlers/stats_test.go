@@ -0,0 +1,409 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"train/db"
+)
+
+// newStatsHandler creates a StatsHandler whose clock reads today, with
+// routines on Monday and Wednesday and sessions on the given dates
+func newStatsHandler(t *testing.T, today string, dates ...string) *StatsHandler {
+	t.Helper()
+	database, err := db.OpenForTesting()
+	if err != nil {
+		t.Fatalf("OpenForTesting: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	id, err := database.CreateExercise("Squat", "weight", "Legs-Push", nil, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateExercise: %v", err)
+	}
+	for i, day := range []string{"Monday", "Wednesday"} {
+		if _, err := database.CreateRoutine(int(id), day, i, nil); err != nil {
+			t.Fatalf("CreateRoutine: %v", err)
+		}
+	}
+	for _, date := range dates {
+		if _, err := database.CreateHistory(int(id), date, nil, []int{5, 5, 5}, true, nil, false, nil, db.Cardio{}); err != nil {
+			t.Fatalf("CreateHistory: %v", err)
+		}
+	}
+
+	now, err := time.Parse(dateLayout, today)
+	if err != nil {
+		t.Fatalf("bad today: %v", err)
+	}
+	return &StatsHandler{DB: database, now: func() time.Time { return now }}
+}
+
+func getCalendar(t *testing.T, h *StatsHandler, query string) CalendarResponse {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stats/calendar"+query, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp CalendarResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return resp
+}
+
+func TestCalendar_StatusesStreaksAndAdherence(t *testing.T) {
+	// Wednesday 14 Jan is missed; Saturday 17 Jan is an extra session on a
+	// rest day; today is Wednesday 21 Jan and hasn't been trained yet
+	h := newStatsHandler(t, "2026-01-21", "2026-01-05", "2026-01-07", "2026-01-12", "2026-01-17", "2026-01-19")
+	cal := getCalendar(t, h, "")
+
+	if cal.Year != 2026 || len(cal.Days) != 365 {
+		t.Fatalf("expected 365 days of 2026, got %d of %d", len(cal.Days), cal.Year)
+	}
+	status := map[string]string{}
+	for _, d := range cal.Days {
+		status[d.Date] = d.Status
+	}
+	for date, want := range map[string]string{
+		"2026-01-04": DayUntracked,
+		"2026-01-05": DayTrained,
+		"2026-01-06": DayRest,
+		"2026-01-14": DayMissed,
+		"2026-01-17": DayTrained,
+		"2026-01-21": DayPlanned,
+		"2026-01-28": DayPlanned,
+	} {
+		if status[date] != want {
+			t.Errorf("%s: expected %s, got %s", date, want, status[date])
+		}
+	}
+
+	if cal.CurrentStreak != 2 || cal.LongestStreak != 3 {
+		t.Errorf("expected current streak 2 and longest 3, got %d and %d", cal.CurrentStreak, cal.LongestStreak)
+	}
+	if cal.TrainedDays != 5 || cal.MissedDays != 1 {
+		t.Errorf("expected 5 trained and 1 missed day, got %d and %d", cal.TrainedDays, cal.MissedDays)
+	}
+	if cal.Adherence == nil || *cal.Adherence != 80 {
+		t.Errorf("expected 80%% adherence, got %v", cal.Adherence)
+	}
+
+	// The first week starts on the Monday before 1 Jan
+	if cal.Weeks[0].WeekStart != "2025-12-29" || cal.Weeks[0].Adherence != nil {
+		t.Errorf("unexpected first week: %+v", cal.Weeks[0])
+	}
+	week := cal.Weeks[2]
+	if week.WeekStart != "2026-01-12" || week.Planned != 2 || week.Missed != 1 || week.Adherence == nil || *week.Adherence != 50 {
+		t.Errorf("unexpected week of 12 Jan: %+v", week)
+	}
+}
+
+func TestCalendar_TodayDoesNotBreakStreak(t *testing.T) {
+	h := newStatsHandler(t, "2026-01-14", "2026-01-07", "2026-01-12")
+	if cal := getCalendar(t, h, ""); cal.CurrentStreak != 2 {
+		t.Errorf("expected current streak 2 on an untrained planned day, got %d", cal.CurrentStreak)
+	}
+
+	h.now = func() time.Time { return time.Date(2026, 1, 15, 9, 0, 0, 0, time.UTC) }
+	if cal := getCalendar(t, h, ""); cal.CurrentStreak != 0 {
+		t.Errorf("expected the missed Wednesday to end the streak, got %d", cal.CurrentStreak)
+	}
+}
+
+func TestCalendar_StreakCrossesYears(t *testing.T) {
+	h := newStatsHandler(t, "2026-01-05", "2025-12-29", "2025-12-31", "2026-01-05")
+	cal := getCalendar(t, h, "?year=2025")
+	if cal.Year != 2025 || cal.CurrentStreak != 3 || cal.LongestStreak != 2 {
+		t.Errorf("expected 2025 with current streak 3 and longest 2, got %d, %d, %d", cal.Year, cal.CurrentStreak, cal.LongestStreak)
+	}
+}
+
+func TestCalendar_InvalidYear(t *testing.T) {
+	h := newStatsHandler(t, "2026-01-05")
+	for _, year := range []string{"abc", "1969", "10000"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stats/calendar?year="+year, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("year=%s: expected 400, got %d", year, w.Code)
+		}
+	}
+}
+
+func TestVolume_WeeklyTotalsAndBalance(t *testing.T) {
+	h := newStatsHandler(t, "2026-01-18")
+	log := func(name, exerciseType, category, date string, weight float64, sets ...int) {
+		t.Helper()
+		ex, err := h.DB.GetExerciseByName(name)
+		var id int
+		if err == nil && ex != nil {
+			id = ex.ID
+		} else {
+			n, err := h.DB.CreateExercise(name, exerciseType, category, nil, nil, nil)
+			if err != nil {
+				t.Fatalf("CreateExercise: %v", err)
+			}
+			id = int(n)
+		}
+		if _, err := h.DB.CreateHistory(id, date, &weight, sets, true, nil, false, nil, db.Cardio{}); err != nil {
+			t.Fatalf("CreateHistory: %v", err)
+		}
+	}
+	log("Squat", "weight", "Legs-Push", "2026-01-05", 100, 5, 5, 5)
+	log("Squat", "weight", "Legs-Push", "2026-01-12", 102.5, 5, 5, 0)
+	log("Squat", "weight", "Legs-Push", "2026-01-19", 105, 5, 5, 5) // after the range
+	log("Deadlift", "weight", "Legs-Pull", "2026-01-07", 140, 5)
+	log("Pull-up", "assisted", "Arms-Pull", "2026-01-07", 20, 8, 8)
+	log("Plank", "timed_hold", "Core-Push", "2026-01-12", 0, 60, 45)
+	log("Run", "cardio", "", "2026-01-12", 0, 1)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stats/volume?from=2026-01-05", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var vol VolumeResponse
+	if err := json.NewDecoder(w.Body).Decode(&vol); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	if want := (VolumeTotals{Sets: 10, Reps: 46, Tonnage: 3225}); vol.Totals != want {
+		t.Errorf("expected totals %+v, got %+v", want, vol.Totals)
+	}
+	if len(vol.Weeks) != 2 || vol.Weeks[0].Sets != 6 || vol.Weeks[1].Sets != 4 {
+		t.Fatalf("expected weeks of 6 and 4 sets, got %+v", vol.Weeks)
+	}
+
+	var names []string
+	for _, e := range vol.Exercises {
+		names = append(names, e.Name)
+	}
+	if got := strings.Join(names, ","); got != "Squat,Plank,Pull-up,Deadlift" {
+		t.Errorf("expected exercises by sets, got %s", got)
+	}
+	if c := vol.Categories[1]; c.Category != "Core-Push" || c.Reps != 0 || c.WeeklySets == nil || *c.WeeklySets != 1 {
+		t.Errorf("unexpected Core-Push volume: %+v", c)
+	}
+
+	balance := map[string]PushPullBalance{}
+	for _, b := range vol.Balance {
+		balance[b.Region] = b
+	}
+	if b := balance["Legs"]; b.PushSets != 5 || b.PullSets != 1 || b.Ratio == nil || *b.Ratio != 5 {
+		t.Errorf("unexpected Legs balance: %+v", b)
+	}
+	if b := balance["Core"]; b.PushSets != 2 || b.Ratio != nil {
+		t.Errorf("unexpected Core balance: %+v", b)
+	}
+}
+
+func TestMuscleVolume_CreditsFractionalSets(t *testing.T) {
+	h := newStatsHandler(t, "2026-01-18", "2026-01-05", "2026-01-12")
+	squat, _ := h.DB.GetExerciseByName("Squat")
+	quads, glutes := muscleID(t, h.DB, "Quads"), muscleID(t, h.DB, "Glutes")
+	err := h.DB.SetExerciseMuscles(squat.ID, []db.ExerciseMuscle{
+		{MuscleID: quads, Role: RolePrimary, Weight: 1},
+		{MuscleID: glutes, Role: RoleSecondary, Weight: 0.5},
+	})
+	if err != nil {
+		t.Fatalf("SetExerciseMuscles: %v", err)
+	}
+	row, err := h.DB.CreateExercise("Row", "weight", "Arms-Pull", nil, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateExercise: %v", err)
+	}
+	if _, err := h.DB.CreateHistory(int(row), "2026-01-12", nil, []int{10, 10}, true, nil, false, nil, db.Cardio{}); err != nil {
+		t.Fatalf("CreateHistory: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stats/muscles?from=2026-01-05", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var vol MuscleVolumeResponse
+	if err := json.NewDecoder(w.Body).Decode(&vol); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	totals := map[string]MuscleSets{}
+	for _, m := range vol.Muscles {
+		totals[m.Name] = m
+	}
+	if m := totals["Quads"]; m.Sets != 6 || m.PrimarySets != 6 || *m.WeeklySets != 3 {
+		t.Errorf("expected 6 primary quad sets, 3 a week, got %+v", m)
+	}
+	if m := totals["Glutes"]; m.Sets != 3 || m.SecondarySets != 3 {
+		t.Errorf("expected 3 secondary glute sets, got %+v", m)
+	}
+	if m, ok := totals["Chest"]; !ok || m.Sets != 0 {
+		t.Errorf("expected untrained muscles listed with zero sets, got %+v", m)
+	}
+	if vol.UnmappedSets != 2 {
+		t.Errorf("expected the unmapped row's 2 sets reported, got %d", vol.UnmappedSets)
+	}
+	if len(vol.Weeks) != 2 || len(vol.Weeks[0].Muscles) != 2 || vol.Weeks[0].Muscles[1].Name != "Quads" || vol.Weeks[0].Muscles[1].Sets != 3 {
+		t.Errorf("unexpected weeks: %+v", vol.Weeks)
+	}
+}
+
+func TestCardio_WeeklyDistanceAndTime(t *testing.T) {
+	h := newStatsHandler(t, "2026-01-18")
+	run, err := h.DB.CreateExercise("Run", "cardio", "", nil, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateExercise: %v", err)
+	}
+	for _, s := range []struct {
+		date     string
+		seconds  int
+		km, pace float64
+	}{
+		{"2026-01-06", 1500, 5, 300},
+		{"2026-01-08", 1800, 0, 0}, // time only, e.g. a treadmill without distance
+		{"2026-01-13", 3300, 10, 330},
+	} {
+		c := db.Cardio{DurationSeconds: &s.seconds}
+		if s.km > 0 {
+			c.DistanceKm, c.PaceSecondsPerKm = &s.km, &s.pace
+		}
+		if _, err := h.DB.CreateHistory(int(run), s.date, nil, nil, true, nil, false, nil, c); err != nil {
+			t.Fatalf("CreateHistory: %v", err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stats/cardio?from=2026-01-05", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp CardioResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	if len(resp.Weeks) != 2 || resp.Weeks[0].Sessions != 2 || resp.Weeks[0].DurationSeconds != 3300 || resp.Weeks[0].DistanceKm != 5 {
+		t.Fatalf("unexpected weeks: %+v", resp.Weeks)
+	}
+	// Pace only counts sessions with both time and distance: 1500 s / 5 km
+	if p := resp.Weeks[0].PaceSecondsPerKm; p == nil || *p != 300 {
+		t.Errorf("expected first week pace 300, got %v", p)
+	}
+	if tot := resp.Totals; tot.Sessions != 3 || tot.DistanceKm != 15 || tot.PaceSecondsPerKm == nil || *tot.PaceSecondsPerKm != 320 {
+		t.Errorf("unexpected totals: %+v", tot)
+	}
+	if len(resp.Exercises) != 1 || resp.Exercises[0].Name != "Run" {
+		t.Errorf("squat sessions without cardio fields should be left out, got %+v", resp.Exercises)
+	}
+	// The 10 km run beats the 5 km distance but not its pace
+	if tot := resp.Totals; tot.DistancePRs != 2 || tot.PacePRs != 1 || resp.Weeks[1].DistancePRs != 1 {
+		t.Errorf("unexpected record counts: %+v, week 2 %+v", tot, resp.Weeks[1])
+	}
+}
+
+func TestVolume_RangeLimitedToFiveYears(t *testing.T) {
+	h := newStatsHandler(t, "2026-01-18")
+	for query, want := range map[string]int{
+		"from=2021-01-18&to=2026-01-18": http.StatusOK,
+		"from=2021-01-17&to=2026-01-18": http.StatusBadRequest,
+		"from=2000-01-01":               http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stats/volume?"+query, nil))
+		if w.Code != want {
+			t.Errorf("%s: expected %d, got %d", query, want, w.Code)
+		}
+	}
+}
+
+func TestVolume_BodyweightTonnageUsesNetLoad(t *testing.T) {
+	h := newStatsHandler(t, "2026-01-18")
+	if _, err := h.DB.CreateMetricEntry(1, "2026-01-01", 80, nil); err != nil {
+		t.Fatalf("CreateMetricEntry: %v", err)
+	}
+	assisted, _ := h.DB.CreateExercise("Assisted Dip", "assisted", "Arms-Push", nil, nil, nil)
+	chinUp, _ := h.DB.CreateExercise("Chin-up", "bodyweight", "Arms-Pull", nil, nil, nil)
+	assistance, added := 20.0, 5.0
+	if _, err := h.DB.CreateHistory(int(assisted), "2026-01-07", &assistance, []int{8, 8}, true, nil, false, nil, db.Cardio{}); err != nil {
+		t.Fatalf("CreateHistory: %v", err)
+	}
+	if _, err := h.DB.CreateHistory(int(chinUp), "2026-01-07", &added, []int{5}, true, nil, false, nil, db.Cardio{}); err != nil {
+		t.Fatalf("CreateHistory: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stats/volume?from=2026-01-05", nil))
+	var vol VolumeResponse
+	if err := json.NewDecoder(w.Body).Decode(&vol); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	tonnage := map[string]float64{}
+	for _, e := range vol.Exercises {
+		tonnage[e.Name] = e.Tonnage
+	}
+	// (80 − 20) × 16 and (80 + 5) × 5
+	if tonnage["Assisted Dip"] != 960 || tonnage["Chin-up"] != 425 {
+		t.Errorf("unexpected bodyweight tonnage: %v", tonnage)
+	}
+}
+
+func TestStrength_ScoresAndStandards(t *testing.T) {
+	h := newStatsHandler(t, "2026-03-01")
+	squat, _ := h.DB.GetExerciseByName("Squat")
+	bench, _ := h.DB.CreateExercise("Bench Press", "weight", "Arms-Push", nil, nil, nil)
+	deadlift, _ := h.DB.CreateExercise("Competition Deadlift", "weight", "Legs-Pull", nil, nil, nil)
+	if _, err := h.DB.CreateMetricEntry(1, "2026-01-01", 100, nil); err != nil {
+		t.Fatalf("CreateMetricEntry: %v", err)
+	}
+	for _, s := range []struct {
+		id     int
+		date   string
+		weight float64
+	}{{squat.ID, "2026-01-05", 200}, {int(bench), "2026-01-05", 140}, {int(deadlift), "2026-01-07", 260}} {
+		if _, err := h.DB.CreateHistory(s.id, s.date, &s.weight, []int{1}, true, nil, false, nil, db.Cardio{}); err != nil {
+			t.Fatalf("CreateHistory: %v", err)
+		}
+	}
+	get := func() StrengthResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stats/strength", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp StrengthResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp
+	}
+
+	// The deadlift isn't named Deadlift, so there's no total until it's mapped
+	if resp := get(); resp.Total != nil || resp.Lifts[2].ExerciseID != nil || len(resp.Series) != 1 {
+		t.Fatalf("unmapped deadlift should leave no total, got %+v", resp)
+	}
+	h.DB.SetSetting(db.SettingDeadliftExercise, strconv.Itoa(int(deadlift)))
+	resp := get()
+	if resp.Total == nil || *resp.Total != 600 || resp.Wilks != nil {
+		t.Fatalf("expected a 600 total without scores before sex is set, got %+v", resp.StrengthScores)
+	}
+
+	h.DB.SetSetting(db.SettingSex, "male")
+	resp = get()
+	if *resp.Wilks != 365.2 || *resp.DOTS != 369.3 || *resp.IPFGL != 75.8 {
+		t.Errorf("unexpected scores for 600 kg at 100 kg: %+v", resp.StrengthScores)
+	}
+	var standards []string
+	for _, l := range resp.Lifts {
+		standards = append(standards, l.Standard)
+	}
+	if got := strings.Join(standards, ","); got != "intermediate,intermediate,advanced" {
+		t.Errorf("unexpected standards: %s", got)
+	}
+	if len(resp.Series) != 2 || resp.Series[0].Total != nil || resp.Series[1].Date != "2026-01-07" || *resp.Series[1].Wilks != 365.2 {
+		t.Errorf("unexpected series: %+v", resp.Series)
+	}
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+
+	"train/db"
+)
+
+// strengthDays is the default range of GET /stats/strength
+const strengthDays = 365
+
+// powerLift is one of the three lifts a powerlifting total is made of
+type powerLift struct {
+	name    string
+	setting string
+	// defaultName is the exercise scored when the setting is unset
+	defaultName string
+	field       func(*LiftSettings) **int
+}
+
+var powerLifts = []powerLift{
+	{"squat", db.SettingSquatExercise, "Squat", func(l *LiftSettings) **int { return &l.Squat }},
+	{"bench", db.SettingBenchExercise, "Bench Press", func(l *LiftSettings) **int { return &l.Bench }},
+	{"deadlift", db.SettingDeadliftExercise, "Deadlift", func(l *LiftSettings) **int { return &l.Deadlift }},
+}
+
+// strengthLevels name the strength standards, weakest first. A lift is at
+// the last level whose threshold its e1RM-to-bodyweight ratio reaches, and
+// "untrained" below the first.
+var strengthLevels = []string{"beginner", "novice", "intermediate", "advanced", "elite"}
+
+// strengthStandards are the ratio thresholds of each level, by sex and lift
+var strengthStandards = map[string]map[string][]float64{
+	"male": {
+		"squat":    {0.75, 1.25, 1.5, 2.25, 2.75},
+		"bench":    {0.5, 1, 1.25, 1.75, 2},
+		"deadlift": {1, 1.5, 2, 2.5, 3},
+	},
+	"female": {
+		"squat":    {0.5, 0.75, 1.25, 1.5, 2},
+		"bench":    {0.25, 0.5, 0.75, 1, 1.5},
+		"deadlift": {0.5, 1, 1.25, 1.75, 2.5},
+	},
+}
+
+// strengthStandard classifies a lift by its e1RM-to-bodyweight ratio
+func strengthStandard(sex, lift string, ratio float64) string {
+	level := "untrained"
+	for i, threshold := range strengthStandards[sex][lift] {
+		if ratio >= threshold {
+			level = strengthLevels[i]
+		}
+	}
+	return level
+}
+
+// Wilks (2017 and earlier) and DOTS polynomial coefficients, lowest order
+// first, and the bodyweight range each formula is defined over
+var (
+	wilksCoefficients = map[string][]float64{
+		"male":   {-216.0475144, 16.2606339, -0.002388645, -0.00113732, 7.01863e-06, -1.291e-08},
+		"female": {594.31747775582, -27.23842536447, 0.82112226871, -0.00930733913, 4.731582e-05, -9.054e-08},
+	}
+	wilksBodyweight = map[string][2]float64{"male": {40, 201.9}, "female": {26.51, 154.53}}
+
+	dotsCoefficients = map[string][]float64{
+		"male":   {-307.75076, 24.0900756, -0.1918759221, 0.0007391293, -0.000001093},
+		"female": {-57.96288, 13.6175032, -0.1126655495, 0.0005158568, -0.0000010706},
+	}
+	dotsBodyweight = map[string][2]float64{"male": {40, 210}, "female": {40, 150}}
+
+	// ipfGLCoefficients are A, B and C of the IPF GL formula for classic
+	// (raw) powerlifting
+	ipfGLCoefficients = map[string][3]float64{
+		"male":   {1199.72839, 1025.18162, 0.00921},
+		"female": {610.32796, 1045.59282, 0.03048},
+	}
+)
+
+// polynomial evaluates the coefficients at x
+func polynomial(coefficients []float64, x float64) float64 {
+	sum, power := 0.0, 1.0
+	for _, c := range coefficients {
+		sum += c * power
+		power *= x
+	}
+	return sum
+}
+
+// clamp limits bodyweight to a formula's range
+func clamp(bodyweight float64, limits [2]float64) float64 {
+	return min(max(bodyweight, limits[0]), limits[1])
+}
+
+// strengthScores scores a total lifted at bodyweight with the Wilks, DOTS
+// and IPF GL formulas
+func strengthScores(sex string, bodyweight, total float64) StrengthScores {
+	wilks := total * 500 / polynomial(wilksCoefficients[sex], clamp(bodyweight, wilksBodyweight[sex]))
+	dots := total * 500 / polynomial(dotsCoefficients[sex], clamp(bodyweight, dotsBodyweight[sex]))
+	gl := ipfGLCoefficients[sex]
+	ipfGL := total * 100 / (gl[0] - gl[1]*math.Exp(-gl[2]*bodyweight))
+	return StrengthScores{Total: roundTenth(total), Wilks: roundTenth(wilks), DOTS: roundTenth(dots), IPFGL: roundTenth(ipfGL)}
+}
+
+// scoreLifts totals the lifts' best e1RMs and scores the total. The total
+// needs all three lifts, and the scores a bodyweight and sex as well.
+func scoreLifts(sex *string, bodyweight *float64, best []*float64) StrengthScores {
+	total := 0.0
+	for _, b := range best {
+		if b == nil {
+			return StrengthScores{}
+		}
+		total += *b
+	}
+	if sex == nil || bodyweight == nil {
+		return StrengthScores{Total: roundTenth(total)}
+	}
+	return strengthScores(*sex, *bodyweight, total)
+}
+
+// strengthExercise resolves the exercise scored as lift: the one set in the
+// settings, or else the one with the lift's default name. It is nil when
+// there is neither.
+func (h *StatsHandler) strengthExercise(lift powerLift, settings Settings) (*db.Exercise, *db.ExerciseType, error) {
+	var ex *db.Exercise
+	var err error
+	if id := *lift.field(&settings.Lifts); id != nil {
+		ex, err = h.DB.GetExerciseByID(*id)
+	}
+	if ex == nil && err == nil {
+		ex, err = h.DB.GetExerciseByName(lift.defaultName)
+	}
+	if ex == nil || err != nil {
+		return nil, nil, err
+	}
+	t, err := h.DB.GetExerciseType(ex.Type)
+	if t == nil || err != nil {
+		return nil, nil, err
+	}
+	return ex, t, nil
+}
+
+// getStrength scores the squat, bench and deadlift relative to bodyweight.
+// Each lift's best is the highest estimated one-rep max (Epley) of any
+// session up to that date. The series has a point for every date in range
+// on which one of the lifts was trained.
+func (h *StatsHandler) getStrength(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := dateRange(w, r, h.today(), strengthDays)
+	if !ok {
+		return
+	}
+	settings, err := loadSettings(h.DB)
+	if err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+
+	resp := StrengthResponse{From: from, To: to, Sex: settings.Sex, Lifts: []LiftStrength{}, Series: []StrengthPoint{}}
+	types := map[int]*db.ExerciseType{}
+	liftOf := map[int]int{}
+	var ids []interface{}
+	for i, lift := range powerLifts {
+		ex, t, err := h.strengthExercise(lift, settings)
+		if err != nil {
+			internalError(w, r, "Database error", err)
+			return
+		}
+		l := LiftStrength{Lift: lift.name}
+		if ex != nil {
+			l.ExerciseID, l.ExerciseName = &ex.ID, &ex.Name
+			types[ex.ID], liftOf[ex.ID] = t, i
+			ids = append(ids, ex.ID)
+		}
+		resp.Lifts = append(resp.Lifts, l)
+	}
+	if resp.Bodyweight, err = h.DB.BodyweightOn(to); err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+
+	best := make([]*float64, len(powerLifts))
+	if len(ids) > 0 {
+		type session struct {
+			date               string
+			exerciseID         int
+			weight, bodyweight *float64
+			sets               []int
+		}
+		var sessions []session
+		rows, err := h.DB.Query(`
+			SELECT CAST(h.session_date AS TEXT), h.exercise_id, h.weight, h.sets_completed, `+db.BodyweightSQL("h.session_date")+`
+			FROM history h
+			WHERE h.exercise_id IN (?`+strings.Repeat(", ?", len(ids)-1)+`) AND h.session_date <= ?
+			ORDER BY h.session_date, h.id
+		`, append(ids, to)...)
+		if err != nil {
+			internalError(w, r, "Database error", err)
+			return
+		}
+		for rows.Next() {
+			var s session
+			var setsJSON string
+			if err := rows.Scan(&s.date, &s.exerciseID, &s.weight, &setsJSON, &s.bodyweight); err != nil {
+				rows.Close()
+				internalError(w, r, "Scan error", err)
+				return
+			}
+			json.Unmarshal([]byte(setsJSON), &s.sets)
+			sessions = append(sessions, s)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			internalError(w, r, "Database error", err)
+			return
+		}
+
+		for i, s := range sessions {
+			lift := liftOf[s.exerciseID]
+			e1rm := sessionLoad(types[s.exerciseID], s.weight, s.bodyweight, s.sets).E1RM
+			if e1rm != nil && (best[lift] == nil || *e1rm > *best[lift]) {
+				best[lift] = e1rm
+				resp.Lifts[lift].E1RM, resp.Lifts[lift].Date = e1rm, &s.date
+			}
+			// One point per date, after its last session
+			if s.date < from || (i+1 < len(sessions) && sessions[i+1].date == s.date) {
+				continue
+			}
+			resp.Series = append(resp.Series, StrengthPoint{
+				Date: s.date, Bodyweight: s.bodyweight,
+				Squat: best[0], Bench: best[1], Deadlift: best[2],
+				StrengthScores: scoreLifts(settings.Sex, s.bodyweight, best),
+			})
+		}
+	}
+
+	resp.StrengthScores = scoreLifts(settings.Sex, resp.Bodyweight, best)
+	if resp.Bodyweight != nil {
+		for i := range resp.Lifts {
+			l := &resp.Lifts[i]
+			if l.E1RM == nil {
+				continue
+			}
+			ratio := math.Round(*l.E1RM / *resp.Bodyweight * 100) / 100
+			l.BodyweightRatio = &ratio
+			if settings.Sex != nil {
+				l.Standard = strengthStandard(*settings.Sex, l.Lift, *l.BodyweightRatio)
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
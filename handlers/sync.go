@@ -0,0 +1,622 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"train/db"
+)
+
+// SyncHandler serves the offline sync protocol. Devices record their edits
+// to history, routines and metric entries while offline and push them as a
+// change log; the response carries every server-side change since their
+// last sync, so all devices converge on the server's copy.
+//
+// Conflicts are resolved the same way for every device:
+//   - deletes win: a deleted record is never brought back by an edit
+//   - an edit applies only on top of the version it was made from; an edit
+//     of an older version loses to the one already on the server, which the
+//     device then adopts from the response
+//
+// Changes in one request apply in order, each on its own, so one invalid
+// record doesn't hold back the rest.
+type SyncHandler struct {
+	DB *db.DB
+	// Events is notified after each change; nil disables notifications
+	Events *Hub
+}
+
+// maxSyncChanges caps the change log accepted in one request
+const maxSyncChanges = 1000
+
+// syncCursorLayout formats cursors the way db.SyncNow does, so they compare
+// correctly against updated_at as strings
+const syncCursorLayout = "2006-01-02T15:04:05.000Z"
+
+// routes mounts the sync endpoint
+func (h *SyncHandler) routes(rt *router) {
+	rt.handle(http.MethodPost, "/sync", h.sync)
+}
+
+// ServeHTTP serves the sync endpoint on its own
+func (h *SyncHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveRoutes(w, r, h.routes)
+}
+
+// sync applies a device's change log and returns the server's changes
+func (h *SyncHandler) sync(w http.ResponseWriter, r *http.Request) {
+	var req SyncRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		invalidJSON(w, r)
+		return
+	}
+
+	since := ""
+	if req.Since != "" {
+		t, err := time.Parse(time.RFC3339Nano, req.Since)
+		if err != nil {
+			fieldError(w, r, "since", "since must be a cursor from a previous sync")
+			return
+		}
+		since = t.UTC().Format(syncCursorLayout)
+	}
+	if len(req.Changes) > maxSyncChanges {
+		fieldError(w, r, "changes", "changes cannot hold more than "+strconv.Itoa(maxSyncChanges)+" entries")
+		return
+	}
+	for i, c := range req.Changes {
+		field := "changes[" + strconv.Itoa(i) + "]"
+		switch {
+		case !slices.Contains(db.SyncTables, c.Entity):
+			fieldError(w, r, field+".entity", "entity must be one of "+strings.Join(db.SyncTables, ", "))
+			return
+		case !clientIDPattern.MatchString(c.ClientID):
+			fieldError(w, r, field+".client_id", "client_id must be 1-64 letters, digits, dashes or underscores")
+			return
+		case c.Op != "upsert" && c.Op != "delete":
+			fieldError(w, r, field+".op", "op must be upsert or delete")
+			return
+		case c.BaseVersion < 0:
+			fieldError(w, r, field+".base_version", "base_version cannot be negative")
+			return
+		case c.Op == "upsert" && len(c.Data) == 0:
+			fieldError(w, r, field+".data", "data is required for an upsert")
+			return
+		}
+	}
+
+	resp := SyncResponse{Results: []SyncResult{}}
+	// Conflicting records go back to the device whatever their timestamp
+	conflicts := map[string][]string{}
+	scope := goalScope{}
+	for _, c := range req.Changes {
+		result, err := h.apply(r.Context(), c, &scope)
+		if err != nil {
+			internalError(w, r, "Failed to apply change", err)
+			return
+		}
+		if result.Status == "conflict" {
+			conflicts[c.Entity] = append(conflicts[c.Entity], c.ClientID)
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	for _, result := range resp.Results {
+		if result.Status == "applied" {
+			// Synced sessions and measurements can meet goals too
+			refreshBodyweightPRs(r.Context(), h.DB, scope.metricTypeIDs...)
+			checkGoals(r.Context(), h.DB, h.Events, scope)
+			h.Events.Publish(Event{Type: "sync.applied"})
+			break
+		}
+	}
+
+	// Anything written from here on gets an updated_at at or after the
+	// cursor, and changes are read with >=, so the next sync can't miss it
+	if err := h.DB.QueryRow(`SELECT ` + db.SyncNow).Scan(&resp.Cursor); err != nil {
+		internalError(w, r, "Database error", err)
+		return
+	}
+	var err error
+	if resp.History, err = h.changedHistory(since, conflicts["history"]); err != nil {
+		internalError(w, r, "Failed to load history changes", err)
+		return
+	}
+	if resp.Routines, err = h.changedRoutines(since, conflicts["routines"]); err != nil {
+		internalError(w, r, "Failed to load routine changes", err)
+		return
+	}
+	if resp.MetricEntries, err = h.changedMetricEntries(since, conflicts["metric_entries"]); err != nil {
+		internalError(w, r, "Failed to load metric entry changes", err)
+		return
+	}
+	if resp.Deleted, err = h.tombstones(since, conflicts); err != nil {
+		internalError(w, r, "Failed to load deletes", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// syncRow holds the columns and values an upsert writes
+type syncRow struct {
+	columns []string
+	args    []interface{}
+	// prExerciseID is set when the row is a new PR of that exercise, whose
+	// other sessions lose the flag once the row is written
+	prExerciseID int
+	// pr holds a history row's values that decide PRs
+	pr *prFields
+	// goals are the goals writing the row can meet
+	goals goalScope
+}
+
+// querier is the part of *db.DB and *sql.Tx that apply reads through
+type querier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// apply applies one change and reports the outcome. Only database failures
+// are returned as errors. An upsert is checked and decoded first, since that
+// reads other tables; the write then runs in one transaction with a second
+// tombstone check, so a delete that lands in between still wins. A history
+// edit or delete that changes what decides PRs has the flags worked out
+// again after the commit; a failure there is logged, not returned. A
+// written row adds the goals it can meet to scope.
+func (h *SyncHandler) apply(ctx context.Context, c SyncChange, scope *goalScope) (SyncResult, error) {
+	result := SyncResult{Entity: c.Entity, ClientID: c.ClientID}
+	if deleted, err := h.deleted(h.DB, c, &result); err != nil || deleted {
+		return result, err
+	}
+
+	var row syncRow
+	var current int
+	exists := false
+	if c.Op == "upsert" {
+		err := h.DB.QueryRow(`SELECT version FROM `+c.Entity+` WHERE client_id = ?`, c.ClientID).Scan(&current)
+		if err != nil && err != sql.ErrNoRows {
+			return result, err
+		}
+		exists = err == nil
+		if exists && c.BaseVersion != current {
+			result.Status, result.Reason, result.Version = "conflict", "stale", current
+			return result, nil
+		}
+		var reason string
+		if row, reason, err = h.decode(c.Entity, c.Data, exists); err != nil {
+			return result, err
+		}
+		if reason != "" {
+			result.Status, result.Reason = "rejected", reason
+			return result, nil
+		}
+	}
+
+	// A deleted measurement can change the bodyweight and goals too
+	if c.Op == "delete" && c.Entity == "metric_entries" {
+		var metricTypeID int
+		err := h.DB.QueryRow(`SELECT metric_type_id FROM metric_entries WHERE client_id = ?`, c.ClientID).Scan(&metricTypeID)
+		if err != nil && err != sql.ErrNoRows {
+			return result, err
+		}
+		if err == nil {
+			row.goals = metricScope(metricTypeID)
+		}
+	}
+
+	// Edited and deleted sessions can hand their PR flag on
+	var before *prFields
+	if (exists && row.pr != nil) || (c.Op == "delete" && c.Entity == "history") {
+		var err error
+		if before, err = sessionPRFields(h.DB, c.ClientID); err != nil {
+			return result, err
+		}
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+	if deleted, err := h.deleted(tx, c, &result); err != nil || deleted {
+		return result, err
+	}
+	if result, err = h.write(tx, c, row, exists, current, result); err != nil || result.Status != "applied" {
+		return result, err
+	}
+	if err := tx.Commit(); err != nil {
+		return result, err
+	}
+	scope.add(row.goals)
+	// The change is committed and applied whatever happens to the flags, so
+	// a failure here is logged rather than failing the batch, whose retry
+	// would conflict with its own write
+	if before != nil {
+		history := &HistoryHandler{DB: h.DB}
+		if row.pr == nil {
+			err = history.refreshPR(before.exerciseID)
+		} else {
+			err = history.refreshPRs(before, *row.pr)
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to update PR flags", "client_id", c.ClientID, "err", err)
+		}
+	}
+	return result, nil
+}
+
+// deleted reports whether c's record has a tombstone and, if so, fills in
+// the result: a delete of it is applied again, an upsert conflicts
+func (h *SyncHandler) deleted(q querier, c SyncChange, result *SyncResult) (bool, error) {
+	err := q.QueryRow(`SELECT version FROM sync_tombstones WHERE entity = ? AND client_id = ?`, c.Entity, c.ClientID).Scan(&result.Version)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if c.Op == "delete" {
+		result.Status = "applied"
+	} else {
+		result.Status, result.Reason = "conflict", "deleted"
+	}
+	return true, nil
+}
+
+// write makes c's change within tx
+func (h *SyncHandler) write(tx *sql.Tx, c SyncChange, row syncRow, exists bool, current int, result SyncResult) (SyncResult, error) {
+	if c.Op == "delete" {
+		// The delete trigger leaves the tombstone; deleting a record the
+		// server never saw is a no-op
+		if _, err := tx.Exec(`DELETE FROM `+c.Entity+` WHERE client_id = ?`, c.ClientID); err != nil {
+			return result, err
+		}
+		tx.QueryRow(`SELECT version FROM sync_tombstones WHERE entity = ? AND client_id = ?`, c.Entity, c.ClientID).Scan(&result.Version)
+		result.Status = "applied"
+		return result, nil
+	}
+	columns, args := row.columns, row.args
+
+	if !exists {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+		res, err := tx.Exec(`INSERT INTO `+c.Entity+` (client_id, version, updated_at, `+strings.Join(columns, ", ")+`)
+			VALUES (?, 1, `+db.SyncNow+`, `+placeholders+`)`, append([]interface{}{c.ClientID}, args...)...)
+		if err != nil {
+			return h.failed(result, err)
+		}
+		if row.prExerciseID != 0 {
+			id, err := res.LastInsertId()
+			if err != nil {
+				return result, err
+			}
+			if err := db.ClearOtherPRs(tx, row.prExerciseID, id); err != nil {
+				return result, err
+			}
+		}
+		result.Status, result.Version = "applied", 1
+		return result, nil
+	}
+
+	// Compare-and-set on the version, so a write that slipped in since the
+	// check in apply still wins
+	res, err := tx.Exec(`UPDATE `+c.Entity+` SET `+strings.Join(columns, " = ?, ")+` = ?, version = version + 1, updated_at = `+db.SyncNow+`
+		WHERE client_id = ? AND version = ?`, append(args, c.ClientID, current)...)
+	if err != nil {
+		return h.failed(result, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		tx.QueryRow(`SELECT version FROM `+c.Entity+` WHERE client_id = ?`, c.ClientID).Scan(&result.Version)
+		result.Status, result.Reason = "conflict", "stale"
+		return result, nil
+	}
+	result.Status, result.Version = "applied", current+1
+	return result, nil
+}
+
+// failed turns a constraint violation into a rejected result
+func (h *SyncHandler) failed(result SyncResult, err error) (SyncResult, error) {
+	switch msg := err.Error(); {
+	case strings.Contains(msg, "UNIQUE constraint failed: routines.day_of_week"):
+		result.Status, result.Reason = "rejected", "order_index is taken on that day"
+	case strings.Contains(msg, "constraint failed"):
+		result.Status, result.Reason = "rejected", "violates a constraint"
+	default:
+		return result, err
+	}
+	return result, nil
+}
+
+// decode validates upsert data and returns the columns to write, or the
+// reason the data is rejected. Records are replaced whole, so the data holds
+// the same fields as a create.
+func (h *SyncHandler) decode(entity string, data json.RawMessage, exists bool) (syncRow, string, error) {
+	switch entity {
+	case "history":
+		return h.decodeHistory(data, exists)
+	case "routines":
+		return h.decodeRoutine(data, exists)
+	default:
+		return h.decodeMetricEntry(data)
+	}
+}
+
+func (h *SyncHandler) decodeHistory(data json.RawMessage, exists bool) (syncRow, string, error) {
+	var d struct {
+		ExerciseID    int      `json:"exercise_id"`
+		SessionDate   string   `json:"session_date"`
+		Weight        *float64 `json:"weight"`
+		SetsCompleted []int    `json:"sets_completed"`
+		Completed     bool     `json:"completed"`
+		Volume        *float64 `json:"volume"`
+		Notes         *string  `json:"notes"`
+		db.Cardio
+	}
+	if err := json.Unmarshal(data, &d); err != nil {
+		return syncRow{}, "data is not a valid history entry", nil
+	}
+	if !isDate(d.SessionDate) {
+		return syncRow{}, "session_date must be a YYYY-MM-DD date", nil
+	}
+	if d.Weight != nil && *d.Weight < 0 {
+		return syncRow{}, "weight cannot be negative", nil
+	}
+	for _, reps := range d.SetsCompleted {
+		if reps < 0 {
+			return syncRow{}, "sets_completed cannot contain negative values", nil
+		}
+	}
+	if _, msg := validateCardio(d.Cardio); msg != "" {
+		return syncRow{}, msg, nil
+	}
+	exercise, err := h.DB.GetExerciseByID(d.ExerciseID)
+	if err != nil {
+		return syncRow{}, "", err
+	}
+	if exercise == nil {
+		return syncRow{}, "Exercise not found", nil
+	}
+
+	row := historyRow(d.ExerciseID, d.SessionDate, d.Weight, d.SetsCompleted, d.Completed, d.Volume, d.Notes, withPace(d.Cardio))
+
+	// A session logged offline can still be a PR; edits that change what
+	// decides PRs have the flags worked out again once written (see apply)
+	if !exists {
+		exType, err := h.DB.GetExerciseType(exercise.Type)
+		if err != nil {
+			return syncRow{}, "", err
+		}
+		if exType == nil {
+			return syncRow{}, "", fmt.Errorf("exercise type %q not found", exercise.Type)
+		}
+		isPR, err := (&HistoryHandler{DB: h.DB}).checkPR(exType, d.ExerciseID, d.SessionDate, d.Weight, d.Volume, d.SetsCompleted)
+		if err != nil {
+			return syncRow{}, "", err
+		}
+		row.columns = append(row.columns, "is_pr")
+		row.args = append(row.args, isPR)
+		if isPR {
+			row.prExerciseID = d.ExerciseID
+		}
+	}
+	return row, "", nil
+}
+
+func (h *SyncHandler) decodeRoutine(data json.RawMessage, exists bool) (syncRow, string, error) {
+	var d struct {
+		ExerciseID int     `json:"exercise_id"`
+		DayOfWeek  string  `json:"day_of_week"`
+		OrderIndex *int    `json:"order_index"`
+		Notes      *string `json:"notes"`
+	}
+	if err := json.Unmarshal(data, &d); err != nil {
+		return syncRow{}, "data is not a valid routine", nil
+	}
+	if !isWeekDay(d.DayOfWeek) {
+		return syncRow{}, "Invalid day of week", nil
+	}
+	exercise, err := h.DB.GetExerciseByID(d.ExerciseID)
+	if err != nil {
+		return syncRow{}, "", err
+	}
+	if exercise == nil {
+		return syncRow{}, "Exercise not found", nil
+	}
+
+	columns := []string{"exercise_id", "day_of_week", "notes"}
+	args := []interface{}{d.ExerciseID, d.DayOfWeek, d.Notes}
+	switch {
+	case d.OrderIndex != nil:
+		columns = append(columns, "order_index")
+		args = append(args, *d.OrderIndex)
+	case !exists:
+		// New routines without a position go last, as with POST /routines
+		var maxOrder int
+		if err := h.DB.QueryRow("SELECT COALESCE(MAX(order_index), -1) FROM routines WHERE day_of_week = ?", d.DayOfWeek).Scan(&maxOrder); err != nil {
+			return syncRow{}, "", err
+		}
+		columns = append(columns, "order_index")
+		args = append(args, maxOrder+1)
+	}
+	return syncRow{columns: columns, args: args}, "", nil
+}
+
+func (h *SyncHandler) decodeMetricEntry(data json.RawMessage) (syncRow, string, error) {
+	var d struct {
+		MetricTypeID int      `json:"metric_type_id"`
+		EntryDate    string   `json:"entry_date"`
+		Value        *float64 `json:"value"`
+		Notes        *string  `json:"notes"`
+	}
+	if err := json.Unmarshal(data, &d); err != nil {
+		return syncRow{}, "data is not a valid metric entry", nil
+	}
+	if !isDate(d.EntryDate) {
+		return syncRow{}, "entry_date must be a YYYY-MM-DD date", nil
+	}
+	if d.Value == nil || math.IsNaN(*d.Value) || math.IsInf(*d.Value, 0) {
+		return syncRow{}, "value must be a finite number", nil
+	}
+	mt, err := h.DB.GetMetricType(d.MetricTypeID)
+	if err != nil {
+		return syncRow{}, "", err
+	}
+	if mt == nil {
+		return syncRow{}, "Metric type not found", nil
+	}
+	if mt.Formula != nil {
+		return syncRow{}, "Computed metrics are read-only", nil
+	}
+	if msg := outOfLimits(*mt, *d.Value); msg != "" {
+		return syncRow{}, msg, nil
+	}
+	return syncRow{
+		columns: []string{"metric_type_id", "entry_date", "value", "notes"},
+		args:    []interface{}{d.MetricTypeID, d.EntryDate, *d.Value, d.Notes},
+		goals:   metricScope(d.MetricTypeID),
+	}, "", nil
+}
+
+// changedSince is the WHERE clause selecting records changed since the
+// cursor plus the listed client ids
+func changedSince(since string, clientIDs []string) (string, []interface{}) {
+	ids, _ := json.Marshal(clientIDs)
+	return ` WHERE (updated_at >= ? OR client_id IN (SELECT value FROM json_each(?))) ORDER BY updated_at, id`,
+		[]interface{}{since, string(ids)}
+}
+
+func (h *SyncHandler) changedHistory(since string, clientIDs []string) ([]SyncHistory, error) {
+	where, args := changedSince(since, clientIDs)
+	rows, err := h.DB.Query(`SELECT client_id, version, updated_at, id, exercise_id, CAST(session_date AS TEXT), weight,
+		sets_completed, completed, volume, is_pr, notes, `+db.CardioColumns+`, `+db.CardioPRSQL("history")+` FROM history`+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []SyncHistory{}
+	for rows.Next() {
+		var rec SyncHistory
+		var sets string
+		fields := []interface{}{&rec.ClientID, &rec.Version, &rec.UpdatedAt, &rec.ID, &rec.ExerciseID, &rec.SessionDate, &rec.Weight,
+			&sets, &rec.Completed, &rec.Volume, &rec.IsPR, &rec.Notes}
+		if err := rows.Scan(append(append(fields, rec.Cardio.ScanFields()...), rec.CardioPRs.ScanFields()...)...); err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(sets), &rec.SetsCompleted)
+		if rec.SetsCompleted == nil {
+			rec.SetsCompleted = []int{}
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+func (h *SyncHandler) changedRoutines(since string, clientIDs []string) ([]SyncRoutine, error) {
+	where, args := changedSince(since, clientIDs)
+	rows, err := h.DB.Query(`SELECT client_id, version, updated_at, id, exercise_id, day_of_week, order_index, notes FROM routines`+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []SyncRoutine{}
+	for rows.Next() {
+		var rec SyncRoutine
+		if err := rows.Scan(&rec.ClientID, &rec.Version, &rec.UpdatedAt, &rec.ID, &rec.ExerciseID, &rec.DayOfWeek, &rec.OrderIndex, &rec.Notes); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+func (h *SyncHandler) changedMetricEntries(since string, clientIDs []string) ([]SyncMetricEntry, error) {
+	where, args := changedSince(since, clientIDs)
+	rows, err := h.DB.Query(`SELECT client_id, version, updated_at, id, metric_type_id, CAST(entry_date AS TEXT), value, notes,
+		CAST(created_at AS TEXT) FROM metric_entries`+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []SyncMetricEntry{}
+	for rows.Next() {
+		var rec SyncMetricEntry
+		if err := rows.Scan(&rec.ClientID, &rec.Version, &rec.UpdatedAt, &rec.ID, &rec.MetricTypeID, &rec.EntryDate, &rec.Value, &rec.Notes, &rec.CreatedAt); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+// tombstones returns the deletes since the cursor plus those of records a
+// device tried to edit after they were deleted
+func (h *SyncHandler) tombstones(since string, conflicts map[string][]string) ([]SyncTombstone, error) {
+	keys := []string{}
+	for entity, ids := range conflicts {
+		for _, id := range ids {
+			keys = append(keys, entity+"/"+id)
+		}
+	}
+	ids, _ := json.Marshal(keys)
+	rows, err := h.DB.Query(`
+		SELECT entity, client_id, version, deleted_at
+		FROM sync_tombstones
+		WHERE deleted_at >= ? OR entity || '/' || client_id IN (SELECT value FROM json_each(?))
+		ORDER BY deleted_at, entity, client_id
+	`, since, string(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deleted := []SyncTombstone{}
+	for rows.Next() {
+		var t SyncTombstone
+		if err := rows.Scan(&t.Entity, &t.ClientID, &t.Version, &t.DeletedAt); err != nil {
+			return nil, err
+		}
+		deleted = append(deleted, t)
+	}
+	return deleted, rows.Err()
+}
+
+// isDeleted reports whether a record with clientID was deleted from table
+func isDeleted(database *db.DB, table, clientID string) (bool, error) {
+	var n int
+	err := database.QueryRow(`SELECT COUNT(*) FROM sync_tombstones WHERE entity = ? AND client_id = ?`, table, clientID).Scan(&n)
+	return n > 0, err
+}
+
+// upsertByClientID writes the column values to the row of table with
+// clientID and returns its id, or found = false if there is no such row. A
+// row that already holds the values is left alone, so a retried request
+// doesn't bump its sync version.
+func upsertByClientID(database *db.DB, table, clientID string, columns []string, args []interface{}) (id int64, found bool, err error) {
+	err = database.QueryRow(`SELECT id FROM `+table+` WHERE client_id = ?`, clientID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	changed := make([]string, len(columns))
+	for i, c := range columns {
+		changed[i] = c + " IS NOT ?"
+	}
+	query := `UPDATE ` + table + ` SET ` + strings.Join(columns, " = ?, ") + ` = ? WHERE id = ? AND (` + strings.Join(changed, " OR ") + `)`
+	params := append(append(append([]interface{}{}, args...), id), args...)
+	if _, err := database.Exec(query, params...); err != nil {
+		return 0, false, err
+	}
+	return id, true, nil
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"train/db"
+)
+
+// newSyncHandler creates a SyncHandler over a database holding a Squat
+func newSyncHandler(t *testing.T) (*SyncHandler, int) {
+	t.Helper()
+	database, err := db.OpenForTesting()
+	if err != nil {
+		t.Fatalf("OpenForTesting: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	id, err := database.CreateExercise("Squat", "weight", "Legs-Push", nil, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateExercise: %v", err)
+	}
+	return &SyncHandler{DB: database}, int(id)
+}
+
+func postSync(t *testing.T, h *SyncHandler, body string) SyncResponse {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/sync", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp SyncResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return resp
+}
+
+func TestSync_DevicesConvergeOnServerCopy(t *testing.T) {
+	h, squat := newSyncHandler(t)
+	data := `{"exercise_id":` + strconv.Itoa(squat) + `,"session_date":"2026-01-12","weight":100,"sets_completed":[5,5,5],"completed":true}`
+
+	// Phone logs a session offline and pushes it
+	phone := postSync(t, h, `{"changes":[{"entity":"history","client_id":"a1","op":"upsert","data":`+data+`}]}`)
+	if r := phone.Results[0]; r.Status != "applied" || r.Version != 1 {
+		t.Fatalf("unexpected result: %+v", r)
+	}
+	if len(phone.History) != 1 || !phone.History[0].IsPR || phone.History[0].ClientID != "a1" {
+		t.Fatalf("expected the new PR session back, got %+v", phone.History)
+	}
+
+	// Tablet pulls it, then both edit version 1 while offline
+	tablet := postSync(t, h, `{}`)
+	if len(tablet.History) != 1 || tablet.History[0].Version != 1 {
+		t.Fatalf("tablet should see the session, got %+v", tablet.History)
+	}
+	edit := strings.Replace(data, `"weight":100`, `"weight":%s`, 1)
+	first := postSync(t, h, `{"since":"`+phone.Cursor+`","changes":[{"entity":"history","client_id":"a1","op":"upsert","base_version":1,"data":`+
+		strings.Replace(edit, "%s", "105", 1)+`}]}`)
+	if r := first.Results[0]; r.Status != "applied" || r.Version != 2 {
+		t.Fatalf("first edit should apply, got %+v", r)
+	}
+	second := postSync(t, h, `{"since":"`+tablet.Cursor+`","changes":[{"entity":"history","client_id":"a1","op":"upsert","base_version":1,"data":`+
+		strings.Replace(edit, "%s", "110", 1)+`}]}`)
+	if r := second.Results[0]; r.Status != "conflict" || r.Reason != "stale" || r.Version != 2 {
+		t.Fatalf("stale edit should conflict, got %+v", r)
+	}
+	if len(second.History) != 1 || *second.History[0].Weight != 105 {
+		t.Errorf("conflict should return the winning copy, got %+v", second.History)
+	}
+
+	// Edits made through the REST API bump the version too
+	if _, err := h.DB.Exec(`UPDATE history SET notes = 'felt heavy' WHERE client_id = 'a1'`); err != nil {
+		t.Fatal(err)
+	}
+	later := postSync(t, h, `{"since":"`+second.Cursor+`"}`)
+	if len(later.History) != 1 || later.History[0].Version != 3 {
+		t.Errorf("expected version 3 after a REST edit, got %+v", later.History)
+	}
+}
+
+func TestSync_DeletesWinAndPropagate(t *testing.T) {
+	h, squat := newSyncHandler(t)
+	id, err := h.DB.CreateRoutine(squat, "Monday", 0, nil)
+	if err != nil {
+		t.Fatalf("CreateRoutine: %v", err)
+	}
+	var clientID string
+	h.DB.QueryRow(`SELECT client_id FROM routines WHERE id = ?`, id).Scan(&clientID)
+	if clientID == "" {
+		t.Fatal("rows created outside sync should get a client_id")
+	}
+
+	start := postSync(t, h, `{}`)
+	if _, err := h.DB.Exec(`DELETE FROM routines WHERE id = ?`, id); err != nil {
+		t.Fatal(err)
+	}
+
+	// An offline edit of the deleted routine loses to the delete
+	resp := postSync(t, h, `{"since":"`+start.Cursor+`","changes":[{"entity":"routines","client_id":"`+clientID+
+		`","op":"upsert","base_version":1,"data":{"exercise_id":`+strconv.Itoa(squat)+`,"day_of_week":"Monday","notes":"slow"}}]}`)
+	if r := resp.Results[0]; r.Status != "conflict" || r.Reason != "deleted" {
+		t.Fatalf("edit of a deleted routine should conflict, got %+v", r)
+	}
+	if len(resp.Deleted) != 1 || resp.Deleted[0].ClientID != clientID || resp.Deleted[0].Entity != "routines" {
+		t.Errorf("expected the tombstone, got %+v", resp.Deleted)
+	}
+	if len(resp.Routines) != 0 {
+		t.Errorf("deleted routine must not come back, got %+v", resp.Routines)
+	}
+
+	// Deleting through sync is idempotent
+	for range 2 {
+		resp = postSync(t, h, `{"changes":[{"entity":"metric_entries","client_id":"m1","op":"upsert","data":{"metric_type_id":1,"entry_date":"2026-01-12","value":80}},
+			{"entity":"metric_entries","client_id":"m1","op":"delete"}]}`)
+		if resp.Results[1].Status != "applied" {
+			t.Errorf("delete should apply, got %+v", resp.Results[1])
+		}
+	}
+	if len(resp.MetricEntries) != 0 || len(resp.Deleted) != 2 {
+		t.Errorf("expected two tombstones and no entries, got %+v / %+v", resp.MetricEntries, resp.Deleted)
+	}
+}
+
+func TestSync_PRFlagsFollowWrites(t *testing.T) {
+	h, squat := newSyncHandler(t)
+	session := func(clientID string, weight int) string {
+		return `{"entity":"history","client_id":"` + clientID + `","op":"upsert","data":{"exercise_id":` + strconv.Itoa(squat) +
+			`,"session_date":"2026-01-12","weight":` + strconv.Itoa(weight) + `,"sets_completed":[5],"completed":true}}`
+	}
+	postSync(t, h, `{"changes":[`+session("a1", 100)+`]}`)
+
+	// A heavier session whose insert fails must not take the flag
+	if _, err := h.DB.Exec(`CREATE TRIGGER refuse BEFORE INSERT ON history WHEN NEW.weight > 200
+		BEGIN SELECT RAISE(ABORT, 'CHECK constraint failed: weight'); END`); err != nil {
+		t.Fatal(err)
+	}
+	resp := postSync(t, h, `{"changes":[`+session("a2", 250)+`]}`)
+	if r := resp.Results[0]; r.Status != "rejected" {
+		t.Fatalf("expected the insert to be rejected, got %+v", r)
+	}
+	prs := func() string {
+		var ids string
+		h.DB.QueryRow(`SELECT group_concat(client_id) FROM history WHERE is_pr = 1`).Scan(&ids)
+		return ids
+	}
+	if got := prs(); got != "a1" {
+		t.Errorf("the earlier session should stay the PR, got %q", got)
+	}
+
+	// A PR that is written takes the flag
+	resp = postSync(t, h, `{"changes":[`+session("a3", 150)+`]}`)
+	if r := resp.Results[0]; r.Status != "applied" {
+		t.Fatalf("expected the PR to apply, got %+v", r)
+	}
+	if got := prs(); got != "a3" {
+		t.Errorf("expected a3 to be the only PR, got %q", got)
+	}
+
+	// Editing the PR down hands the flag back to the best remaining session
+	edit := strings.Replace(session("a3", 90), `"op":"upsert"`, `"op":"upsert","base_version":1`, 1)
+	if r := postSync(t, h, `{"changes":[`+edit+`]}`).Results[0]; r.Status != "applied" {
+		t.Fatalf("expected the edit to apply, got %+v", r)
+	}
+	if got := prs(); got != "a1" {
+		t.Errorf("expected a1 to be the PR after the edit, got %q", got)
+	}
+
+	// Deleting the PR hands the flag on too
+	if r := postSync(t, h, `{"changes":[{"entity":"history","client_id":"a1","op":"delete"}]}`).Results[0]; r.Status != "applied" {
+		t.Fatalf("expected the delete to apply, got %+v", r)
+	}
+	if got := prs(); got != "a3" {
+		t.Errorf("expected a3 to be the PR after the delete, got %q", got)
+	}
+
+	// A change is applied even when the flags can't be moved after it
+	postSync(t, h, `{"changes":[`+session("a4", 80)+`]}`)
+	if _, err := h.DB.Exec(`CREATE TRIGGER pin BEFORE UPDATE OF is_pr ON history
+		BEGIN SELECT RAISE(ABORT, 'pinned'); END`); err != nil {
+		t.Fatal(err)
+	}
+	if r := postSync(t, h, `{"changes":[{"entity":"history","client_id":"a3","op":"delete"}]}`).Results[0]; r.Status != "applied" {
+		t.Errorf("expected the delete to apply despite the flags, got %+v", r)
+	}
+}
+
+func TestSync_InvalidChanges(t *testing.T) {
+	h, _ := newSyncHandler(t)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/sync",
+		strings.NewReader(`{"changes":[{"entity":"exercises","client_id":"x","op":"upsert","data":{}}]}`)))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "changes[0].entity") {
+		t.Errorf("expected 400 on changes[0].entity, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Bad data rejects that change only
+	resp := postSync(t, h, `{"changes":[
+		{"entity":"history","client_id":"h1","op":"upsert","data":{"exercise_id":999,"session_date":"2026-01-12"}},
+		{"entity":"metric_entries","client_id":"m1","op":"upsert","data":{"metric_type_id":1,"entry_date":"2026-01-12","value":80}}]}`)
+	if r := resp.Results[0]; r.Status != "rejected" || r.Reason != "Exercise not found" {
+		t.Errorf("expected rejected history, got %+v", r)
+	}
+	if r := resp.Results[1]; r.Status != "applied" {
+		t.Errorf("expected applied metric entry, got %+v", r)
+	}
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultTrendAlpha is the share of the gap to each day's value an EWMA
+	// closes, as in the Hacker's Diet trend line
+	defaultTrendAlpha = 0.1
+	// rollingDays is the window of the rolling mean, ending on each date
+	rollingDays = 7
+)
+
+// dailyPoint is a metric's mean value on a date, for the trend maths
+type dailyPoint struct {
+	date  time.Time
+	value float64
+}
+
+// dailyMeans collapses entries (any order) to one mean per date, oldest
+// first. Entries with an unparseable date are skipped.
+func dailyMeans(entries []MetricPoint) []dailyPoint {
+	sums := map[string][2]float64{}
+	for _, e := range entries {
+		day := e.Date[:min(len(e.Date), len(dateLayout))]
+		s := sums[day]
+		sums[day] = [2]float64{s[0] + e.Value, s[1] + 1}
+	}
+	points := make([]dailyPoint, 0, len(sums))
+	for _, day := range slices.Sorted(maps.Keys(sums)) {
+		if t, err := time.Parse(dateLayout, day); err == nil {
+			points = append(points, dailyPoint{t, sums[day][0] / sums[day][1]})
+		}
+	}
+	return points
+}
+
+// metricTrend smooths a metric's entries and fits a line through them.
+// Each date's entries are averaged first. The EWMA decays per day, so a gap
+// of n days closes 1 - (1 - alpha)^n of the distance to the next value.
+// The slope is a least-squares fit over the whole series; the projected
+// date is when that line reaches goal, set only while it heads there.
+// Series are newest first, like the entries.
+func metricTrend(entries []MetricPoint, alpha float64, goal *float64) *MetricTrend {
+	points := dailyMeans(entries)
+	trend := &MetricTrend{EWMA: []MetricPoint{}, RollingMean: []MetricPoint{}, Goal: goal}
+	if len(points) == 0 {
+		return trend
+	}
+
+	ewma := points[0].value
+	for i, p := range points {
+		if i > 0 {
+			decay := math.Pow(1-alpha, float64(daysBetween(points[i-1].date, p.date)))
+			ewma = p.value + (ewma-p.value)*decay
+		}
+		sum, n := 0.0, 0
+		for j := i; j >= 0 && daysBetween(points[j].date, p.date) < rollingDays; j-- {
+			sum += points[j].value
+			n++
+		}
+		date := p.date.Format(dateLayout)
+		trend.EWMA = append([]MetricPoint{{Date: date, Value: math.Round(ewma*100) / 100}}, trend.EWMA...)
+		trend.RollingMean = append([]MetricPoint{{Date: date, Value: math.Round(sum/float64(n)*100) / 100}}, trend.RollingMean...)
+	}
+
+	last, perDay, ok := fitLine(points)
+	if !ok {
+		return trend
+	}
+	perWeek := math.Round(perDay*7*100) / 100
+	trend.SlopePerWeek = &perWeek
+	if goal != nil {
+		trend.ProjectedDate = projectDate(points[len(points)-1].date, last, perDay, *goal)
+	}
+	return trend
+}
+
+// fitLine fits a least-squares line through the points, returning its
+// value on the last point's date and its slope per day. ok is false with
+// fewer than two dates.
+func fitLine(points []dailyPoint) (last, perDay float64, ok bool) {
+	if len(points) < 2 {
+		return 0, 0, false
+	}
+	first := points[0].date
+	var sx, sy, sxx, sxy float64
+	for _, p := range points {
+		x := float64(daysBetween(first, p.date))
+		sx += x
+		sy += p.value
+		sxx += x * x
+		sxy += x * p.value
+	}
+	n := float64(len(points))
+	perDay = (n*sxy - sx*sy) / (n*sxx - sx*sx)
+	intercept := (sy - perDay*sx) / n
+	return intercept + perDay*float64(daysBetween(first, points[len(points)-1].date)), perDay, true
+}
+
+// projectDate is the date a line at value on date, changing by perDay,
+// reaches goal. It is nil when the line has passed the goal, is heading
+// away from it or would take over a century.
+func projectDate(date time.Time, value, perDay, goal float64) *string {
+	if perDay == 0 {
+		return nil
+	}
+	days := math.Ceil((goal - value) / perDay)
+	if days < 0 || days > 100*365 {
+		return nil
+	}
+	projected := date.AddDate(0, 0, int(days)).Format(dateLayout)
+	return &projected
+}
+
+// parseTrendOptions reads the dashboard's alpha and goal parameters. Goals
+// are "<metric type id>:<value>", one goal parameter per metric.
+func parseTrendOptions(w http.ResponseWriter, r *http.Request) (alpha float64, goals map[int]float64, ok bool) {
+	q := r.URL.Query()
+	alpha = defaultTrendAlpha
+	if s := q.Get("alpha"); s != "" {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || !(v > 0 && v <= 1) {
+			fieldError(w, r, "alpha", "alpha must be a number in (0, 1]")
+			return 0, nil, false
+		}
+		alpha = v
+	}
+	goals = map[int]float64{}
+	for _, s := range q["goal"] {
+		id, value, found := strings.Cut(s, ":")
+		metricID, err := strconv.Atoi(id)
+		v, valueErr := strconv.ParseFloat(value, 64)
+		if !found || err != nil || valueErr != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			fieldError(w, r, "goal", "goal must be <metric type id>:<value>, e.g. 1:75")
+			return 0, nil, false
+		}
+		goals[metricID] = v
+	}
+	return alpha, goals, true
+}
@@ -0,0 +1,650 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"train/db"
+)
+
+// Response bodies shared by the API handlers. Field names match the JSON the
+// PWA has always consumed.
+
+// MessageResponse acknowledges an update or delete
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// CreatedResponse returns the ID of a newly created record
+type CreatedResponse struct {
+	ID      int64  `json:"id"`
+	Message string `json:"message"`
+}
+
+// ExerciseListResponse is returned by GET /api/v1/exercises
+type ExerciseListResponse struct {
+	Exercises []db.Exercise `json:"exercises"`
+	Page
+}
+
+// RoutineExercise is one exercise on a day's routine, with progression
+// derived from history
+type RoutineExercise struct {
+	RoutineID            int      `json:"routine_id"`
+	ExerciseID           int      `json:"exercise_id"`
+	OrderIndex           int      `json:"order_index"`
+	Name                 string   `json:"name"`
+	Type                 string   `json:"type"`
+	Category             *string  `json:"category,omitempty"`
+	TargetSets           *int     `json:"target_sets,omitempty"`
+	TargetReps           *int     `json:"target_reps,omitempty"`
+	TargetWeight         *float64 `json:"target_weight,omitempty"`
+	Notes                *string  `json:"notes,omitempty"`
+	LastDone             *string  `json:"last_done,omitempty"`
+	ConsecutiveSuccesses int      `json:"consecutive_successes"`
+	ReadyToProgress      bool     `json:"ready_to_progress"`
+}
+
+// RoutineDayResponse is returned by GET /api/v1/routines/{day}
+type RoutineDayResponse struct {
+	Day       string            `json:"day"`
+	Title     string            `json:"title"`
+	Exercises []RoutineExercise `json:"exercises"`
+}
+
+// HistoryResponse is returned by GET /api/v1/history/{exerciseID}
+type HistoryResponse struct {
+	ExerciseID   int          `json:"exercise_id"`
+	ExerciseName string       `json:"exercise_name"`
+	History      []db.History `json:"history"`
+	Page
+}
+
+// LoggedSession is a session in the cross-exercise history log, with the
+// exercise it belongs to
+type LoggedSession struct {
+	db.History
+	ExerciseName string  `json:"exercise_name"`
+	ExerciseType string  `json:"exercise_type"`
+	Category     *string `json:"category,omitempty"`
+}
+
+// HistoryLogDay groups the sessions recorded on one date
+type HistoryLogDay struct {
+	Date      string          `json:"date"`
+	DayOfWeek string          `json:"day_of_week"`
+	Sessions  []LoggedSession `json:"sessions"`
+}
+
+// HistoryLogResponse is returned by GET /api/v1/history; days without
+// sessions are omitted. TotalSessions counts every session in the range,
+// like Page.Total; Days holds only this page's.
+type HistoryLogResponse struct {
+	From          string          `json:"from"`
+	To            string          `json:"to"`
+	Days          []HistoryLogDay `json:"days"`
+	TotalSessions int             `json:"total_sessions"`
+	Page
+}
+
+// PersonalRecord is the session flagged as an exercise's PR
+type PersonalRecord struct {
+	Weight float64 `json:"weight"`
+	Date   string  `json:"date"`
+	Volume float64 `json:"volume"`
+	db.Load
+}
+
+// PRResponse is returned by GET /api/v1/history/{exerciseID}/pr; PR is null
+// when the exercise has none
+type PRResponse struct {
+	PR *PersonalRecord `json:"pr"`
+	// LongestDistance and FastestPace are the cardio records, if any
+	// session logged a distance or pace
+	LongestDistance *CardioRecord `json:"longest_distance,omitempty"`
+	FastestPace     *CardioRecord `json:"fastest_pace,omitempty"`
+}
+
+// CardioRecord is the session that holds a cardio record
+type CardioRecord struct {
+	Date string `json:"date"`
+	db.Cardio
+}
+
+// HistoryCreatedResponse is returned by POST /api/v1/history
+type HistoryCreatedResponse struct {
+	ID   int64 `json:"id"`
+	IsPR bool  `json:"is_pr"`
+	db.CardioPRs
+	Message string `json:"message"`
+}
+
+// DayTitleResponse is returned by GET /api/v1/days/{day}
+type DayTitleResponse struct {
+	DayOfWeek string `json:"day_of_week"`
+	Title     string `json:"title"`
+}
+
+// MetricPoint is a single dated value, as charted by the PWA
+type MetricPoint struct {
+	Date  string  `json:"date"`
+	Value float64 `json:"value"`
+}
+
+// MetricTypeSummary is a metric type with its most recent entry
+type MetricTypeSummary struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Unit       string `json:"unit"`
+	Color      string `json:"color"`
+	OrderIndex int    `json:"order_index"`
+	IsDefault  bool   `json:"is_default"`
+	// Formula is set on computed metrics, whose entries are read-only
+	Formula     *string         `json:"formula,omitempty"`
+	Limits      db.MetricLimits `json:"limits"`
+	LatestEntry *MetricPoint    `json:"latest_entry,omitempty"`
+}
+
+// MetricTypeListResponse is returned by GET /api/v1/metrics
+type MetricTypeListResponse struct {
+	MetricTypes []MetricTypeSummary `json:"metric_types"`
+}
+
+// MetricEntriesResponse is returned by GET /api/v1/metrics/{id}/entries
+type MetricEntriesResponse struct {
+	Entries []db.MetricEntry `json:"entries"`
+	Page
+}
+
+// MetricEntrySavedResponse is returned by POST /api/v1/metric-entries and
+// PUT /api/v1/metric-entries/{id}. Warnings describe changes from the
+// neighbouring entries faster than the metric's max_daily_change; the entry
+// is saved regardless.
+type MetricEntrySavedResponse struct {
+	ID       int64    `json:"id"`
+	Message  string   `json:"message"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// MetricAnomaly is an entry worth reviewing. Reason is "below_min" or
+// "above_max" for entries outside the metric's limits, "jump" for ones
+// changing faster than its max_daily_change, else "outlier".
+// Expected is the median of the neighbouring entries and Score the
+// modified z-score of the entry's distance from it; both are nil with
+// fewer than three entries.
+type MetricAnomaly struct {
+	db.MetricEntry
+	Reason   string   `json:"reason"`
+	Expected *float64 `json:"expected"`
+	Score    *float64 `json:"score"`
+}
+
+// MetricAnomaliesResponse is returned by GET /api/v1/metrics/{id}/anomalies
+type MetricAnomaliesResponse struct {
+	Anomalies []MetricAnomaly `json:"anomalies"`
+	Threshold float64         `json:"threshold"`
+	Window    int             `json:"window"`
+}
+
+// DashboardMetric is one metric type's series on the dashboard
+type DashboardMetric struct {
+	ID      int           `json:"id"`
+	Name    string        `json:"name"`
+	Unit    string        `json:"unit"`
+	Color   string        `json:"color"`
+	Formula *string       `json:"formula,omitempty"`
+	Entries []MetricPoint `json:"entries"`
+	// Trend is set when the dashboard is requested with trend=true
+	Trend *MetricTrend `json:"trend,omitempty"`
+}
+
+// MetricTrend is a dashboard metric's smoothed series and rate of change,
+// computed from the entries in range. Slope and projection need entries on
+// two dates; the projection also needs a goal the trend is heading to.
+type MetricTrend struct {
+	EWMA          []MetricPoint `json:"ewma"`
+	RollingMean   []MetricPoint `json:"rolling_mean"`
+	SlopePerWeek  *float64      `json:"slope_per_week"`
+	Goal          *float64      `json:"goal,omitempty"`
+	ProjectedDate *string       `json:"projected_date"`
+}
+
+// DashboardResponse is returned by GET /api/v1/metrics/dashboard
+type DashboardResponse struct {
+	Metrics []DashboardMetric `json:"metrics"`
+}
+
+// PlanResponse is returned by GET /api/v1/plan when the client asks for JSON
+type PlanResponse struct {
+	Plan string `json:"plan"`
+}
+
+// PlanImportResponse is returned by POST /api/v1/plan
+type PlanImportResponse struct {
+	Message     string `json:"message"`
+	DaysUpdated int    `json:"days_updated"`
+}
+
+// CalendarDay is one day of the training calendar
+type CalendarDay struct {
+	Date     string `json:"date"`
+	Status   string `json:"status"`
+	Sessions int    `json:"sessions"`
+}
+
+// CalendarWeek summarises a Monday-to-Sunday week. Adherence is the
+// percentage of planned days trained so far, null when none were planned.
+type CalendarWeek struct {
+	WeekStart string   `json:"week_start"`
+	Planned   int      `json:"planned"`
+	Trained   int      `json:"trained"`
+	Missed    int      `json:"missed"`
+	Adherence *float64 `json:"adherence"`
+}
+
+// CalendarResponse is returned by GET /api/v1/stats/calendar
+type CalendarResponse struct {
+	Year            int            `json:"year"`
+	PlannedWeekdays []string       `json:"planned_weekdays"`
+	Days            []CalendarDay  `json:"days"`
+	Weeks           []CalendarWeek `json:"weeks"`
+	TrainedDays     int            `json:"trained_days"`
+	MissedDays      int            `json:"missed_days"`
+	Adherence       *float64       `json:"adherence"`
+	CurrentStreak   int            `json:"current_streak"`
+	LongestStreak   int            `json:"longest_streak"`
+}
+
+// HealthResponse is returned by /healthz and /readyz
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+// VolumeTotals are the hard sets, reps and tonnage (kg) of some sessions
+type VolumeTotals struct {
+	Sets    int     `json:"sets"`
+	Reps    int     `json:"reps"`
+	Tonnage float64 `json:"tonnage"`
+}
+
+// CategoryVolume is the volume of one exercise category. Category is empty
+// for uncategorised exercises.
+type CategoryVolume struct {
+	Category string `json:"category"`
+	VolumeTotals
+	// WeeklySets is the average over the range's weeks; set on range totals only
+	WeeklySets *float64 `json:"weekly_sets,omitempty"`
+}
+
+// ExerciseVolume is the volume of one exercise
+type ExerciseVolume struct {
+	ExerciseID int    `json:"exercise_id"`
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Category   string `json:"category"`
+	VolumeTotals
+}
+
+// VolumeWeek is the volume of a Monday-to-Sunday week
+type VolumeWeek struct {
+	WeekStart string `json:"week_start"`
+	VolumeTotals
+	Categories []CategoryVolume `json:"categories"`
+	Exercises  []ExerciseVolume `json:"exercises"`
+}
+
+// PushPullBalance compares push and pull sets for one body region, e.g. "Legs"
+type PushPullBalance struct {
+	Region   string `json:"region"`
+	PushSets int    `json:"push_sets"`
+	PullSets int    `json:"pull_sets"`
+	// Ratio is push sets per pull set, null when there were no pull sets
+	Ratio *float64 `json:"ratio"`
+}
+
+// VolumeResponse is returned by GET /api/v1/stats/volume
+type VolumeResponse struct {
+	From       string            `json:"from"`
+	To         string            `json:"to"`
+	Weeks      []VolumeWeek      `json:"weeks"`
+	Totals     VolumeTotals      `json:"totals"`
+	Categories []CategoryVolume  `json:"categories"`
+	Exercises  []ExerciseVolume  `json:"exercises"`
+	Balance    []PushPullBalance `json:"balance"`
+}
+
+// MuscleSummary is a muscle with the number of exercises that work it
+type MuscleSummary struct {
+	db.Muscle
+	ExerciseCount int `json:"exercise_count"`
+}
+
+// MuscleListResponse is returned by GET /api/v1/muscles
+type MuscleListResponse struct {
+	Muscles []MuscleSummary `json:"muscles"`
+}
+
+// ExerciseMusclesResponse is returned by GET and PUT
+// /api/v1/exercises/{id}/muscles
+type ExerciseMusclesResponse struct {
+	ExerciseID int                 `json:"exercise_id"`
+	Muscles    []db.ExerciseMuscle `json:"muscles"`
+}
+
+// MuscleSets is the number of sets credited to a muscle, split by whether
+// it was a primary or secondary mover
+type MuscleSets struct {
+	MuscleID      int     `json:"muscle_id"`
+	Name          string  `json:"name"`
+	Sets          float64 `json:"sets"`
+	PrimarySets   float64 `json:"primary_sets"`
+	SecondarySets float64 `json:"secondary_sets"`
+	// WeeklySets is the average over the range's weeks; set on range totals only
+	WeeklySets *float64 `json:"weekly_sets,omitempty"`
+}
+
+// MuscleWeek is the sets credited to each muscle worked in a week
+type MuscleWeek struct {
+	WeekStart string       `json:"week_start"`
+	Muscles   []MuscleSets `json:"muscles"`
+}
+
+// MuscleVolumeResponse is returned by GET /api/v1/stats/muscles
+type MuscleVolumeResponse struct {
+	From    string       `json:"from"`
+	To      string       `json:"to"`
+	Weeks   []MuscleWeek `json:"weeks"`
+	Muscles []MuscleSets `json:"muscles"`
+	// UnmappedSets counts sets of exercises with no muscles mapped
+	UnmappedSets int `json:"unmapped_sets"`
+}
+
+// CategorySummary is a category with the number of exercises in it
+type CategorySummary struct {
+	db.Category
+	ExerciseCount int `json:"exercise_count"`
+}
+
+// CategoryListResponse is returned by GET /api/v1/categories
+type CategoryListResponse struct {
+	Categories []CategorySummary `json:"categories"`
+}
+
+// ExerciseTypeSummary is an exercise type with the number of exercises of it
+type ExerciseTypeSummary struct {
+	db.ExerciseType
+	ExerciseCount int `json:"exercise_count"`
+}
+
+// ExerciseTypeListResponse is returned by GET /api/v1/exercise-types
+type ExerciseTypeListResponse struct {
+	ExerciseTypes []ExerciseTypeSummary `json:"exercise_types"`
+}
+
+// CardioTotals sum the structured cardio fields of some sessions. Pace is
+// the total duration over the total distance of sessions that logged both.
+type CardioTotals struct {
+	Sessions         int      `json:"sessions"`
+	DurationSeconds  int      `json:"duration_seconds"`
+	DistanceKm       float64  `json:"distance_km"`
+	ElevationGainM   float64  `json:"elevation_gain_m"`
+	Calories         int      `json:"calories"`
+	PaceSecondsPerKm *float64 `json:"pace_seconds_per_km"`
+	// DistancePRs and PacePRs count the sessions that set a distance or
+	// pace record (see db.CardioPRs)
+	DistancePRs int `json:"distance_prs"`
+	PacePRs     int `json:"pace_prs"`
+}
+
+// CardioExercise is the cardio totals of one exercise
+type CardioExercise struct {
+	ExerciseID int    `json:"exercise_id"`
+	Name       string `json:"name"`
+	CardioTotals
+}
+
+// CardioWeek is the cardio totals of a Monday-to-Sunday week
+type CardioWeek struct {
+	WeekStart string `json:"week_start"`
+	CardioTotals
+}
+
+// CardioResponse is returned by GET /api/v1/stats/cardio
+type CardioResponse struct {
+	From      string           `json:"from"`
+	To        string           `json:"to"`
+	Weeks     []CardioWeek     `json:"weeks"`
+	Totals    CardioTotals     `json:"totals"`
+	Exercises []CardioExercise `json:"exercises"`
+}
+
+// SyncChange is one entry of a device's change log. BaseVersion is the
+// server version the device last saw, 0 for a record created on the device.
+type SyncChange struct {
+	Entity      string          `json:"entity"`
+	ClientID    string          `json:"client_id"`
+	Op          string          `json:"op"`
+	BaseVersion int             `json:"base_version"`
+	Data        json.RawMessage `json:"data,omitempty"`
+}
+
+// SyncRequest is the body of POST /api/v1/sync
+type SyncRequest struct {
+	// Since is the cursor returned by the device's previous sync; empty
+	// asks for everything
+	Since   string       `json:"since"`
+	Changes []SyncChange `json:"changes"`
+}
+
+// SyncResult reports what happened to one change: applied, conflict (the
+// server copy won and is in the response) or rejected (invalid data)
+type SyncResult struct {
+	Entity   string `json:"entity"`
+	ClientID string `json:"client_id"`
+	Status   string `json:"status"`
+	Version  int    `json:"version,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// SyncMeta is the sync metadata carried by every synced record
+type SyncMeta struct {
+	ClientID  string `json:"client_id"`
+	Version   int    `json:"version"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// SyncHistory is a history entry as exchanged by the sync API
+type SyncHistory struct {
+	SyncMeta
+	db.History
+}
+
+// SyncRoutine is a routine entry as exchanged by the sync API
+type SyncRoutine struct {
+	SyncMeta
+	db.Routine
+}
+
+// SyncMetricEntry is a metric entry as exchanged by the sync API
+type SyncMetricEntry struct {
+	SyncMeta
+	db.MetricEntry
+}
+
+// SyncTombstone records a deleted record
+type SyncTombstone struct {
+	Entity    string `json:"entity"`
+	ClientID  string `json:"client_id"`
+	Version   int    `json:"version"`
+	DeletedAt string `json:"deleted_at"`
+}
+
+// SyncResponse is returned by POST /api/v1/sync. The record lists hold
+// everything changed since the request's cursor plus the server copy of
+// every conflicting record, so applying them makes the device converge.
+type SyncResponse struct {
+	Cursor        string            `json:"cursor"`
+	Results       []SyncResult      `json:"results"`
+	History       []SyncHistory     `json:"history"`
+	Routines      []SyncRoutine     `json:"routines"`
+	MetricEntries []SyncMetricEntry `json:"metric_entries"`
+	Deleted       []SyncTombstone   `json:"deleted"`
+}
+
+// SharedExercise is an exercise on a shared session's routine
+type SharedExercise struct {
+	ExerciseID   int      `json:"exercise_id"`
+	Name         string   `json:"name"`
+	Type         string   `json:"type"`
+	TargetSets   *int     `json:"target_sets,omitempty"`
+	TargetReps   *int     `json:"target_reps,omitempty"`
+	TargetWeight *float64 `json:"target_weight,omitempty"`
+}
+
+// SharedSet is a set a participant completed
+type SharedSet struct {
+	ExerciseID  int      `json:"exercise_id"`
+	SetNumber   int      `json:"set_number"`
+	Reps        int      `json:"reps"`
+	Weight      *float64 `json:"weight,omitempty"`
+	CompletedAt string   `json:"completed_at"`
+}
+
+// SharedEntry is a participant's result for one exercise as a history entry.
+// The host's entries are logged on this server (HistoryID); partners post
+// theirs to their own server, matching the exercise by name, where ClientID
+// makes posting twice harmless.
+type SharedEntry struct {
+	ClientID      string   `json:"client_id"`
+	ExerciseID    int      `json:"exercise_id"`
+	ExerciseName  string   `json:"exercise_name"`
+	SessionDate   string   `json:"session_date"`
+	Weight        *float64 `json:"weight,omitempty"`
+	SetsCompleted []int    `json:"sets_completed"`
+	Completed     bool     `json:"completed"`
+	Volume        *float64 `json:"volume,omitempty"`
+	HistoryID     *int64   `json:"history_id,omitempty"`
+	IsPR          bool     `json:"is_pr,omitempty"`
+}
+
+// SharedParticipant is someone training in a shared session. Entries are
+// filled in once the session is finished.
+type SharedParticipant struct {
+	ID      int           `json:"id"`
+	Name    string        `json:"name"`
+	IsHost  bool          `json:"is_host"`
+	Sets    []SharedSet   `json:"sets"`
+	Entries []SharedEntry `json:"entries,omitempty"`
+}
+
+// SharedSession is returned by GET /api/v1/shared-sessions/{code}
+type SharedSession struct {
+	ID           int                 `json:"id"`
+	Code         string              `json:"code"`
+	DayOfWeek    string              `json:"day_of_week"`
+	SessionDate  string              `json:"session_date"`
+	FinishedAt   *string             `json:"finished_at,omitempty"`
+	Exercises    []SharedExercise    `json:"exercises"`
+	Participants []SharedParticipant `json:"participants"`
+}
+
+// SharedSessionJoinResponse is returned when a session is started or
+// joined. Token identifies the participant when recording sets; keep it on
+// the device.
+type SharedSessionJoinResponse struct {
+	ParticipantID int64         `json:"participant_id"`
+	Token         string        `json:"token"`
+	Session       SharedSession `json:"session"`
+}
+
+// Settings is returned by GET and PUT /api/v1/settings; unset values are
+// null
+type Settings struct {
+	Sex *string `json:"sex"`
+	// HeightCm is the height computed metrics such as BMI use
+	HeightCm *float64     `json:"height_cm"`
+	Lifts    LiftSettings `json:"lifts"`
+}
+
+// LiftSettings maps the powerlifting lifts to exercise IDs. An unset lift
+// is scored from the exercise named Squat, Bench Press or Deadlift.
+type LiftSettings struct {
+	Squat    *int `json:"squat"`
+	Bench    *int `json:"bench"`
+	Deadlift *int `json:"deadlift"`
+}
+
+// StrengthScores are a powerlifting total of best e1RMs and its
+// bodyweight-normalised scores. The total needs all three lifts; the scores
+// also need a bodyweight and the sex setting.
+type StrengthScores struct {
+	Total *float64 `json:"total,omitempty"`
+	Wilks *float64 `json:"wilks,omitempty"`
+	DOTS  *float64 `json:"dots,omitempty"`
+	IPFGL *float64 `json:"ipf_gl,omitempty"`
+}
+
+// LiftStrength is a lift's best estimated one-rep max and where it stands
+// against the strength standards for the current bodyweight
+type LiftStrength struct {
+	Lift string `json:"lift"`
+	// ExerciseID is null when no exercise is scored as the lift
+	ExerciseID      *int     `json:"exercise_id"`
+	ExerciseName    *string  `json:"exercise_name,omitempty"`
+	E1RM            *float64 `json:"e1rm,omitempty"`
+	Date            *string  `json:"date,omitempty"`
+	BodyweightRatio *float64 `json:"bodyweight_ratio,omitempty"`
+	// Standard is untrained, beginner, novice, intermediate, advanced or
+	// elite; set when the sex setting is
+	Standard string `json:"standard,omitempty"`
+}
+
+// StrengthPoint is the best of each lift so far and their scores at the
+// bodyweight of a date one of them was trained
+type StrengthPoint struct {
+	Date       string   `json:"date"`
+	Bodyweight *float64 `json:"bodyweight,omitempty"`
+	Squat      *float64 `json:"squat,omitempty"`
+	Bench      *float64 `json:"bench,omitempty"`
+	Deadlift   *float64 `json:"deadlift,omitempty"`
+	StrengthScores
+}
+
+// StrengthResponse is returned by GET /api/v1/stats/strength. The scores
+// and lifts are as of to, at the bodyweight then.
+type StrengthResponse struct {
+	From       string         `json:"from"`
+	To         string         `json:"to"`
+	Sex        *string        `json:"sex"`
+	Bodyweight *float64       `json:"bodyweight,omitempty"`
+	Lifts      []LiftStrength `json:"lifts"`
+	StrengthScores
+	Series []StrengthPoint `json:"series"`
+}
+
+// GoalResponse is a goal with its progress, returned by GET
+// /api/v1/goals and /api/v1/goals/{id}. Kind is "metric" or "lift"; Name
+// and Unit are the metric's, or the exercise's name and kg.
+type GoalResponse struct {
+	db.Goal
+	Kind     string       `json:"kind"`
+	Name     string       `json:"name"`
+	Unit     string       `json:"unit"`
+	Progress GoalProgress `json:"progress"`
+}
+
+// GoalProgress is where a goal stands. Current is the latest metric value
+// or the heaviest load lifted for the reps; Percent is the way from the
+// start value to the target. ETA is null once achieved or while the trend
+// isn't heading to the target, and OnTrack needs an ETA and a target date.
+type GoalProgress struct {
+	Current     *float64 `json:"current"`
+	CurrentDate *string  `json:"current_date"`
+	Percent     *float64 `json:"percent"`
+	ETA         *string  `json:"eta"`
+	OnTrack     *bool    `json:"on_track,omitempty"`
+}
+
+// GoalListResponse is returned by GET /api/v1/goals
+type GoalListResponse struct {
+	Goals []GoalResponse `json:"goals"`
+}
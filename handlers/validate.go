@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// dateLayout is the format used for session and entry dates throughout the API
+const dateLayout = "2006-01-02"
+
+// isWeekDay reports whether day is a capitalised day name, e.g. "Monday"
+func isWeekDay(day string) bool {
+	return slices.Contains(weekDays, day)
+}
+
+// isDate reports whether s is a YYYY-MM-DD calendar date
+func isDate(s string) bool {
+	_, err := time.Parse(dateLayout, s)
+	return err == nil
+}
+
+// maxRangeYears bounds the span a dateRange may cover, so one request can't
+// aggregate the whole database
+const maxRangeYears = 5
+
+// dateRange reads the inclusive ?from= and ?to= dates. to defaults to today
+// and from to the days-long window ending on to. The span may not exceed
+// maxRangeYears. On invalid input it writes a field error and returns false.
+func dateRange(w http.ResponseWriter, r *http.Request, today time.Time, days int) (from, to string, ok bool) {
+	q := r.URL.Query()
+	to = q.Get("to")
+	if to == "" {
+		to = today.Format(dateLayout)
+	}
+	toDate, err := time.Parse(dateLayout, to)
+	if err != nil {
+		fieldError(w, r, "to", "to must be a YYYY-MM-DD date")
+		return "", "", false
+	}
+	from = q.Get("from")
+	if from == "" {
+		from = toDate.AddDate(0, 0, -(days - 1)).Format(dateLayout)
+	}
+	if !isDate(from) {
+		fieldError(w, r, "from", "from must be a YYYY-MM-DD date")
+		return "", "", false
+	}
+	if from > to {
+		fieldError(w, r, "from", "from must not be after to")
+		return "", "", false
+	}
+	if from < toDate.AddDate(-maxRangeYears, 0, 0).Format(dateLayout) {
+		fieldError(w, r, "from", fmt.Sprintf("from must be within %d years of to", maxRangeYears))
+		return "", "", false
+	}
+	return from, to, true
+}
+
+// clientIDPattern accepts UUIDs and the hex ids the server generates
+var clientIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// idempotencyKey returns the client_id of a create, taken from the body or
+// the Idempotency-Key header; nil when neither is set. On invalid input it
+// writes a field error and returns false.
+func idempotencyKey(w http.ResponseWriter, r *http.Request, body *string) (*string, bool) {
+	key := body
+	if header := r.Header.Get("Idempotency-Key"); header != "" {
+		if body != nil && *body != header {
+			fieldError(w, r, "client_id", "client_id and the Idempotency-Key header differ")
+			return nil, false
+		}
+		key = &header
+	}
+	if key != nil && !clientIDPattern.MatchString(*key) {
+		fieldError(w, r, "client_id", "client_id must be 1-64 letters, digits, dashes or underscores")
+		return nil, false
+	}
+	return key, true
+}
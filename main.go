@@ -1,52 +1,199 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"embed"
+	"errors"
+	"flag"
+	"io/fs"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"train/certs"
+	"train/config"
 	"train/db"
 	"train/handlers"
 )
 
-const port = ":3001" // Keeping same port as old server for ease
+// publicFiles holds the PWA frontend, compiled into the binary so a deploy is
+// a single file.
+//
+//go:embed public
+var publicFiles embed.FS
 
 func main() {
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	slog.SetDefault(cfg.Logger(os.Stderr))
+
 	// Check if migration is needed
-	if db.ShouldMigrate() {
+	if cfg.Features.LegacyMigration && db.ShouldMigrate(cfg.DBPath, cfg.LegacyJSONPath) {
 		log.Println("Database not found. Starting migration from train.json...")
-		if err := db.Migrate(); err != nil {
+		if err := db.Migrate(cfg.DBPath, cfg.LegacyJSONPath, cfg.BackupPath("train.json.backup")); err != nil {
 			log.Fatalf("Migration failed: %v", err)
 		}
 		log.Println("Migration completed successfully!")
 	}
 
 	// Open database connection
-	database, err := db.Open()
+	database, err := db.Open(cfg.DBPath)
 	if err != nil {
 		log.Fatalf("Failed to open database: %v", err)
 	}
-	defer database.Close()
+
+	var draining atomic.Bool
+	mux := http.NewServeMux()
 
 	// Register handlers
-	http.Handle("/", http.FileServer(http.Dir("./public")))
+	mux.Handle("/", http.FileServer(staticFS(cfg.PublicDir)))
+	health := &handlers.HealthHandler{DB: database, Draining: &draining}
+	mux.Handle("/healthz", health)
+	mux.Handle("/readyz", health)
+	telemetry := &handlers.Telemetry{DB: database}
+	if cfg.Features.Metrics {
+		mux.Handle("/metrics", telemetry)
+	}
 
 	// API endpoints
-	http.Handle("/api/exercises", &handlers.ExercisesHandler{DB: database})
-	http.Handle("/api/exercises/", &handlers.ExercisesHandler{DB: database})
-	http.Handle("/api/routines", &handlers.RoutinesHandler{DB: database})
-	http.Handle("/api/routines/", &handlers.RoutinesHandler{DB: database})
-	http.Handle("/api/history", &handlers.HistoryHandler{DB: database})
-	http.Handle("/api/history/", &handlers.HistoryHandler{DB: database})
-	http.Handle("/api/days/", &handlers.DaysHandler{DB: database})
-	http.Handle("/api/metrics", &handlers.MetricsHandler{DB: database})
-	http.Handle("/api/metrics/", &handlers.MetricsHandler{DB: database})
-	http.Handle("/api/metric-entries", &handlers.MetricEntriesHandler{DB: database})
-	http.Handle("/api/metric-entries/", &handlers.MetricEntriesHandler{DB: database})
-	http.Handle("/api/plan", &handlers.PlanHandler{DB: database})
-
-	log.Printf("Server listening on http://localhost%s", port)
-	err = http.ListenAndServe(port, nil)
-	if err != nil {
+	events := handlers.NewHub()
+	handlers.Register(mux, database, handlers.Options{PlanImport: cfg.Features.PlanImport, Events: events})
+
+	certFile, keyFile := cfg.TLSCert, cfg.TLSKey
+	var caCert http.HandlerFunc
+	if cfg.TLSEnabled() && (certFile == "" || keyFile == "") {
+		files, err := certs.Ensure(cfg.CertDir(), cfg.TLSHostnames)
+		if err != nil {
+			log.Fatalf("Failed to prepare TLS certificate: %v", err)
+		}
+		certFile, keyFile = files.Cert, files.Key
+		log.Printf("Using local CA certificate for %s; install %s (or download /ca.crt) on your devices", strings.Join(cfg.TLSHostnames, ", "), files.CACert)
+		caCert = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/x-x509-ca-cert")
+			http.ServeFile(w, r, files.CACert)
+		}
+		mux.Handle("/ca.crt", caCert)
+	}
+
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           telemetry.Wrap(mux),
+		ReadHeaderTimeout: cfg.Timeouts.ReadHeader,
+		ReadTimeout:       cfg.Timeouts.Read,
+		WriteTimeout:      cfg.Timeouts.Write,
+		IdleTimeout:       cfg.Timeouts.Idle,
+		// HTTP/2 is negotiated automatically when serving TLS
+		TLSConfig: &tls.Config{MinVersion: tls.VersionTLS12},
+	}
+	// Event streams never finish on their own; end them when shutdown starts
+	srv.RegisterOnShutdown(events.Close)
+	servers := []*http.Server{srv}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 2)
+	go func() {
+		if cfg.TLSEnabled() {
+			log.Printf("Server listening on https://localhost%s", cfg.Addr)
+			serveErr <- srv.ListenAndServeTLS(certFile, keyFile)
+		} else {
+			log.Printf("Server listening on http://localhost%s", cfg.Addr)
+			serveErr <- srv.ListenAndServe()
+		}
+	}()
+
+	if cfg.TLSEnabled() && cfg.HTTPRedirectAddr != "" {
+		redirectMux := http.NewServeMux()
+		redirectMux.Handle("/", httpsRedirect(cfg.Addr))
+		// Devices that don't trust the local CA yet can't reach it over HTTPS
+		if caCert != nil {
+			redirectMux.Handle("/ca.crt", caCert)
+		}
+		redirect := &http.Server{
+			Addr:              cfg.HTTPRedirectAddr,
+			Handler:           redirectMux,
+			ReadHeaderTimeout: cfg.Timeouts.ReadHeader,
+			IdleTimeout:       cfg.Timeouts.Idle,
+		}
+		servers = append(servers, redirect)
+		go func() {
+			log.Printf("Redirecting http://localhost%s to HTTPS", cfg.HTTPRedirectAddr)
+			serveErr <- redirect.ListenAndServe()
+		}()
+	}
+
+	select {
+	case err := <-serveErr:
+		database.Close()
 		log.Fatal(err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	draining.Store(true)
+	if cfg.Timeouts.Drain > 0 {
+		log.Printf("Shutting down: failing readiness checks for %s...", cfg.Timeouts.Drain)
+		time.Sleep(cfg.Timeouts.Drain)
+	}
+	log.Println("Shutting down: draining in-flight requests...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Shutdown)
+	defer cancel()
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Warning: graceful shutdown incomplete: %v", err)
+		}
+	}
+
+	if err := database.Checkpoint(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if err := database.Close(); err != nil {
+		log.Printf("Warning: failed to close database: %v", err)
+	}
+	log.Println("Shutdown complete")
+}
+
+// httpsRedirect sends plain HTTP requests to the same host and path on the
+// HTTPS listener at httpsAddr
+func httpsRedirect(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// staticFS returns the frontend file system: the override directory when one
+// is given, otherwise the copy embedded at build time.
+func staticFS(dir string) http.FileSystem {
+	if dir != "" {
+		log.Printf("Serving frontend from %s", dir)
+		return http.Dir(dir)
+	}
+	sub, err := fs.Sub(publicFiles, "public")
+	if err != nil {
+		log.Fatalf("Failed to load embedded frontend: %v", err)
 	}
+	return http.FS(sub)
 }
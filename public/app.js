@@ -45,6 +45,14 @@ function getTodayDateString() {
     return `${year}-${month}-${day}`;
 }
 
+// Helper: Random id for POSTs, so a retried request updates the entry it
+// already created instead of logging a duplicate
+function newClientId() {
+    if (crypto.randomUUID) return crypto.randomUUID();
+    const bytes = crypto.getRandomValues(new Uint8Array(16));
+    return Array.from(bytes, b => b.toString(16).padStart(2, '0')).join('');
+}
+
 // Helper: Get current day of week name
 function getCurrentDayOfWeek() {
     const days = ['Sunday', 'Monday', 'Tuesday', 'Wednesday', 'Thursday', 'Friday', 'Saturday'];
@@ -80,15 +88,58 @@ async function init() {
             renderWorkout();
         });
 
+        subscribeToLiveUpdates();
+
     } catch (err) {
         console.error('Failed to init:', err);
         dom.workoutContainer.innerHTML = '<p>Error loading data. Please reload.</p>';
     }
 }
 
+// Live updates: refetch the day when another device changes it. Refreshes
+// wait while the session modal or edit mode is open so they don't reset
+// what the user is doing.
+const LIVE_EVENT_PREFIXES = ['routine.', 'history.', 'exercise.', 'day.', 'plan.', 'sync.'];
+let liveRefreshTimer = null;
+let liveRefreshPending = false;
+
+function subscribeToLiveUpdates() {
+    if (!window.EventSource) return;
+    const source = new EventSource('/api/v1/events');
+    let connected = false;
+    source.onmessage = (msg) => {
+        const event = JSON.parse(msg.data);
+        if (LIVE_EVENT_PREFIXES.some(prefix => event.type.startsWith(prefix))) {
+            scheduleLiveRefresh();
+        }
+    };
+    // Changes made while reconnecting were missed, so refetch
+    source.onopen = () => {
+        if (connected) scheduleLiveRefresh();
+        connected = true;
+    };
+}
+
+function scheduleLiveRefresh() {
+    clearTimeout(liveRefreshTimer);
+    liveRefreshTimer = setTimeout(async () => {
+        if (state.modal.isOpen || state.isEditing) {
+            liveRefreshPending = true;
+            return;
+        }
+        liveRefreshPending = false;
+        try {
+            await loadDayData(state.selectedDay);
+            renderWorkout();
+        } catch (err) {
+            console.error('Live refresh failed:', err);
+        }
+    }, 300);
+}
+
 // Load data for a specific day from new API
 async function loadDayData(day) {
-    const res = await fetch(`/api/routines/${day}`);
+    const res = await fetch(`/api/v1/routines/${day}`);
     if (!res.ok) {
         throw new Error('Failed to load day data');
     }
@@ -386,12 +437,12 @@ window.toggleExercise = async (index) => {
         // Find and delete today's history entry
         try {
             // We need to find the history ID for today's entry
-            const historyRes = await fetch(`/api/history/${ex.exercise_id}`);
+            const historyRes = await fetch(`/api/v1/history/${ex.exercise_id}`);
             const historyData = await historyRes.json();
             const todayEntry = historyData.history.find(h => h.session_date === today || h.session_date.startsWith(today));
             
             if (todayEntry) {
-                await fetch(`/api/history/${todayEntry.id}`, {
+                await fetch(`/api/v1/history/${todayEntry.id}`, {
                     method: 'DELETE'
                 });
             }
@@ -405,10 +456,11 @@ window.toggleExercise = async (index) => {
     } else {
         // Create new history entry for today
         try {
-            await fetch('/api/history', {
+            await fetch('/api/v1/history', {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({
+                    client_id: newClientId(),
                     exercise_id: ex.exercise_id,
                     session_date: today,
                     weight: 0,
@@ -436,7 +488,7 @@ window.removeExercise = async (index) => {
     const ex = state.exercises[index];
 
     try {
-        await fetch(`/api/routines/${ex.routine_id}`, {
+        await fetch(`/api/v1/routines/${ex.routine_id}`, {
             method: 'DELETE'
         });
 
@@ -458,7 +510,7 @@ window.saveChanges = async () => {
         // Update day title
         const titleInput = document.getElementById('day-title-input');
         if (titleInput && titleInput.value !== state.dayTitle) {
-            await fetch(`/api/days/${state.selectedDay}`, {
+            await fetch(`/api/v1/days/${state.selectedDay}`, {
                 method: 'PUT',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({ title: titleInput.value })
@@ -473,7 +525,7 @@ window.saveChanges = async () => {
             if (ex.type === 'cardio') {
                 const textInput = document.querySelector(`.exercise-input[data-index="${i}"]`);
                 if (textInput) {
-                    await fetch(`/api/routines/${ex.routine_id}`, {
+                    await fetch(`/api/v1/routines/${ex.routine_id}`, {
                         method: 'PUT',
                         headers: { 'Content-Type': 'application/json' },
                         body: JSON.stringify({ notes: textInput.value })
@@ -591,7 +643,7 @@ function onDragStart(e) {
 
             const routineIds = exercises.map(ex => ex.routine_id);
             try {
-                const res = await fetch('/api/routines/reorder', {
+                const res = await fetch('/api/v1/routines/reorder', {
                     method: 'POST',
                     headers: { 'Content-Type': 'application/json' },
                     body: JSON.stringify({
@@ -639,7 +691,7 @@ window.addEventListener('beforeunload', () => {
 // Open modal to add exercise from library
 async function openAddExerciseModal() {
     // Load all exercises
-    const res = await fetch('/api/exercises');
+    const res = await fetch('/api/v1/exercises');
     const data = await res.json();
     state.searchModal.exercises = data.exercises || [];
     state.searchModal.filteredExercises = data.exercises || [];
@@ -739,7 +791,7 @@ async function addExerciseFromLibrary(exerciseId, exerciseName, exerciseType) {
             notes: exerciseType === 'cardio' ? '' : null
         };
 
-        const res = await fetch('/api/routines', {
+        const res = await fetch('/api/v1/routines', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify(payload)
@@ -877,12 +929,13 @@ window.closeExerciseDetail = (saveDraft = true) => {
     state.modal.exerciseId = null;
     state.modal.currentSession = { sets: [], weight: null };
     renderWorkout();
+    if (liveRefreshPending) scheduleLiveRefresh();
 };
 
 window.deleteHistoryEntry = async (historyId) => {
     if (!confirm('Delete this history entry?')) return;
     try {
-        const res = await fetch(`/api/history/${historyId}`, { method: 'DELETE' });
+        const res = await fetch(`/api/v1/history/${historyId}`, { method: 'DELETE' });
         if (!res.ok) throw new Error('Failed to delete');
 
         // Remove from state
@@ -899,7 +952,7 @@ window.deleteHistoryEntry = async (historyId) => {
         }
 
         // Re-fetch PR (may have changed) and re-render graph
-        const prRes = await fetch(`/api/history/${state.modal.exerciseId}/pr`);
+        const prRes = await fetch(`/api/v1/history/${state.modal.exerciseId}/pr`);
         const prData = await prRes.json();
         state.modal.pr = prData.pr || null;
         if (state.modal.history.length > 0) {
@@ -917,6 +970,7 @@ function renderHistoryPage(history) {
     const isBodyweight = exercise && exercise.type === 'bodyweight';
     const isTimedHold = exercise && exercise.type === 'timed_hold';
     const isCarry = exercise && exercise.type === 'carry';
+    const isCardio = exercise && exercise.type === 'cardio';
     const unitLabel = isTimedHold ? 's' : isCarry ? 'laps' : 'reps';
     const page = state.modal.historyPage;
     const start = page * 5;
@@ -927,9 +981,16 @@ function renderHistoryPage(history) {
                 <span class="history-date">${session.session_date}</span>
                 <span class="history-status">${session.completed ? '✓' : '✗'}</span>
                 ${session.is_pr ? '<span class="pr-badge">🏆 PR</span>' : ''}
+                ${session.distance_pr ? '<span class="pr-badge">🏆 Distance</span>' : ''}
+                ${session.pace_pr ? '<span class="pr-badge">🏆 Pace</span>' : ''}
                 <button class="history-delete-btn" onclick="event.stopPropagation(); deleteHistoryEntry(${session.id})" title="Delete entry">✕</button>
             </div>
             <div class="history-details">
+                ${isCardio ? `
+                ${session.distance_km != null ? `<span>${session.distance_km} km</span>` : ''}
+                ${session.duration_seconds != null ? `<span>${formatTime(session.duration_seconds)}</span>` : ''}
+                ${session.pace_seconds_per_km != null ? `<span class="history-volume">${formatTime(Math.round(session.pace_seconds_per_km))} /km</span>` : ''}
+                ` : `
                 ${(isBodyweight || isTimedHold) ? '' : `<span>${session.weight}kg${isCarry ? ' per hand' : ''}</span>`}
                 <span class="history-sets">${session.sets_completed.join(', ')} ${unitLabel}</span>
                 ${isBodyweight
@@ -938,6 +999,9 @@ function renderHistoryPage(history) {
                     ? `<span class="history-volume">${session.volume}s best hold</span>`
                     : `<span class="history-volume">${session.volume}kg total</span>`
                 }
+                ${session.bodyweight != null && session.effective_load != null ? `<span class="history-load">${session.effective_load}kg load @ ${session.bodyweight}kg BW</span>` : ''}
+                ${session.e1rm != null && !isTimedHold ? `<span class="history-load">e1RM ${session.e1rm}kg</span>` : ''}
+                `}
             </div>
         </div>
     `).join('');
@@ -976,7 +1040,7 @@ window.adjustTargetReps = async (delta) => {
     const exercise = state.exercises[state.modal.exerciseIndex];
     const newTargetReps = Math.max(1, (exercise.target_reps || 1) + delta);
     exercise.target_reps = newTargetReps;
-    await fetch(`/api/exercises/${exercise.exercise_id}`, {
+    await fetch(`/api/v1/exercises/${exercise.exercise_id}`, {
         method: 'PUT',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify({ target_reps: newTargetReps })
@@ -1088,12 +1152,18 @@ window.completeExerciseSession = async () => {
         ? totalReps
         : totalReps * state.modal.currentSession.weight;
 
+    // Keep the id across retries of this save
+    if (!state.modal.currentSession.clientId) {
+        state.modal.currentSession.clientId = newClientId();
+    }
+
     try {
         // Create history entry via API
-        const response = await fetch('/api/history', {
+        const response = await fetch('/api/v1/history', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify({
+                client_id: state.modal.currentSession.clientId,
                 exercise_id: exercise.exercise_id,
                 session_date: today,
                 weight: (isBodyweight || (isTimedHold && !state.modal.currentSession.weight)) ? 0 : state.modal.currentSession.weight,
@@ -1113,7 +1183,7 @@ window.completeExerciseSession = async () => {
             if (newConsecutive === 3) {
                 const increment = isTimedHold ? 5 : 1;
                 const newTargetReps = (exercise.target_reps || 0) + increment;
-                await fetch(`/api/exercises/${exercise.exercise_id}`, {
+                await fetch(`/api/v1/exercises/${exercise.exercise_id}`, {
                     method: 'PUT',
                     headers: { 'Content-Type': 'application/json' },
                     body: JSON.stringify({ target_reps: newTargetReps })
@@ -1124,7 +1194,7 @@ window.completeExerciseSession = async () => {
             // Update exercise's target_weight if it changed
             const originalWeight = exercise.target_weight || 0;
             if (state.modal.currentSession.weight !== originalWeight) {
-                await fetch(`/api/exercises/${exercise.exercise_id}`, {
+                await fetch(`/api/v1/exercises/${exercise.exercise_id}`, {
                     method: 'PUT',
                     headers: { 'Content-Type': 'application/json' },
                     body: JSON.stringify({ target_weight: state.modal.currentSession.weight })
@@ -1176,8 +1246,8 @@ window.openExerciseDetail = async (index) => {
     // Fetch history and PR data from API
     try {
         const [historyRes, prRes] = await Promise.all([
-            fetch(`/api/history/${exercise.exercise_id}`),
-            fetch(`/api/history/${exercise.exercise_id}/pr`)
+            fetch(`/api/v1/history/${exercise.exercise_id}`),
+            fetch(`/api/v1/history/${exercise.exercise_id}/pr`)
         ]);
 
         const historyData = await historyRes.json();
@@ -1,6 +1,8 @@
 // State management
 const state = {
     exercises: [],
+    exerciseTypes: {},
+    categories: [],
     filteredExercises: [],
     searchQuery: '',
     typeFilter: '',
@@ -51,8 +53,10 @@ const dom = {
 // Initialize
 async function init() {
     try {
+        await loadTaxonomy();
         await loadExercises();
         setupEventListeners();
+        subscribeToLiveUpdates();
         dom.loading.style.display = 'none';
     } catch (err) {
         console.error('Failed to initialize:', err);
@@ -60,6 +64,19 @@ async function init() {
     }
 }
 
+// Reload the list when another device changes exercises
+function subscribeToLiveUpdates() {
+    if (!window.EventSource) return;
+    let timer = null;
+    const source = new EventSource('/api/v1/events');
+    source.onmessage = (msg) => {
+        const event = JSON.parse(msg.data);
+        if (!event.type.startsWith('exercise.') && !event.type.startsWith('plan.')) return;
+        clearTimeout(timer);
+        timer = setTimeout(() => loadExercises().catch(err => console.error('Live refresh failed:', err)), 300);
+    };
+}
+
 // Setup event listeners
 function setupEventListeners() {
     // Search with debouncing
@@ -119,37 +136,59 @@ function setupEventListeners() {
     });
 }
 
+// Labels for the reps target by the type's set unit
+const setUnitLabels = { reps: 'Reps', laps: 'Laps', seconds: 'Seconds' };
+
+// Show the form fields that apply to the selected type's descriptor
 function applyTypeUI(type) {
-    if (type === 'cardio') {
-        dom.exerciseCategory.disabled = true;
-        dom.targetsGroup.style.display = 'none';
-    } else if (type === '') {
-        dom.exerciseCategory.disabled = false;
-        dom.targetsGroup.style.display = 'block';
-        dom.exerciseTargetWeight.closest('.form-group').style.display = 'block';
-    } else {
-        dom.exerciseCategory.disabled = false;
-        dom.targetsGroup.style.display = 'block';
-        dom.exerciseTargetWeight.closest('.form-group').style.display =
-            type === 'bodyweight' ? 'none' : 'block';
-    }
+    const descriptor = state.exerciseTypes[type];
+    const hasSets = !descriptor || descriptor.set_unit !== 'none';
+    const usesWeight = !descriptor || descriptor.uses_weight;
+
+    dom.exerciseCategory.disabled = !hasSets;
+    dom.targetsGroup.style.display = hasSets ? 'block' : 'none';
+    dom.exerciseTargetWeight.closest('.form-group').style.display = usesWeight ? 'block' : 'none';
+
+    const unit = descriptor ? descriptor.set_unit : 'reps';
     const repsLabel = document.getElementById('label-target-reps');
     const weightLabel = document.getElementById('label-target-weight');
     if (repsLabel) {
-        if (type === 'carry') repsLabel.textContent = 'Laps';
-        else if (type === 'timed_hold') repsLabel.textContent = 'Seconds';
-        else repsLabel.textContent = 'Reps';
+        repsLabel.textContent = setUnitLabels[unit] || 'Reps';
     }
     if (weightLabel) {
-        if (type === 'carry') weightLabel.textContent = 'Weight per hand (kg)';
-        else if (type === 'timed_hold') weightLabel.textContent = 'Added weight (kg, optional)';
+        if (unit === 'laps') weightLabel.textContent = 'Weight per hand (kg)';
+        else if (unit === 'seconds' || (descriptor && descriptor.bodyweight_load === 'add')) weightLabel.textContent = 'Added weight (kg, optional)';
         else weightLabel.textContent = 'Weight (kg)';
     }
 }
 
+// Load exercise types and categories and fill the filter and form selects
+async function loadTaxonomy() {
+    const [typesRes, categoriesRes] = await Promise.all([
+        fetch('/api/v1/exercise-types'),
+        fetch('/api/v1/categories')
+    ]);
+    if (!typesRes.ok || !categoriesRes.ok) {
+        throw new Error('Failed to load exercise types and categories');
+    }
+    const types = (await typesRes.json()).exercise_types || [];
+    state.categories = (await categoriesRes.json()).categories || [];
+
+    state.exerciseTypes = {};
+    types.forEach(t => {
+        state.exerciseTypes[t.name] = t;
+        dom.typeFilter.add(new Option(t.label, t.name));
+        dom.exerciseType.add(new Option(t.label, t.name));
+    });
+    state.categories.forEach(c => {
+        dom.categoryFilter.add(new Option(getCategoryDisplayLabel(c.name), c.name));
+        dom.exerciseCategory.add(new Option(getCategoryDisplayLabel(c.name), c.name));
+    });
+}
+
 // Load exercises from API
 async function loadExercises() {
-    const response = await fetch('/api/exercises');
+    const response = await fetch('/api/v1/exercises');
     if (!response.ok) {
         throw new Error('Failed to load exercises');
     }
@@ -341,12 +380,15 @@ async function handleSubmit(e) {
 
     const name = dom.exerciseName.value.trim();
     const type = dom.exerciseType.value;
-    const category = type === 'cardio' ? null : (dom.exerciseCategory.value || null);
+    const descriptor = state.exerciseTypes[type];
+    const hasSets = !descriptor || descriptor.set_unit !== 'none';
+    const usesWeight = !descriptor || descriptor.uses_weight;
+    const category = hasSets ? (dom.exerciseCategory.value || null) : null;
 
     // Gather targets
-    const targetSets = type !== 'cardio' && dom.exerciseTargetSets.value ? parseInt(dom.exerciseTargetSets.value) : null;
-    const targetReps = type !== 'cardio' && dom.exerciseTargetReps.value ? parseInt(dom.exerciseTargetReps.value) : null;
-    const targetWeight = (type !== 'cardio' && type !== 'bodyweight') && dom.exerciseTargetWeight.value ? parseFloat(dom.exerciseTargetWeight.value) : null;
+    const targetSets = hasSets && dom.exerciseTargetSets.value ? parseInt(dom.exerciseTargetSets.value) : null;
+    const targetReps = hasSets && dom.exerciseTargetReps.value ? parseInt(dom.exerciseTargetReps.value) : null;
+    const targetWeight = hasSets && usesWeight && dom.exerciseTargetWeight.value ? parseFloat(dom.exerciseTargetWeight.value) : null;
 
     if (!name || !type) {
         alert('Please fill in all required fields');
@@ -383,7 +425,7 @@ async function createExercise(name, type, category, targetSets, targetReps, targ
     if (targetReps !== null) body.target_reps = targetReps;
     if (targetWeight !== null) body.target_weight = targetWeight;
 
-    const response = await fetch('/api/exercises', {
+    const response = await fetch('/api/v1/exercises', {
         method: 'POST',
         headers: {
             'Content-Type': 'application/json'
@@ -392,8 +434,7 @@ async function createExercise(name, type, category, targetSets, targetReps, targ
     });
 
     if (!response.ok) {
-        const error = await response.text();
-        throw new Error(error);
+        throw await apiError(response);
     }
 
     return await response.json();
@@ -406,7 +447,7 @@ async function updateExercise(id, name, type, category, targetSets, targetReps,
     if (targetReps !== null) body.target_reps = targetReps;
     if (targetWeight !== null) body.target_weight = targetWeight;
 
-    const response = await fetch(`/api/exercises/${id}`, {
+    const response = await fetch(`/api/v1/exercises/${id}`, {
         method: 'PUT',
         headers: {
             'Content-Type': 'application/json'
@@ -415,8 +456,7 @@ async function updateExercise(id, name, type, category, targetSets, targetReps,
     });
 
     if (!response.ok) {
-        const error = await response.text();
-        throw new Error(error);
+        throw await apiError(response);
     }
 
     return await response.json();
@@ -430,13 +470,12 @@ async function handleDelete() {
     dom.deleteConfirmBtn.textContent = 'Deleting...';
 
     try {
-        const response = await fetch(`/api/exercises/${state.deletingExerciseId}`, {
+        const response = await fetch(`/api/v1/exercises/${state.deletingExerciseId}`, {
             method: 'DELETE'
         });
 
         if (!response.ok) {
-            const error = await response.text();
-            throw new Error(error);
+            throw await apiError(response);
         }
 
         closeDeleteModal();
@@ -457,6 +496,12 @@ function escapeHtml(text) {
     return div.innerHTML;
 }
 
+// Format a pace in seconds per km as M:SS
+function formatPace(seconds) {
+    const total = Math.round(seconds);
+    return `${Math.floor(total / 60)}:${(total % 60).toString().padStart(2, '0')}`;
+}
+
 // ============================================
 // History Modal Functions
 // ============================================
@@ -470,8 +515,8 @@ async function openHistoryModal(exerciseId, exerciseName) {
     // Fetch history and PR data
     try {
         const [historyRes, prRes] = await Promise.all([
-            fetch(`/api/history/${exerciseId}`),
-            fetch(`/api/history/${exerciseId}/pr`)
+            fetch(`/api/v1/history/${exerciseId}`),
+            fetch(`/api/v1/history/${exerciseId}/pr`)
         ]);
 
         const historyData = await historyRes.json();
@@ -543,6 +588,7 @@ function renderHistoryModal() {
                                             ${exercise && ['weight', 'assisted', 'carry'].includes(exercise.type) ? '<th>Weight</th>' : ''}
                                             ${exercise && exercise.type !== 'cardio' ? `<th>${exercise.type === 'timed_hold' ? 'Sets (s)' : exercise.type === 'carry' ? 'Sets (laps)' : 'Sets × Reps'}</th>` : ''}
                                             ${exercise && ['weight', 'assisted', 'carry', 'timed_hold'].includes(exercise.type) ? '<th>Volume</th>' : ''}
+                                            ${exercise && exercise.type === 'cardio' ? '<th>Distance</th><th>Pace</th>' : ''}
                                             <th>Status</th>
                                         </tr>
                                     </thead>
@@ -554,6 +600,7 @@ function renderHistoryModal() {
                                                 ${exercise && exercise.type !== 'cardio' ? `<td>${session.sets_completed.join(', ')} ${session.is_pr && exercise.type === 'timed_hold' ? '🏆' : ''}</td>` : ''}
                                                 ${exercise && ['weight', 'assisted', 'carry'].includes(exercise.type) ? `<td>${session.volume} kg</td>` : ''}
                                                 ${exercise && exercise.type === 'timed_hold' ? `<td>${session.volume}s</td>` : ''}
+                                                ${exercise && exercise.type === 'cardio' ? `<td>${session.distance_km != null ? `${session.distance_km} km` : '-'} ${session.distance_pr ? '🏆' : ''}</td><td>${session.pace_seconds_per_km != null ? `${formatPace(session.pace_seconds_per_km)} /km` : '-'} ${session.pace_pr ? '🏆' : ''}</td>` : ''}
                                                 <td><span class="status-badge ${session.completed ? 'complete' : 'incomplete'}">${session.completed ? '✓' : '✗'}</span></td>
                                             </tr>
                                         `).join('')}
@@ -764,3 +811,16 @@ function renderWeightGraph(history, pr, exerciseType = 'weight') {
 
 // Start the app
 init();
+
+// Build an Error from an API error response, using the message from the
+// {"error": {...}} envelope when there is one
+async function apiError(response) {
+    const text = await response.text();
+    try {
+        const body = JSON.parse(text);
+        if (body.error && body.error.message) return new Error(body.error.message);
+    } catch (_) {
+        // Not JSON; fall through to the raw body
+    }
+    return new Error(text || `HTTP ${response.status}`);
+}
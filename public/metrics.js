@@ -2,6 +2,7 @@
 const state = {
     metricTypes: [],
     entries: {},
+    trends: {},
     visibleMetrics: new Set(),
     timeRange: 30,
     modal: {
@@ -9,6 +10,7 @@ const state = {
         type: null,
         data: null
     },
+    entryClientId: null,
     editingMetricTypeId: null,
     deletingMetricTypeId: null
 };
@@ -47,6 +49,7 @@ const dom = {
     manageMetricsClose: document.getElementById('manage-metrics-close'),
     metricsList: document.getElementById('metrics-list'),
     addMetricTypeBtn: document.getElementById('add-metric-type-btn'),
+    profileHeight: document.getElementById('profile-height'),
 
     // Metric type modal
     metricTypeModal: document.getElementById('metric-type-modal'),
@@ -55,6 +58,7 @@ const dom = {
     metricName: document.getElementById('metric-name'),
     metricUnit: document.getElementById('metric-unit'),
     metricColor: document.getElementById('metric-color'),
+    metricFormula: document.getElementById('metric-formula'),
     colorPreview: document.getElementById('color-preview'),
     metricTypeModalClose: document.getElementById('metric-type-modal-close'),
     metricTypeCancelBtn: document.getElementById('metric-type-cancel-btn'),
@@ -77,6 +81,7 @@ async function init() {
         setupEventListeners();
         renderMetricsGrid();
         renderMetricsGraphs();
+        subscribeToLiveUpdates();
         dom.loading.style.display = 'none';
     } catch (err) {
         console.error('Failed to initialize:', err);
@@ -84,6 +89,29 @@ async function init() {
     }
 }
 
+// Redraw the dashboard when another device records or edits a measurement,
+// or changes the height computed metrics use
+function subscribeToLiveUpdates() {
+    if (!window.EventSource) return;
+    let timer = null;
+    const source = new EventSource('/api/v1/events');
+    source.onmessage = (msg) => {
+        const event = JSON.parse(msg.data);
+        if (!event.type.startsWith('metric_') && !event.type.startsWith('sync.') && event.type !== 'settings.updated') return;
+        clearTimeout(timer);
+        timer = setTimeout(async () => {
+            try {
+                await loadMetricTypes();
+                await loadDashboardData(state.timeRange);
+                renderMetricsGrid();
+                renderMetricsGraphs();
+            } catch (err) {
+                console.error('Live refresh failed:', err);
+            }
+        }, 300);
+    };
+}
+
 // Setup event listeners
 function setupEventListeners() {
     // Time range selector
@@ -102,6 +130,7 @@ function setupEventListeners() {
 
     // Manage metrics button
     dom.manageMetricsBtn.addEventListener('click', openManageMetricsModal);
+    dom.profileHeight.addEventListener('change', handleHeightChange);
 
     // Entry modal
     dom.entryModalClose.addEventListener('click', closeEntryModal);
@@ -151,21 +180,23 @@ function setupEventListeners() {
 // API calls
 
 async function loadMetricTypes() {
-    const response = await fetch('/api/metrics');
+    const response = await fetch('/api/v1/metrics');
     if (!response.ok) throw new Error('Failed to load metric types');
     const data = await response.json();
     state.metricTypes = data.metric_types || [];
 }
 
 async function loadDashboardData(days) {
-    const response = await fetch(`/api/metrics/dashboard?days=${days}`);
+    const response = await fetch(`/api/v1/metrics/dashboard?days=${days}&trend=true`);
     if (!response.ok) throw new Error('Failed to load dashboard data');
     const data = await response.json();
 
-    // Convert to entries map
+    // Convert to entries and trends maps
     state.entries = {};
+    state.trends = {};
     (data.metrics || []).forEach(metric => {
         state.entries[metric.id] = metric.entries || [];
+        state.trends[metric.id] = metric.trend || null;
     });
 }
 
@@ -224,7 +255,8 @@ function renderMetricsGraphs() {
     const metricsWithData = state.metricTypes
         .map(m => ({
             ...m,
-            entries: (state.entries[m.id] || []).slice().reverse() // Oldest to newest
+            entries: (state.entries[m.id] || []).slice().reverse(), // Oldest to newest
+            trend: state.trends[m.id]
         }))
         .filter(m => m.entries.length > 0);
 
@@ -239,9 +271,13 @@ function renderMetricsGraphs() {
 
     // Render individual graph for each metric
     dom.graphsContainer.innerHTML = metricsWithData.map(metric => {
+        const slope = metric.trend && metric.trend.slope_per_week;
+        const slopeDisplay = slope != null
+            ? `<span class="metric-graph-slope">${slope > 0 ? '+' : ''}${slope} ${metric.unit}/week</span>`
+            : '';
         return `
             <div class="metric-graph-card">
-                <h3 class="metric-graph-title" style="color: ${metric.color}">${metric.name}</h3>
+                <h3 class="metric-graph-title" style="color: ${metric.color}">${metric.name}${slopeDisplay}</h3>
                 <canvas class="metric-graph-canvas" data-metric-id="${metric.id}" width="400" height="250"></canvas>
             </div>
         `;
@@ -336,6 +372,26 @@ function renderSingleGraph(canvas, metric) {
     });
     ctx.stroke();
 
+    // Overlay the smoothed trend (EWMA), which has one value per date
+    if (metric.trend && metric.trend.ewma.length > 1) {
+        const ewmaByDate = new Map(metric.trend.ewma.map(p => [p.date, p.value]));
+        ctx.globalAlpha = 0.6;
+        ctx.setLineDash([6, 4]);
+        ctx.beginPath();
+        entries.forEach((entry, idx) => {
+            const value = ewmaByDate.get(entry.date.slice(0, 10));
+            if (value === undefined) return;
+            if (idx === 0) {
+                ctx.moveTo(getX(idx), getY(value));
+            } else {
+                ctx.lineTo(getX(idx), getY(value));
+            }
+        });
+        ctx.stroke();
+        ctx.setLineDash([]);
+        ctx.globalAlpha = 1;
+    }
+
     // Draw points
     ctx.fillStyle = metric.color;
     entries.forEach((entry, idx) => {
@@ -369,15 +425,18 @@ function openAddEntryModal() {
     dom.entryModalTitle.textContent = 'Add Measurement';
     dom.entryMetricType.disabled = false;
 
-    // Populate metric type dropdown
+    // Populate metric type dropdown; computed metrics can't be entered
     dom.entryMetricType.innerHTML = '<option value="">Select metric...</option>' +
-        state.metricTypes.map(mt => `<option value="${mt.id}">${mt.name}</option>`).join('');
+        state.metricTypes.filter(mt => !mt.formula).map(mt => `<option value="${mt.id}">${mt.name}</option>`).join('');
 
     // Set today's date
     dom.entryDate.value = getTodayDateString();
     dom.entryValue.value = '';
     dom.entryNotes.value = '';
     dom.entryUnitHint.textContent = '';
+    // One id per measurement: submitting again after a failed request
+    // updates the entry if the first attempt did reach the server
+    state.entryClientId = newClientId();
 
     dom.entryModal.style.display = 'flex';
 }
@@ -397,10 +456,11 @@ async function handleEntrySubmit(e) {
     const notes = dom.entryNotes.value.trim() || null;
 
     try {
-        const response = await fetch('/api/metric-entries', {
+        const response = await fetch('/api/v1/metric-entries', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify({
+                client_id: state.entryClientId,
                 metric_type_id: metricTypeId,
                 entry_date: entryDate,
                 value: value,
@@ -408,7 +468,10 @@ async function handleEntrySubmit(e) {
             })
         });
 
-        if (!response.ok) throw new Error('Failed to create entry');
+        if (!response.ok) throw await apiError(response);
+        // Saved, but changed faster than the metric's max daily change
+        const { warnings } = await response.json();
+        if (warnings?.length) alert(`Saved, but check this measurement:\n${warnings.join('\n')}`);
 
         closeEntryModal();
         await loadMetricTypes();
@@ -417,13 +480,44 @@ async function handleEntrySubmit(e) {
         renderMetricsGraphs();
     } catch (err) {
         console.error('Failed to create entry:', err);
-        alert('Failed to save measurement. Please try again.');
+        alert(`Failed to save measurement: ${err.message}`);
     }
 }
 
-function openManageMetricsModal() {
+async function openManageMetricsModal() {
     renderMetricsList();
     dom.manageMetricsModal.style.display = 'flex';
+
+    try {
+        const response = await fetch('/api/v1/settings');
+        if (!response.ok) throw await apiError(response);
+        const settings = await response.json();
+        dom.profileHeight.value = settings.height_cm ?? '';
+    } catch (err) {
+        console.error('Failed to load settings:', err);
+    }
+}
+
+// Save the height and recompute the metrics that use it
+async function handleHeightChange() {
+    const height = parseFloat(dom.profileHeight.value);
+
+    try {
+        const response = await fetch('/api/v1/settings', {
+            method: 'PUT',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ height_cm: isNaN(height) ? 0 : height })
+        });
+        if (!response.ok) throw await apiError(response);
+
+        await loadMetricTypes();
+        await loadDashboardData(state.timeRange);
+        renderMetricsGrid();
+        renderMetricsGraphs();
+    } catch (err) {
+        console.error('Failed to save height:', err);
+        alert(`Failed to save height: ${err.message}`);
+    }
 }
 
 function closeManageMetricsModal() {
@@ -443,6 +537,7 @@ function renderMetricsList() {
                 <span class="metric-manage-name">${mt.name}</span>
                 <span class="metric-manage-unit">(${mt.unit})</span>
                 ${mt.is_default ? '<span class="badge">Default</span>' : ''}
+                ${mt.formula ? `<span class="badge" title="${mt.formula}">Computed</span>` : ''}
             </div>
             <div class="metric-manage-actions">
                 ${!mt.is_default ? `<button class="btn-icon" onclick="deleteMetricType(${mt.id})" title="Delete">🗑️</button>` : ''}
@@ -462,6 +557,7 @@ function openAddMetricTypeModal() {
     dom.metricName.value = '';
     dom.metricUnit.value = '';
     dom.metricColor.value = availableColor;
+    dom.metricFormula.value = '';
     dom.colorPreview.style.backgroundColor = availableColor;
 
     dom.metricTypeModal.style.display = 'flex';
@@ -479,32 +575,34 @@ async function handleMetricTypeSubmit(e) {
     const name = dom.metricName.value.trim();
     const unit = dom.metricUnit.value.trim();
     const color = dom.metricColor.value;
+    const formula = dom.metricFormula.value.trim();
 
     try {
-        const response = await fetch('/api/metrics', {
+        const response = await fetch('/api/v1/metrics', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify({
                 name: name,
                 unit: unit,
                 color: color,
+                formula: formula,
                 order_index: state.metricTypes.length
             })
         });
 
         if (!response.ok) {
-            const error = await response.text();
-            throw new Error(error);
+            throw await apiError(response);
         }
 
         closeMetricTypeModal();
         await loadMetricTypes();
+        await loadDashboardData(state.timeRange);
         renderMetricsList();
         renderMetricsGrid();
         renderMetricsGraphs();
     } catch (err) {
         console.error('Failed to create metric type:', err);
-        alert('Failed to create metric. Please try again.');
+        alert(`Failed to create metric: ${err.message}`);
     }
 }
 
@@ -526,7 +624,7 @@ async function handleDelete() {
     if (!state.deletingMetricTypeId) return;
 
     try {
-        const response = await fetch(`/api/metrics/${state.deletingMetricTypeId}`, {
+        const response = await fetch(`/api/v1/metrics/${state.deletingMetricTypeId}`, {
             method: 'DELETE'
         });
 
@@ -547,6 +645,12 @@ async function handleDelete() {
 
 // Utility functions
 
+function newClientId() {
+    if (crypto.randomUUID) return crypto.randomUUID();
+    const bytes = crypto.getRandomValues(new Uint8Array(16));
+    return Array.from(bytes, b => b.toString(16).padStart(2, '0')).join('');
+}
+
 function getTodayDateString() {
     const today = new Date();
     const year = today.getFullYear();
@@ -557,3 +661,16 @@ function getTodayDateString() {
 
 // Initialize on load
 document.addEventListener('DOMContentLoaded', init);
+
+// Build an Error from an API error response, using the message from the
+// {"error": {...}} envelope when there is one
+async function apiError(response) {
+    const text = await response.text();
+    try {
+        const body = JSON.parse(text);
+        if (body.error && body.error.message) return new Error(body.error.message);
+    } catch (_) {
+        // Not JSON; fall through to the raw body
+    }
+    return new Error(text || `HTTP ${response.status}`);
+}
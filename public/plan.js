@@ -12,8 +12,8 @@ function showStatus(msg, type) {
 
 async function loadPlan() {
     try {
-        const res = await fetch('/api/plan');
-        if (!res.ok) throw new Error(await res.text());
+        const res = await fetch('/api/v1/plan');
+        if (!res.ok) throw await apiError(res);
         textarea.value = await res.text();
     } catch (err) {
         showStatus('Failed to load plan: ' + err.message, 'error');
@@ -35,12 +35,12 @@ async function applyPlan() {
     showStatus('', '');
 
     try {
-        const res = await fetch('/api/plan', {
+        const res = await fetch('/api/v1/plan', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify({ plan }),
         });
-        if (!res.ok) throw new Error(await res.text());
+        if (!res.ok) throw await apiError(res);
         const data = await res.json();
         showStatus(`Applied — ${data.days_updated} day(s) updated.`, 'success');
     } catch (err) {
@@ -67,3 +67,16 @@ document.getElementById('apply-btn').addEventListener('click', applyPlan);
 document.getElementById('copy-btn').addEventListener('click', copyPlan);
 
 loadPlan();
+
+// Build an Error from an API error response, using the message from the
+// {"error": {...}} envelope when there is one
+async function apiError(response) {
+    const text = await response.text();
+    try {
+        const body = JSON.parse(text);
+        if (body.error && body.error.message) return new Error(body.error.message);
+    } catch (_) {
+        // Not JSON; fall through to the raw body
+    }
+    return new Error(text || `HTTP ${response.status}`);
+}
@@ -1,4 +1,4 @@
-const CACHE_NAME = 'workout-planner-v19';
+const CACHE_NAME = 'workout-planner-v30';
 const ASSETS = [
     '/',
     '/index.html',
@@ -42,6 +42,8 @@ self.addEventListener('fetch', (event) => {
     // Only handle GET requests for http/https URLs
     if (event.request.method !== 'GET') return;
     if (!event.request.url.startsWith('http://') && !event.request.url.startsWith('https://')) return;
+    // The live update stream never ends, so it can't be cached
+    if (new URL(event.request.url).pathname.endsWith('/events')) return;
 
     // Network first for everything - always get fresh content
     // Only fall back to cache if offline